@@ -83,6 +83,6 @@ func cleanupCmdWorker(techniques <-chan *stratus.AttackTechnique, errors chan<-
 
 func doCleanupAllCmd() {
 	log.Println("Cleaning up all techniques that have been warmed-up or detonated")
-	availableTechniques := stratus.GetRegistry().ListAttackTechniques()
+	availableTechniques := listAllTechniques()
 	doCleanupCmd(availableTechniques)
 }
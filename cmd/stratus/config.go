@@ -0,0 +1,352 @@
+package main
+
+import (
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/config"
+	"github.com/datadog/stratus-red-team/internal/declarative"
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/internal/metrics"
+	"github.com/datadog/stratus-red-team/internal/notifier"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/redaction"
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/internal/tracing"
+	"github.com/datadog/stratus-red-team/internal/utils"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/plugin"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var flagShowSecrets bool
+var flagTechniquesDirectory string
+var flagPluginsDirectory string
+var flagPluginTimeout time.Duration
+
+// Tracing flags
+var flagOtlpEndpoint string
+var flagTraceFile string
+
+// Metrics flags
+var flagMetricsAddress string
+var flagMetricsTextfile string
+
+// Retry policy flags
+var flagMaxAttempts int
+var flagRetryMode string
+var flagMaxBackoff time.Duration
+var flagRateLimit float64
+
+// AWS flags
+var flagAwsProfile string
+var flagAwsRegion string
+var flagAwsAssumeRoleArn string
+var flagAwsAssumeRoleExternalId string
+var flagAwsAssumeRoleSessionName string
+
+// Kubernetes flags
+var flagKubeContext string
+var flagKubeNamespace string
+var flagKubeImpersonate string
+var flagKubeImpersonateGroups []string
+
+// Azure flags
+var flagAzureSubscription string
+var flagAzureTenant string
+var flagAzureCloud string
+var flagAzureCredential string
+
+// registerGlobalFlags registers the flags available for all commands
+func registerGlobalFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.BoolVarP(&flagShowSecrets, "show-secrets", "", false, "Do not redact secrets (passwords, access keys, tokens...) from the output. Use for debugging only")
+	flags.StringVarP(&flagTechniquesDirectory, "techniques-dir", "", "", "Directory containing declarative attack techniques (YAML or JSON) to load, instead of $HOME/.stratus-red-team/techniques")
+	flags.StringVarP(&flagPluginsDirectory, "plugins-dir", "", "", "Directory containing attack technique plugin executables to load, instead of $HOME/.stratus-red-team/plugins")
+	flags.DurationVarP(&flagPluginTimeout, "plugin-timeout", "", plugin.DefaultTimeout, "Maximum time a plugin can take to detonate or revert an attack technique")
+
+	flags.StringVarP(&flagOtlpEndpoint, "otlp-endpoint", "", "", "OTLP/HTTP endpoint to export OpenTelemetry traces to, e.g. localhost:4318 or https://otel-collector:4318")
+	flags.StringVarP(&flagTraceFile, "trace-file", "", "", "File to write OpenTelemetry traces to, as JSON")
+	flags.StringVarP(&flagMetricsAddress, "metrics-address", "", "", "Address to expose Prometheus metrics on while running, e.g. :9090")
+	flags.StringVarP(&flagMetricsTextfile, "metrics-textfile", "", "", "File to write Prometheus metrics to, for the node-exporter textfile collector")
+
+	defaultRetryPolicy := providers.DefaultRetryPolicy()
+	flags.IntVarP(&flagMaxAttempts, "max-attempts", "", defaultRetryPolicy.MaxAttempts, "Maximum number of attempts for each cloud API call, including the initial one")
+	flags.StringVarP(&flagRetryMode, "retry-mode", "", defaultRetryPolicy.Mode, "Retry mode, 'standard' or 'adaptive' (slows down API calls when throttled, AWS only)")
+	flags.DurationVarP(&flagMaxBackoff, "max-backoff", "", defaultRetryPolicy.MaxBackoff, "Maximum delay between two attempts of a cloud API call")
+	flags.Float64VarP(&flagRateLimit, "rate-limit", "", defaultRetryPolicy.RateLimit, "Maximum number of cloud API calls per second and per platform (0 for unlimited)")
+
+	flags.StringVarP(&flagAwsProfile, "aws-profile", "", "", "AWS profile to use, instead of the one resolved from the environment")
+	flags.StringVarP(&flagAwsRegion, "aws-region", "", "", "AWS region to use, instead of the one resolved from the environment")
+	flags.StringVarP(&flagAwsAssumeRoleArn, "aws-assume-role-arn", "", "", "ARN of an IAM role to assume before running AWS attack techniques")
+	flags.StringVarP(&flagAwsAssumeRoleExternalId, "aws-assume-role-external-id", "", "", "External ID to use when assuming the IAM role")
+	flags.StringVarP(&flagAwsAssumeRoleSessionName, "aws-assume-role-session-name", "", providers.DefaultAssumeRoleSessionName, "Session name to use when assuming the IAM role")
+
+	flags.StringVarP(&flagKubeContext, "kube-context", "", "", "Kubeconfig context to use, instead of the current one")
+	flags.StringVarP(&flagKubeNamespace, "kube-namespace", "", "", "Existing Kubernetes namespace in which attack techniques create objects, instead of a dedicated one")
+	flags.StringVarP(&flagKubeImpersonate, "as", "", "", "Kubernetes user to impersonate")
+	flags.StringArrayVarP(&flagKubeImpersonateGroups, "as-group", "", []string{}, "Kubernetes group to impersonate, can be repeated. Requires --as")
+
+	flags.StringVarP(&flagAzureSubscription, "azure-subscription", "", "", "Azure subscription ID to use, instead of the AZURE_SUBSCRIPTION_ID environment variable")
+	flags.StringVarP(&flagAzureTenant, "azure-tenant", "", "", "Azure tenant ID to authenticate against")
+	flags.StringVarP(&flagAzureCloud, "azure-cloud", "", providers.AzureCloudPublic, "Azure cloud to use: "+providers.AzureCloudPublic+", "+providers.AzureCloudUSGovernment+" or "+providers.AzureCloudChina)
+	flags.StringVarP(&flagAzureCredential, "azure-credential", "", providers.AzureCredentialDefault, "Azure credentials to use: "+providers.AzureCredentialDefault+" (environment, then managed identity, then Azure CLI), "+
+		providers.AzureCredentialCLI+", "+providers.AzureCredentialManagedIdentity+" or "+providers.AzureCredentialServicePrincipal)
+}
+
+// applyConfiguration loads the configuration file and applies it, along with the global flags that take precedence
+func applyConfiguration(cmd *cobra.Command) {
+	redaction.SetEnabled(!flagShowSecrets)
+
+	stratusConfig, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := setupTracing(stratusConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := applyRetryPolicy(cmd, &stratusConfig.Retry); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := loadDeclarativeTechniques(stratusConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := configurePlugins(cmd, stratusConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := registerNotifiers(stratusConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := setupMetrics(stratusConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := applyAwsOptions(); err != nil {
+		log.Fatal(err)
+	}
+
+	k8sOptions := providers.K8sOptions{
+		Context:           flagKubeContext,
+		Namespace:         flagKubeNamespace,
+		Impersonate:       flagKubeImpersonate,
+		ImpersonateGroups: flagKubeImpersonateGroups,
+	}
+	if err := k8sOptions.Validate(); err != nil {
+		log.Fatal(err.Error() + ", use --as with --as-group")
+	}
+	providers.K8s().SetOptions(k8sOptions)
+
+	err = providers.Azure().SetOptions(providers.AzureOptions{
+		SubscriptionID: flagAzureSubscription,
+		TenantID:       flagAzureTenant,
+		Cloud:          flagAzureCloud,
+		Credential:     flagAzureCredential,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadDeclarativeTechniques registers the attack techniques of the techniques directory. The default directory
+// is optional, while a directory set explicitly must exist
+func loadDeclarativeTechniques(stratusConfig *config.Config) error {
+	directory := stratusConfig.GetTechniquesDirectory()
+	if flagTechniquesDirectory != "" {
+		directory = flagTechniquesDirectory
+	}
+	if !utils.FileExists(directory) {
+		if flagTechniquesDirectory != "" || stratusConfig.TechniquesDirectory != "" {
+			return errors.New("techniques directory " + directory + " does not exist")
+		}
+		return nil
+	}
+
+	_, err := declarative.LoadDirectory(directory, stratus.GetRegistry())
+	return err
+}
+
+// Plugins directory and timeout. Plugins are only started the first time their techniques may be needed
+var (
+	pluginsDirectory string
+	pluginsTimeout   time.Duration
+	pluginsLoaded    sync.Once
+)
+
+// configurePlugins sets the plugins directory and timeout. The default directory is optional, while a directory set
+// explicitly must exist
+func configurePlugins(cmd *cobra.Command, stratusConfig *config.Config) error {
+	directory := stratusConfig.GetPluginsDirectory()
+	if flagPluginsDirectory != "" {
+		directory = flagPluginsDirectory
+	}
+	if !utils.FileExists(directory) {
+		if flagPluginsDirectory != "" || stratusConfig.Plugins.Directory != "" {
+			return errors.New("plugins directory " + directory + " does not exist")
+		}
+		return nil
+	}
+
+	timeout, err := stratusConfig.Plugins.GetTimeout()
+	if err != nil {
+		return err
+	}
+	if timeout == 0 || cmd.PersistentFlags().Changed("plugin-timeout") {
+		timeout = flagPluginTimeout
+	}
+
+	pluginsDirectory = directory
+	pluginsTimeout = timeout
+	return nil
+}
+
+// loadPlugins registers the attack techniques of the plugins directory, the first time it is called
+func loadPlugins() {
+	pluginsLoaded.Do(func() {
+		if pluginsDirectory == "" {
+			return
+		}
+		if _, err := plugin.LoadDirectory(pluginsDirectory, pluginsTimeout, stratus.GetRegistry()); err != nil {
+			log.Println("Warning: unable to load plugins: " + err.Error())
+		}
+	})
+}
+
+// setupTracing sets up the export of OpenTelemetry traces, if an OTLP endpoint or a trace file is set
+func setupTracing(stratusConfig *config.Config) error {
+	options := tracing.Options{Endpoint: stratusConfig.Tracing.OTLPEndpoint, File: stratusConfig.Tracing.File}
+	if flagOtlpEndpoint != "" {
+		options.Endpoint = flagOtlpEndpoint
+	}
+	if flagTraceFile != "" {
+		options.File = flagTraceFile
+	}
+	return tracing.Setup(options)
+}
+
+// registerNotifiers notifies the external systems of the configuration of all operations run on attack techniques
+func registerNotifiers(stratusConfig *config.Config) error {
+	notifiers, err := notifier.FromConfig(stratusConfig.Notifiers)
+	if err != nil {
+		return err
+	}
+	if len(notifiers) > 0 {
+		history.RegisterRecorder(notifier.NewDispatcher(stratus.GetRegistry(), notifiers...))
+	}
+	return nil
+}
+
+// Prometheus metrics, if enabled
+var stratusMetrics *metrics.Metrics
+
+// setupMetrics records the operations run on attack techniques as Prometheus metrics, exposed on an HTTP endpoint
+// and/or written to a node-exporter textfile
+func setupMetrics(stratusConfig *config.Config) error {
+	address := stratusConfig.Metrics.ListenAddress
+	if flagMetricsAddress != "" {
+		address = flagMetricsAddress
+	}
+	textfile := stratusConfig.Metrics.Textfile
+	if flagMetricsTextfile != "" {
+		textfile = flagMetricsTextfile
+	}
+	if address == "" && textfile == "" {
+		return nil
+	}
+
+	stratusMetrics = metrics.New(stratus.GetRegistry(), func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState {
+		return state.NewFileSystemStateManager(technique).GetTechniqueState()
+	})
+	stratusMetrics.Textfile = textfile
+	if textfile != "" {
+		// Each run writes the textfile from scratch, so counters are seeded from the history of previous runs to
+		// remain monotonic
+		homeDirectory, _ := os.UserHomeDir()
+		historyStore := history.NewFileStore(filepath.Join(homeDirectory, state.StratusStateDirectoryName, history.FileName))
+		entries, err := historyStore.Read(time.Time{})
+		if err != nil {
+			return err
+		}
+		stratusMetrics.Seed(entries)
+	}
+	history.RegisterRecorder(stratusMetrics)
+
+	if address != "" {
+		server, err := stratusMetrics.Serve(address)
+		if err != nil {
+			return err
+		}
+		log.Println("Exposing Prometheus metrics on http://" + server.Addr + "/metrics")
+	}
+	return nil
+}
+
+// writeMetricsTextfile writes the metrics textfile once the command has completed, so that it reflects the final state
+// of attack techniques even if no operation was run
+func writeMetricsTextfile() error {
+	if stratusMetrics == nil || stratusMetrics.Textfile == "" {
+		return nil
+	}
+	return stratusMetrics.WriteTextfile(stratusMetrics.Textfile)
+}
+
+func applyAwsOptions() error {
+	if flagAwsAssumeRoleArn == "" && (flagAwsAssumeRoleExternalId != "" || flagAwsAssumeRoleSessionName != providers.DefaultAssumeRoleSessionName) {
+		return errors.New("--aws-assume-role-external-id and --aws-assume-role-session-name require --aws-assume-role-arn")
+	}
+	providers.AWS().SetOptions(providers.AWSOptions{
+		Profile:               flagAwsProfile,
+		Region:                flagAwsRegion,
+		AssumeRoleArn:         flagAwsAssumeRoleArn,
+		AssumeRoleExternalId:  flagAwsAssumeRoleExternalId,
+		AssumeRoleSessionName: flagAwsAssumeRoleSessionName,
+	})
+	return nil
+}
+
+func applyRetryPolicy(cmd *cobra.Command, retryConfig *config.RetryConfig) error {
+	policy := providers.DefaultRetryPolicy()
+	if retryConfig.MaxAttempts > 0 {
+		policy.MaxAttempts = retryConfig.MaxAttempts
+	}
+	if retryConfig.Mode != "" {
+		policy.Mode = retryConfig.Mode
+	}
+	maxBackoff, err := retryConfig.GetMaxBackoff()
+	if err != nil {
+		return err
+	}
+	if maxBackoff > 0 {
+		policy.MaxBackoff = maxBackoff
+	}
+	if retryConfig.RateLimit > 0 {
+		policy.RateLimit = retryConfig.RateLimit
+	}
+	if retryConfig.RateLimitBurst > 0 {
+		policy.RateLimitBurst = retryConfig.RateLimitBurst
+	}
+
+	flags := cmd.PersistentFlags()
+	if flags.Changed("max-attempts") {
+		policy.MaxAttempts = flagMaxAttempts
+	}
+	if flags.Changed("retry-mode") {
+		policy.Mode = flagRetryMode
+	}
+	if flags.Changed("max-backoff") {
+		policy.MaxBackoff = flagMaxBackoff
+	}
+	if flags.Changed("rate-limit") {
+		policy.RateLimit = flagRateLimit
+	}
+
+	return providers.SetRetryPolicy(policy)
+}
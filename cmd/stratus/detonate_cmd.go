@@ -2,7 +2,10 @@ package main
 
 import (
 	"errors"
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/internal/junit"
 	"github.com/datadog/stratus-red-team/internal/utils"
+	"log"
 	"os"
 	"strings"
 
@@ -13,6 +16,8 @@ import (
 
 var detonateForce bool
 var detonateCleanup bool
+var detonateJUnitReport string
+var detonateReapplyOutdated bool
 
 func buildDetonateCmd() *cobra.Command {
 	detonateCmd := &cobra.Command{
@@ -21,6 +26,7 @@ func buildDetonateCmd() *cobra.Command {
 		Example: strings.Join([]string{
 			"stratus detonate aws.defense-evasion.cloudtrail-stop",
 			"stratus detonate aws.defense-evasion.cloudtrail-stop --cleanup",
+			"stratus detonate aws.defense-evasion.cloudtrail-stop --cleanup --junit-report report.xml",
 		}, "\n"),
 		DisableFlagsInUseLine: true,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
@@ -48,6 +54,8 @@ func buildDetonateCmd() *cobra.Command {
 	detonateCmd.Flags().BoolVarP(&detonateCleanup, "cleanup", "", false, "Clean up the infrastructure that was spun up as part of the technique prerequisites")
 	//detonateCmd.Flags().BoolVarP(&detonateNoWarmup, "no-warmup", "", false, "Do not spin up prerequisite infrastructure or configuration. Requires that 'warmup' was used before.")
 	detonateCmd.Flags().BoolVarP(&detonateForce, "force", "f", false, "Force detonation in cases where the technique is not idempotent and has already been detonated")
+	detonateCmd.Flags().BoolVarP(&detonateReapplyOutdated, "reapply-outdated", "", false, "Re-apply prerequisites spun up by a previous version of the technique, e.g. after upgrading Stratus Red Team")
+	detonateCmd.Flags().StringVarP(&detonateJUnitReport, "junit-report", "", "", "Write a JUnit XML report to this file, with a test suite per technique and a test case per phase")
 
 	return detonateCmd
 }
@@ -57,9 +65,14 @@ func doDetonateCmd(techniques []*stratus.AttackTechnique, cleanup bool) {
 	techniquesChan := make(chan *stratus.AttackTechnique, workerCount)
 	errorsChan := make(chan error, workerCount)
 
+	var junitCollector *junit.Collector
+	if detonateJUnitReport != "" {
+		junitCollector = junit.NewCollector()
+	}
+
 	// Create workers
 	for i := 0; i < workerCount; i++ {
-		go detonateCmdWorker(techniquesChan, errorsChan)
+		go detonateCmdWorker(techniquesChan, errorsChan, junitCollector)
 	}
 
 	// Send attack techniques to detonate
@@ -68,14 +81,27 @@ func doDetonateCmd(techniques []*stratus.AttackTechnique, cleanup bool) {
 	}
 	close(techniquesChan)
 
-	if hadError := handleErrorsChannel(errorsChan, workerCount); hadError {
+	hadError := handleErrorsChannel(errorsChan, workerCount)
+	if junitCollector != nil {
+		if err := junitCollector.WriteFile(detonateJUnitReport); err != nil {
+			log.Println(err)
+			hadError = true
+		} else {
+			log.Println("Wrote JUnit report to " + detonateJUnitReport)
+		}
+	}
+	if hadError {
 		os.Exit(1)
 	}
 }
 
-func detonateCmdWorker(techniques <-chan *stratus.AttackTechnique, errors chan<- error) {
+func detonateCmdWorker(techniques <-chan *stratus.AttackTechnique, errors chan<- error, junitCollector *junit.Collector) {
 	for technique := range techniques {
 		stratusRunner := runner.NewRunner(technique, detonateForce)
+		stratusRunner.ShouldReapplyOutdated = detonateReapplyOutdated
+		if junitCollector != nil {
+			stratusRunner.History = history.Recorders{stratusRunner.History, junitCollector}
+		}
 		detonateErr := stratusRunner.Detonate()
 		if detonateCleanup {
 			cleanupErr := stratusRunner.CleanUp()
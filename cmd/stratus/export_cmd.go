@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/sigma"
+	"github.com/spf13/cobra"
+	"log"
+)
+
+var flagExportPlatform string
+var flagExportOutput string
+
+func buildExportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export detection content shipped with attack techniques.",
+	}
+	exportCmd.AddCommand(buildExportSigmaCmd())
+	return exportCmd
+}
+
+func buildExportSigmaCmd() *cobra.Command {
+	exportSigmaCmd := &cobra.Command{
+		Use:   "sigma [attack-technique-id]...",
+		Short: "Export the Sigma rules of attack techniques, one file per rule.",
+		Long: "Export the Sigma rules detecting attack techniques to a directory, one file per rule, to convert them " +
+			"to the query language of a SIEM with a Sigma backend. Exports the rules of all the attack techniques " +
+			"if none is specified.",
+		Example: "stratus export sigma -o rules/\n" +
+			"stratus export sigma --platform aws -o rules/\n" +
+			"stratus export sigma aws.defense-evasion.cloudtrail-stop -o rules/",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if flagExportOutput == "" {
+				return errors.New("you must specify the directory to export the rules to with --output")
+			}
+			if flagExportPlatform != "" {
+				if _, err := stratus.PlatformFromString(flagExportPlatform); err != nil {
+					return err
+				}
+			}
+			_, err := resolveTechniques(args)
+			return err
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return getTechniquesCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			techniques, _ := resolveTechniques(args)
+			if len(args) == 0 {
+				techniques = listAllTechniques()
+			}
+			if err := doExportSigmaCmd(techniques, flagExportPlatform, flagExportOutput); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	exportSigmaCmd.Flags().StringVarP(&flagExportPlatform, "platform", "", "", "Only export the rules of attack techniques of a specific platform")
+	exportSigmaCmd.Flags().StringVarP(&flagExportOutput, "output", "o", "", "Directory to write the rules to, created if needed")
+	return exportSigmaCmd
+}
+
+func doExportSigmaCmd(techniques []*stratus.AttackTechnique, platform string, directory string) error {
+	if platform != "" {
+		filterPlatform, _ := stratus.PlatformFromString(platform)
+		var filtered []*stratus.AttackTechnique
+		for _, technique := range techniques {
+			if technique.Platform == filterPlatform {
+				filtered = append(filtered, technique)
+			}
+		}
+		techniques = filtered
+	}
+
+	paths, err := sigma.Export(techniques, directory)
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d Sigma rules to %s", len(paths), directory)
+	return nil
+}
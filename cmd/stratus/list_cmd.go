@@ -47,6 +47,7 @@ func doListCmd(mitreAttackTactic string, platform string) {
 		}
 		filter.Tactic = tactic
 	}
+	loadPlugins()
 	techniques := stratus.GetRegistry().GetAttackTechniques(&filter)
 	t := GetDisplayTable()
 	t.AppendHeader(table.Row{"Technique ID", "Technique name", "Platform", "MITRE ATT&CK Tactic"})
@@ -64,8 +65,8 @@ func doListCmd(mitreAttackTactic string, platform string) {
 		})
 	}
 
-	fmt.Println()
-	fmt.Println(color.CyanString("View the list of all available attack techniques at: https://stratus-red-team.cloud/attack-techniques/list/\n"))
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, color.CyanString("View the list of all available attack techniques at: https://stratus-red-team.cloud/attack-techniques/list/\n"))
 	t.Render()
 }
 
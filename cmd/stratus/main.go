@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques"
+	"github.com/datadog/stratus-red-team/internal/redaction"
+	"github.com/datadog/stratus-red-team/internal/tracing"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
@@ -11,9 +14,22 @@ var rootCmd = &cobra.Command{
 	Use: "stratus",
 }
 
+// Standard output and error, with sensitive data redacted
+var stdout = redaction.NewWriter(os.Stdout)
+var stderr = redaction.NewWriter(os.Stderr)
+
 func init() {
 	setupLogging()
 
+	// Errors returned by commands are printed by cobra, and may contain secrets
+	rootCmd.SetOut(stdout)
+	rootCmd.SetErr(stderr)
+
+	registerGlobalFlags(rootCmd)
+	cobra.OnInitialize(func() {
+		applyConfiguration(rootCmd)
+	})
+
 	listCmd := buildListCmd()
 	showCmd := buildShowCmd()
 	warmupCmd := buildWarmupCmd()
@@ -22,6 +38,11 @@ func init() {
 	statusCmd := buildStatusCmd()
 	cleanupCmd := buildCleanupCmd()
 	versionCmd := buildVersionCmd()
+	validateCmd := buildValidateCmd()
+	reportCmd := buildReportCmd()
+	exportCmd := buildExportCmd()
+	simulateCmd := buildSimulateCmd()
+	traceCmd := buildTraceCmd()
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(showCmd)
@@ -31,12 +52,23 @@ func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(cleanupCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(simulateCmd)
+	rootCmd.AddCommand(traceCmd)
 }
 
 func setupLogging() {
-	log.SetOutput(os.Stdout)
+	log.SetOutput(stdout)
 }
 
 func main() {
 	rootCmd.Execute()
+	if err := tracing.Shutdown(context.Background()); err != nil {
+		log.Println("Warning: unable to export traces: " + err.Error())
+	}
+	if err := writeMetricsTextfile(); err != nil {
+		log.Println("Warning: " + err.Error())
+	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/internal/report"
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var flagReportSince string
+var flagReportOutput string
+
+func buildReportCmd() *cobra.Command {
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate a report of the attack techniques detonated recently.",
+		Long: "Generate a report of the attack techniques detonated recently, from the history of the operations run " +
+			"by Stratus Red Team: time window, target and identity used, execution ID, MITRE ATT&CK tactics, " +
+			"detection guidance and verification of each detonation, as well as techniques that have not been " +
+			"cleaned up.",
+		Example: "stratus report\n" +
+			"stratus report --since 7d -o report.html\n" +
+			"stratus report --since 2h -o report.md",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				return errors.New("unexpected arguments")
+			}
+			if _, err := parseSince(flagReportSince); err != nil {
+				return err
+			}
+			_, err := reportFormat(flagReportOutput)
+			return err
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			since, _ := parseSince(flagReportSince)
+			format, _ := reportFormat(flagReportOutput)
+			if err := doReportCmd(time.Now().Add(-since), format, flagReportOutput); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	reportCmd.Flags().StringVarP(&flagReportSince, "since", "", "24h", "Only report on detonations more recent than this duration, e.g. 2h30m or 7d")
+	reportCmd.Flags().StringVarP(&flagReportOutput, "output", "o", "", "File to write the report to, in HTML (.html) or Markdown (.md). Defaults to Markdown on the standard output")
+	return reportCmd
+}
+
+func doReportCmd(since time.Time, format report.Format, outputFile string) error {
+	homeDirectory, _ := os.UserHomeDir()
+	historyStore := history.NewFileStore(filepath.Join(homeDirectory, state.StratusStateDirectoryName, history.FileName))
+	entries, err := historyStore.Read(since)
+	if err != nil {
+		return err
+	}
+
+	loadPlugins()
+	stratusReport := report.Build(entries, stratus.GetRegistry(), since.UTC(), func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState {
+		return state.NewFileSystemStateManager(technique).GetTechniqueState()
+	})
+
+	var output io.Writer = stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return errors.New("unable to create report file: " + err.Error())
+		}
+		defer file.Close()
+		output = file
+	}
+	if err := stratusReport.Render(output, format); err != nil {
+		return errors.New("unable to render report: " + err.Error())
+	}
+	if outputFile != "" {
+		log.Printf("Wrote a report of %d detonations to %s", len(stratusReport.Detonations), outputFile)
+	}
+	return nil
+}
+
+// parseSince parses a duration such as 24h or 7d
+func parseSince(since string) (time.Duration, error) {
+	if days := strings.TrimSuffix(since, "d"); days != since {
+		count, err := strconv.Atoi(days)
+		if err != nil || count < 0 {
+			return 0, errors.New("invalid duration " + since)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	duration, err := time.ParseDuration(since)
+	if err != nil || duration < 0 {
+		return 0, errors.New("invalid duration " + since + ", use for instance 24h, 2h30m or 7d")
+	}
+	return duration, nil
+}
+
+// reportFormat returns the format of a report from the extension of the file it is written to
+func reportFormat(outputFile string) (report.Format, error) {
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case "":
+		if outputFile == "" {
+			return report.FormatMarkdown, nil
+		}
+	case ".md", ".markdown":
+		return report.FormatMarkdown, nil
+	case ".html", ".htm":
+		return report.FormatHTML, nil
+	}
+	return "", errors.New("unsupported report file " + outputFile + ", use a .html or .md file")
+}
@@ -4,13 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/sigma"
 	"github.com/spf13/cobra"
+	"log"
 )
 
+var flagShowSigma bool
+
 func buildShowCmd() *cobra.Command {
 	warmupCmd := &cobra.Command{
 		Use:   "show",
 		Short: "Displays detailed information about an attack technique.",
+		Example: "stratus show aws.defense-evasion.cloudtrail-stop\n" +
+			"stratus show aws.defense-evasion.cloudtrail-stop --sigma",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				return errors.New("you must specify at least one attack technique")
@@ -23,14 +29,36 @@ func buildShowCmd() *cobra.Command {
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			techniques, _ := resolveTechniques(args)
-			doShowCmd(techniques)
+			if flagShowSigma {
+				doShowSigmaCmd(techniques)
+			} else {
+				doShowCmd(techniques)
+			}
 		},
 	}
+	warmupCmd.Flags().BoolVarP(&flagShowSigma, "sigma", "", false, "Display the Sigma rules detecting the attack techniques, as a YAML stream")
 	return warmupCmd
 }
 
 func doShowCmd(techniques []*stratus.AttackTechnique) {
 	for i := range techniques {
-		fmt.Println(techniques[i].Description)
+		fmt.Fprintln(stdout, techniques[i].Description)
+	}
+}
+
+func doShowSigmaCmd(techniques []*stratus.AttackTechnique) {
+	var rules [][]byte
+	for i := range techniques {
+		documents := sigma.Documents(techniques[i].SigmaRules)
+		if len(documents) == 0 {
+			log.Println("Warning: " + techniques[i].ID + " has no Sigma rule")
+		}
+		rules = append(rules, documents...)
+	}
+	for i := range rules {
+		if i > 0 {
+			fmt.Fprintln(stdout, "---")
+		}
+		fmt.Fprint(stdout, string(rules[i]))
 	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+var flagSimulateAccountID string
+var flagSimulatePrincipal string
+var flagSimulateSourceIP string
+var flagSimulateRegion string
+var flagSimulateStartTime string
+var flagSimulateOutput string
+
+func buildSimulateCmd() *cobra.Command {
+	simulateCmd := &cobra.Command{
+		Use:   "simulate attack-technique-id [attack-technique-id]...",
+		Short: "Generate the logs an attack technique would produce, without calling any API.",
+		Long: "Generate the CloudTrail events, Kubernetes audit events or Azure Activity logs an attack technique " +
+			"would produce when detonated, as JSON lines. No credentials are needed and no API is called, which " +
+			"allows testing detection rules offline, e.g. in CI.",
+		Example: "stratus simulate aws.defense-evasion.cloudtrail-stop\n" +
+			"stratus simulate aws.defense-evasion.cloudtrail-stop --account-id 210987654321 --source-ip 198.51.100.7\n" +
+			"stratus simulate k8s.privilege-escalation.privileged-pod --start-time 2022-03-01T10:00:00Z -o events.jsonl",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("you must specify at least one attack technique")
+			}
+			techniques, err := resolveTechniques(args)
+			if err != nil {
+				return err
+			}
+			for _, technique := range techniques {
+				if technique.Simulate == nil {
+					return errors.New(technique.ID + " does not support simulation")
+				}
+			}
+			if flagSimulateSourceIP != "" && net.ParseIP(flagSimulateSourceIP) == nil {
+				return errors.New("invalid source IP " + flagSimulateSourceIP)
+			}
+			_, err = parseStartTime(flagSimulateStartTime)
+			return err
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return getTechniquesCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			techniques, _ := resolveTechniques(args)
+			startTime, _ := parseStartTime(flagSimulateStartTime)
+			if err := doSimulateCmd(techniques, startTime, flagSimulateOutput); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	simulateCmd.Flags().StringVarP(&flagSimulateAccountID, "account-id", "", "", "AWS account ID, Azure subscription ID or Kubernetes cluster name to simulate the attack technique in")
+	simulateCmd.Flags().StringVarP(&flagSimulatePrincipal, "principal", "", "", "Identity detonating the attack technique: ARN of an AWS IAM principal, Azure user principal name or Kubernetes username")
+	simulateCmd.Flags().StringVarP(&flagSimulateSourceIP, "source-ip", "", "", "IP address the simulated API calls are made from (default "+stratus.DefaultSimulatedSourceIP+")")
+	simulateCmd.Flags().StringVarP(&flagSimulateRegion, "region", "", "", "Cloud region to simulate the attack technique in")
+	simulateCmd.Flags().StringVarP(&flagSimulateStartTime, "start-time", "", "", "Time of the first log record, in RFC 3339 format, e.g. 2022-03-01T10:00:00Z (default now)")
+	simulateCmd.Flags().StringVarP(&flagSimulateOutput, "output", "o", "", "File to write the log records to. Defaults to the standard output")
+	return simulateCmd
+}
+
+func doSimulateCmd(techniques []*stratus.AttackTechnique, startTime time.Time, outputFile string) error {
+	// Simulated log records are synthetic, and are not redacted so that they can be fed as-is to detection rules
+	var output io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			return errors.New("unable to create output file: " + err.Error())
+		}
+		defer file.Close()
+		output = file
+	}
+
+	encoder := json.NewEncoder(output)
+	recordsCount := 0
+	for _, technique := range techniques {
+		simulation := stratus.NewSimulationContext(technique.Platform, flagSimulateAccountID)
+		if flagSimulatePrincipal != "" {
+			simulation.Principal = flagSimulatePrincipal
+		}
+		if flagSimulateSourceIP != "" {
+			simulation.SourceIP = flagSimulateSourceIP
+		}
+		if flagSimulateRegion != "" {
+			simulation.Region = flagSimulateRegion
+		}
+		simulation.StartTime = startTime
+
+		for _, record := range technique.Simulate(simulation) {
+			if err := encoder.Encode(record); err != nil {
+				return errors.New("unable to write log record: " + err.Error())
+			}
+			recordsCount++
+		}
+		// The records of the next technique follow the ones of this technique
+		startTime = simulation.NextTime()
+	}
+	if outputFile != "" {
+		log.Printf("Wrote %d simulated log records to %s", recordsCount, outputFile)
+	}
+	return nil
+}
+
+// parseStartTime parses the time of the first simulated log record, defaulting to now
+func parseStartTime(startTime string) (time.Time, error) {
+	if startTime == "" {
+		return time.Now().UTC(), nil
+	}
+	parsed, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return time.Time{}, errors.New("invalid start time " + startTime + ", use the RFC 3339 format, e.g. 2022-03-01T10:00:00Z")
+	}
+	return parsed.UTC(), nil
+}
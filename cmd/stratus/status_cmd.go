@@ -1,18 +1,31 @@
 package main
 
 import (
+	"errors"
 	"github.com/datadog/stratus-red-team/internal/state"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/runner"
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
+	"log"
+	"os"
 )
 
+var flagStatusRefresh bool
+var flagStatusFix bool
+
 func buildStatusCmd() *cobra.Command {
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Display the status of TTPs.",
+		Example: "stratus status\n" +
+			"stratus status aws.defense-evasion.cloudtrail-stop --refresh\n" +
+			"stratus status --refresh --fix",
 		Args: func(cmd *cobra.Command, args []string) error {
+			if flagStatusFix && !flagStatusRefresh {
+				return errors.New("--fix can only be used with --refresh")
+			}
 			if len(args) == 0 {
 				return nil // no technique specified == all techniques
 			}
@@ -20,14 +33,19 @@ func buildStatusCmd() *cobra.Command {
 			return err
 		},
 		Run: func(cmd *cobra.Command, args []string) {
+			techniques := listAllTechniques()
 			if len(args) > 0 {
-				techniques, _ := resolveTechniques(args)
-				doStatusCmd(techniques)
+				techniques, _ = resolveTechniques(args)
+			}
+			if flagStatusRefresh {
+				doStatusRefreshCmd(techniques, flagStatusFix)
 			} else {
-				doStatusCmd(stratus.GetRegistry().ListAttackTechniques())
+				doStatusCmd(techniques)
 			}
 		},
 	}
+	statusCmd.Flags().BoolVarP(&flagStatusRefresh, "refresh", "", false, "Compare the persisted state of techniques with reality, by probing their detonation and checking their prerequisites")
+	statusCmd.Flags().BoolVarP(&flagStatusFix, "fix", "", false, "When used with --refresh, update the persisted state to match reality and re-apply drifted prerequisites")
 	return statusCmd
 }
 
@@ -40,9 +58,90 @@ func doStatusCmd(techniques []*stratus.AttackTechnique) {
 		if techniqueState == "" {
 			techniqueState = stratus.AttackTechniqueStatusCold
 		}
-		t.AppendRow(table.Row{techniques[i].ID, techniques[i].FriendlyName, colorState(techniqueState)})
+		status := colorState(techniqueState) + formatOutdated(runner.GetOutdatedPrerequisites(techniques[i], stateManager))
+		t.AppendRow(table.Row{techniques[i].ID, techniques[i].FriendlyName, status})
+	}
+	t.Render()
+}
+
+func doStatusRefreshCmd(techniques []*stratus.AttackTechnique, fix bool) {
+	// Authentication status of each platform, only checked if at least one technique needs it
+	authenticationErrors := map[stratus.Platform]error{}
+	hadError := false
+
+	t := GetDisplayTable()
+	t.AppendHeader(table.Row{"ID", "Name", "Status", "Observed status", "Prerequisites"})
+	for i := range techniques {
+		technique := techniques[i]
+		stratusRunner := runner.NewRunner(technique, runner.StratusRunnerNoForce)
+		if !stratusRunner.CanDetectDrift() {
+			t.AppendRow(table.Row{technique.ID, technique.FriendlyName, colorState(stratusRunner.GetState()), "-", "-"})
+			continue
+		}
+
+		authErr, checked := authenticationErrors[technique.Platform]
+		if !checked {
+			log.Println("Checking your authentication against " + string(technique.Platform))
+			authErr = stratus.EnsureAuthenticated(technique.Platform)
+			authenticationErrors[technique.Platform] = authErr
+		}
+		if authErr != nil {
+			log.Println("Unable to refresh the state of " + technique.ID + ": " + authErr.Error())
+			t.AppendRow(table.Row{technique.ID, technique.FriendlyName, colorState(stratusRunner.GetState()), "unknown", "unknown"})
+			hadError = true
+			continue
+		}
+
+		drift, err := stratusRunner.DetectDrift()
+		if err != nil {
+			log.Println(err)
+			t.AppendRow(table.Row{technique.ID, technique.FriendlyName, colorState(stratusRunner.GetState()), "unknown", "unknown"})
+			hadError = true
+			continue
+		}
+		status := colorState(drift.PersistedState) + formatOutdated(stratusRunner.GetOutdatedPrerequisites())
+		t.AppendRow(table.Row{technique.ID, technique.FriendlyName, status, formatObservedState(drift), formatPrerequisitesDrift(drift)})
+
+		if fix && drift.HasDrifted() {
+			if err := stratusRunner.FixDrift(drift); err != nil {
+				log.Println(err)
+				hadError = true
+			}
+		}
 	}
 	t.Render()
+
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+// formatOutdated indicates that the prerequisites of a technique were spun up by a previous version of the technique
+func formatOutdated(outdated *runner.OutdatedPrerequisites) string {
+	if outdated == nil {
+		return ""
+	}
+	return color.RedString(" (outdated, " + outdated.Persisted.String() + " -> " + outdated.Current.String() + ")")
+}
+
+func formatObservedState(drift *runner.StateDrift) string {
+	if !drift.WasProbed {
+		return "-"
+	}
+	if drift.ObservedState != drift.PersistedState {
+		return color.RedString(string(drift.ObservedState) + " (drift)")
+	}
+	return colorState(drift.ObservedState)
+}
+
+func formatPrerequisitesDrift(drift *runner.StateDrift) string {
+	if !drift.PrerequisitesChecked {
+		return "-"
+	}
+	if drift.PrerequisitesDrifted {
+		return color.RedString("DRIFTED")
+	}
+	return color.GreenString("OK")
 }
 
 func colorState(state stratus.AttackTechniqueState) string {
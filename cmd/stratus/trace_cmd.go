@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"log"
+	"strconv"
+	"time"
+)
+
+var flagTraceJSON bool
+
+func buildTraceCmd() *cobra.Command {
+	traceCmd := &cobra.Command{
+		Use:   "trace attack-technique-id",
+		Short: "Display the API calls made by the last detonation of an attack technique.",
+		Long: "Display the API calls made by the last detonation of an attack technique, and by its reversion if it was " +
+			"reverted, with the request ID of each call to find the log record it produced (e.g. the requestID of a " +
+			"CloudTrail event). API calls are recorded for AWS, Kubernetes and Azure attack techniques, and are kept " +
+			"after the technique is cleaned up.",
+		Example: "stratus trace aws.defense-evasion.cloudtrail-stop\n" +
+			"stratus trace k8s.privilege-escalation.privileged-pod --json",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("you must specify exactly one attack technique")
+			}
+			_, err := resolveTechniques(args)
+			return err
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return getTechniquesCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			techniques, _ := resolveTechniques(args)
+			if err := doTraceCmd(techniques[0], flagTraceJSON); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	traceCmd.Flags().BoolVarP(&flagTraceJSON, "json", "", false, "Output the API calls as JSON")
+	return traceCmd
+}
+
+func doTraceCmd(technique *stratus.AttackTechnique, outputJSON bool) error {
+	traces, err := state.NewFileSystemStateManager(technique).GetAPICallTraces()
+	if err != nil {
+		return errors.New("unable to read the API calls made by " + technique.ID + ": " + err.Error())
+	}
+	if outputJSON {
+		if traces == nil {
+			traces = []apitrace.Trace{}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(traces)
+	}
+	if len(traces) == 0 {
+		log.Println("No API calls were recorded for " + technique.ID + ", detonate it first")
+		return nil
+	}
+
+	t := GetDisplayTable()
+	t.AppendHeader(table.Row{"Time", "Operation", "Service", "API call", "Resource", "Status", "Request ID"})
+	for _, trace := range traces {
+		for _, call := range trace.Calls {
+			t.AppendRow(table.Row{
+				call.Time.Format(time.RFC3339), trace.Operation, call.Service, call.Operation, call.Resource,
+				formatCallStatus(call), call.RequestID,
+			})
+		}
+	}
+	t.Render()
+	for _, trace := range traces {
+		log.Printf("%s of %s (execution %s) made %d API calls", trace.Operation, technique.ID, trace.ExecutionID, len(trace.Calls))
+	}
+	return nil
+}
+
+// formatCallStatus returns the status code of an API call, or its error if no response was received
+func formatCallStatus(call apitrace.Call) string {
+	status := call.Error
+	if call.StatusCode != 0 {
+		status = strconv.Itoa(call.StatusCode)
+	}
+	if !call.Succeeded() {
+		return color.RedString(status)
+	}
+	return color.GreenString(status)
+}
@@ -5,14 +5,13 @@ import (
 	"github.com/datadog/stratus-red-team/pkg/stratus"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"log"
-	"os"
 	"strings"
 )
 
 func GetDisplayTable() table.Writer {
 	t := table.NewWriter()
 	t.SetStyle(table.StyleDefault)
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(stdout)
 	return t
 }
 
@@ -20,6 +19,11 @@ func resolveTechniques(names []string) ([]*stratus.AttackTechnique, error) {
 	var result []*stratus.AttackTechnique
 	for i := range names {
 		technique := stratus.GetRegistry().GetAttackTechniqueByName(names[i])
+		if technique == nil {
+			// The technique may be implemented by a plugin
+			loadPlugins()
+			technique = stratus.GetRegistry().GetAttackTechniqueByName(names[i])
+		}
 		if technique == nil {
 			return nil, errors.New("unknown technique name " + names[i])
 		}
@@ -58,8 +62,14 @@ func VerifyPlatformRequirements(attackTechniques []*stratus.AttackTechnique) {
 	}
 }
 
+// listAllTechniques returns all the attack techniques, including the ones implemented by plugins
+func listAllTechniques() []*stratus.AttackTechnique {
+	loadPlugins()
+	return stratus.GetRegistry().ListAttackTechniques()
+}
+
 func getTechniquesCompletion(completionPrefix string) []string {
-	attackTechniques := stratus.GetRegistry().GetAttackTechniques(&stratus.AttackTechniqueFilter{})
+	attackTechniques := listAllTechniques()
 	var matchingTechniques []string
 	for _, technique := range attackTechniques {
 		if strings.HasPrefix(technique.ID, completionPrefix) {
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/validation"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var flagValidateStrict bool
+
+func buildValidateCmd() *cobra.Command {
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check that attack techniques are well-formed, without detonating them.",
+		Long: "Check that attack techniques are well-formed, without detonating them: format of their ID, presence of " +
+			"their description and detection guidance, revert function of techniques that are not idempotent, " +
+			"and Terraform outputs required by techniques. Custom techniques loaded from the techniques and plugins " +
+			"directories are validated as well.",
+		Example: "stratus validate\n" +
+			"stratus validate aws.defense-evasion.cloudtrail-stop\n" +
+			"stratus validate --techniques-dir ./my-techniques --strict",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return nil // no technique specified == all techniques
+			}
+			_, err := resolveTechniques(args)
+			return err
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return getTechniquesCompletion(toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			var issues []validation.Issue
+			techniquesCount := len(listAllTechniques())
+			if len(args) == 0 {
+				issues = validation.ValidateRegistry(stratus.GetRegistry())
+			} else {
+				techniques, _ := resolveTechniques(args)
+				techniquesCount = len(techniques)
+				for i := range techniques {
+					issues = append(issues, validation.ValidateTechnique(techniques[i])...)
+				}
+			}
+			if !doValidateCmd(issues, techniquesCount, flagValidateStrict) {
+				os.Exit(1)
+			}
+		},
+	}
+	validateCmd.Flags().BoolVarP(&flagValidateStrict, "strict", "", false, "Fail on warnings as well as on errors")
+	return validateCmd
+}
+
+// doValidateCmd displays validation issues, and returns false if validation failed
+func doValidateCmd(issues []validation.Issue, techniquesCount int, strict bool) bool {
+	errorsCount, warningsCount := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == validation.SeverityError {
+			errorsCount++
+		} else {
+			warningsCount++
+		}
+	}
+
+	if len(issues) > 0 {
+		t := GetDisplayTable()
+		t.AppendHeader(table.Row{"ID", "Severity", "Issue"})
+		for _, issue := range issues {
+			t.AppendRow(table.Row{issue.TechniqueID, colorSeverity(issue.Severity), issue.Message})
+		}
+		t.Render()
+	}
+	fmt.Fprintf(stdout, "Validated %d attack techniques: %d errors, %d warnings\n", techniquesCount, errorsCount, warningsCount)
+
+	return errorsCount == 0 && (!strict || warningsCount == 0)
+}
+
+func colorSeverity(severity validation.Severity) string {
+	if severity == validation.SeverityError {
+		return color.RedString(string(severity))
+	}
+	return color.YellowString(string(severity))
+}
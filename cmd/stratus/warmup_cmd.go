@@ -9,6 +9,7 @@ import (
 )
 
 var forceWarmup bool
+var reapplyOutdatedWarmup bool
 
 func buildWarmupCmd() *cobra.Command {
 	warmupCmd := &cobra.Command{
@@ -39,6 +40,7 @@ func buildWarmupCmd() *cobra.Command {
 		},
 	}
 	warmupCmd.Flags().BoolVarP(&forceWarmup, "force", "f", false, "Force re-ensuring the prerequisite infrastructure or configuration is up to date")
+	warmupCmd.Flags().BoolVarP(&reapplyOutdatedWarmup, "reapply-outdated", "", false, "Re-apply prerequisites spun up by a previous version of the technique, e.g. after upgrading Stratus Red Team")
 	return warmupCmd
 }
 
@@ -63,6 +65,7 @@ func doWarmupCmd(techniques []*stratus.AttackTechnique) {
 func warmupCmdWorker(techniques <-chan *stratus.AttackTechnique, errors chan<- error) {
 	for technique := range techniques {
 		stratusRunner := runner.NewRunner(technique, forceWarmup)
+		stratusRunner.ShouldReapplyOutdated = reapplyOutdatedWarmup
 		_, err := stratusRunner.WarmUp()
 		errors <- err
 	}
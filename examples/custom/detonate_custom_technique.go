@@ -10,7 +10,6 @@ import (
 	_ "github.com/datadog/stratus-red-team/pkg/stratus/loader" // Note: This import is needed
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	stratusrunner "github.com/datadog/stratus-red-team/pkg/stratus/runner"
-	"log"
 )
 
 /*
@@ -31,9 +30,9 @@ func buildCustomAttackTechnique() *stratus.AttackTechnique {
 	}
 }
 
-func detonate(params map[string]string) error {
-	iamUserName := params["iam_user_name"]
-	iamClient := iam.NewFromConfig(stratus.AWSProvider().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	iamUserName := execution.Parameters["iam_user_name"]
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
 
 	userResponse, err := iamClient.GetUser(context.Background(), &iam.GetUserInput{
 		UserName: &iamUserName,
@@ -43,7 +42,7 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to retrieve IAM user information: " + err.Error())
 	}
 
-	log.Println("The ARN of our IAM user is: " + *userResponse.User.Arn)
+	execution.Logger.Println("The ARN of our IAM user is: " + *userResponse.User.Arn)
 	return nil
 }
 
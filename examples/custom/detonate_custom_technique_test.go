@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+	This example tests the custom attack technique without an AWS account, using a stand-in for AWS APIs.
+*/
+
+func TestCustomAttackTechnique(t *testing.T) {
+	harness := stratustest.New(t, buildCustomAttackTechnique(), stratustest.WithOutputs(map[string]string{
+		"iam_user_name": "sample-user",
+	}))
+	harness.AWS.Respond("iam", "GetUser", 200, `<GetUserResponse><GetUserResult><User>
+		<UserName>sample-user</UserName><Arn>arn:aws:iam::123456789012:user/sample-user</Arn>
+	</User></GetUserResult></GetUserResponse>`)
+
+	assert.Nil(t, harness.Detonate())
+	harness.AssertAWSCalled("iam", "GetUser")
+}
@@ -0,0 +1,103 @@
+// Package apitrace records the API calls made by attack techniques when they are detonated or reverted, so that each
+// call can be mapped to the log record it produces (e.g. a CloudTrail event). Calls are recorded in the recorder of
+// their Go context, and are not recorded at all if their context has none
+package apitrace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Call is an API call made to a platform
+type Call struct {
+	Time     time.Time `json:"time"`
+	Platform string    `json:"platform"`
+
+	// Service called, e.g. "CloudTrail" for AWS, "rbac.authorization.k8s.io/v1" for Kubernetes or "Microsoft.Compute"
+	// for Azure
+	Service string `json:"service,omitempty"`
+
+	// Operation, named as in the logs of the platform, e.g. "StopLogging" for AWS, "create pods" for Kubernetes or
+	// "Microsoft.Compute/virtualMachines/runCommand/action" for Azure
+	Operation string `json:"operation"`
+
+	// Resource targeted by the call, when known, e.g. the name of a CloudTrail trail
+	Resource string `json:"resource,omitempty"`
+
+	// HTTP status code of the response, 0 if no response was received
+	StatusCode int `json:"status_code,omitempty"`
+
+	// Identifier of the request, as found in the logs of the platform (e.g. requestID of CloudTrail events, auditID of
+	// Kubernetes audit events, correlationId of Azure Activity logs)
+	RequestID string `json:"request_id,omitempty"`
+
+	// Error of the call, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// Succeeded returns true if the call did not fail
+func (m Call) Succeeded() bool {
+	return m.Error == "" && m.StatusCode < 400
+}
+
+// Trace is the list of API calls made by an operation run on an attack technique, e.g. its detonation
+type Trace struct {
+	ExecutionID string    `json:"execution_id"`
+	Operation   string    `json:"operation"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Calls       []Call    `json:"calls"`
+}
+
+// Recorder records API calls, possibly made concurrently
+type Recorder struct {
+	lock  sync.Mutex
+	calls []Call
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record records an API call
+func (m *Recorder) Record(call Call) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.calls = append(m.calls, call)
+}
+
+// Calls returns the API calls recorded so far, in the order they were made
+func (m *Recorder) Calls() []Call {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a copy of a context in which API calls are recorded by a recorder
+func WithRecorder(ctx context.Context, recorder *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recorder)
+}
+
+// FromContext returns the recorder of a context, or nil if API calls made with this context are not recorded
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	recorder, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return recorder
+}
+
+// IsRecording returns true if API calls made with a context are recorded
+func IsRecording(ctx context.Context) bool {
+	return FromContext(ctx) != nil
+}
+
+// Record records an API call in the recorder of its context, if any
+func Record(ctx context.Context, call Call) {
+	if recorder := FromContext(ctx); recorder != nil {
+		recorder.Record(call)
+	}
+}
@@ -0,0 +1,55 @@
+package apitrace
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordsCallsInTheRecorderOfTheirContext(t *testing.T) {
+	// Calls made without a recorder are not recorded
+	assert.False(t, IsRecording(context.Background()))
+	Record(context.Background(), Call{Operation: "StopLogging"})
+
+	recorder := NewRecorder()
+	ctx := WithRecorder(context.Background(), recorder)
+	assert.True(t, IsRecording(ctx))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Record(ctx, Call{Operation: "StopLogging"})
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, recorder.Calls(), 10)
+}
+
+func TestCallSucceeded(t *testing.T) {
+	assert.True(t, Call{StatusCode: 200}.Succeeded())
+	assert.False(t, Call{StatusCode: 403}.Succeeded())
+	assert.False(t, Call{Error: "connection refused"}.Succeeded())
+}
+
+func TestAWSResource(t *testing.T) {
+	assert.Equal(t, "my-trail", AWSResource(&cloudtrail.StopLoggingInput{Name: aws.String("my-trail")}))
+	assert.Equal(t, "i-1,i-2", AWSResource(&ec2.DescribeInstancesInput{InstanceIds: []string{"i-1", "i-2"}}))
+	assert.Equal(t, "my-bucket", AWSResource(&s3.PutBucketPolicyInput{Bucket: aws.String("my-bucket"), Policy: aws.String("{}")}))
+	assert.Equal(t, "snap-1", AWSResource(&ec2.ModifySnapshotAttributeInput{SnapshotId: aws.String("snap-1"), UserIds: []string{"123456789012"}}))
+	assert.Equal(t, "i-1", AWSResource(&ssm.SendCommandInput{DocumentName: aws.String("AWS-RunShellScript"), InstanceIds: []string{"i-1"}}))
+	assert.Equal(t, "my-user", AWSResource(&iam.AttachUserPolicyInput{PolicyArn: aws.String("arn:aws:iam::aws:policy/AdministratorAccess"), UserName: aws.String("my-user")}))
+	assert.Equal(t, "arn:aws:iam::123456789012:policy/my-policy", AWSResource(&iam.DeletePolicyInput{PolicyArn: aws.String("arn:aws:iam::123456789012:policy/my-policy")}))
+	assert.Empty(t, AWSResource(&ec2.DescribeAccountAttributesInput{}))
+	assert.Empty(t, AWSResource((*cloudtrail.StopLoggingInput)(nil)))
+	assert.Empty(t, AWSResource(nil))
+}
@@ -0,0 +1,80 @@
+package apitrace
+
+import (
+	"reflect"
+	"strings"
+)
+
+// awsResourceFieldSuffixes are the suffixes of the fields of AWS API inputs identifying the resource targeted by a
+// call, by order of preference, e.g. TrailName or InstanceIds
+var awsResourceFieldSuffixes = []string{"Name", "Names", "Id", "Ids", "Arn", "Arns", "Bucket"}
+
+// awsSecondaryResourceFields are fields of AWS API inputs identifying a resource used by a call, rather than the
+// resource it targets, e.g. the DocumentName of an ssm:SendCommand call run on InstanceIds. They identify the
+// resource only when no other field does
+var awsSecondaryResourceFields = map[string]bool{
+	"DocumentName": true,
+	"PolicyArn":    true,
+	"PolicyName":   true,
+}
+
+// AWSResource returns the resource targeted by an AWS API call from its input, e.g. the name of the trail of a
+// cloudtrail:StopLogging call, or an empty string if the input does not identify any resource
+func AWSResource(input interface{}) string {
+	value := reflect.ValueOf(input)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return ""
+	}
+
+	if resource := awsResourceField(value, false); resource != "" {
+		return resource
+	}
+	return awsResourceField(value, true)
+}
+
+// awsResourceField returns the value of the first field of an AWS API input identifying a resource, by order of
+// preference of their suffix, considering either the primary or the secondary resource fields
+func awsResourceField(value reflect.Value, secondary bool) string {
+	for _, suffix := range awsResourceFieldSuffixes {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() || !strings.HasSuffix(field.Name, suffix) || awsSecondaryResourceFields[field.Name] != secondary {
+				continue
+			}
+			if resource := stringValues(value.Field(i)); resource != "" {
+				return resource
+			}
+		}
+	}
+	return ""
+}
+
+// stringValues returns a string, a pointer to a string or a list of strings as a comma-separated string
+func stringValues(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Ptr:
+		if value.IsNil() {
+			return ""
+		}
+		return stringValues(value.Elem())
+	case reflect.Slice:
+		var values []string
+		for i := 0; i < value.Len(); i++ {
+			if item := value.Index(i); item.Kind() == reflect.String || item.Kind() == reflect.Ptr {
+				if itemValue := stringValues(item); itemValue != "" {
+					values = append(values, itemValue)
+				}
+			}
+		}
+		return strings.Join(values, ",")
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package apitrace
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DescribeFunc describes an HTTP request made to the API of a platform, and its response if one was received
+type DescribeFunc func(request *http.Request, response *http.Response) Call
+
+// Transport records the HTTP requests made to the API of a platform in the recorder of their context
+type Transport struct {
+	Platform string
+	Describe DescribeFunc
+	Next     http.RoundTripper
+}
+
+func NewTransport(platform string, describe DescribeFunc, next http.RoundTripper) *Transport {
+	return &Transport{Platform: platform, Describe: describe, Next: next}
+}
+
+func (m *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !IsRecording(request.Context()) {
+		return m.Next.RoundTrip(request)
+	}
+	start := time.Now().UTC()
+	response, err := m.Next.RoundTrip(request)
+	Record(request.Context(), NewHTTPCall(m.Platform, m.Describe, start, request, response, err))
+	return response, err
+}
+
+// NewHTTPCall returns the API call corresponding to an HTTP request made at a given time
+func NewHTTPCall(platform string, describe DescribeFunc, start time.Time, request *http.Request, response *http.Response, err error) Call {
+	call := describe(request, response)
+	call.Time = start
+	call.Platform = platform
+	if response != nil {
+		call.StatusCode = response.StatusCode
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	return call
+}
+
+// DescribeKubernetesRequest describes a request made to the Kubernetes API with the verb and resource found in
+// Kubernetes audit events, e.g. "create pods" in the namespace "default"
+func DescribeKubernetesRequest(request *http.Request, response *http.Response) Call {
+	call := Call{Operation: strings.ToLower(request.Method) + " " + request.URL.Path}
+	if response != nil {
+		call.RequestID = response.Header.Get("Audit-Id")
+	}
+
+	// Resource paths are /api/<version>/... for the core API group, and /apis/<group>/<version>/... for others
+	segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+	switch {
+	case len(segments) >= 3 && segments[0] == "api":
+		call.Service, segments = segments[1], segments[2:]
+	case len(segments) >= 4 && segments[0] == "apis":
+		call.Service, segments = segments[1]+"/"+segments[2], segments[3:]
+	default:
+		// Non-resource URL, e.g. /version
+		return call
+	}
+
+	var namespace, resource, name, subresource string
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		namespace, segments = segments[1], segments[2:]
+	}
+	resource = segments[0]
+	if len(segments) >= 2 {
+		name = segments[1]
+	}
+	if len(segments) >= 3 {
+		subresource = strings.Join(segments[2:], "/")
+	}
+
+	verb := kubernetesVerb(request, name)
+	if subresource != "" {
+		resource += "/" + subresource
+	}
+	call.Operation = verb + " " + resource
+	call.Resource = strings.Trim(namespace+"/"+name, "/")
+	return call
+}
+
+// kubernetesVerb returns the verb of a Kubernetes API request, as found in audit events
+func kubernetesVerb(request *http.Request, name string) string {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		if name != "" {
+			return "get"
+		}
+		if watch := request.URL.Query().Get("watch"); watch == "true" || watch == "1" {
+			return "watch"
+		}
+		return "list"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		if name == "" {
+			return "deletecollection"
+		}
+		return "delete"
+	}
+	return strings.ToLower(request.Method)
+}
+
+// DescribeAzureRequest describes a request made to the Azure Resource Manager API with the operation name and resource
+// ID found in Azure Activity logs, e.g. "Microsoft.Compute/virtualMachines/runCommand/action"
+func DescribeAzureRequest(request *http.Request, response *http.Response) Call {
+	call := Call{Operation: request.Method + " " + request.URL.Path, Resource: request.URL.Path}
+	if response != nil {
+		call.RequestID = response.Header.Get("x-ms-correlation-request-id")
+		if call.RequestID == "" {
+			call.RequestID = response.Header.Get("x-ms-request-id")
+		}
+	}
+
+	segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+	if len(segments) < 2 || !strings.EqualFold(segments[0], "subscriptions") {
+		// Not a request to the Azure Resource Manager API
+		return call
+	}
+
+	// Resource IDs are made of pairs of resource types and names, in the namespace of the last resource provider
+	namespace, types := "Microsoft.Resources", segments
+	for i := len(segments) - 2; i >= 0; i-- {
+		if strings.EqualFold(segments[i], "providers") {
+			namespace, types = segments[i+1], segments[i+2:]
+			break
+		}
+	}
+	var typeNames []string
+	for i := 0; i < len(types); i += 2 {
+		typeNames = append(typeNames, types[i])
+	}
+
+	var action string
+	switch request.Method {
+	case http.MethodPut, http.MethodPatch:
+		action = "write"
+	case http.MethodDelete:
+		action = "delete"
+	case http.MethodPost:
+		action = "action"
+		if len(types)%2 == 1 {
+			// The last segment is the name of the action, e.g. runCommand, and not part of the resource ID
+			call.Resource = "/" + strings.Join(segments[:len(segments)-1], "/")
+		}
+	default:
+		action = "read"
+	}
+	call.Service = namespace
+	call.Operation = strings.Join(append([]string{namespace}, append(typeNames, action)...), "/")
+	return call
+}
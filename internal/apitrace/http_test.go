@@ -0,0 +1,93 @@
+package apitrace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRequest(t *testing.T, method string, url string) *http.Request {
+	request, err := http.NewRequest(method, url, nil)
+	assert.Nil(t, err)
+	return request
+}
+
+func TestDescribeKubernetesRequest(t *testing.T) {
+	response := &http.Response{StatusCode: 201, Header: http.Header{"Audit-Id": []string{"0d9a9e4c"}}}
+	testCases := []struct {
+		Method   string
+		Path     string
+		Expected Call
+	}{
+		{"POST", "/api/v1/namespaces/default/pods", Call{Service: "v1", Operation: "create pods", Resource: "default", RequestID: "0d9a9e4c"}},
+		{"GET", "/api/v1/namespaces/default/pods/my-pod", Call{Service: "v1", Operation: "get pods", Resource: "default/my-pod", RequestID: "0d9a9e4c"}},
+		{"GET", "/api/v1/namespaces/default/secrets?limit=500", Call{Service: "v1", Operation: "list secrets", Resource: "default", RequestID: "0d9a9e4c"}},
+		{"GET", "/api/v1/pods?watch=true", Call{Service: "v1", Operation: "watch pods", RequestID: "0d9a9e4c"}},
+		{"POST", "/api/v1/namespaces/default/pods/my-pod/exec", Call{Service: "v1", Operation: "create pods/exec", Resource: "default/my-pod", RequestID: "0d9a9e4c"}},
+		{"DELETE", "/apis/rbac.authorization.k8s.io/v1/clusterrolebindings/admin", Call{Service: "rbac.authorization.k8s.io/v1", Operation: "delete clusterrolebindings", Resource: "admin", RequestID: "0d9a9e4c"}},
+		{"PATCH", "/api/v1/namespaces/kube-system", Call{Service: "v1", Operation: "patch namespaces", Resource: "kube-system", RequestID: "0d9a9e4c"}},
+		{"GET", "/version", Call{Operation: "get /version", RequestID: "0d9a9e4c"}},
+	}
+	for _, testCase := range testCases {
+		call := DescribeKubernetesRequest(newRequest(t, testCase.Method, "https://kubernetes.local"+testCase.Path), response)
+		assert.Equal(t, testCase.Expected, call, testCase.Method+" "+testCase.Path)
+	}
+}
+
+func TestDescribeAzureRequest(t *testing.T) {
+	vm := "/subscriptions/45e0ad3f/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"
+	testCases := []struct {
+		Method   string
+		Path     string
+		Expected Call
+	}{
+		{"POST", vm + "/runCommand", Call{Service: "Microsoft.Compute", Operation: "Microsoft.Compute/virtualMachines/runCommand/action", Resource: vm}},
+		{"PUT", vm + "/extensions/my-extension", Call{Service: "Microsoft.Compute", Operation: "Microsoft.Compute/virtualMachines/extensions/write", Resource: vm + "/extensions/my-extension"}},
+		{"DELETE", vm, Call{Service: "Microsoft.Compute", Operation: "Microsoft.Compute/virtualMachines/delete", Resource: vm}},
+		{"GET", "/subscriptions/45e0ad3f/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines", Call{Service: "Microsoft.Compute", Operation: "Microsoft.Compute/virtualMachines/read", Resource: "/subscriptions/45e0ad3f/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines"}},
+		{"PUT", "/subscriptions/45e0ad3f/resourceGroups/my-rg", Call{Service: "Microsoft.Resources", Operation: "Microsoft.Resources/subscriptions/resourceGroups/write", Resource: "/subscriptions/45e0ad3f/resourceGroups/my-rg"}},
+		{"GET", "/my-container/my-blob", Call{Operation: "GET /my-container/my-blob", Resource: "/my-container/my-blob"}},
+	}
+	for _, testCase := range testCases {
+		call := DescribeAzureRequest(newRequest(t, testCase.Method, "https://management.azure.com"+testCase.Path+"?api-version=2022-03-01"), nil)
+		assert.Equal(t, testCase.Expected, call, testCase.Method+" "+testCase.Path)
+	}
+
+	response := &http.Response{Header: http.Header{"X-Ms-Request-Id": []string{"b1c2"}}}
+	assert.Equal(t, "b1c2", DescribeAzureRequest(newRequest(t, "GET", "https://management.azure.com"+vm), response).RequestID)
+	response.Header.Set("x-ms-correlation-request-id", "a4f3")
+	assert.Equal(t, "a4f3", DescribeAzureRequest(newRequest(t, "GET", "https://management.azure.com"+vm), response).RequestID)
+}
+
+func TestTransportRecordsRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Audit-Id", "0d9a9e4c")
+		writer.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport("Kubernetes", DescribeKubernetesRequest, http.DefaultTransport)}
+
+	// Requests are not recorded if their context has no recorder
+	response, err := client.Get(server.URL + "/api/v1/namespaces/default/secrets")
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	recorder := NewRecorder()
+	request := newRequest(t, "GET", server.URL+"/api/v1/namespaces/default/secrets")
+	response, err = client.Do(request.WithContext(WithRecorder(context.Background(), recorder)))
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	calls := recorder.Calls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "Kubernetes", calls[0].Platform)
+		assert.Equal(t, "list secrets", calls[0].Operation)
+		assert.Equal(t, http.StatusForbidden, calls[0].StatusCode)
+		assert.Equal(t, "0d9a9e4c", calls[0].RequestID)
+		assert.False(t, calls[0].Time.IsZero())
+		assert.False(t, calls[0].Succeeded())
+	}
+}
@@ -1,24 +1,25 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"strconv"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.credential-access.ec2-get-password-data",
@@ -39,32 +40,35 @@ Detonation:
 - Run a number of ec2:GetPasswordData calls (which will be denied) using fictitious instance IDs
 `,
 		Detection:                  "Identify principals making a large number of ec2:GetPasswordData calls, using CloudTrail's GetPasswordData event",
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.CredentialAccess},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"role_arn"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
 const numCalls = 30
 
-func detonate(params map[string]string) error {
-	roleArn := params["role_arn"]
+func detonate(execution *stratus.ExecutionContext) error {
+	roleArn := execution.Parameters["role_arn"]
 
-	awsConnection := providers.AWS().GetConnection()
+	awsConnection := execution.AWS.GetConnection()
 	stsClient := sts.NewFromConfig(awsConnection)
 	awsConnection.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleArn))
 	ec2Client := ec2.NewFromConfig(awsConnection)
 
-	log.Println("Running ec2:GetPasswordData on " + strconv.Itoa(numCalls) + " random instance IDs")
+	execution.Logger.Println("Running ec2:GetPasswordData on " + strconv.Itoa(numCalls) + " random instance IDs")
 
 	for i := 0; i < numCalls; i++ {
 		// Generate a fake, real-looking instance ID
 		// Since we don't have the permission, we don't care if the instance actually exists
 		instanceId := "i-" + utils.RandomString(16)
 
-		_, err := ec2Client.GetPasswordData(context.Background(), &ec2.GetPasswordDataInput{
+		_, err := ec2Client.GetPasswordData(execution.Context, &ec2.GetPasswordDataInput{
 			InstanceId: &instanceId,
 		})
 
@@ -75,3 +79,17 @@ func detonate(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail events of the denied ec2:GetPasswordData calls, made by a role without permissions
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	identity := logs.AWSIdentity(simulation.AccountID, logs.AWSAssumedRoleArn(simulation.AccountID, "stratus-red-team-get-password-data-role", "aws-go-sdk-"+strconv.FormatInt(simulation.StartTime.Unix(), 10)))
+	var records []stratus.LogRecord
+	for i := 0; i < numCalls; i++ {
+		event := logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "GetPasswordData", map[string]interface{}{
+			"instanceId": "i-" + utils.RandomString(16),
+		})
+		event["userIdentity"] = identity
+		records = append(records, logs.CloudTrailError(event, "Client.UnauthorizedOperation", "You are not authorized to perform this operation."))
+	}
+	return records
+}
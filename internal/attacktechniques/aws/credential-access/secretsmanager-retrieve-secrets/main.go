@@ -1,20 +1,23 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
+	"strconv"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.credential-access.secretsmanager-retrieve-secrets",
@@ -38,18 +41,20 @@ The following may be use to tune the detection, or validate findings:
 
 - Principals who do not usually call secretsmanager:GetSecretValue
 - Attempts to call GetSecretValue resulting in access denied errors`,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.CredentialAccess},
 		PrerequisitesTerraformCode: tf,
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(map[string]string) error {
-	secretsManagerClient := secretsmanager.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	secretsManagerClient := secretsmanager.NewFromConfig(execution.AWS.GetConnection())
 
-	secretsResponse, err := secretsManagerClient.ListSecrets(context.Background(), &secretsmanager.ListSecretsInput{
+	secretsResponse, err := secretsManagerClient.ListSecrets(execution.Context, &secretsmanager.ListSecretsInput{
 		Filters: []types.Filter{
 			{Key: types.FilterNameStringTypeTagKey, Values: []string{"StratusRedTeam"}},
 		},
@@ -62,8 +67,8 @@ func detonate(map[string]string) error {
 
 	for i := range secretsResponse.SecretList {
 		secret := secretsResponse.SecretList[i]
-		log.Println("Retrieving value of secret " + *secret.ARN)
-		_, err := secretsManagerClient.GetSecretValue(context.Background(), &secretsmanager.GetSecretValueInput{
+		execution.Logger.Println("Retrieving value of secret " + *secret.ARN)
+		_, err := secretsManagerClient.GetSecretValue(execution.Context, &secretsmanager.GetSecretValueInput{
 			SecretId: secret.ARN,
 		})
 
@@ -74,3 +79,20 @@ func detonate(map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail events of the listing and retrieval of the secrets
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	records := []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "secretsmanager.amazonaws.com", "ListSecrets", map[string]interface{}{
+			"maxResults": 100,
+			"filters":    []interface{}{map[string]interface{}{"key": "tag-key", "values": []string{"StratusRedTeam"}}},
+		}),
+	}
+	for i := 0; i < 3; i++ {
+		secretArn := "arn:aws:secretsmanager:" + simulation.Region + ":" + simulation.AccountID + ":secret:stratus-red-team-retrieve-secret-" + strconv.Itoa(i) + "-" + utils.RandomString(6)
+		records = append(records, logs.CloudTrailEvent(simulation, "secretsmanager.amazonaws.com", "GetSecretValue", map[string]interface{}{
+			"secretId": secretArn,
+		}))
+	}
+	return records
+}
@@ -1,14 +1,12 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"strconv"
 	"strings"
 )
@@ -16,6 +14,9 @@ import (
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.credential-access.ssm-retrieve-securestring-parameters",
@@ -43,23 +44,25 @@ The following may be use to tune the detection, or validate findings:
 - Principals who do not usually call ssm:GetParameter(s)
 - Attempts to call ssm:GetParameter(s) resulting in access denied errors
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.CredentialAccess},
 		PrerequisitesTerraformCode: tf,
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(map[string]string) error {
-	ssmClient := ssm.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	ssmClient := ssm.NewFromConfig(execution.AWS.GetConnection())
 
-	log.Println("Running ssm:DescribeParameters and ssm:GetParameters by batch of 10 to find all SSM Parameters in the current region")
+	execution.Logger.Println("Running ssm:DescribeParameters and ssm:GetParameters by batch of 10 to find all SSM Parameters in the current region")
 	paginator := ssm.NewDescribeParametersPaginator(ssmClient, &ssm.DescribeParametersInput{}, func(options *ssm.DescribeParametersPaginatorOptions) {
 		options.Limit = 10
 	})
 	for paginator.HasMorePages() {
-		result, err := paginator.NextPage(context.Background())
+		result, err := paginator.NextPage(execution.Context)
 		if err != nil {
 			return errors.New("unable to retrieve SSM parameters: " + err.Error())
 		}
@@ -77,14 +80,31 @@ func detonate(map[string]string) error {
 			continue
 		}
 
-		response, err := ssmClient.GetParameters(context.Background(), &ssm.GetParametersInput{
+		response, err := ssmClient.GetParameters(execution.Context, &ssm.GetParametersInput{
 			Names:          names,
 			WithDecryption: true,
 		})
 		if err != nil {
 			return errors.New("unable to retrieve SSM parameters: " + err.Error())
 		}
-		log.Println("Successfully retrieved " + strconv.Itoa(len(response.Parameters)) + " SSM Parameters")
+		execution.Logger.Println("Successfully retrieved " + strconv.Itoa(len(response.Parameters)) + " SSM Parameters")
 	}
 	return nil
 }
+
+// simulate generates the CloudTrail events of the listing and decryption of the SSM parameters
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	var names []string
+	for i := 0; i < 3; i++ {
+		names = append(names, "/credentials/stratus-red-team/credentials-"+strconv.Itoa(i))
+	}
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ssm.amazonaws.com", "DescribeParameters", map[string]interface{}{
+			"maxResults": 10,
+		}),
+		logs.CloudTrailEvent(simulation, "ssm.amazonaws.com", "GetParameters", map[string]interface{}{
+			"names":          names,
+			"withDecryption": true,
+		}),
+	}
+}
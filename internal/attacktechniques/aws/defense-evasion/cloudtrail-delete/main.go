@@ -1,19 +1,20 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.cloudtrail-delete",
@@ -36,19 +37,22 @@ Identify when a CloudTrail trail is deleted, through CloudTrail's <code>DeleteTr
 
 GuardDuty also provides a dedicated finding type, [Stealth:IAMUser/CloudTrailLoggingDisabled](https://docs.aws.amazon.com/guardduty/latest/ug/guardduty_finding-types-iam.html#stealth-iam-cloudtrailloggingdisabled).
 `,
+		SigmaRules:                 sigma,
 		IsIdempotent:               false, // can't delete a CloudTrail twice
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"cloudtrail_trail_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	cloudtrailClient := cloudtrail.NewFromConfig(providers.AWS().GetConnection())
-	trailName := params["cloudtrail_trail_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
 
-	log.Println("Deleting CloudTrail trail " + trailName)
+	execution.Logger.Println("Deleting CloudTrail trail " + trailName)
 
-	_, err := cloudtrailClient.DeleteTrail(context.Background(), &cloudtrail.DeleteTrailInput{
+	_, err := cloudtrailClient.DeleteTrail(execution.Context, &cloudtrail.DeleteTrailInput{
 		Name: &trailName,
 	})
 
@@ -58,3 +62,12 @@ func detonate(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the deletion of the trail
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "cloudtrail.amazonaws.com", "DeleteTrail", map[string]interface{}{
+			"name": "stratus-red-team-cloudtrail-trail",
+		}),
+	}
+}
@@ -1,21 +1,22 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.cloudtrail-event-selectors",
@@ -38,20 +39,23 @@ Detonation:
 		Detection: `
 Identify when event selectors of a CloudTrail trail are updated, through CloudTrail's <code>PutEventSelectors</code> event.
 `,
+		SigmaRules:                 sigma,
 		IsIdempotent:               true, // cloudtrail:PutEventSelectors is idempotent
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"cloudtrail_trail_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	cloudtrailClient := cloudtrail.NewFromConfig(providers.AWS().GetConnection())
-	trailName := params["cloudtrail_trail_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
 
-	log.Println("Applying event selector on CloudTrail trail " + trailName + " to disable logging management and data events")
+	execution.Logger.Println("Applying event selector on CloudTrail trail " + trailName + " to disable logging management and data events")
 
-	_, err := cloudtrailClient.PutEventSelectors(context.Background(), &cloudtrail.PutEventSelectorsInput{
+	_, err := cloudtrailClient.PutEventSelectors(execution.Context, &cloudtrail.PutEventSelectorsInput{
 		TrailName: &trailName,
 		EventSelectors: []types.EventSelector{
 			{
@@ -72,12 +76,12 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	cloudtrailClient := cloudtrail.NewFromConfig(providers.AWS().GetConnection())
-	trailName := params["cloudtrail_trail_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
 
-	log.Println("Reverting event selector on CloudTrail trail " + trailName)
-	_, err := cloudtrailClient.PutEventSelectors(context.Background(), &cloudtrail.PutEventSelectorsInput{
+	execution.Logger.Println("Reverting event selector on CloudTrail trail " + trailName)
+	_, err := cloudtrailClient.PutEventSelectors(execution.Context, &cloudtrail.PutEventSelectorsInput{
 		TrailName:      &trailName,
 		EventSelectors: []types.EventSelector{{IncludeManagementEvents: aws.Bool(true)}},
 	})
@@ -88,3 +92,22 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the update of the event selectors of the trail
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "cloudtrail.amazonaws.com", "PutEventSelectors", map[string]interface{}{
+			"trailName": "stratus-red-team-cloudtrail-trail",
+			"eventSelectors": []interface{}{
+				map[string]interface{}{
+					"readWriteType":           "ReadOnly",
+					"includeManagementEvents": false,
+					"dataResources": []interface{}{
+						map[string]interface{}{"type": "AWS::S3::Object", "values": []string{}},
+						map[string]interface{}{"type": "AWS::Lambda::Function", "values": []string{}},
+					},
+				},
+			},
+		}),
+	}
+}
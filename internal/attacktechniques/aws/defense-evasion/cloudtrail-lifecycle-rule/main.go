@@ -1,21 +1,23 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.cloudtrail-lifecycle-rule",
@@ -41,19 +43,22 @@ Identify when lifecycle rule with a short expiration is applied to an S3 bucket
 The CloudTrail event <code>PutBucketLifecycle</code> and its attribute 
 <code>requestParameters.LifecycleConfiguration.Rule.Expiration.Days</code> can be used.
 `,
+		SigmaRules:                 sigma,
 		IsIdempotent:               false, // can't create twice a lifecycle rule with the same name
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"s3_bucket_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	s3Client := s3.NewFromConfig(providers.AWS().GetConnection())
-	bucketName := params["s3_bucket_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	s3Client := s3.NewFromConfig(execution.AWS.GetConnection())
+	bucketName := execution.Parameters["s3_bucket_name"]
 
-	log.Println("Setting a short retention policy on CloudTrail S3 bucket " + bucketName)
-	_, err := s3Client.PutBucketLifecycleConfiguration(context.Background(), &s3.PutBucketLifecycleConfigurationInput{
+	execution.Logger.Println("Setting a short retention policy on CloudTrail S3 bucket " + bucketName)
+	_, err := s3Client.PutBucketLifecycleConfiguration(execution.Context, &s3.PutBucketLifecycleConfigurationInput{
 		Bucket: &bucketName,
 		LifecycleConfiguration: &types.BucketLifecycleConfiguration{
 			Rules: []types.LifecycleRule{
@@ -75,12 +80,12 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	s3Client := s3.NewFromConfig(providers.AWS().GetConnection())
-	bucketName := params["s3_bucket_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	s3Client := s3.NewFromConfig(execution.AWS.GetConnection())
+	bucketName := execution.Parameters["s3_bucket_name"]
 
-	log.Println("Reverting S3 Lifecycle Rules on CloudTrail S3 bucket " + bucketName)
-	_, err := s3Client.DeleteBucketLifecycle(context.Background(), &s3.DeleteBucketLifecycleInput{
+	execution.Logger.Println("Reverting S3 Lifecycle Rules on CloudTrail S3 bucket " + bucketName)
+	_, err := s3Client.DeleteBucketLifecycle(execution.Context, &s3.DeleteBucketLifecycleInput{
 		Bucket: &bucketName,
 	})
 
@@ -89,3 +94,23 @@ func revert(params map[string]string) error {
 	}
 	return nil
 }
+
+// simulate generates the CloudTrail event of the creation of the lifecycle rule
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "s3.amazonaws.com", "PutBucketLifecycle", map[string]interface{}{
+			"bucketName": "stratus-red-team-cloudtrail-" + utils.RandomString(8),
+			"Host":       "s3.amazonaws.com",
+			"lifecycle":  "",
+			"LifecycleConfiguration": map[string]interface{}{
+				"xmlns": "http://s3.amazonaws.com/doc/2006-03-01/",
+				"Rule": map[string]interface{}{
+					"ID":         "nuke-cloudtrail-logs-after-1-day",
+					"Status":     "Enabled",
+					"Filter":     map[string]interface{}{"Prefix": "*"},
+					"Expiration": map[string]interface{}{"Days": 1},
+				},
+			},
+		}),
+	}
+}
@@ -1,19 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.cloudtrail-stop",
@@ -36,20 +38,24 @@ Identify when a CloudTrail trail is disabled, through CloudTrail's <code>StopLog
 
 GuardDuty also provides a dedicated finding type, [Stealth:IAMUser/CloudTrailLoggingDisabled](https://docs.aws.amazon.com/guardduty/latest/ug/guardduty_finding-types-iam.html#stealth-iam-cloudtrailloggingdisabled).
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
 		IsIdempotent:               true, // cloudtrail:StopLogging is idempotent
+		RequiredOutputs:            []string{"cloudtrail_trail_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
+		IsDetonated:                isDetonated,
 	})
 }
 
-func detonate(params map[string]string) error {
-	cloudtrailClient := cloudtrail.NewFromConfig(providers.AWS().GetConnection())
-	trailName := params["cloudtrail_trail_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
 
-	log.Println("Stopping CloudTrail trail " + trailName)
+	execution.Logger.Println("Stopping CloudTrail trail " + trailName)
 
-	_, err := cloudtrailClient.StopLogging(context.Background(), &cloudtrail.StopLoggingInput{
+	_, err := cloudtrailClient.StopLogging(execution.Context, &cloudtrail.StopLoggingInput{
 		Name: &trailName,
 	})
 
@@ -60,14 +66,42 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	cloudtrailClient := cloudtrail.NewFromConfig(providers.AWS().GetConnection())
-	trailName := params["cloudtrail_trail_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
 
-	log.Println("Restarting CloudTrail trail " + trailName)
-	_, err := cloudtrailClient.StartLogging(context.Background(), &cloudtrail.StartLoggingInput{
+	execution.Logger.Println("Restarting CloudTrail trail " + trailName)
+	_, err := cloudtrailClient.StartLogging(execution.Context, &cloudtrail.StartLoggingInput{
 		Name: &trailName,
 	})
 
 	return err
 }
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	cloudtrailClient := cloudtrail.NewFromConfig(execution.AWS.GetConnection())
+	trailName := execution.Parameters["cloudtrail_trail_name"]
+
+	result, err := cloudtrailClient.GetTrailStatus(execution.Context, &cloudtrail.GetTrailStatusInput{
+		Name: &trailName,
+	})
+	if err != nil {
+		var notFound *types.TrailNotFoundException
+		if errors.As(err, &notFound) {
+			// A deleted trail is a drifted prerequisite, not a detonation
+			return false, nil
+		}
+		return false, errors.New("unable to retrieve CloudTrail trail status: " + err.Error())
+	}
+
+	return !*result.IsLogging, nil
+}
+
+// simulate generates the CloudTrail event of the trail being stopped
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "cloudtrail.amazonaws.com", "StopLogging", map[string]interface{}{
+			"name": "stratus-red-team-cloudtrail-trail",
+		}),
+	}
+}
@@ -0,0 +1,23 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDetonatedWithDeletedTrail(t *testing.T) {
+	server := stratustest.NewAWSServer()
+	defer server.Close()
+	server.Respond("cloudtrail", "GetTrailStatus", 400, `{"__type": "TrailNotFoundException", "message": "Unknown trail"}`)
+	execution := stratus.NewExecutionContext(map[string]string{"cloudtrail_trail_name": "my-trail"})
+	execution.AWS = providers.NewAWSProviderFromConfig(server.Config())
+
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+	assert.True(t, server.Called("cloudtrail", "GetTrailStatus"))
+}
@@ -1,23 +1,24 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.organizations-leave",
@@ -43,22 +44,25 @@ Detonation:
 Any attempts from a child account to leave its AWS Organization should be considered suspicious. 
 
 Use the CloudTrail event <code>LeaveOrganization</code>.`,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"role_arn"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	roleArn := params["role_arn"]
+func detonate(execution *stratus.ExecutionContext) error {
+	roleArn := execution.Parameters["role_arn"]
 
-	awsConnection := providers.AWS().GetConnection()
+	awsConnection := execution.AWS.GetConnection()
 	stsClient := sts.NewFromConfig(awsConnection)
 	awsConnection.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleArn))
 	organizationsClient := organizations.NewFromConfig(awsConnection)
 
-	log.Println("Attempting to leave the AWS organization (will trigger an Access Denied error)")
+	execution.Logger.Println("Attempting to leave the AWS organization (will trigger an Access Denied error)")
 
-	_, err := organizationsClient.LeaveOrganization(context.Background(), &organizations.LeaveOrganizationInput{})
+	_, err := organizationsClient.LeaveOrganization(execution.Context, &organizations.LeaveOrganizationInput{})
 
 	if err == nil {
 		// We expected an error
@@ -70,6 +74,14 @@ func detonate(params map[string]string) error {
 		return errors.New("expected organizations:LeaveOrganization to return an access denied error, got instead: " + err.Error())
 	}
 
-	log.Println("Got an access denied error as expected")
+	execution.Logger.Println("Got an access denied error as expected")
 	return nil
 }
+
+// simulate generates the CloudTrail event of the denied attempt to leave the organization
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	event := logs.CloudTrailEvent(simulation, "organizations.amazonaws.com", "LeaveOrganization", nil)
+	return []stratus.LogRecord{
+		logs.CloudTrailError(event, "AccessDenied", "You don't have permissions to access this resource."),
+	}
+}
@@ -1,19 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.defense-evasion.vpc-remove-flow-logs",
@@ -38,20 +40,23 @@ Using CloudTrail's <code>DeleteFlowLogs</code> event.
 To reduce the risk of false positives related to VPC deletion in development environments, alerts can be raised
 only when <code>DeleteFlowLogs</code> is not closely followed by <code>DeleteVpc</code>.
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"vpc_id", "flow_logs_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
 
-	vpcId := params["vpc_id"]
-	flowLogsId := params["flow_logs_id"]
+	vpcId := execution.Parameters["vpc_id"]
+	flowLogsId := execution.Parameters["flow_logs_id"]
 
-	log.Println("Removing VPC Flow Logs " + flowLogsId + " in VPC " + vpcId)
+	execution.Logger.Println("Removing VPC Flow Logs " + flowLogsId + " in VPC " + vpcId)
 
-	_, err := ec2Client.DeleteFlowLogs(context.Background(), &ec2.DeleteFlowLogsInput{
+	_, err := ec2Client.DeleteFlowLogs(execution.Context, &ec2.DeleteFlowLogsInput{
 		FlowLogIds: []string{flowLogsId},
 	})
 	if err != nil {
@@ -63,3 +68,14 @@ func detonate(params map[string]string) error {
 
 // The technique is non-revertible once it has been detonated, otherwise it would require re-creating the VPC
 // flow log programmatically, which we don't want as it's implemented in the Terraform for the warm-up phase
+
+// simulate generates the CloudTrail event of the deletion of the flow logs
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "DeleteFlowLogs", map[string]interface{}{
+			"DeleteFlowLogsRequest": map[string]interface{}{
+				"FlowLogId": map[string]interface{}{"tag": 1, "content": "fl-" + utils.RandomHexString(8)},
+			},
+		}),
+	}
+}
@@ -1,25 +1,26 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
-	"log"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"strconv"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.discovery.ec2-download-user-data",
@@ -49,21 +50,24 @@ Through CloudTrail's <code>DescribeInstanceAttribute</code> event.
 See:
 
 * [Associated Sigma rule](https://github.com/SigmaHQ/sigma/blob/master/rules/cloud/aws/aws_ec2_download_userdata.yml)`,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Discovery},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"role_arn"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
 const numCalls = 15
 
-func detonate(params map[string]string) error {
+func detonate(execution *stratus.ExecutionContext) error {
 
-	awsConnection := providers.AWS().GetConnection()
+	awsConnection := execution.AWS.GetConnection()
 	stsClient := sts.NewFromConfig(awsConnection)
-	awsConnection.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, params["role_arn"]))
+	awsConnection.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, execution.Parameters["role_arn"]))
 	ec2Client := ec2.NewFromConfig(awsConnection)
 
 	for i := 0; i < numCalls; i++ {
@@ -72,13 +76,28 @@ func detonate(params map[string]string) error {
 
 		// Call DescribeInstanceAttribute to retrieve the userData attribute
 		// Expected Client.UnauthorizedOperation
-		ec2Client.DescribeInstanceAttribute(context.Background(), &ec2.DescribeInstanceAttributeInput{
+		ec2Client.DescribeInstanceAttribute(execution.Context, &ec2.DescribeInstanceAttributeInput{
 			Attribute:  types.InstanceAttributeNameUserData,
 			InstanceId: &instanceId,
 		})
 
-		log.Println("Running ec2:DescribeInstanceAttribute to retrieve userData on " + instanceId)
+		execution.Logger.Println("Running ec2:DescribeInstanceAttribute to retrieve userData on " + instanceId)
 	}
 
 	return nil
 }
+
+// simulate generates the CloudTrail events of the denied attempts to retrieve the user data of instances
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	identity := logs.AWSIdentity(simulation.AccountID, logs.AWSAssumedRoleArn(simulation.AccountID, "stratus-red-team-download-user-data-role", "aws-go-sdk-"+strconv.FormatInt(simulation.StartTime.Unix(), 10)))
+	var records []stratus.LogRecord
+	for i := 0; i < numCalls; i++ {
+		event := logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "DescribeInstanceAttribute", map[string]interface{}{
+			"instanceId": "i-" + utils.RandomHexString(8),
+			"attribute":  "userData",
+		})
+		event["userIdentity"] = identity
+		records = append(records, logs.CloudTrailError(event, "Client.UnauthorizedOperation", "You are not authorized to perform this operation."))
+	}
+	return records
+}
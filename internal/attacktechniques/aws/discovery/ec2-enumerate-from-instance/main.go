@@ -1,15 +1,14 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"strings"
 	"time"
 )
@@ -17,6 +16,9 @@ import (
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.discovery.ec2-enumerate-from-instance",
@@ -55,17 +57,20 @@ An action can be determined to have been performed by an EC2 instance under its
 arn:aws:sts::012345678901:assumed-role/my-instance-role/i-0adc17a5acb70d9ae
 </code>
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Discovery},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"instance_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ssmClient := ssm.NewFromConfig(providers.AWS().GetConnection())
-	instanceId := params["instance_id"]
+func detonate(execution *stratus.ExecutionContext) error {
+	ssmClient := ssm.NewFromConfig(execution.AWS.GetConnection())
+	instanceId := execution.Parameters["instance_id"]
 	commands := []string{
 		"aws sts get-caller-identity || true", // Note: we need the || true to ensure the command exits with status 0, even if the instance role doesn't have the permission
 		"aws s3 ls || true",
@@ -78,9 +83,9 @@ func detonate(params map[string]string) error {
 		"aws guardduty list-detectors || true",
 	}
 
-	log.Println("Running commands through SSM on " + instanceId + ":\n  - " + strings.Join(commands, "\n  - "))
+	execution.Logger.Println("Running commands through SSM on " + instanceId + ":\n  - " + strings.Join(commands, "\n  - "))
 
-	result, err := ssmClient.SendCommand(context.Background(), &ssm.SendCommandInput{
+	result, err := ssmClient.SendCommand(execution.Context, &ssm.SendCommandInput{
 		DocumentName: aws.String("AWS-RunShellScript"),
 		InstanceIds:  []string{instanceId},
 		Parameters: map[string][]string{
@@ -90,7 +95,7 @@ func detonate(params map[string]string) error {
 	if err != nil {
 		return errors.New("unable to send SSM command to instance: " + err.Error())
 	}
-	_, err = ssm.NewCommandExecutedWaiter(ssmClient).WaitForOutput(context.Background(), &ssm.GetCommandInvocationInput{
+	_, err = ssm.NewCommandExecutedWaiter(ssmClient).WaitForOutput(execution.Context, &ssm.GetCommandInvocationInput{
 		CommandId:  result.Command.CommandId,
 		InstanceId: &instanceId,
 	}, 2*time.Minute)
@@ -101,3 +106,34 @@ func detonate(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail events of the discovery commands run on the instance, with its role
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	instanceId := "i-" + utils.RandomHexString(8)
+	identity := logs.AWSIdentity(simulation.AccountID, logs.AWSAssumedRoleArn(simulation.AccountID, "stratus-red-team-ec2-enumerate-role", instanceId))
+	calls := [][]string{
+		{"sts.amazonaws.com", "GetCallerIdentity"},
+		{"s3.amazonaws.com", "ListBuckets"},
+		{"iam.amazonaws.com", "GetAccountSummary"},
+		{"iam.amazonaws.com", "ListRoles"},
+		{"iam.amazonaws.com", "ListUsers"},
+		{"iam.amazonaws.com", "GetAccountAuthorizationDetails"},
+		{"ec2.amazonaws.com", "DescribeSnapshots"},
+		{"cloudtrail.amazonaws.com", "DescribeTrails"},
+		{"guardduty.amazonaws.com", "ListDetectors"},
+	}
+	records := []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ssm.amazonaws.com", "SendCommand", map[string]interface{}{
+			"documentName": "AWS-RunShellScript",
+			"instanceIds":  []string{instanceId},
+			"parameters":   "HIDDEN_DUE_TO_SECURITY_REASONS",
+		}),
+	}
+	for _, call := range calls {
+		event := logs.CloudTrailEvent(simulation, call[0], call[1], nil)
+		event["userIdentity"] = identity
+		event["userAgent"] = "aws-cli/2.7.0 Python/3.9.11 Linux/5.10.102-99.473.amzn2.x86_64 exe/x86_64.amzn.2 prompt/off"
+		records = append(records, event)
+	}
+	return records
+}
@@ -1,7 +1,6 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -9,16 +8,20 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
+	"strconv"
 	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 const instanceType = types.InstanceTypeP2Xlarge
 const numInstances = 10
 
@@ -42,27 +45,30 @@ field will contain the instance type that was attempted to be launched.
 
 Depending on your account limits you might also see <code>VcpuLimitExceeded</code> error codes.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Execution},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"ami_id", "role_arn", "subnet_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ctx := context.Background()
-	awsConnection := providers.AWS().GetConnection()
+func detonate(execution *stratus.ExecutionContext) error {
+	ctx := execution.Context
+	awsConnection := execution.AWS.GetConnection()
 
-	amiId := params["ami_id"]
-	roleArn := params["role_arn"]
-	subnetId := params["subnet_id"]
+	amiId := execution.Parameters["ami_id"]
+	roleArn := execution.Parameters["role_arn"]
+	subnetId := execution.Parameters["subnet_id"]
 
 	stsClient := sts.NewFromConfig(awsConnection)
 	awsConnection.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient, roleArn))
 	ec2Client := ec2.NewFromConfig(awsConnection)
 
-	log.Printf("Attempting to run up to %d instances of type %s\n", numInstances, string(instanceType))
+	execution.Logger.Printf("Attempting to run up to %d instances of type %s\n", numInstances, string(instanceType))
 	_, err := ec2Client.RunInstances(ctx, &ec2.RunInstancesInput{
 		ImageId:  aws.String(amiId),
 		SubnetId: aws.String(subnetId),
@@ -82,7 +88,25 @@ func detonate(params map[string]string) error {
 		return errors.New("expected ec2:RunInstances to return an access denied error, got instead: " + err.Error())
 	}
 
-	log.Println("Got an access denied error as expected")
+	execution.Logger.Println("Got an access denied error as expected")
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the denied attempt to launch the instances
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	identity := logs.AWSIdentity(simulation.AccountID, logs.AWSAssumedRoleArn(simulation.AccountID, "stratus-red-team-ec2-launch-unusual-instances-role", "aws-go-sdk-"+strconv.FormatInt(simulation.StartTime.Unix(), 10)))
+	event := logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "RunInstances", map[string]interface{}{
+		"instancesSet": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"imageId": "ami-" + utils.RandomHexString(8), "minCount": 1, "maxCount": numInstances},
+			},
+		},
+		"instanceType": string(instanceType),
+		"subnetId":     "subnet-" + utils.RandomHexString(8),
+	})
+	event["userIdentity"] = identity
+	return []stratus.LogRecord{
+		logs.CloudTrailError(event, "Client.UnauthorizedOperation", "You are not authorized to perform this operation."),
+	}
+}
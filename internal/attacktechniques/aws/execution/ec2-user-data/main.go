@@ -1,22 +1,24 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"time"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 //go:embed malicious-user-data.sh
 var maliciousUserData []byte
 
@@ -54,25 +56,28 @@ When not possible to perform such correlation, alerting on the second event only
 expected that the user data of an EC2 instance changes often, especially with the popularity of immutable machine images,
 provisioned before instantiation.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Execution, mitreattack.PrivilegeEscalation},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"instance_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	instanceId := params["instance_id"]
+func detonate(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	instanceId := execution.Parameters["instance_id"]
 
-	err := stopInstance(instanceId)
+	err := stopInstance(execution, instanceId)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Injecting malicious user data")
-	_, err = ec2Client.ModifyInstanceAttribute(context.Background(), &ec2.ModifyInstanceAttributeInput{
+	execution.Logger.Println("Injecting malicious user data")
+	_, err = ec2Client.ModifyInstanceAttribute(execution.Context, &ec2.ModifyInstanceAttributeInput{
 		InstanceId: &instanceId,
 		UserData:   &types.BlobAttributeValue{Value: maliciousUserData},
 	})
@@ -80,12 +85,12 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to update user data: " + err.Error())
 	}
 
-	err = startInstance(instanceId)
+	err = startInstance(execution, instanceId)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Instance " + instanceId + " started, malicious script in user data has been executed")
+	execution.Logger.Println("Instance " + instanceId + " started, malicious script in user data has been executed")
 	return nil
 }
 
@@ -93,10 +98,10 @@ func detonate(params map[string]string) error {
 const maxWaitDuration = 2 * time.Minute
 
 // Stops an EC2 instance, and synchronously returns only when it is stopped
-func stopInstance(instanceId string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	log.Println("Stopping instance " + instanceId)
-	_, err := ec2Client.StopInstances(context.Background(), &ec2.StopInstancesInput{
+func stopInstance(execution *stratus.ExecutionContext, instanceId string) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	execution.Logger.Println("Stopping instance " + instanceId)
+	_, err := ec2Client.StopInstances(execution.Context, &ec2.StopInstancesInput{
 		InstanceIds: []string{instanceId},
 		Force:       aws.Bool(true),
 	})
@@ -104,13 +109,13 @@ func stopInstance(instanceId string) error {
 		return errors.New("unable to stop instance " + instanceId + ": " + err.Error())
 	}
 
-	log.Println("Waiting for instance to be stopped")
+	execution.Logger.Println("Waiting for instance to be stopped")
 	var stopOptions = func(options *ec2.InstanceStoppedWaiterOptions) {
 		options.MaxDelay = 2 * time.Second // retry every 2 seconds
 		options.MinDelay = 1 * time.Second
 	}
 	err = ec2.NewInstanceStoppedWaiter(ec2Client, stopOptions).Wait(
-		context.Background(),
+		execution.Context,
 		&ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}},
 		maxWaitDuration,
 	)
@@ -121,10 +126,10 @@ func stopInstance(instanceId string) error {
 }
 
 // Starts an EC2 instance, and synchronously returns only when it is running
-func startInstance(instanceId string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	log.Println("Starting instance")
-	_, err := ec2Client.StartInstances(context.Background(), &ec2.StartInstancesInput{
+func startInstance(execution *stratus.ExecutionContext, instanceId string) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	execution.Logger.Println("Starting instance")
+	_, err := ec2Client.StartInstances(execution.Context, &ec2.StartInstancesInput{
 		InstanceIds: []string{instanceId},
 	})
 	if err != nil {
@@ -136,7 +141,7 @@ func startInstance(instanceId string) error {
 		options.MinDelay = 1 * time.Second
 	}
 	err = ec2.NewInstanceRunningWaiter(ec2Client, startOptions).Wait(
-		context.Background(),
+		execution.Context,
 		&ec2.DescribeInstancesInput{InstanceIds: []string{instanceId}},
 		maxWaitDuration,
 	)
@@ -145,3 +150,24 @@ func startInstance(instanceId string) error {
 	}
 	return nil
 }
+
+// simulate generates the CloudTrail events of the instance being stopped, its user data modified, and started again
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	instanceId := "i-" + utils.RandomHexString(8)
+	instancesSet := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"instanceId": instanceId}},
+	}
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "StopInstances", map[string]interface{}{
+			"instancesSet": instancesSet,
+			"force":        false,
+		}),
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "ModifyInstanceAttribute", map[string]interface{}{
+			"instanceId": instanceId,
+			"userData":   "<sensitiveDataRemoved>",
+		}),
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "StartInstances", map[string]interface{}{
+			"instancesSet": instancesSet,
+		}),
+	}
+}
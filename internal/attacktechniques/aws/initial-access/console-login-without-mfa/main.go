@@ -4,11 +4,12 @@ import (
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
 	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -18,6 +19,9 @@ import (
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 
@@ -76,25 +80,28 @@ Sample CloudTrail event (redacted for clarity):
 }
 ` + codeBlock + `
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		PrerequisitesTerraformCode: tf,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.InitialAccess},
+		RequiredOutputs:            []string{"username", "account_id", "password"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
+func detonate(execution *stratus.ExecutionContext) error {
 	// The code to generate a 'ConsoleLogin' event programmatically was inspired from
 	// https://naikordian.github.io/blog/posts/brute-force-aws-console/
 	// courtesy of Naikordian (naikordian@protonmail.com)
 
 	// Build the HTTP request
-	request := buildHttpRequest(params)
-	log.Println("Performing a console login for user " + params["username"] + " in account " + params["account_id"])
+	request := buildHttpRequest(execution.Parameters).WithContext(execution.Context)
+	execution.Logger.Println("Performing a console login for user " + execution.Parameters["username"] + " in account " + execution.Parameters["account_id"])
 
 	// Perform the HTTP request
-	response, err := doHttpRequest(request)
+	response, err := doHttpRequest(execution, request)
 	if err != nil {
 		return err
 	}
@@ -107,7 +114,7 @@ func detonate(params map[string]string) error {
 
 	// AWS returns 'SUCCESS' or 'FAIL' in the 'state' key of the response JSON object
 	if jsonResponse["state"] == "SUCCESS" {
-		log.Println("Successfully performed a console login!")
+		execution.Logger.Println("Successfully performed a console login!")
 	} else {
 		return errors.New("unable to authenticate to the AWS Console (received a 'FAIL' response from the authentication endpoint)")
 	}
@@ -140,9 +147,12 @@ func buildHttpRequest(params map[string]string) *http.Request {
 }
 
 // doHttpRequest performs the HTTP request to the AWS console sign-in endpoint
-func doHttpRequest(request *http.Request) (*http.Response, error) {
-	// Send the HTTP request to simulate a console login
-	httpClient := http.Client{}
+func doHttpRequest(execution *stratus.ExecutionContext, request *http.Request) (*http.Response, error) {
+	// Send the HTTP request to simulate a console login, recorded as the ConsoleLogin call found in CloudTrail
+	username := execution.Parameters["username"]
+	httpClient := execution.AWS.GetHTTPClient(func(request *http.Request, response *http.Response) apitrace.Call {
+		return apitrace.Call{Service: "Signin", Operation: "ConsoleLogin", Resource: username}
+	})
 	res, err := httpClient.Do(request)
 	if err != nil {
 		return nil, errors.New("Unable to perform AWS Console login: " + err.Error())
@@ -172,3 +182,19 @@ func parseAwsResponse(response *http.Response) (map[string]interface{}, error) {
 
 	return parsedResponse, nil
 }
+
+// simulate generates the CloudTrail event of the console login of the IAM user, without MFA
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	event := logs.CloudTrailEvent(simulation, "signin.amazonaws.com", "ConsoleLogin", nil)
+	event["userIdentity"] = logs.AWSIdentity(simulation.AccountID, "arn:aws:iam::"+simulation.AccountID+":user/stratus-red-team-console-login-user")
+	event["eventType"] = "AwsConsoleSignIn"
+	event["readOnly"] = false
+	event["userAgent"] = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.60 Safari/537.36"
+	event["responseElements"] = map[string]interface{}{"ConsoleLogin": "Success"}
+	event["additionalEventData"] = map[string]interface{}{
+		"LoginTo":       "https://console.aws.amazon.com/console/home",
+		"MobileVersion": "No",
+		"MFAUsed":       "No",
+	}
+	return []stratus.LogRecord{event}
+}
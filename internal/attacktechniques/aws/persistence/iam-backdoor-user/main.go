@@ -1,18 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
+	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.persistence.iam-backdoor-user",
@@ -32,37 +35,40 @@ Detonation:
 Through CloudTrail's <code>CreateAccessKey</code> event. This event can hardly be considered suspicious by itself, unless
 correlated with other indicators.
 '`,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               false, // iam:CreateAccessKey can only be called twice (limit of 2 access keys per user)
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"user_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
-	userName := params["user_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+	userName := execution.Parameters["user_name"]
 
-	log.Println("Creating access key on legit IAM user to simulate backdoor")
-	result, err := iamClient.CreateAccessKey(context.Background(), &iam.CreateAccessKeyInput{
+	execution.Logger.Println("Creating access key on legit IAM user to simulate backdoor")
+	result, err := iamClient.CreateAccessKey(execution.Context, &iam.CreateAccessKeyInput{
 		UserName: &userName,
 	})
 	if err != nil {
 		return err
 	}
 
-	log.Println("Successfully created access key " + *result.AccessKey.AccessKeyId)
+	execution.Logger.Println("Successfully created access key " + *result.AccessKey.AccessKeyId)
 	return nil
 }
 
-func revert(params map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
-	userName := params["user_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+	userName := execution.Parameters["user_name"]
 
-	log.Println("Removing access key from IAM user " + userName)
-	result, err := iamClient.ListAccessKeys(context.Background(), &iam.ListAccessKeysInput{
+	execution.Logger.Println("Removing access key from IAM user " + userName)
+	result, err := iamClient.ListAccessKeys(execution.Context, &iam.ListAccessKeysInput{
 		UserName: &userName,
 	})
 	if err != nil {
@@ -71,15 +77,32 @@ func revert(params map[string]string) error {
 
 	for i := range result.AccessKeyMetadata {
 		accessKeyId := result.AccessKeyMetadata[i].AccessKeyId
-		log.Println("Removing access key " + *accessKeyId)
-		_, err := iamClient.DeleteAccessKey(context.Background(), &iam.DeleteAccessKeyInput{
+		execution.Logger.Println("Removing access key " + *accessKeyId)
+		_, err := iamClient.DeleteAccessKey(execution.Context, &iam.DeleteAccessKeyInput{
 			AccessKeyId: accessKeyId,
 			UserName:    &userName,
 		})
 		if err != nil {
-			log.Println("failed: " + err.Error())
+			execution.Logger.Println("failed: " + err.Error())
 		}
 	}
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the access key being created for the IAM user
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	userName := "stratus-red-team-backdoor-user"
+	event := logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "CreateAccessKey", map[string]interface{}{
+		"userName": userName,
+	})
+	event["responseElements"] = map[string]interface{}{
+		"accessKey": map[string]interface{}{
+			"userName":    userName,
+			"accessKeyId": "AKIA" + strings.ToUpper(utils.RandomString(16)),
+			"status":      "Active",
+			"createDate":  event["eventTime"],
+		},
+	}
+	return []stratus.LogRecord{event}
+}
@@ -1,18 +1,19 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
+//go:embed sigma.yml
+var sigma []byte
+
 var userName = aws.String("malicious-iam-user")
 var adminPolicyArn = aws.String("arn:aws:iam::aws:policy/AdministratorAccess")
 
@@ -39,19 +40,22 @@ can help to craft more precise detections:
 
 - Identify a call to <code>CreateUser</code> resulting in an access denied error.
 `,
+		SigmaRules:         sigma,
 		Platform:           stratus.AWS,
 		IsIdempotent:       false, // cannot create twice an IAM user with the same name
 		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
 		Detonate:           detonate,
+		Simulate:           simulate,
 		Revert:             revert,
+		IsDetonated:        isDetonated,
 	})
 }
 
-func detonate(map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
 
-	log.Println("Creating a malicious IAM user")
-	_, err := iamClient.CreateUser(context.Background(), &iam.CreateUserInput{
+	execution.Logger.Println("Creating a malicious IAM user")
+	_, err := iamClient.CreateUser(execution.Context, &iam.CreateUserInput{
 		UserName: userName,
 		Tags: []types.Tag{
 			{Key: aws.String("StratusRedTeam"), Value: aws.String("true")},
@@ -61,8 +65,8 @@ func detonate(map[string]string) error {
 		return err
 	}
 
-	log.Println("Attaching an administrative IAM policy to the malicious IAM user")
-	_, err = iamClient.AttachUserPolicy(context.Background(), &iam.AttachUserPolicyInput{
+	execution.Logger.Println("Attaching an administrative IAM policy to the malicious IAM user")
+	_, err = iamClient.AttachUserPolicy(execution.Context, &iam.AttachUserPolicyInput{
 		UserName:  userName,
 		PolicyArn: adminPolicyArn,
 	})
@@ -70,23 +74,23 @@ func detonate(map[string]string) error {
 		return err
 	}
 
-	log.Println("Creating an access key for the IAM user")
-	result, err := iamClient.CreateAccessKey(context.Background(), &iam.CreateAccessKeyInput{
+	execution.Logger.Println("Creating an access key for the IAM user")
+	result, err := iamClient.CreateAccessKey(execution.Context, &iam.CreateAccessKeyInput{
 		UserName: userName,
 	})
 	if err != nil {
 		return err
 	}
 
-	log.Println("Created access key " + *result.AccessKey.AccessKeyId)
+	execution.Logger.Println("Created access key " + *result.AccessKey.AccessKeyId)
 
 	return nil
 }
 
-func revert(map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
+func revert(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
 
-	result, err := iamClient.ListAccessKeys(context.Background(), &iam.ListAccessKeysInput{
+	result, err := iamClient.ListAccessKeys(execution.Context, &iam.ListAccessKeysInput{
 		UserName: userName,
 	})
 	if err != nil {
@@ -95,18 +99,18 @@ func revert(map[string]string) error {
 
 	for i := range result.AccessKeyMetadata {
 		accessKeyId := result.AccessKeyMetadata[i].AccessKeyId
-		_, err := iamClient.DeleteAccessKey(context.Background(), &iam.DeleteAccessKeyInput{
+		_, err := iamClient.DeleteAccessKey(execution.Context, &iam.DeleteAccessKeyInput{
 			UserName:    userName,
 			AccessKeyId: accessKeyId,
 		})
 		if err != nil {
 			return errors.New("unable to remove IAM user access key " + *accessKeyId + ": " + err.Error())
 		}
-		log.Println("Removed access key " + *accessKeyId)
+		execution.Logger.Println("Removed access key " + *accessKeyId)
 	}
 
-	log.Println("Detaching administrative policy")
-	_, err = iamClient.DetachUserPolicy(context.Background(), &iam.DetachUserPolicyInput{
+	execution.Logger.Println("Detaching administrative policy")
+	_, err = iamClient.DetachUserPolicy(execution.Context, &iam.DetachUserPolicyInput{
 		UserName:  userName,
 		PolicyArn: adminPolicyArn,
 	})
@@ -114,7 +118,40 @@ func revert(map[string]string) error {
 		return err
 	}
 
-	log.Println("Removing IAM user")
-	_, err = iamClient.DeleteUser(context.Background(), &iam.DeleteUserInput{UserName: userName})
+	execution.Logger.Println("Removing IAM user")
+	_, err = iamClient.DeleteUser(execution.Context, &iam.DeleteUserInput{UserName: userName})
 	return err
 }
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+
+	_, err := iamClient.GetUser(execution.Context, &iam.GetUserInput{UserName: userName})
+	if err != nil {
+		var noSuchEntity *types.NoSuchEntityException
+		if errors.As(err, &noSuchEntity) {
+			return false, nil
+		}
+		return false, errors.New("unable to retrieve IAM user: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// simulate generates the CloudTrail events of the IAM user being created, granted administrator permissions and
+// given an access key
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "CreateUser", map[string]interface{}{
+			"userName": *userName,
+			"tags":     []interface{}{map[string]interface{}{"key": "StratusRedTeam", "value": "true"}},
+		}),
+		logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "AttachUserPolicy", map[string]interface{}{
+			"userName":  *userName,
+			"policyArn": *adminPolicyArn,
+		}),
+		logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "CreateAccessKey", map[string]interface{}{
+			"userName": *userName,
+		}),
+	}
+}
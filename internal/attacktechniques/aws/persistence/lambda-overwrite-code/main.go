@@ -1,23 +1,25 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	"io/ioutil"
-	"log"
 	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.persistence.lambda-overwrite-code",
@@ -41,28 +43,31 @@ References:
 		Detection: `
 Through CloudTrail's <code>UpdateFunctionCode*</code> event, e.g. <code>UpdateFunctionCode20150331v2</code>.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"lambda_function_name", "bucket_name", "bucket_object_key"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	functionName := params["lambda_function_name"]
-	lambdaClient := lambda.NewFromConfig(providers.AWS().GetConnection())
+func detonate(execution *stratus.ExecutionContext) error {
+	functionName := execution.Parameters["lambda_function_name"]
+	lambdaClient := lambda.NewFromConfig(execution.AWS.GetConnection())
 	zip := "UEsDBAoDAAAAABGy0lRE4o1NOwAAADsAAAAJAAAAbGFtYmRhLnB5ZGVmIGxhbWJkYV9oYW5kbGVyKGUsIGMpOgogICAgcHJpbnQoIlN0cmF0dXMgc2F5cyBoZWxsbyEiKQpQSwECPwMKAwAAAAARstJUROKNTTsAAAA7AAAACQAkAAAAAAAAACCApIEAAAAAbGFtYmRhLnB5CgAgAAAAAAABABgAAL0yTlCD2AEA6mNPUIPYAQC9Mk5Qg9gBUEsFBgAAAAABAAEAWwAAAGIAAAAAAA=="
 
-	log.Println("Updating the code of Lambda function " + functionName)
+	execution.Logger.Println("Updating the code of Lambda function " + functionName)
 
 	zipFile, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding, strings.NewReader(zip)))
 	if err != nil {
 		return errors.New("unable to decode the payload to overwrite the code with: " + err.Error())
 	}
 
-	_, err = lambdaClient.UpdateFunctionCode(context.Background(), &lambda.UpdateFunctionCodeInput{
+	_, err = lambdaClient.UpdateFunctionCode(execution.Context, &lambda.UpdateFunctionCodeInput{
 		FunctionName: &functionName,
 		Publish:      true,
 		ZipFile:      zipFile,
@@ -76,15 +81,15 @@ func detonate(params map[string]string) error {
 }
 
 // revert to original unmodified lambda
-func revert(params map[string]string) error {
-	functionName := params["lambda_function_name"]
-	bucketName := params["bucket_name"]
-	bucketKey := params["bucket_object_key"]
-	lambdaClient := lambda.NewFromConfig(providers.AWS().GetConnection())
+func revert(execution *stratus.ExecutionContext) error {
+	functionName := execution.Parameters["lambda_function_name"]
+	bucketName := execution.Parameters["bucket_name"]
+	bucketKey := execution.Parameters["bucket_object_key"]
+	lambdaClient := lambda.NewFromConfig(execution.AWS.GetConnection())
 
-	log.Println("Reverting the code of the Lambda function " + functionName)
+	execution.Logger.Println("Reverting the code of the Lambda function " + functionName)
 
-	_, err := lambdaClient.UpdateFunctionCode(context.Background(), &lambda.UpdateFunctionCodeInput{
+	_, err := lambdaClient.UpdateFunctionCode(execution.Context, &lambda.UpdateFunctionCodeInput{
 		FunctionName: &functionName,
 		Publish:      true,
 		S3Bucket:     &bucketName,
@@ -97,3 +102,14 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the CloudTrail event of the code of the Lambda function being updated
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "lambda.amazonaws.com", "UpdateFunctionCode20150331v2", map[string]interface{}{
+			"functionName": "stratus-red-team-overwrite-f-" + utils.RandomString(8),
+			"publish":      true,
+			"dryRun":       false,
+		}),
+	}
+}
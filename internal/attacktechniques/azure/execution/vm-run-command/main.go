@@ -7,16 +7,18 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"time"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
@@ -82,25 +84,31 @@ Sample event (redacted for clarity):
 }
 ` + codeBlock + `
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.Azure,
 		IsSlow:                     true,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Execution},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"vm_instance_object_id", "vm_name", "resource_group_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	vmObjectId := params["vm_instance_object_id"]
-	vmName := params["vm_name"]
-	resourceGroup := params["resource_group_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	vmObjectId := execution.Parameters["vm_instance_object_id"]
+	vmName := execution.Parameters["vm_name"]
+	resourceGroup := execution.Parameters["resource_group_name"]
 
-	cred := providers.Azure().GetCredentials()
-	subscriptionID := providers.Azure().SubscriptionID
-	clientOptions := providers.Azure().ClientOptions
+	cred, err := execution.Azure.GetCredentials()
+	if err != nil {
+		return err
+	}
+	subscriptionID := execution.Azure.SubscriptionID
+	clientOptions := execution.Azure.GetClientOptions()
 
-	log.Println("Issuing Run Command for VM instance " + vmObjectId)
+	execution.Logger.Println("Issuing Run Command for VM instance " + vmObjectId)
 	vmClient, err := armcompute.NewVirtualMachinesClient(subscriptionID, cred, clientOptions)
 	runCommandInput := armcompute.RunCommandInput{
 		CommandID: to.Ptr("RunPowerShellScript"),
@@ -110,13 +118,13 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to instantiate Azure virtual machine client: " + err.Error())
 	}
 
-	commandCreation, err := vmClient.BeginRunCommand(context.Background(), resourceGroup, vmName, runCommandInput, nil)
+	commandCreation, err := vmClient.BeginRunCommand(execution.Context, resourceGroup, vmName, runCommandInput, nil)
 	if err != nil {
 		return errors.New("unable to run a command on the virtual machine: " + err.Error())
 	}
 
-	log.Println("Waiting for command to be run on the VM")
-	ctxWithTimeout, done := context.WithTimeout(context.Background(), 60*3*time.Second) // This can sometimes be quite slow
+	execution.Logger.Println("Waiting for command to be run on the VM")
+	ctxWithTimeout, done := context.WithTimeout(execution.Context, 60*3*time.Second) // This can sometimes be quite slow
 	defer done()
 	commandResult, err := commandCreation.PollUntilDone(ctxWithTimeout, &runtime.PollUntilDoneOptions{Frequency: 2 * time.Second})
 	if err != nil {
@@ -124,6 +132,14 @@ func detonate(params map[string]string) error {
 	}
 
 	_ = *commandResult.RunCommandResult.Value[0].Message // contains the output of the command executed
-	log.Println("Command successfully executed on the virtual machine")
+	execution.Logger.Println("Command successfully executed on the virtual machine")
 	return nil
 }
+
+// simulate generates the Activity log of the command being run on the virtual machine
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	resourceId := logs.AzureResourceID(simulation, "stratus-red-team-run-command", "Microsoft.Compute/virtualMachines/stratus-red-team-vm")
+	return []stratus.LogRecord{
+		logs.AzureActivityLog(simulation, "Microsoft.Compute/virtualMachines/runCommand/action", resourceId),
+	}
+}
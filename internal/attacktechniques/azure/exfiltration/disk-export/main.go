@@ -1,23 +1,24 @@
 package azure
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	"github.com/aws/smithy-go/ptr"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
-	"log"
 	"time"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
@@ -62,69 +63,83 @@ Sample event (redacted for clarity):
 }
 ` + codeBlock + `
 `,
+		SigmaRules:                 sigma,
 		Platform:                   stratus.Azure,
 		IsIdempotent:               true,
 		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Exfiltration},
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"disk_name", "resource_group_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	diskName := params["disk_name"]
-	disksClient, err := getAzureDisksClient()
+func detonate(execution *stratus.ExecutionContext) error {
+	diskName := execution.Parameters["disk_name"]
+	disksClient, err := getAzureDisksClient(execution)
 	if err != nil {
 		return errors.New("unable to instantiate Azure disks client: " + err.Error())
 	}
 
-	log.Println("Creating Shared Access Secret (SAS) URL for disk " + diskName)
+	execution.Logger.Println("Creating Shared Access Secret (SAS) URL for disk " + diskName)
 
 	readPermissions := armcompute.GrantAccessData{
 		Access:            to.Ptr(armcompute.AccessLevelRead),
 		DurationInSeconds: ptr.Int32(3600),
 	}
-	sharingTask, err := disksClient.BeginGrantAccess(context.Background(), params["resource_group_name"], diskName, readPermissions, nil)
+	sharingTask, err := disksClient.BeginGrantAccess(execution.Context, execution.Parameters["resource_group_name"], diskName, readPermissions, nil)
 	if err != nil {
 		return errors.New("unable to export disk: " + err.Error())
 	}
 
-	sharingResult, err := sharingTask.PollUntilDone(context.Background(), &runtime.PollUntilDoneOptions{Frequency: 1 * time.Second})
+	sharingResult, err := sharingTask.PollUntilDone(execution.Context, &runtime.PollUntilDoneOptions{Frequency: 1 * time.Second})
 	if err != nil {
 		return errors.New("disk export failed: " + err.Error())
 	}
 
 	exportUrl := *sharingResult.AccessSAS
-	log.Println("Successfully generated SAS URL for disk at " + exportUrl)
+	execution.Logger.Println("Successfully generated SAS URL for disk at " + exportUrl)
 	return nil
 }
 
-func revert(params map[string]string) error {
-	diskName := params["disk_name"]
-	disksClient, err := getAzureDisksClient()
+func revert(execution *stratus.ExecutionContext) error {
+	diskName := execution.Parameters["disk_name"]
+	disksClient, err := getAzureDisksClient(execution)
 	if err != nil {
 		return errors.New("unable to instantiate Azure disks client: " + err.Error())
 	}
 
-	log.Println("Creating Shared Access Secret (SAS) URL for disk " + diskName)
+	execution.Logger.Println("Creating Shared Access Secret (SAS) URL for disk " + diskName)
 
-	revokeTask, err := disksClient.BeginRevokeAccess(context.Background(), params["resource_group_name"], diskName, nil)
+	revokeTask, err := disksClient.BeginRevokeAccess(execution.Context, execution.Parameters["resource_group_name"], diskName, nil)
 	if err != nil {
 		return errors.New("unable to revoke access to disk: " + err.Error())
 	}
 
-	_, err = revokeTask.PollUntilDone(context.Background(), &runtime.PollUntilDoneOptions{Frequency: 1 * time.Second})
+	_, err = revokeTask.PollUntilDone(execution.Context, &runtime.PollUntilDoneOptions{Frequency: 1 * time.Second})
 	if err != nil {
 		return errors.New("revokation of disk access failed: " + err.Error())
 	}
 
-	log.Println("Successfully revoked SAS URL for disk " + diskName)
+	execution.Logger.Println("Successfully revoked SAS URL for disk " + diskName)
 	return nil
 }
 
-func getAzureDisksClient() (*armcompute.DisksClient, error) {
-	cred := providers.Azure().GetCredentials()
-	subscriptionID := providers.Azure().SubscriptionID
-	clientOptions := providers.Azure().ClientOptions
+func getAzureDisksClient(execution *stratus.ExecutionContext) (*armcompute.DisksClient, error) {
+	cred, err := execution.Azure.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
+	subscriptionID := execution.Azure.SubscriptionID
+	clientOptions := execution.Azure.GetClientOptions()
 	return armcompute.NewDisksClient(subscriptionID, cred, clientOptions)
 }
+
+// simulate generates the Activity log of the SAS URL of the disk being generated
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	resourceId := logs.AzureResourceID(simulation, "stratus-red-team-disk-export", "Microsoft.Compute/disks/stratus-red-team-disk")
+	return []stratus.LogRecord{
+		logs.AzureActivityLog(simulation, "Microsoft.Compute/disks/beginGetAccess/action", resourceId),
+	}
+}
@@ -0,0 +1,145 @@
+package attacktechniques
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/sigma"
+	"github.com/datadog/stratus-red-team/pkg/stratus/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTechniquesAreValid checks that "stratus validate" reports no error for the built-in techniques
+func TestTechniquesAreValid(t *testing.T) {
+	for _, issue := range validation.ValidateRegistry(stratus.GetRegistry()) {
+		if issue.Severity == validation.SeverityError {
+			t.Error(issue)
+		} else {
+			t.Log(issue)
+		}
+	}
+}
+
+// TestTechniquesShipSigmaRules checks that each built-in technique ships at least one Sigma rule. The rules themselves
+// are validated by TestTechniquesAreValid
+func TestTechniquesShipSigmaRules(t *testing.T) {
+	for _, technique := range stratus.GetRegistry().ListAttackTechniques() {
+		rules, err := sigma.Parse(technique.SigmaRules)
+		assert.Nil(t, err, technique.ID)
+		assert.NotEmpty(t, rules, technique.ID+" has no Sigma rule")
+	}
+}
+
+// Platforms whose built-in techniques must support simulation, i.e. whose logs "stratus simulate" generates
+var simulatedPlatforms = []stratus.Platform{stratus.AWS, stratus.Kubernetes, stratus.Azure}
+
+// TestTechniquesSimulationTriggersSigmaRules checks that the log records generated by the simulation of each technique
+// are valid JSON objects, and trigger each of its Sigma rules written against the same logs
+func TestTechniquesSimulationTriggersSigmaRules(t *testing.T) {
+	for _, technique := range stratus.GetRegistry().ListAttackTechniques() {
+		if technique.Simulate == nil {
+			for _, platform := range simulatedPlatforms {
+				assert.NotEqual(t, platform, technique.Platform, technique.ID+" does not support simulation")
+			}
+			continue
+		}
+
+		var records []map[string]interface{}
+		for _, record := range technique.Simulate(stratus.NewSimulationContext(technique.Platform, "")) {
+			raw, err := json.Marshal(record)
+			assert.Nil(t, err, technique.ID)
+			var decoded map[string]interface{}
+			assert.Nil(t, json.Unmarshal(raw, &decoded), technique.ID)
+			records = append(records, decoded)
+		}
+		if !assert.NotEmpty(t, records, technique.ID+" simulation generates no log record") {
+			continue
+		}
+
+		rules, _ := sigma.Parse(technique.SigmaRules)
+		for _, rule := range rules {
+			if rule.LogSource.Category != "" {
+				continue
+			}
+			triggered := false
+			for _, record := range records {
+				matched, err := rule.Matches(record)
+				assert.Nil(t, err, technique.ID)
+				triggered = triggered || matched
+			}
+			assert.True(t, triggered, "simulation of "+technique.ID+" does not trigger the Sigma rule "+rule.Title)
+		}
+	}
+}
+
+var (
+	techniqueIdDefinition = regexp.MustCompile(`\bID:\s+"([^"]+)"`)
+	outputRead            = regexp.MustCompile(`(?:\.Parameters|\bparams)\["([^"]+)"\]`)
+)
+
+// TestTechniquesSourceCode checks that the directory of each technique matches its ID, and that its required outputs
+// are the ones read in its source code
+func TestTechniquesSourceCode(t *testing.T) {
+	mainFiles, err := filepath.Glob(filepath.Join("*", "*", "*", "main.go"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, mainFiles)
+
+	for _, mainFile := range mainFiles {
+		directory := filepath.Dir(mainFile)
+		sourceCode := readSourceCode(t, directory)
+		match := techniqueIdDefinition.FindStringSubmatch(sourceCode)
+		if !assert.NotNil(t, match, "no technique ID found in "+directory) {
+			continue
+		}
+		id := match[1]
+
+		// e.g. aws/defense-evasion/cloudtrail-stop for aws.defense-evasion.cloudtrail-stop
+		assert.Equal(t, strings.ReplaceAll(id, ".", "/"), filepath.ToSlash(directory), "directory of "+id)
+
+		technique := stratus.GetRegistry().GetAttackTechniqueByName(id)
+		if !assert.NotNil(t, technique, id+" is not registered") {
+			continue
+		}
+		var readOutputs []string
+		for _, read := range outputRead.FindAllStringSubmatch(sourceCode, -1) {
+			readOutputs = append(readOutputs, read[1])
+		}
+		assert.ElementsMatch(t, unique(readOutputs), technique.RequiredOutputs, "required outputs of "+id)
+	}
+}
+
+// readSourceCode returns the concatenated Go code of a technique, excluding tests
+func readSourceCode(t *testing.T, directory string) string {
+	files, err := filepath.Glob(filepath.Join(directory, "*.go"))
+	assert.Nil(t, err)
+	sort.Strings(files)
+
+	var sourceCode strings.Builder
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		sourceCode.Write(content)
+	}
+	return sourceCode.String()
+}
+
+func unique(values []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
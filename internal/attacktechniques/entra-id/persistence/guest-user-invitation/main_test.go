@@ -0,0 +1,63 @@
+package entraid
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/internal/graph/graphtest"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuestUserInvitation(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+
+	memberId := server.Add("users", graph.User{Mail: "member@contoso.com", UserType: "Member"})
+	groupId := server.Add("groups", map[string]string{"displayName": "group"})
+	execution.Parameters = map[string]string{"group_object_id": groupId}
+
+	assert.Nil(t, detonate(execution))
+	var guests []graph.User
+	server.List("users", &guests)
+	assert.Len(t, guests, 2)
+	guest := guests[1]
+	assert.Equal(t, guestEmail, guest.Mail)
+	assert.Equal(t, "Guest", guest.UserType)
+	var members []graph.User
+	server.List("groups/"+groupId+"/members", &members)
+	assert.Equal(t, []graph.User{{Id: guest.Id}}, members)
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, revert(execution))
+	assert.False(t, server.Get("users", guest.Id, &graph.User{}))
+	assert.True(t, server.Get("users", memberId, &graph.User{}))
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
+
+func TestGuestUserInvitationIsIdempotent(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+	groupId := server.Add("groups", map[string]string{"displayName": "group"})
+	execution.Parameters = map[string]string{"group_object_id": groupId}
+
+	assert.Nil(t, detonate(execution))
+	assert.Nil(t, detonate(execution))
+	var guests []graph.User
+	server.List("users", &guests)
+	assert.Len(t, guests, 1)
+	var members []graph.User
+	server.List("groups/"+groupId+"/members", &members)
+	assert.Equal(t, []graph.User{{Id: guests[0].Id}}, members)
+}
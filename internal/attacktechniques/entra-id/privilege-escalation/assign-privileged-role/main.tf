@@ -0,0 +1,33 @@
+terraform {
+  required_providers {
+    azuread = {
+      source  = "hashicorp/azuread"
+      version = "2.26.1"
+    }
+  }
+}
+
+provider "azuread" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "azuread_application" "application" {
+  display_name = "stratus-red-team-application-${random_string.suffix.result}"
+}
+
+resource "azuread_service_principal" "service_principal" {
+  application_id = azuread_application.application.application_id
+}
+
+output "service_principal_object_id" {
+  value = azuread_service_principal.service_principal.object_id
+}
+
+output "display" {
+  value = format("Service principal %s is ready", azuread_service_principal.service_principal.display_name)
+}
@@ -0,0 +1,42 @@
+package entraid
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/internal/graph/graphtest"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignPrivilegedRole(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+	execution := stratus.NewExecutionContext(nil)
+	execution.EntraID = &providers.EntraIDProvider{}
+	server.Use(t, execution.EntraID)
+
+	principalId := server.Add("servicePrincipals", graph.ServicePrincipal{DisplayName: "app"})
+	otherAssignment := graph.UnifiedRoleAssignment{PrincipalId: "someone-else", RoleDefinitionId: graph.GlobalAdministratorRoleId, DirectoryScopeId: "/"}
+	otherAssignment.Id = server.Add("roleManagement/directory/roleAssignments", otherAssignment)
+	execution.Parameters = map[string]string{"service_principal_object_id": principalId}
+
+	assert.Nil(t, detonate(execution))
+	var roleAssignments []graph.UnifiedRoleAssignment
+	server.List("roleManagement/directory/roleAssignments", &roleAssignments)
+	assert.Len(t, roleAssignments, 2)
+	assert.Equal(t, principalId, roleAssignments[1].PrincipalId)
+	assert.Equal(t, graph.GlobalAdministratorRoleId, roleAssignments[1].RoleDefinitionId)
+	assert.Equal(t, "/", roleAssignments[1].DirectoryScopeId)
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, revert(execution))
+	server.List("roleManagement/directory/roleAssignments", &roleAssignments)
+	assert.Equal(t, []graph.UnifiedRoleAssignment{otherAssignment}, roleAssignments)
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
@@ -0,0 +1,139 @@
+package gcp
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/logging/v2"
+	"google.golang.org/api/storage/v1"
+	"net/http"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:                 "gcp.defense-evasion.delete-logging-sink",
+		FriendlyName:       "Delete a GCP Log Sink",
+		Platform:           stratus.GCP,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.DefenseEvasion},
+		Description: `
+Deletes a GCP log sink. Simulates an attacker disrupting the export of audit logs.
+
+Warm-up:
+
+- Create a GCS bucket
+- Create a log sink exporting logs to the bucket
+
+Detonation:
+
+- Delete the log sink
+`,
+		Detection: `
+Using GCP Admin Activity audit logs, through the <code>google.logging.v2.ConfigServiceV2.DeleteSink</code> event.
+`,
+		SigmaRules:                 sigma,
+		IsIdempotent:               false, // can't delete a sink twice
+		Version:                    2,
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"sink_name", "sink_filter", "bucket_name"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+// Role allowing the writer identity of the sink to export logs to the bucket
+const sinkWriterRole = "roles/storage.objectCreator"
+
+func detonate(execution *stratus.ExecutionContext) error {
+	sinkName := execution.Parameters["sink_name"]
+	loggingClient, err := logging.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP Logging client: " + err.Error())
+	}
+
+	execution.Logger.Println("Deleting log sink " + sinkName)
+	_, err = loggingClient.Projects.Sinks.Delete("projects/" + execution.GCP.GetProjectId() + "/sinks/" + sinkName).Do()
+	if err != nil {
+		return errors.New("unable to delete log sink: " + err.Error())
+	}
+
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	sinkName := execution.Parameters["sink_name"]
+	bucketName := execution.Parameters["bucket_name"]
+	loggingClient, err := logging.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP Logging client: " + err.Error())
+	}
+
+	execution.Logger.Println("Re-creating log sink " + sinkName)
+	sink := &logging.LogSink{
+		Name:        sinkName,
+		Destination: "storage.googleapis.com/" + bucketName,
+		Filter:      execution.Parameters["sink_filter"],
+	}
+	sink, err = loggingClient.Projects.Sinks.Create("projects/"+execution.GCP.GetProjectId(), sink).UniqueWriterIdentity(true).Do()
+	if err != nil {
+		return errors.New("unable to re-create log sink: " + err.Error())
+	}
+
+	// The re-created sink may have a new writer identity, which needs to be allowed to write to the bucket
+	execution.Logger.Println("Allowing " + sink.WriterIdentity + " to export logs to bucket " + bucketName)
+	if err := grantSinkWriter(execution, bucketName, sink.WriterIdentity); err != nil {
+		return errors.New("unable to allow the log sink to write to its bucket: " + err.Error())
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	loggingClient, err := logging.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return false, errors.New("unable to instantiate the GCP Logging client: " + err.Error())
+	}
+
+	_, err = loggingClient.Projects.Sinks.Get("projects/" + execution.GCP.GetProjectId() + "/sinks/" + execution.Parameters["sink_name"]).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, errors.New("unable to retrieve log sink: " + err.Error())
+	}
+	return false, nil
+}
+
+// grantSinkWriter grants the role allowing to write logs to a bucket to the writer identity of a sink, if it does not
+// have it already
+func grantSinkWriter(execution *stratus.ExecutionContext, bucketName string, writerIdentity string) error {
+	storageClient, err := storage.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP Storage client: " + err.Error())
+	}
+
+	policy, err := storageClient.Buckets.GetIamPolicy(bucketName).Do()
+	if err != nil {
+		return err
+	}
+	for _, binding := range policy.Bindings {
+		if binding.Role != sinkWriterRole {
+			continue
+		}
+		for _, member := range binding.Members {
+			if member == writerIdentity {
+				return nil
+			}
+		}
+	}
+	policy.Bindings = append(policy.Bindings, &storage.PolicyBindings{Role: sinkWriterRole, Members: []string{writerIdentity}})
+	_, err = storageClient.Buckets.SetIamPolicy(bucketName, policy).Do()
+	return err
+}
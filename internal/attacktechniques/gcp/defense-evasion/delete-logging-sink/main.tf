@@ -0,0 +1,54 @@
+terraform {
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = "4.28.0"
+    }
+  }
+}
+
+# The project is set through the GOOGLE_PROJECT environment variable
+provider "google" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "google_storage_bucket" "bucket" {
+  name                        = "stratus-red-team-logs-${random_string.suffix.result}"
+  location                    = "US"
+  force_destroy               = true
+  uniform_bucket_level_access = true
+}
+
+resource "google_logging_project_sink" "sink" {
+  name                   = "stratus-red-team-sink-${random_string.suffix.result}"
+  destination            = "storage.googleapis.com/${google_storage_bucket.bucket.name}"
+  filter                 = "severity >= WARNING"
+  unique_writer_identity = true
+}
+
+resource "google_storage_bucket_iam_member" "sink_writer" {
+  bucket = google_storage_bucket.bucket.name
+  role   = "roles/storage.objectCreator"
+  member = google_logging_project_sink.sink.writer_identity
+}
+
+output "sink_name" {
+  value = google_logging_project_sink.sink.name
+}
+
+output "sink_filter" {
+  value = google_logging_project_sink.sink.filter
+}
+
+output "bucket_name" {
+  value = google_storage_bucket.bucket.name
+}
+
+output "display" {
+  value = format("Logging sink %s is ready", google_logging_project_sink.sink.name)
+}
@@ -0,0 +1,118 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/logging/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/api/storage/v1"
+)
+
+// fakeGCP serves the log sinks of a project and the IAM policy of a bucket
+type fakeGCP struct {
+	lock         sync.Mutex
+	sinks        map[string]*logging.LogSink
+	bucketPolicy *storage.Policy
+}
+
+func (m *fakeGCP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	const sinksPath = "/v2/projects/my-project/sinks"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == sinksPath:
+		var sink logging.LogSink
+		json.NewDecoder(r.Body).Decode(&sink)
+		if r.URL.Query().Get("uniqueWriterIdentity") == "true" {
+			sink.WriterIdentity = "serviceAccount:new-writer@gcp-sa-logging.iam.gserviceaccount.com"
+		}
+		m.sinks[sink.Name] = &sink
+		json.NewEncoder(w).Encode(sink)
+	case strings.HasPrefix(r.URL.Path, sinksPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, sinksPath+"/")
+		sink, found := m.sinks[name]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": 404, "message": "sink not found"}}`))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(m.sinks, name)
+			w.Write([]byte(`{}`))
+			return
+		}
+		json.NewEncoder(w).Encode(sink)
+	case r.URL.Path == "/b/my-bucket/iam" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(m.bucketPolicy)
+	case r.URL.Path == "/b/my-bucket/iam" && r.Method == http.MethodPut:
+		m.bucketPolicy = &storage.Policy{}
+		json.NewDecoder(r.Body).Decode(m.bucketPolicy)
+		json.NewEncoder(w).Encode(m.bucketPolicy)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestDeleteLoggingSink(t *testing.T) {
+	fake := &fakeGCP{
+		sinks: map[string]*logging.LogSink{"my-sink": {
+			Name:           "my-sink",
+			Destination:    "storage.googleapis.com/my-bucket",
+			Filter:         "severity >= WARNING",
+			WriterIdentity: "serviceAccount:writer@gcp-sa-logging.iam.gserviceaccount.com",
+		}},
+		bucketPolicy: &storage.Policy{Bindings: []*storage.PolicyBindings{{
+			Role:    sinkWriterRole,
+			Members: []string{"serviceAccount:writer@gcp-sa-logging.iam.gserviceaccount.com"},
+		}}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	harness := stratustest.New(t, stratus.GetRegistry().GetAttackTechniqueByName("gcp.defense-evasion.delete-logging-sink"),
+		stratustest.WithOutputs(map[string]string{
+			"sink_name":   "my-sink",
+			"sink_filter": "severity >= WARNING",
+			"bucket_name": "my-bucket",
+		}),
+	)
+	harness.Execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+
+	assert.True(t, harness.AssertRevertUndoesDetonation())
+	sink := fake.sinks["my-sink"]
+	if assert.NotNil(t, sink) {
+		assert.Equal(t, "storage.googleapis.com/my-bucket", sink.Destination)
+		assert.Equal(t, "severity >= WARNING", sink.Filter)
+	}
+	// The new writer identity of the sink is allowed to write to the bucket
+	assert.Len(t, fake.bucketPolicy.Bindings, 2)
+	assert.Equal(t, sinkWriterRole, fake.bucketPolicy.Bindings[1].Role)
+	assert.Equal(t, []string{"serviceAccount:new-writer@gcp-sa-logging.iam.gserviceaccount.com"}, fake.bucketPolicy.Bindings[1].Members)
+}
+
+func TestDeleteLoggingSinkRevertKeepsExistingWriter(t *testing.T) {
+	fake := &fakeGCP{
+		sinks: map[string]*logging.LogSink{},
+		bucketPolicy: &storage.Policy{Bindings: []*storage.PolicyBindings{{
+			Role:    sinkWriterRole,
+			Members: []string{"serviceAccount:new-writer@gcp-sa-logging.iam.gserviceaccount.com"},
+		}}},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	execution := stratus.NewExecutionContext(map[string]string{"sink_name": "my-sink", "bucket_name": "my-bucket"})
+	execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+
+	assert.Nil(t, revert(execution))
+	assert.Contains(t, fake.sinks, "my-sink")
+	assert.Len(t, fake.bucketPolicy.Bindings, 1)
+}
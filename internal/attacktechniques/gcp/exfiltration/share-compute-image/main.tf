@@ -0,0 +1,48 @@
+terraform {
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = "4.28.0"
+    }
+  }
+}
+
+# The project is set through the GOOGLE_PROJECT environment variable
+provider "google" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "google_compute_disk" "disk" {
+  name = "stratus-red-team-disk-${random_string.suffix.result}"
+  type = "pd-standard"
+  zone = "us-central1-a"
+  size = 10
+}
+
+resource "google_compute_image" "image" {
+  name        = "stratus-red-team-image-${random_string.suffix.result}"
+  source_disk = google_compute_disk.disk.id
+}
+
+# Principal the image is shared with, standing in for an external attacker-controlled account
+resource "google_service_account" "external" {
+  account_id   = "stratus-red-team-sci-${random_string.suffix.result}"
+  display_name = "Stratus Red Team external principal for gcp.exfiltration.share-compute-image"
+}
+
+output "image_name" {
+  value = google_compute_image.image.name
+}
+
+output "external_principal" {
+  value = "serviceAccount:${google_service_account.external.email}"
+}
+
+output "display" {
+  value = format("Compute image %s is ready", google_compute_image.image.name)
+}
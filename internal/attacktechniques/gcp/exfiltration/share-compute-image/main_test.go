@@ -0,0 +1,61 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestShareComputeImage(t *testing.T) {
+	var lock sync.Mutex
+	policy := &compute.Policy{Bindings: []*compute.Binding{{Role: "roles/compute.admin", Members: []string{"user:admin@example.com"}}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/projects/my-project/global/images/my-image/getIamPolicy":
+			json.NewEncoder(w).Encode(policy)
+		case "/projects/my-project/global/images/my-image/setIamPolicy":
+			var request compute.GlobalSetPolicyRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			policy = request.Policy
+			json.NewEncoder(w).Encode(policy)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	const externalPrincipal = "serviceAccount:external@my-project.iam.gserviceaccount.com"
+	outputs := map[string]string{"image_name": "my-image", "external_principal": externalPrincipal}
+	harness := stratustest.New(t, stratus.GetRegistry().GetAttackTechniqueByName("gcp.exfiltration.share-compute-image"),
+		stratustest.WithOutputs(outputs),
+	)
+	harness.Execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+
+	assert.Nil(t, harness.Detonate())
+	assert.Equal(t, []*compute.Binding{
+		{Role: "roles/compute.admin", Members: []string{"user:admin@example.com"}},
+		{Role: imageUserRole, Members: []string{externalPrincipal}},
+	}, policy.Bindings)
+
+	// Detonating again does not share the image twice
+	assert.Nil(t, harness.Detonate())
+	assert.Equal(t, []string{externalPrincipal}, policy.Bindings[1].Members)
+
+	assert.Nil(t, harness.Revert())
+	assert.Equal(t, []string{"user:admin@example.com"}, policy.Bindings[0].Members)
+	assert.Empty(t, policy.Bindings[1].Members)
+	detonated, err := isDetonated(harness.Execution.WithOutputs(stratus.StringOutputs(outputs)))
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
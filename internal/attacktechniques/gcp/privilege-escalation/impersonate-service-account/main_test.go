@@ -0,0 +1,66 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+)
+
+func TestImpersonateServiceAccount(t *testing.T) {
+	expiration := time.Now().Add(tokenLifetime).UTC().Format(time.RFC3339)
+	var request iamcredentials.GenerateAccessTokenRequest
+	var requestPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&request)
+		json.NewEncoder(w).Encode(iamcredentials.GenerateAccessTokenResponse{AccessToken: "token", ExpireTime: expiration})
+	}))
+	defer server.Close()
+	execution := stratus.NewExecutionContext(map[string]string{"service_account_email": "sa@my-project.iam.gserviceaccount.com"})
+	execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+
+	assert.Nil(t, detonate(execution))
+	assert.Equal(t, "/v1/projects/-/serviceAccounts/sa@my-project.iam.gserviceaccount.com:generateAccessToken", requestPath)
+	assert.Equal(t, "600s", request.Lifetime)
+	assert.Equal(t, []string{"https://www.googleapis.com/auth/cloud-platform"}, request.Scope)
+	assert.Equal(t, expiration, execution.Parameters[tokenExpirationOutput])
+	assert.Contains(t, execution.Outputs, tokenExpirationOutput)
+
+	assert.Nil(t, revert(execution))
+}
+
+func TestImpersonateServiceAccountRevertWithoutExpiration(t *testing.T) {
+	execution := stratus.NewExecutionContext(map[string]string{"service_account_email": "sa@my-project.iam.gserviceaccount.com"})
+
+	// Detonated before the expiration of the token was persisted
+	assert.Nil(t, revert(execution))
+}
+
+func TestImpersonateServiceAccountStopsWaitingWhenCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error": {"code": 403, "message": "permission denied"}}`))
+	}))
+	defer server.Close()
+	execution := stratus.NewExecutionContext(map[string]string{"service_account_email": "sa@my-project.iam.gserviceaccount.com"})
+	execution.GCP = providers.NewGCPProviderFromOptions("my-project", option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/"))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	execution.Context = ctx
+
+	// The detonation does not wait for IAM permissions to propagate once cancelled
+	start := time.Now()
+	err := detonate(execution)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.Less(t, time.Since(start), propagationRetryInterval)
+}
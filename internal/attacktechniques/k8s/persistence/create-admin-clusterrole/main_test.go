@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/stratustest"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCreateAdminClusterRoleRevertsInDetonationNamespace(t *testing.T) {
+	technique := stratus.GetRegistry().GetAttackTechniqueByName("k8s.persistence.create-admin-clusterrole")
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stratus-red-team-serviceaccount-token", Namespace: "my-namespace"},
+		Data:       map[string][]byte{"token": []byte("token")},
+	}
+	harness := stratustest.New(t, technique, stratustest.WithKubernetesObjects(tokenSecret))
+	// Provision the token secret of the service account, as the token controller does
+	harness.Kubernetes.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		createdServiceAccount := action.(k8stesting.CreateAction).GetObject().(*corev1.ServiceAccount)
+		createdServiceAccount.Secrets = []corev1.ObjectReference{{Name: tokenSecret.Name}}
+		return false, nil, nil
+	})
+	harness.Execution.K8s = providers.NewK8sProvider(providers.K8sOptions{Namespace: "my-namespace"})
+	harness.Execution.K8s.SetClient(harness.Kubernetes)
+
+	assert.Nil(t, harness.Detonate())
+	_, err := harness.Kubernetes.CoreV1().ServiceAccounts("my-namespace").Get(harness.Execution.Context, serviceAccount.Name, metav1.GetOptions{})
+	assert.Nil(t, err)
+	crb, err := harness.Kubernetes.RbacV1().ClusterRoleBindings().Get(harness.Execution.Context, "stratus-red-team-crb", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "my-namespace", crb.Subjects[0].Namespace)
+
+	// Revert without selecting the namespace anymore, e.g. from another invocation of stratus
+	harness.Execution.K8s = providers.NewK8sProvider(providers.K8sOptions{})
+	harness.Execution.K8s.SetClient(harness.Kubernetes)
+	outputs, _ := harness.State.GetTerraformOutputs()
+	detonated, err := isDetonated(harness.Execution.WithOutputs(outputs))
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, harness.Revert())
+	_, err = harness.Kubernetes.CoreV1().ServiceAccounts("my-namespace").Get(harness.Execution.Context, serviceAccount.Name, metav1.GetOptions{})
+	assert.NotNil(t, err)
+	detonated, err = isDetonated(harness.Execution.WithOutputs(outputs))
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
+
+func TestCreateAdminClusterRoleDetonationNamespace(t *testing.T) {
+	execution := stratus.NewExecutionContext(nil)
+	execution.K8s = providers.NewK8sProvider(providers.K8sOptions{Namespace: "selected"})
+
+	// Detonated before the namespace was persisted
+	assert.Equal(t, "selected", getDetonationNamespace(execution))
+	execution.K8s = providers.NewK8sProvider(providers.K8sOptions{})
+	assert.Equal(t, defaultNamespace, getDetonationNamespace(execution))
+
+	outputs := stratus.StringOutputs(map[string]string{namespaceOutput: "persisted"})
+	assert.Equal(t, "persisted", getDetonationNamespace(execution.WithOutputs(outputs)))
+}
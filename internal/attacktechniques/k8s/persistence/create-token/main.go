@@ -1,19 +1,20 @@
 package kubernetes
 
 import (
-	"context"
 	_ "embed"
 	"errors"
 	"github.com/aws/smithy-go/ptr"
-	"github.com/datadog/stratus-red-team/internal/providers"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"log"
 	"time"
 )
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 
@@ -64,7 +65,9 @@ Notes:
 
 * AWS EKS caps the token lifetime to 1 hour, although the behavior is undocumented and not part of Kubernetes itself.
 `,
-		Detonate: detonate,
+		SigmaRules: sigma,
+		Detonate:   detonate,
+		Simulate:   simulate,
 	})
 }
 
@@ -82,17 +85,26 @@ var params = authenticationv1.TokenRequest{
 	},
 }
 
-func detonate(map[string]string) error {
-	client := providers.K8s().GetClient()
-	ctx := context.Background()
+func detonate(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
+	ctx := execution.Context
 
-	log.Println("Creating a long-lived token for the service account " + serviceAccountName + " in " + namespace)
+	execution.Logger.Println("Creating a long-lived token for the service account " + serviceAccountName + " in " + namespace)
 	result, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccountName, &params, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("unable to create token: " + err.Error())
 	}
 
 	token := result.Status.Token
-	log.Printf("Successfully created a long-lived token valid for the next %d years: \n%s\n", numYears, token)
+	execution.Logger.Printf("Successfully created a long-lived token valid for the next %d years: \n%s\n", numYears, token)
 	return nil
 }
+
+// simulate generates the audit event of the token being created for the service account
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	return []stratus.LogRecord{
+		logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+			Resource: "serviceaccounts", Namespace: namespace, Name: serviceAccountName, Subresource: "token",
+		}, &params),
+	}
+}
@@ -1,16 +1,14 @@
 package kubernetes
 
 import (
-	"context"
 	"errors"
 	"github.com/aws/smithy-go/ptr"
 	v1 "k8s.io/api/core/v1"
-	"log"
 
 	_ "embed"
-
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -18,6 +16,9 @@ import (
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
 		ID:                 "k8s.privilege-escalation.hostpath-volume",
@@ -42,35 +43,42 @@ Detonation:
 - Create a privileged busybox pod with the node root filesystem mounted at "/host" 
 	that reads "/etc/passwd" from the host filesystem
 `,
+		Detection: `
+Using Kubernetes API server audit logs, looking for pod creation events with a <code>hostPath</code> volume in
+<code>requestObject.spec.volumes[*]</code>, in particular when its path is <code>/</code> or a sensitive directory of the node.
+`,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"namespace"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 		Revert:                     revert,
 	})
 }
 
-func detonate(params map[string]string) error {
-	client := providers.K8s().GetClient()
-	namespace := params["namespace"]
+func detonate(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
+	namespace := execution.Parameters["namespace"]
 	podSpec := nodeRootPodSpec(namespace)
 
-	log.Println("Creating malicious pod " + podSpec.ObjectMeta.Name)
-	_, err := client.CoreV1().Pods(namespace).Create(context.Background(), podSpec, metav1.CreateOptions{})
+	execution.Logger.Println("Creating malicious pod " + podSpec.ObjectMeta.Name)
+	_, err := client.CoreV1().Pods(namespace).Create(execution.Context, podSpec, metav1.CreateOptions{})
 	if err != nil {
 		return errors.New("unable to create pod: " + err.Error())
 	}
 
-	log.Println("Pod " + podSpec.ObjectMeta.Name + " created in namespace " + namespace)
+	execution.Logger.Println("Pod " + podSpec.ObjectMeta.Name + " created in namespace " + namespace)
 	return nil
 }
 
-func revert(params map[string]string) error {
-	client := providers.K8s().GetClient()
-	namespace := params["namespace"]
+func revert(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
+	namespace := execution.Parameters["namespace"]
 	podSpec := nodeRootPodSpec(namespace)
 
-	log.Println("Removing malicious pod " + podSpec.ObjectMeta.Name)
+	execution.Logger.Println("Removing malicious pod " + podSpec.ObjectMeta.Name)
 	deleteOptions := metav1.DeleteOptions{GracePeriodSeconds: ptr.Int64(0)}
-	err := client.CoreV1().Pods(namespace).Delete(context.Background(), podSpec.ObjectMeta.Name, deleteOptions)
+	err := client.CoreV1().Pods(namespace).Delete(execution.Context, podSpec.ObjectMeta.Name, deleteOptions)
 	if err != nil {
 		return errors.New("unable to remove pod: " + err.Error())
 	}
@@ -117,3 +125,13 @@ func nodeRootPodSpec(namespace string) *v1.Pod {
 		},
 	}
 }
+
+// simulate generates the audit event of the pod mounting the root filesystem of its node being created
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	namespace := "stratus-red-team-" + utils.RandomString(8)
+	return []stratus.LogRecord{
+		logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+			Resource: "pods", Namespace: namespace,
+		}, nodeRootPodSpec(namespace)),
+	}
+}
@@ -2,25 +2,30 @@ package kubernetes
 
 import (
 	"context"
-	"crypto/tls"
 	_ "embed"
 	"errors"
 	"fmt"
-	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/logs"
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 	"io"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"log"
+	"k8s.io/client-go/rest"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 //go:embed main.tf
 var tf []byte
 
+//go:embed sigma.yml
+var sigma []byte
+
 func init() {
 	const codeBlock = "```"
 	const code = "`"
@@ -87,45 +92,48 @@ Consequently, alerting on ` + code + `objectRef.resource == "nodes" && objectRef
 Additionally, looking at the Kubelet API path that was proxied can help identify malicious activity (/runningpods in this example).
 See [kubeletctl](https://github.com/cyberark/kubeletctl/blob/master/pkg/api/constants.go) for an unofficial list of Kubelet API endpoints.
 `,
+		SigmaRules:                 sigma,
 		PrerequisitesTerraformCode: tf,
+		RequiredOutputs:            []string{"service_account_name", "service_account_namespace"},
 		Detonate:                   detonate,
+		Simulate:                   simulate,
 	})
 }
 
-func detonate(params map[string]string) error {
-	client := providers.K8s().GetClient()
-	serviceAccountName := params["service_account_name"]
-	serviceAccountNamespace := params["service_account_namespace"]
+func detonate(execution *stratus.ExecutionContext) error {
+	client := execution.K8s.GetClient()
+	serviceAccountName := execution.Parameters["service_account_name"]
+	serviceAccountNamespace := execution.Parameters["service_account_namespace"]
 
 	// Step 1: Get a service account token for our service account, which has "nodes/proxy" permissions
-	log.Println("Retrieving service account token for service account " + serviceAccountName)
-	authenticationToken, err := getServiceAccountToken(serviceAccountName, serviceAccountNamespace, client)
+	execution.Logger.Println("Retrieving service account token for service account " + serviceAccountName)
+	authenticationToken, err := getServiceAccountToken(execution.Context, serviceAccountName, serviceAccountNamespace, client)
 	if err != nil {
 		return err
 	}
 
 	// Step 2: Choose a node to proxy from
-	node, err := getRandomNodeName(client)
+	node, err := getRandomNodeName(execution.Context, client)
 	if err != nil {
 		return err
 	}
 
 	// Step 3: Proxy the request to the Kubelet through this node
-	log.Println("Using worker node '" + node + "' to proxy to the Kubelet API")
-	_, err = proxyKubeletRequest("/runningpods/", authenticationToken, node, client)
+	execution.Logger.Println("Using worker node '" + node + "' to proxy to the Kubelet API")
+	_, err = proxyKubeletRequest(execution, "/runningpods/", authenticationToken, node, client)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Successfully proxied a benign Kubelet API request through the worker node")
+	execution.Logger.Println("Successfully proxied a benign Kubelet API request through the worker node")
 	return nil
 }
 
 // Generates a service account token for a specific service account
-func getServiceAccountToken(serviceAccount string, namespace string, client *kubernetes.Clientset) (string, error) {
+func getServiceAccountToken(ctx context.Context, serviceAccount string, namespace string, client kubernetes.Interface) (string, error) {
 	tokenRequest := &authenticationv1.TokenRequest{}
 	options := metav1.CreateOptions{}
-	result, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(context.Background(), serviceAccount, tokenRequest, options)
+	result, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccount, tokenRequest, options)
 	if err != nil {
 		return "", errors.New("unable to retrieve service account token for " + serviceAccount + ": " + err.Error())
 	}
@@ -134,8 +142,8 @@ func getServiceAccountToken(serviceAccount string, namespace string, client *kub
 }
 
 // Returns the name of a worker node, no matter which one
-func getRandomNodeName(client *kubernetes.Clientset) (string, error) {
-	result, err := client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+func getRandomNodeName(ctx context.Context, client kubernetes.Interface) (string, error) {
+	result, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return "", errors.New("unable to list worker nodes: " + err.Error())
 	}
@@ -144,22 +152,21 @@ func getRandomNodeName(client *kubernetes.Clientset) (string, error) {
 
 // Uses the nodes proxy API to proxy a request through a node to hit the Kubelet
 // see https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.22/#-strong-proxy-operations-node-v1-core-strong-
-func proxyKubeletRequest(kubeletApiPath string, token string, node string, client *kubernetes.Clientset) (string, error) {
-	// Note: We have to use a raw HTTP request because it's not straightforward to create a new K8s API client from
-	// a static bearer token
-	config := providers.K8s().GetRestConfig()
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+func proxyKubeletRequest(execution *stratus.ExecutionContext, kubeletApiPath string, token string, node string, client kubernetes.Interface) (string, error) {
+	// Note: We use a raw HTTP request, authenticated with the service account token, to call the proxy endpoint
+	config, err := execution.K8s.GetRestConfigForToken(token)
+	if err != nil {
+		return "", errors.New("unable to build a Kubernetes client for the service account: " + err.Error())
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return "", errors.New("unable to build a Kubernetes client for the service account: " + err.Error())
 	}
-	apiServerUrl := fmt.Sprintf("%s/%s", config.Host, config.APIPath)
+	apiServerUrl := fmt.Sprintf("%s/%s", strings.TrimSuffix(config.Host, "/"), strings.TrimPrefix(config.APIPath, "/"))
 	endpointUrl := fmt.Sprintf("%sapi/v1/nodes/%s/proxy%s", apiServerUrl, node, kubeletApiPath)
-	req, _ := http.NewRequest("GET", endpointUrl, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("User-Agent", providers.StratusUserAgent)
+	req, _ := http.NewRequestWithContext(execution.Context, "GET", endpointUrl, nil)
 
-	log.Println("Performing request to " + endpointUrl)
+	execution.Logger.Println("Performing request to " + endpointUrl)
 	response, err := httpClient.Do(req)
 
 	if err != nil {
@@ -179,3 +186,24 @@ func proxyKubeletRequest(kubeletApiPath string, token string, node string, clien
 	return body, nil
 
 }
+
+// simulate generates the audit events of the token of the service account being created, and of the request to the
+// kubelet API being proxied through the API server with it
+func simulate(simulation *stratus.SimulationContext) []stratus.LogRecord {
+	serviceAccountNamespace := "stratus-red-team-" + utils.RandomString(8)
+	serviceAccountName := "stratus-red-team-nodes-proxy"
+	tokenRequest := logs.KubernetesAuditEvent(simulation, "create", logs.KubernetesObjectReference{
+		Resource: "serviceaccounts", Namespace: serviceAccountNamespace, Name: serviceAccountName, Subresource: "token",
+	}, nil)
+
+	nodeName := "ip-10-0-1-" + strconv.Itoa(10+rand.Intn(240)) + ".ec2.internal"
+	proxy := logs.KubernetesAuditEvent(simulation, "get", logs.KubernetesObjectReference{
+		Resource: "nodes", Name: nodeName, Subresource: "proxy",
+	}, nil)
+	proxy["requestURI"] = proxy["requestURI"].(string) + "/runningpods/"
+	proxy["user"] = map[string]interface{}{
+		"username": "system:serviceaccount:" + serviceAccountNamespace + ":" + serviceAccountName,
+		"groups":   []interface{}{"system:serviceaccounts", "system:serviceaccounts:" + serviceAccountNamespace, "system:authenticated"},
+	}
+	return []stratus.LogRecord{tokenRequest, proxy}
+}
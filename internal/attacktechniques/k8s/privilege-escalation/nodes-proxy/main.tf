@@ -7,12 +7,24 @@ terraform {
   }
 }
 
+variable "kubeconfig_path" {
+  description = "Kubeconfig to use, set by Stratus Red Team. Defaults to ~/.kube/config"
+  type        = string
+  default     = ""
+}
+
+variable "namespace" {
+  description = "Existing namespace to use, instead of creating a dedicated one"
+  type        = string
+  default     = ""
+}
+
 locals {
-  kubeconfig_path = pathexpand("~/.kube/config")
-  namespace = format("stratus-red-team-%s", random_string.suffix.result)
+  kubeconfig_path = var.kubeconfig_path != "" ? var.kubeconfig_path : pathexpand("~/.kube/config")
+  namespace = var.namespace != "" ? var.namespace : one(kubernetes_namespace.namespace[*].metadata[0].name)
 }
 
-# Use ~/.kube/config as a configuration file if it exists (with current context).
+# Use the kubeconfig provided by Stratus Red Team, or ~/.kube/config, as a configuration file if it exists (with current context).
 # Fallback to using in-cluster configuration
 # see https://registry.terraform.io/providers/hashicorp/kubernetes/latest/docs#authentication
 provider "kubernetes" {
@@ -25,14 +37,15 @@ resource "random_string" "suffix" {
 }
 
 resource "kubernetes_namespace" "namespace" {
+  count = var.namespace == "" ? 1 : 0
   metadata {
-    name   = local.namespace
+    name   = format("stratus-red-team-%s", random_string.suffix.result)
     labels = { "datadoghq.com/stratus-red-team" : true }
   }
 }
 
 output "namespace" {
-  value = kubernetes_namespace.namespace.metadata[0].name
+  value = local.namespace
 }
 
 resource "kubernetes_cluster_role" "clusterrole" {
@@ -50,7 +63,7 @@ resource "kubernetes_cluster_role" "clusterrole" {
 resource "kubernetes_service_account" "sa" {
   metadata {
     name = "stratus-red-team-node-proxy-sa"
-    namespace = kubernetes_namespace.namespace.metadata[0].name
+    namespace = local.namespace
   }
 }
 
@@ -82,6 +95,6 @@ output "display" {
   value = format(
     "K8s service account with node/proxy permission is ready: %s in namespace %s",
     kubernetes_service_account.sa.metadata[0].name,
-    kubernetes_namespace.namespace.metadata[0].name
+    local.namespace
   )
 }
\ No newline at end of file
@@ -7,12 +7,24 @@ terraform {
   }
 }
 
+variable "kubeconfig_path" {
+  description = "Kubeconfig to use, set by Stratus Red Team. Defaults to ~/.kube/config"
+  type        = string
+  default     = ""
+}
+
+variable "namespace" {
+  description = "Existing namespace to use, instead of creating a dedicated one"
+  type        = string
+  default     = ""
+}
+
 locals {
-  kubeconfig_path = pathexpand("~/.kube/config")
-  namespace = format("stratus-red-team-%s", random_string.suffix.result)
+  kubeconfig_path = var.kubeconfig_path != "" ? var.kubeconfig_path : pathexpand("~/.kube/config")
+  namespace = var.namespace != "" ? var.namespace : one(kubernetes_namespace.namespace[*].metadata[0].name)
 }
 
-# Use ~/.kube/config as a configuration file if it exists (with current context).
+# Use the kubeconfig provided by Stratus Red Team, or ~/.kube/config, as a configuration file if it exists (with current context).
 # Fallback to using in-cluster configuration
 # see https://registry.terraform.io/providers/hashicorp/kubernetes/latest/docs#authentication
 provider "kubernetes" {
@@ -25,16 +37,17 @@ resource "random_string" "suffix" {
 }
 
 resource "kubernetes_namespace" "namespace" {
+  count = var.namespace == "" ? 1 : 0
   metadata {
-    name   = local.namespace
+    name   = format("stratus-red-team-%s", random_string.suffix.result)
     labels = { "datadoghq.com/stratus-red-team" : true }
   }
 }
 
 output "namespace" {
-  value = kubernetes_namespace.namespace.metadata[0].name
+  value = local.namespace
 }
 
 output "display" {
-  value = format("Namespace %s ready", kubernetes_namespace.namespace.metadata[0].name)
+  value = format("Namespace %s ready", local.namespace)
 }
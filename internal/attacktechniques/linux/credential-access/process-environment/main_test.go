@@ -0,0 +1,35 @@
+package linux
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSensitiveVariables(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("/proc is only available on Linux")
+	}
+	cmd := exec.Command("sleep", "60")
+	cmd.Env = append(os.Environ(), "MY_API_TOKEN=secret", "NOT_INTERESTING=value")
+	assert.Nil(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	secrets, err := findSensitiveVariables()
+	assert.Nil(t, err)
+	assert.Contains(t, secrets[cmd.Process.Pid], "MY_API_TOKEN")
+	assert.NotContains(t, secrets[cmd.Process.Pid], "NOT_INTERESTING")
+}
+
+func TestIsSensitive(t *testing.T) {
+	assert.True(t, isSensitive("AWS_SECRET_ACCESS_KEY"))
+	assert.True(t, isSensitive("github_token"))
+	assert.True(t, isSensitive("GOOGLE_APPLICATION_CREDENTIALS"))
+	assert.False(t, isSensitive("HOME"))
+}
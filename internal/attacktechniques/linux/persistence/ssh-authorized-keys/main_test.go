@@ -0,0 +1,53 @@
+package linux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizedKeysAreRestored(t *testing.T) {
+	execution := stratus.NewExecutionContext(nil)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".ssh", "authorized_keys")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.Nil(t, os.WriteFile(path, []byte("ssh-rsa AAAA legit"), 0600))
+
+	assert.Nil(t, detonate(execution))
+	authorizedKeys, _ := os.ReadFile(path)
+	assert.Equal(t, "ssh-rsa AAAA legit\n"+publicKey+"\n", string(authorizedKeys))
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	// The technique is idempotent
+	assert.Nil(t, detonate(execution))
+	authorizedKeys, _ = os.ReadFile(path)
+	assert.Equal(t, "ssh-rsa AAAA legit\n"+publicKey+"\n", string(authorizedKeys))
+
+	assert.Nil(t, revert(execution))
+	authorizedKeys, _ = os.ReadFile(path)
+	assert.Equal(t, "ssh-rsa AAAA legit", string(authorizedKeys))
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
+
+func TestAuthorizedKeysAreRemovedIfTheyDidNotExist(t *testing.T) {
+	execution := stratus.NewExecutionContext(nil)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".ssh", "authorized_keys")
+
+	assert.Nil(t, detonate(execution))
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.Nil(t, revert(execution))
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,153 @@
+package linux
+
+import (
+	_ "embed"
+	"errors"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+//go:embed sigma.yml
+var sigma []byte
+
+const unitName = "stratus-red-team.service"
+
+const payload = `#!/bin/sh
+# Benign payload used by Stratus Red Team
+echo "stratus-red-team" > /dev/null
+`
+
+func init() {
+	stratus.GetRegistry().RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:           "linux.persistence.systemd-unit",
+		FriendlyName: "Establish Persistence through a Systemd User Unit",
+		Description: `
+Establishes persistence by installing a systemd user unit, started each time the current user logs in.
+
+Warm-up:
+
+- Create a benign payload script in the home directory of the current user.
+
+Detonation:
+
+- Create the systemd user unit <code>~/.config/systemd/user/` + unitName + `</code>, running the payload script.
+- Enable the unit, by linking it in <code>~/.config/systemd/user/default.target.wants</code>.
+- Reload the systemd user manager, if it is running.
+
+Reverting the detonation removes the unit.
+`,
+		Detection: `
+Using file integrity monitoring or auditd rules on systemd unit directories, in particular
+<code>~/.config/systemd/user</code> and <code>/etc/systemd/system</code>.
+`,
+		SigmaRules:         sigma,
+		Platform:           stratus.Linux,
+		IsIdempotent:       true,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+		PrerequisitesHost: &stratus.HostPrerequisites{
+			Files: []stratus.HostFile{{Name: "payload_path", Path: "~/.stratus-red-team-systemd.sh", Content: []byte(payload), Mode: 0700}},
+		},
+		RequiredOutputs: []string{"payload_path"},
+		Detonate:        detonate,
+		Revert:          revert,
+		IsDetonated:     isDetonated,
+	})
+}
+
+// unitFiles returns the paths of the unit file and of the link enabling it
+func unitFiles(execution *stratus.ExecutionContext) (string, string, error) {
+	unitDirectory, err := execution.Linux.ExpandPath("~/.config/systemd/user")
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(unitDirectory, unitName), filepath.Join(unitDirectory, "default.target.wants", unitName), nil
+}
+
+func unit(payloadPath string) string {
+	return `[Unit]
+Description=Stratus Red Team
+
+[Service]
+Type=oneshot
+ExecStart=` + payloadPath + `
+
+[Install]
+WantedBy=default.target
+`
+}
+
+func detonate(execution *stratus.ExecutionContext) error {
+	unitFile, enableLink, err := unitFiles(execution)
+	if err != nil {
+		return err
+	}
+	linux := execution.Linux
+	if err := linux.BackupFile(unitFile); err != nil {
+		return err
+	}
+	if err := linux.BackupFile(enableLink); err != nil {
+		return err
+	}
+
+	execution.Logger.Println("Creating systemd user unit " + unitFile)
+	if err := os.MkdirAll(filepath.Dir(enableLink), 0755); err != nil {
+		return errors.New("unable to create systemd user unit directory: " + err.Error())
+	}
+	if err := os.WriteFile(unitFile, []byte(unit(execution.Parameters["payload_path"])), 0644); err != nil {
+		return errors.New("unable to create systemd user unit: " + err.Error())
+	}
+
+	execution.Logger.Println("Enabling the unit")
+	if err := os.Remove(enableLink); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("unable to enable systemd user unit: " + err.Error())
+	}
+	if err := os.Symlink(unitFile, enableLink); err != nil {
+		return errors.New("unable to enable systemd user unit: " + err.Error())
+	}
+
+	reloadSystemd(execution)
+	return nil
+}
+
+func revert(execution *stratus.ExecutionContext) error {
+	unitFile, enableLink, err := unitFiles(execution)
+	if err != nil {
+		return err
+	}
+
+	execution.Logger.Println("Removing systemd user unit " + unitFile)
+	if err := execution.Linux.RestoreFile(enableLink); err != nil {
+		return err
+	}
+	if err := execution.Linux.RestoreFile(unitFile); err != nil {
+		return err
+	}
+
+	reloadSystemd(execution)
+	return nil
+}
+
+func isDetonated(execution *stratus.ExecutionContext) (bool, error) {
+	_, enableLink, err := unitFiles(execution)
+	if err != nil {
+		return false, err
+	}
+	target, err := os.Readlink(enableLink)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("unable to read " + enableLink + ": " + err.Error())
+	}
+	return filepath.Base(target) == unitName, nil
+}
+
+// reloadSystemd makes the systemd user manager pick up unit changes. It is not running in most containers, in
+// which case the unit is only picked up at the next login
+func reloadSystemd(execution *stratus.ExecutionContext) {
+	if output, err := exec.Command("systemctl", "--user", "daemon-reload").CombinedOutput(); err != nil {
+		execution.Logger.Println("Unable to reload the systemd user manager, changes will be picked up at the next login: " + string(output))
+	}
+}
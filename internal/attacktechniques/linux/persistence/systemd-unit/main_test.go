@@ -0,0 +1,37 @@
+package linux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	execution := stratus.NewExecutionContext(map[string]string{"payload_path": "/home/user/.stratus-red-team-systemd.sh"})
+	unitFile := filepath.Join(home, ".config", "systemd", "user", unitName)
+	enableLink := filepath.Join(home, ".config", "systemd", "user", "default.target.wants", unitName)
+
+	assert.Nil(t, detonate(execution))
+	unitContent, err := os.ReadFile(unitFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(unitContent), "ExecStart=/home/user/.stratus-red-team-systemd.sh")
+	target, err := os.Readlink(enableLink)
+	assert.Nil(t, err)
+	assert.Equal(t, unitFile, target)
+	detonated, err := isDetonated(execution)
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	assert.Nil(t, revert(execution))
+	assert.NoFileExists(t, unitFile)
+	_, err = os.Lstat(enableLink)
+	assert.True(t, os.IsNotExist(err))
+	detonated, err = isDetonated(execution)
+	assert.Nil(t, err)
+	assert.False(t, detonated)
+}
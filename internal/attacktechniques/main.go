@@ -11,8 +11,8 @@ import (
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/defense-evasion/cloudtrail-stop"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/defense-evasion/organizations-leave"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/defense-evasion/vpc-remove-flow-logs"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/discovery/ec2-download-user-data"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/discovery/ec2-enumerate-from-instance"
-	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/discovery/ec2-get-user-data"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/execution/ec2-launch-unusual-instances"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/execution/ec2-user-data"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/aws/exfiltration/ec2-security-group-open-port-22-ingress"
@@ -31,6 +31,14 @@ import (
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/azure/execution/vm-custom-script-extension"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/azure/execution/vm-run-command"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/azure/exfiltration/disk-export"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/entra-id/persistence/backdoor-application-secret"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/entra-id/persistence/guest-user-invitation"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/entra-id/persistence/register-application-with-graph-permissions"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/entra-id/privilege-escalation/assign-privileged-role"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/gcp/defense-evasion/delete-logging-sink"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/gcp/exfiltration/share-compute-image"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/gcp/persistence/create-service-account-key"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/gcp/privilege-escalation/impersonate-service-account"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/credential-access/dump-secrets"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/credential-access/steal-serviceaccount-token"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/persistence/create-admin-clusterrole"
@@ -38,4 +46,9 @@ import (
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/privilege-escalation/hostpath-volume"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/privilege-escalation/nodes-proxy"
 	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/privilege-escalation/privileged-pod"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/linux/credential-access/aws-credentials-file"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/linux/credential-access/process-environment"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/linux/persistence/cron-job"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/linux/persistence/ssh-authorized-keys"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/linux/persistence/systemd-unit"
 )
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/internal/utils"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// ConfigPathEnvVarKey is the environment variable that can be used to override the path of the configuration file
+const ConfigPathEnvVarKey = "STRATUS_CONFIG_PATH"
+
+const DefaultConfigFileName = "config.yaml"
+
+// DefaultTechniquesDirectoryName is the directory, in $HOME/.stratus-red-team, from which declarative attack techniques
+// are loaded by default
+const DefaultTechniquesDirectoryName = "techniques"
+
+// DefaultPluginsDirectoryName is the directory, in $HOME/.stratus-red-team, from which attack technique plugins are
+// loaded by default
+const DefaultPluginsDirectoryName = "plugins"
+
+// Config is the configuration of Stratus Red Team, read from $HOME/.stratus-red-team/config.yaml
+// Command-line flags take precedence over values of the configuration file
+type Config struct {
+	Retry RetryConfig `json:"retry"`
+
+	// Directory containing declarative attack technique definitions
+	TechniquesDirectory string `json:"techniques_directory"`
+
+	Plugins PluginsConfig `json:"plugins"`
+
+	// External systems notified of the operations run on attack techniques
+	Notifiers []NotifierConfig `json:"notifiers"`
+
+	Tracing TracingConfig `json:"tracing"`
+
+	Metrics MetricsConfig `json:"metrics"`
+}
+
+// MetricsConfig is the configuration of the Prometheus metrics
+type MetricsConfig struct {
+	// Address to expose the /metrics endpoint on while Stratus Red Team runs, e.g. ":9090"
+	ListenAddress string `json:"listen_address"`
+
+	// File to write the metrics to, in the node-exporter textfile format
+	Textfile string `json:"textfile"`
+}
+
+// TracingConfig is the configuration of the export of OpenTelemetry traces
+type TracingConfig struct {
+	// OTLP/HTTP endpoint to export spans to, e.g. "localhost:4318" or "https://otel-collector.internal:4318"
+	OTLPEndpoint string `json:"otlp_endpoint"`
+
+	// File to write spans to, as JSON
+	File string `json:"file"`
+}
+
+// NotifierConfig is the configuration of an external system notified of the operations run on attack techniques
+type NotifierConfig struct {
+	// "webhook", "syslog" or "hec"
+	Type string `json:"type"`
+
+	// URL of the webhook or of the HTTP event collector
+	URL string `json:"url"`
+
+	// Additional HTTP headers sent to the webhook, e.g. for authentication
+	Headers map[string]string `json:"headers"`
+
+	// Address of the syslog server, e.g. "udp://localhost:514" or "tcp://syslog.internal:601"
+	Address string `json:"address"`
+
+	// Token of the HTTP event collector
+	Token string `json:"token"`
+
+	// Index, source and source type of the events sent to the HTTP event collector
+	Index      string `json:"index"`
+	Source     string `json:"source"`
+	SourceType string `json:"sourcetype"`
+
+	// Maximum time to deliver an event, e.g. "5s"
+	Timeout string `json:"timeout"`
+}
+
+type PluginsConfig struct {
+	// Directory containing attack technique plugin executables
+	Directory string `json:"directory"`
+
+	// Maximum time a plugin can take to detonate or revert an attack technique, e.g. "5m"
+	Timeout string `json:"timeout"`
+}
+
+type RetryConfig struct {
+	// Maximum number of attempts for each API call, including the initial one
+	MaxAttempts int `json:"max_attempts"`
+
+	// "standard" or "adaptive"
+	Mode string `json:"mode"`
+
+	// Maximum delay between two attempts, e.g. "20s"
+	MaxBackoff string `json:"max_backoff"`
+
+	// Maximum number of API calls per second and per provider
+	RateLimit float64 `json:"rate_limit"`
+
+	// Maximum number of API calls that can be made at once before the rate limit kicks in
+	RateLimitBurst int `json:"rate_limit_burst"`
+}
+
+// GetConfigPath returns the path of the configuration file
+func GetConfigPath() string {
+	if path := os.Getenv(ConfigPathEnvVarKey); path != "" {
+		return path
+	}
+	homeDirectory, _ := os.UserHomeDir()
+	return filepath.Join(homeDirectory, state.StratusStateDirectoryName, DefaultConfigFileName)
+}
+
+// GetTechniquesDirectory returns the directory from which declarative attack techniques are loaded
+func (m *Config) GetTechniquesDirectory() string {
+	if m.TechniquesDirectory != "" {
+		return m.TechniquesDirectory
+	}
+	homeDirectory, _ := os.UserHomeDir()
+	return filepath.Join(homeDirectory, state.StratusStateDirectoryName, DefaultTechniquesDirectoryName)
+}
+
+// GetPluginsDirectory returns the directory from which attack technique plugins are loaded
+func (m *Config) GetPluginsDirectory() string {
+	if m.Plugins.Directory != "" {
+		return m.Plugins.Directory
+	}
+	homeDirectory, _ := os.UserHomeDir()
+	return filepath.Join(homeDirectory, state.StratusStateDirectoryName, DefaultPluginsDirectoryName)
+}
+
+// Load reads the configuration file. A missing configuration file is not an error
+func Load() (*Config, error) {
+	path := GetConfigPath()
+	if !utils.FileExists(path) {
+		return &Config{}, nil
+	}
+
+	rawConfig, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("unable to read configuration file " + path + ": " + err.Error())
+	}
+	return Parse(rawConfig)
+}
+
+// Parse parses a YAML or JSON configuration
+func Parse(rawConfig []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(rawConfig, config); err != nil {
+		return nil, errors.New("invalid configuration file: " + err.Error())
+	}
+	return config, nil
+}
+
+// GetMaxBackoff returns the parsed maximum backoff, or 0 if not set
+func (m *RetryConfig) GetMaxBackoff() (time.Duration, error) {
+	if m.MaxBackoff == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(m.MaxBackoff)
+	if err != nil {
+		return 0, errors.New("invalid maximum backoff " + m.MaxBackoff + ": " + err.Error())
+	}
+	return duration, nil
+}
+
+// GetTimeout returns the parsed plugin timeout, or 0 if not set
+func (m *PluginsConfig) GetTimeout() (time.Duration, error) {
+	if m.Timeout == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(m.Timeout)
+	if err != nil {
+		return 0, errors.New("invalid plugin timeout " + m.Timeout + ": " + err.Error())
+	}
+	return duration, nil
+}
+
+// GetTimeout returns the parsed notifier timeout, or 0 if not set
+func (m *NotifierConfig) GetTimeout() (time.Duration, error) {
+	if m.Timeout == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(m.Timeout)
+	if err != nil {
+		return 0, errors.New("invalid notifier timeout " + m.Timeout + ": " + err.Error())
+	}
+	return duration, nil
+}
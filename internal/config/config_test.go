@@ -0,0 +1,111 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParsesConfiguration(t *testing.T) {
+	config, err := Parse([]byte(`
+retry:
+  max_attempts: 10
+  mode: adaptive
+  max_backoff: 1m
+  rate_limit: 2.5
+`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 10, config.Retry.MaxAttempts)
+	assert.Equal(t, "adaptive", config.Retry.Mode)
+	assert.Equal(t, 2.5, config.Retry.RateLimit)
+	maxBackoff, err := config.Retry.GetMaxBackoff()
+	assert.Nil(t, err)
+	assert.Equal(t, 1*time.Minute, maxBackoff)
+}
+
+func TestRejectsUnknownConfigurationKeys(t *testing.T) {
+	_, err := Parse([]byte(`retry: {max_retries: 3}`))
+	assert.NotNil(t, err)
+}
+
+func TestRejectsInvalidBackoff(t *testing.T) {
+	config, err := Parse([]byte(`retry: {max_backoff: forever}`))
+	assert.Nil(t, err)
+
+	_, err = config.Retry.GetMaxBackoff()
+	assert.NotNil(t, err)
+}
+
+func TestConfigPathCanBeOverridden(t *testing.T) {
+	t.Setenv(ConfigPathEnvVarKey, "/tmp/stratus.yaml")
+	assert.Equal(t, "/tmp/stratus.yaml", GetConfigPath())
+}
+
+func TestTechniquesDirectory(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	config, err := Parse([]byte(``))
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/user/.stratus-red-team/techniques", config.GetTechniquesDirectory())
+
+	config, err = Parse([]byte(`techniques_directory: /opt/techniques`))
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/techniques", config.GetTechniquesDirectory())
+}
+
+func TestPluginsConfig(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	config, err := Parse([]byte(``))
+	assert.Nil(t, err)
+	assert.Equal(t, "/home/user/.stratus-red-team/plugins", config.GetPluginsDirectory())
+	timeout, err := config.Plugins.GetTimeout()
+	assert.Nil(t, err)
+	assert.Zero(t, timeout)
+
+	config, err = Parse([]byte("plugins:\n  directory: /opt/plugins\n  timeout: 5m"))
+	assert.Nil(t, err)
+	assert.Equal(t, "/opt/plugins", config.GetPluginsDirectory())
+	timeout, err = config.Plugins.GetTimeout()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, timeout)
+
+	config, err = Parse([]byte("plugins:\n  timeout: soon"))
+	assert.Nil(t, err)
+	_, err = config.Plugins.GetTimeout()
+	assert.NotNil(t, err)
+}
+
+func TestNotifiersConfig(t *testing.T) {
+	config, err := Parse([]byte(`
+notifiers:
+  - type: webhook
+    url: https://hooks.example.com/stratus
+    headers:
+      Authorization: Bearer secret
+    timeout: 5s
+  - type: syslog
+    address: udp://localhost:514
+`))
+	assert.Nil(t, err)
+	assert.Len(t, config.Notifiers, 2)
+	assert.Equal(t, "webhook", config.Notifiers[0].Type)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer secret"}, config.Notifiers[0].Headers)
+	timeout, err := config.Notifiers[0].GetTimeout()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, timeout)
+	assert.Equal(t, "udp://localhost:514", config.Notifiers[1].Address)
+
+	_, err = Parse([]byte("notifiers:\n  - type: webhook\n    endpoint: https://hooks.example.com"))
+	assert.NotNil(t, err)
+}
+
+func TestMetricsConfig(t *testing.T) {
+	config, err := Parse([]byte(`
+metrics:
+  listen_address: ":9090"
+  textfile: /var/lib/node_exporter/textfile_collector/stratus.prom
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, ":9090", config.Metrics.ListenAddress)
+	assert.Equal(t, "/var/lib/node_exporter/textfile_collector/stratus.prom", config.Metrics.Textfile)
+}
@@ -0,0 +1,119 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rolesanywhere"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// AWS services that can be called from steps, by name
+var awsServices = map[string]func(aws.Config) interface{}{
+	"cloudtrail":     func(cfg aws.Config) interface{} { return cloudtrail.NewFromConfig(cfg) },
+	"ec2":            func(cfg aws.Config) interface{} { return ec2.NewFromConfig(cfg) },
+	"iam":            func(cfg aws.Config) interface{} { return iam.NewFromConfig(cfg) },
+	"lambda":         func(cfg aws.Config) interface{} { return lambda.NewFromConfig(cfg) },
+	"organizations":  func(cfg aws.Config) interface{} { return organizations.NewFromConfig(cfg) },
+	"rds":            func(cfg aws.Config) interface{} { return rds.NewFromConfig(cfg) },
+	"rolesanywhere":  func(cfg aws.Config) interface{} { return rolesanywhere.NewFromConfig(cfg) },
+	"s3":             func(cfg aws.Config) interface{} { return s3.NewFromConfig(cfg) },
+	"secretsmanager": func(cfg aws.Config) interface{} { return secretsmanager.NewFromConfig(cfg) },
+	"ssm":            func(cfg aws.Config) interface{} { return ssm.NewFromConfig(cfg) },
+	"sts":            func(cfg aws.Config) interface{} { return sts.NewFromConfig(cfg) },
+}
+
+// AWSStep calls an AWS API, e.g. cloudtrail:StopLogging
+type AWSStep struct {
+	// Name of the AWS service, e.g. cloudtrail
+	Service string `json:"service"`
+
+	// Name of the API call, e.g. StopLogging
+	Action string `json:"action"`
+
+	// Parameters of the API call, using the field names of the AWS API, e.g. {"Name": "my-trail"}
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+func (m *AWSStep) validate() error {
+	if _, found := awsServices[m.Service]; !found {
+		return errors.New("unsupported AWS service " + m.Service + ", supported services are " + strings.Join(supportedAwsServices(), ", "))
+	}
+	_, err := m.method(reflect.ValueOf(awsServices[m.Service](aws.Config{})))
+	return err
+}
+
+func (m *AWSStep) run(ctx context.Context, execution *stratus.ExecutionContext, data *templateData) error {
+	client := reflect.ValueOf(awsServices[m.Service](execution.AWS.GetConnection()))
+	method, err := m.method(client)
+	if err != nil {
+		return err
+	}
+	parameters, err := renderValue(m.Parameters, data)
+	if err != nil {
+		return err
+	}
+	input, err := buildInput(method.Type().In(1), parameters)
+	if err != nil {
+		return errors.New("invalid parameters for " + m.Service + ":" + m.Action + ": " + err.Error())
+	}
+
+	execution.Logger.Println("Calling " + m.Service + ":" + m.Action)
+	results := method.Call([]reflect.Value{reflect.ValueOf(ctx), input})
+	if err, _ := results[1].Interface().(error); err != nil {
+		return errors.New("unable to call " + m.Service + ":" + m.Action + ": " + err.Error())
+	}
+	return nil
+}
+
+// method returns the client method of the API call, with the signature func(context.Context, *Input, ...func(*Options)) (*Output, error)
+func (m *AWSStep) method(client reflect.Value) (reflect.Value, error) {
+	method := client.MethodByName(m.Action)
+	if !method.IsValid() || method.Type().NumIn() != 3 || method.Type().NumOut() != 2 || method.Type().In(1).Kind() != reflect.Ptr {
+		return reflect.Value{}, errors.New("unknown action " + m.Action + " for AWS service " + m.Service)
+	}
+	return method, nil
+}
+
+// buildInput converts parameters to the input structure of an API call, e.g. *cloudtrail.StopLoggingInput
+func buildInput(inputType reflect.Type, parameters interface{}) (reflect.Value, error) {
+	input := reflect.New(inputType.Elem())
+	if parameters == nil {
+		return input, nil
+	}
+	rawParameters, err := json.Marshal(parameters)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(rawParameters))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(input.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return input, nil
+}
+
+func supportedAwsServices() []string {
+	var services []string
+	for service := range awsServices {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
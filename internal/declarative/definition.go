@@ -0,0 +1,130 @@
+// Package declarative loads attack techniques defined in YAML or JSON files, instead of Go code
+package declarative
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"sigs.k8s.io/yaml"
+)
+
+// TechniqueDefinition is the declarative definition of an attack technique
+type TechniqueDefinition struct {
+	// Short identifier, e.g. aws.discovery.my-technique
+	ID string `json:"id"`
+
+	// Friendly-looking short name
+	Name string `json:"name"`
+
+	Description string `json:"description"`
+	Detection   string `json:"detection"`
+
+	// Platform of the technique, e.g. AWS
+	Platform string `json:"platform"`
+
+	// MITRE ATT&CK tactics, e.g. "Defense Evasion"
+	Tactics []string `json:"tactics"`
+
+	Idempotent bool `json:"idempotent"`
+	Slow       bool `json:"slow"`
+
+	// Version of the technique, to increment when its prerequisites change in a way that requires re-applying them
+	Version int `json:"version"`
+
+	// Terraform code of the prerequisites, either inline or in a file relative to the definition file
+	Terraform     string `json:"terraform"`
+	TerraformFile string `json:"terraform_file"`
+
+	// Steps executed in order to detonate the technique, and to revert its detonation
+	Detonate []Step `json:"detonate"`
+	Revert   []Step `json:"revert"`
+}
+
+// Parse parses a YAML or JSON technique definition
+func Parse(rawDefinition []byte) (*TechniqueDefinition, error) {
+	definition := &TechniqueDefinition{}
+	if err := yaml.UnmarshalStrict(rawDefinition, definition); err != nil {
+		return nil, errors.New("invalid technique definition: " + err.Error())
+	}
+	return definition, nil
+}
+
+// ToAttackTechnique validates the definition and converts it to an attack technique. Terraform files are resolved
+// relatively to baseDirectory
+func (m *TechniqueDefinition) ToAttackTechnique(baseDirectory string) (*stratus.AttackTechnique, error) {
+	if m.ID == "" {
+		return nil, errors.New("missing technique ID")
+	}
+	if m.Name == "" {
+		return nil, errors.New(m.ID + ": missing technique name")
+	}
+	platform, err := stratus.PlatformFromString(m.Platform)
+	if err != nil {
+		return nil, errors.New(m.ID + ": " + err.Error())
+	}
+	if len(m.Tactics) == 0 {
+		return nil, errors.New(m.ID + ": at least one MITRE ATT&CK tactic is required")
+	}
+	var tactics []mitreattack.Tactic
+	for _, name := range m.Tactics {
+		tactic, err := mitreattack.AttackTacticFromString(name)
+		if err != nil {
+			return nil, errors.New(m.ID + ": " + err.Error())
+		}
+		tactics = append(tactics, tactic)
+	}
+
+	if len(m.Detonate) == 0 {
+		return nil, errors.New(m.ID + ": at least one detonation step is required")
+	}
+	for i := range m.Detonate {
+		if err := m.Detonate[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: invalid detonation step %d: %s", m.ID, i+1, err)
+		}
+	}
+	for i := range m.Revert {
+		if err := m.Revert[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: invalid revert step %d: %s", m.ID, i+1, err)
+		}
+	}
+
+	technique := &stratus.AttackTechnique{
+		ID:                 m.ID,
+		FriendlyName:       m.Name,
+		Description:        m.Description,
+		Detection:          m.Detection,
+		IsSlow:             m.Slow,
+		Version:            m.Version,
+		IsIdempotent:       m.Idempotent,
+		MitreAttackTactics: tactics,
+		Platform:           platform,
+		RequiredOutputs:    requiredOutputs(m.Detonate, m.Revert),
+		Detonate:           runSteps(m.Detonate),
+	}
+	if len(m.Revert) > 0 {
+		technique.Revert = runSteps(m.Revert)
+	}
+
+	switch {
+	case m.Terraform != "" && m.TerraformFile != "":
+		return nil, errors.New(m.ID + ": terraform and terraform_file cannot both be set")
+	case m.Terraform != "":
+		technique.PrerequisitesTerraformCode = []byte(m.Terraform)
+	case m.TerraformFile != "":
+		terraformFile := m.TerraformFile
+		if !filepath.IsAbs(terraformFile) {
+			terraformFile = filepath.Join(baseDirectory, terraformFile)
+		}
+		code, err := os.ReadFile(terraformFile)
+		if err != nil {
+			return nil, errors.New(m.ID + ": unable to read Terraform file: " + err.Error())
+		}
+		technique.PrerequisitesTerraformCode = code
+	}
+
+	return technique, nil
+}
@@ -0,0 +1,97 @@
+package declarative
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+)
+
+const validDefinition = `
+id: aws.defense-evasion.custom-stop-trail
+name: Stop a CloudTrail Trail
+platform: AWS
+tactics: [Defense Evasion]
+description: Stops a trail
+detection: Through CloudTrail
+version: 2
+terraform_file: main.tf
+detonate:
+  - name: Stopping the trail
+    aws:
+      service: cloudtrail
+      action: StopLogging
+      parameters:
+        Name: "{{ .Outputs.trail_name }}"
+revert:
+  - aws:
+      service: cloudtrail
+      action: StartLogging
+      parameters:
+        Name: "{{ .Outputs.trail_name }}"
+`
+
+func TestParsesDefinition(t *testing.T) {
+	directory := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "main.tf"), []byte("# terraform"), 0600))
+
+	definition, err := Parse([]byte(validDefinition))
+	assert.Nil(t, err)
+	technique, err := definition.ToAttackTechnique(directory)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "aws.defense-evasion.custom-stop-trail", technique.ID)
+	assert.Equal(t, "Stop a CloudTrail Trail", technique.FriendlyName)
+	assert.Equal(t, stratus.Platform(stratus.AWS), technique.Platform)
+	assert.Equal(t, []mitreattack.Tactic{mitreattack.DefenseEvasion}, technique.MitreAttackTactics)
+	assert.Equal(t, []byte("# terraform"), technique.PrerequisitesTerraformCode)
+	assert.Equal(t, []string{"trail_name"}, technique.RequiredOutputs)
+	assert.NotNil(t, technique.Detonate)
+	assert.NotNil(t, technique.Revert)
+	assert.False(t, technique.IsIdempotent)
+	assert.Equal(t, 2, technique.Version)
+}
+
+func TestParsesJSONDefinition(t *testing.T) {
+	definition, err := Parse([]byte(`{
+		"id": "k8s.persistence.custom", "name": "Custom", "platform": "kubernetes", "tactics": ["Persistence"],
+		"terraform": "# inline terraform",
+		"detonate": [{"kubernetes": {"apply": "kind: ServiceAccount\nmetadata: {name: foo}"}}]
+	}`))
+	assert.Nil(t, err)
+	technique, err := definition.ToAttackTechnique("")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("# inline terraform"), technique.PrerequisitesTerraformCode)
+	assert.Nil(t, technique.Revert)
+}
+
+func TestRejectsInvalidDefinitions(t *testing.T) {
+	scenarios := map[string]string{
+		"unknown field":     `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{http: {url: "http://x"}}], unknown: true}`,
+		"missing ID":        `{name: Foo, platform: AWS, tactics: [Discovery], detonate: [{http: {url: "http://x"}}]}`,
+		"unknown platform":  `{id: foo, name: Foo, platform: Mainframe, tactics: [Discovery], detonate: [{http: {url: "http://x"}}]}`,
+		"unknown tactic":    `{id: foo, name: Foo, platform: AWS, tactics: [Relaxation], detonate: [{http: {url: "http://x"}}]}`,
+		"no tactic":         `{id: foo, name: Foo, platform: AWS, detonate: [{http: {url: "http://x"}}]}`,
+		"no step":           `{id: foo, name: Foo, platform: AWS, tactics: [Discovery]}`,
+		"empty step":        `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{name: nothing}]}`,
+		"two actions":       `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{http: {url: "http://x"}, kubernetes: {apply: x}}]}`,
+		"unknown service":   `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{aws: {service: mainframe, action: Foo}}]}`,
+		"unknown action":    `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{aws: {service: s3, action: DoesNotExist}}]}`,
+		"apply and delete":  `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{kubernetes: {apply: x, delete: y}}]}`,
+		"two terraforms":    `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], terraform: x, terraform_file: y, detonate: [{http: {url: "http://x"}}]}`,
+		"missing terraform": `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], terraform_file: missing.tf, detonate: [{http: {url: "http://x"}}]}`,
+		"invalid revert":    `{id: foo, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{http: {url: "http://x"}}], revert: [{http: {}}]}`,
+	}
+	for name, rawDefinition := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			definition, err := Parse([]byte(rawDefinition))
+			if err == nil {
+				_, err = definition.ToAttackTechnique(t.TempDir())
+			}
+			assert.NotNil(t, err)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package declarative
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// HTTPStep sends an HTTP request
+type HTTPStep struct {
+	// HTTP method, GET by default
+	Method string `json:"method"`
+
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+
+	// Expected status code of the response. By default, any 2xx status code is accepted
+	ExpectedStatus int `json:"expected_status"`
+}
+
+func (m *HTTPStep) validate() error {
+	if m.URL == "" {
+		return errors.New("missing URL")
+	}
+	return nil
+}
+
+func (m *HTTPStep) run(ctx context.Context, execution *stratus.ExecutionContext, data *templateData) error {
+	method := m.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	url, err := render(m.URL, data)
+	if err != nil {
+		return err
+	}
+	body, err := render(m.Body, data)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return errors.New("invalid HTTP request: " + err.Error())
+	}
+	request.Header.Set("User-Agent", providers.GetStratusUserAgent())
+	for name, value := range m.Headers {
+		renderedValue, err := render(value, data)
+		if err != nil {
+			return err
+		}
+		request.Header.Set(name, renderedValue)
+	}
+
+	execution.Logger.Println("Sending HTTP request " + request.Method + " " + url)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return errors.New("unable to send HTTP request: " + err.Error())
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if m.ExpectedStatus != 0 && response.StatusCode != m.ExpectedStatus {
+		return errors.New("unexpected HTTP status " + strconv.Itoa(response.StatusCode) + ", expected " + strconv.Itoa(m.ExpectedStatus))
+	}
+	if m.ExpectedStatus == 0 && (response.StatusCode < 200 || response.StatusCode >= 300) {
+		return errors.New("unexpected HTTP status " + strconv.Itoa(response.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package declarative
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// Field manager used when applying Kubernetes manifests
+const fieldManager = "stratus-red-team"
+
+// Namespace of namespaced objects when neither the manifest nor --kube-namespace specify one
+const defaultNamespace = "default"
+
+// KubernetesStep applies or deletes Kubernetes manifests. Multiple objects can be separated with ---
+type KubernetesStep struct {
+	Apply  string `json:"apply"`
+	Delete string `json:"delete"`
+}
+
+func (m *KubernetesStep) validate() error {
+	if (m.Apply == "") == (m.Delete == "") {
+		return errors.New("a Kubernetes step must have exactly one of apply or delete")
+	}
+	return nil
+}
+
+func (m *KubernetesStep) run(ctx context.Context, execution *stratus.ExecutionContext, data *templateData) error {
+	manifest, err := render(m.Apply+m.Delete, data)
+	if err != nil {
+		return err
+	}
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+
+	clientset := execution.K8s.GetClient()
+	client, err := dynamic.NewForConfig(execution.K8s.GetRestConfig())
+	if err != nil {
+		return errors.New("unable to create Kubernetes client: " + err.Error())
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	if m.Delete != "" {
+		// Delete objects in the reverse order of their creation
+		for i := len(objects) - 1; i >= 0; i-- {
+			if err := deleteObject(ctx, execution, client, mapper, objects[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, object := range objects {
+		if err := applyObject(ctx, execution, client, mapper, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyObject(ctx context.Context, execution *stratus.ExecutionContext, client dynamic.Interface, mapper meta.RESTMapper, object *unstructured.Unstructured) error {
+	resource, err := resourceFor(client, mapper, object, execution.K8s.GetNamespace(defaultNamespace))
+	if err != nil {
+		return err
+	}
+	rawObject, err := object.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	execution.Logger.Println("Applying " + object.GetKind() + " " + object.GetName())
+	force := true
+	_, err = resource.Patch(ctx, object.GetName(), types.ApplyPatchType, rawObject, metav1.PatchOptions{FieldManager: fieldManager, Force: &force})
+	if err != nil {
+		return errors.New("unable to apply " + object.GetKind() + " " + object.GetName() + ": " + err.Error())
+	}
+	return nil
+}
+
+func deleteObject(ctx context.Context, execution *stratus.ExecutionContext, client dynamic.Interface, mapper meta.RESTMapper, object *unstructured.Unstructured) error {
+	resource, err := resourceFor(client, mapper, object, execution.K8s.GetNamespace(defaultNamespace))
+	if err != nil {
+		return err
+	}
+	execution.Logger.Println("Deleting " + object.GetKind() + " " + object.GetName())
+	err = resource.Delete(ctx, object.GetName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.New("unable to delete " + object.GetKind() + " " + object.GetName() + ": " + err.Error())
+	}
+	return nil
+}
+
+// resourceFor returns the client of the API resource of an object, in the right namespace
+func resourceFor(client dynamic.Interface, mapper meta.RESTMapper, object *unstructured.Unstructured, defaultObjectNamespace string) (dynamic.ResourceInterface, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.New("unknown Kubernetes resource " + gvk.String() + ": " + err.Error())
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource), nil
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = defaultObjectNamespace
+		object.SetNamespace(namespace)
+	}
+	return client.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// decodeManifest decodes the objects of a YAML or JSON manifest
+func decodeManifest(manifest string) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		var rawObject map[string]interface{}
+		err := decoder.Decode(&rawObject)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New("invalid Kubernetes manifest: " + err.Error())
+		}
+		if len(rawObject) == 0 {
+			continue
+		}
+		object := &unstructured.Unstructured{Object: rawObject}
+		if object.GetKind() == "" || object.GetName() == "" {
+			return nil, errors.New("invalid Kubernetes manifest: objects must have a kind and a name")
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
@@ -0,0 +1,78 @@
+package declarative
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// Extensions of the technique definition files
+var definitionExtensions = []string{".yaml", ".yml", ".json"}
+
+// LoadFile loads an attack technique from a YAML or JSON definition file
+func LoadFile(path string) (*stratus.AttackTechnique, error) {
+	rawDefinition, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("unable to read technique definition: " + err.Error())
+	}
+	definition, err := Parse(rawDefinition)
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	technique, err := definition.ToAttackTechnique(filepath.Dir(path))
+	if err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return technique, nil
+}
+
+// LoadDirectory loads the attack techniques defined in a directory and its subdirectories, and adds them to a registry.
+// Nothing is registered if any of the definitions is invalid
+func LoadDirectory(directory string, registry *stratus.Registry) ([]*stratus.AttackTechnique, error) {
+	var techniques []*stratus.AttackTechnique
+	loaded := map[string]string{}
+
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isDefinitionFile(path) {
+			return nil
+		}
+
+		technique, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+		if registry.GetAttackTechniqueByName(technique.ID) != nil {
+			return errors.New(path + ": technique " + technique.ID + " already exists")
+		}
+		if previousPath, found := loaded[technique.ID]; found {
+			return errors.New(path + ": technique " + technique.ID + " is already defined in " + previousPath)
+		}
+		loaded[technique.ID] = path
+		techniques = append(techniques, technique)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("unable to load techniques from " + directory + ": " + err.Error())
+	}
+
+	for _, technique := range techniques {
+		registry.RegisterAttackTechnique(technique)
+	}
+	return techniques, nil
+}
+
+func isDefinitionFile(path string) bool {
+	for _, extension := range definitionExtensions {
+		if strings.EqualFold(filepath.Ext(path), extension) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package declarative
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeDefinition(t *testing.T, path string, id string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	definition := `{id: ` + id + `, name: Foo, platform: AWS, tactics: [Discovery], detonate: [{http: {url: "http://localhost"}}]}`
+	assert.Nil(t, os.WriteFile(path, []byte(definition), 0600))
+}
+
+func TestLoadsDirectory(t *testing.T) {
+	directory := t.TempDir()
+	writeDefinition(t, filepath.Join(directory, "first.yaml"), "custom.first")
+	writeDefinition(t, filepath.Join(directory, "nested", "second.yml"), "custom.second")
+	writeDefinition(t, filepath.Join(directory, "nested", "third.JSON"), "custom.third")
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "nested", "main.tf"), []byte("# not a definition"), 0600))
+	registry := stratus.NewRegistry()
+
+	techniques, err := LoadDirectory(directory, &registry)
+	assert.Nil(t, err)
+	assert.Len(t, techniques, 3)
+	assert.Len(t, registry.ListAttackTechniques(), 3)
+	assert.NotNil(t, registry.GetAttackTechniqueByName("custom.second"))
+}
+
+func TestLoadingDirectoryRejectsDuplicates(t *testing.T) {
+	directory := t.TempDir()
+	writeDefinition(t, filepath.Join(directory, "first.yaml"), "custom.first")
+	writeDefinition(t, filepath.Join(directory, "second.yaml"), "custom.first")
+	registry := stratus.NewRegistry()
+
+	_, err := LoadDirectory(directory, &registry)
+	assert.NotNil(t, err)
+	assert.Empty(t, registry.ListAttackTechniques(), "nothing should be registered when a definition is invalid")
+}
+
+func TestLoadingDirectoryRejectsExistingTechniques(t *testing.T) {
+	directory := t.TempDir()
+	writeDefinition(t, filepath.Join(directory, "first.yaml"), "aws.defense-evasion.cloudtrail-stop")
+	registry := stratus.NewRegistry()
+	registry.RegisterAttackTechnique(&stratus.AttackTechnique{ID: "aws.defense-evasion.cloudtrail-stop"})
+
+	_, err := LoadDirectory(directory, &registry)
+	assert.NotNil(t, err)
+	assert.Len(t, registry.ListAttackTechniques(), 1)
+}
@@ -0,0 +1,149 @@
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"regexp"
+	"text/template"
+)
+
+// Step is a detonation or revert step. Exactly one of its actions must be set
+type Step struct {
+	// Optional description of the step, displayed when running it
+	Name string `json:"name"`
+
+	AWS        *AWSStep        `json:"aws"`
+	Kubernetes *KubernetesStep `json:"kubernetes"`
+	HTTP       *HTTPStep       `json:"http"`
+
+	// Indicates if a failure of the step should be logged and ignored, e.g. when deleting a resource that may not exist
+	IgnoreErrors bool `json:"ignore_errors"`
+}
+
+// action is implemented by each type of step
+type action interface {
+	validate() error
+	run(ctx context.Context, execution *stratus.ExecutionContext, data *templateData) error
+}
+
+// templateData is made available to templates in step parameters, e.g. {{ .Outputs.bucket_name }}
+type templateData struct {
+	// Terraform outputs of the technique prerequisites
+	Outputs map[string]string
+}
+
+func (m *Step) action() (action, error) {
+	var actions []action
+	if m.AWS != nil {
+		actions = append(actions, m.AWS)
+	}
+	if m.Kubernetes != nil {
+		actions = append(actions, m.Kubernetes)
+	}
+	if m.HTTP != nil {
+		actions = append(actions, m.HTTP)
+	}
+	if len(actions) != 1 {
+		return nil, errors.New("a step must have exactly one of aws, kubernetes or http")
+	}
+	return actions[0], nil
+}
+
+func (m *Step) validate() error {
+	action, err := m.action()
+	if err != nil {
+		return err
+	}
+	return action.validate()
+}
+
+// runSteps returns a detonation or revert function running steps in order
+func runSteps(steps []Step) func(execution *stratus.ExecutionContext) error {
+	return func(execution *stratus.ExecutionContext) error {
+		data := &templateData{Outputs: execution.Parameters}
+		ctx := execution.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		for i := range steps {
+			action, err := steps[i].action()
+			if err != nil {
+				return err
+			}
+			if steps[i].Name != "" {
+				execution.Logger.Println(steps[i].Name)
+			}
+			err = action.run(ctx, execution, data)
+			if err != nil && steps[i].IgnoreErrors {
+				execution.Logger.Printf("Ignoring error of step %d: %s", i+1, err)
+			} else if err != nil {
+				return fmt.Errorf("step %d failed: %s", i+1, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Reference to a Terraform output in a template, e.g. {{ .Outputs.bucket_name }}
+var outputReference = regexp.MustCompile(`\.Outputs\.([A-Za-z0-9_]+)`)
+
+// requiredOutputs returns the names of the Terraform outputs referenced by the templates of steps
+func requiredOutputs(steps ...[]Step) []string {
+	var outputs []string
+	seen := map[string]bool{}
+	rawSteps, _ := json.Marshal(steps)
+	for _, match := range outputReference.FindAllStringSubmatch(string(rawSteps), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			outputs = append(outputs, match[1])
+		}
+	}
+	return outputs
+}
+
+// render executes a template, failing when it references an unknown Terraform output
+func render(text string, data *templateData) (string, error) {
+	tpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.New("invalid template: " + err.Error())
+	}
+	var result bytes.Buffer
+	if err := tpl.Execute(&result, data); err != nil {
+		return "", errors.New("unable to render template: " + err.Error())
+	}
+	return result.String(), nil
+}
+
+// renderValue renders the templates of all strings of a value decoded from YAML or JSON
+func renderValue(value interface{}, data *templateData) (interface{}, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return render(typedValue, data)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typedValue))
+		for key, item := range typedValue {
+			rendered, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = rendered
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			rendered, err := renderValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = rendered
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,102 @@
+package declarative
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendersTemplates(t *testing.T) {
+	data := &templateData{Outputs: map[string]string{"name": "my-trail"}}
+
+	rendered, err := renderValue(map[string]interface{}{
+		"Name":  "{{ .Outputs.name }}",
+		"Tags":  []interface{}{map[string]interface{}{"Key": "owner", "Value": "{{ .Outputs.name }}-owner"}},
+		"Count": 2.0,
+	}, data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Name":  "my-trail",
+		"Tags":  []interface{}{map[string]interface{}{"Key": "owner", "Value": "my-trail-owner"}},
+		"Count": 2.0,
+	}, rendered)
+
+	_, err = render("{{ .Outputs.unknown }}", data)
+	assert.NotNil(t, err, "unknown outputs should be rejected")
+}
+
+func TestBuildsAWSInput(t *testing.T) {
+	input, err := buildInput(reflect.TypeOf(&cloudtrail.StopLoggingInput{}), map[string]interface{}{"Name": "my-trail"})
+	assert.Nil(t, err)
+	assert.Equal(t, "my-trail", *input.Interface().(*cloudtrail.StopLoggingInput).Name)
+
+	input, err = buildInput(reflect.TypeOf(&ec2.DescribeInstancesInput{}), map[string]interface{}{
+		"MaxResults": 5,
+		"Filters":    []interface{}{map[string]interface{}{"Name": "instance-state-name", "Values": []interface{}{"running"}}},
+	})
+	assert.Nil(t, err)
+	describeInstances := input.Interface().(*ec2.DescribeInstancesInput)
+	assert.Equal(t, int32(5), *describeInstances.MaxResults)
+	assert.Equal(t, []string{"running"}, describeInstances.Filters[0].Values)
+
+	_, err = buildInput(reflect.TypeOf(&cloudtrail.StopLoggingInput{}), map[string]interface{}{"TrailName": "my-trail"})
+	assert.NotNil(t, err, "unknown parameters should be rejected")
+}
+
+func TestRunsHTTPSteps(t *testing.T) {
+	var receivedMethod, receivedBody, receivedHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receivedMethod, receivedBody, receivedHeader = r.Method, string(body), r.Header.Get("X-Token")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	execution := stratus.NewExecutionContext(map[string]string{"url": server.URL, "token": "secret"})
+
+	detonate := runSteps([]Step{{HTTP: &HTTPStep{
+		Method:  "post",
+		URL:     "{{ .Outputs.url }}/resource",
+		Headers: map[string]string{"X-Token": "{{ .Outputs.token }}"},
+		Body:    `{"name": "stratus"}`,
+	}}})
+	assert.Nil(t, detonate(execution))
+	assert.Equal(t, http.MethodPost, receivedMethod)
+	assert.Equal(t, `{"name": "stratus"}`, receivedBody)
+	assert.Equal(t, "secret", receivedHeader)
+
+	assert.NotNil(t, runSteps([]Step{{HTTP: &HTTPStep{URL: "{{ .Outputs.url }}/missing"}}})(execution))
+	assert.Nil(t, runSteps([]Step{{HTTP: &HTTPStep{URL: "{{ .Outputs.url }}/missing", ExpectedStatus: 404}}})(execution))
+	assert.Nil(t, runSteps([]Step{{HTTP: &HTTPStep{URL: "{{ .Outputs.url }}/missing"}, IgnoreErrors: true}})(execution))
+}
+
+func TestDecodesKubernetesManifests(t *testing.T) {
+	objects, err := decodeManifest(`
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: stratus
+---
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: stratus
+roleRef: {apiGroup: rbac.authorization.k8s.io, kind: ClusterRole, name: cluster-admin}
+`)
+	assert.Nil(t, err)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "ServiceAccount", objects[0].GetKind())
+	assert.Equal(t, "rbac.authorization.k8s.io/v1", objects[1].GetAPIVersion())
+
+	_, err = decodeManifest("apiVersion: v1\nkind: ServiceAccount")
+	assert.NotNil(t, err, "objects without a name should be rejected")
+}
@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultBaseURL is the base URL of the Microsoft Graph API in the Azure public cloud
+const DefaultBaseURL = "https://graph.microsoft.com/v1.0"
+
+// TokenProvider returns an access token for the Microsoft Graph API
+type TokenProvider func(ctx context.Context) (string, error)
+
+// Client is a minimal Microsoft Graph API client
+type Client struct {
+	BaseURL       string
+	HttpClient    *http.Client
+	TokenProvider TokenProvider
+}
+
+func NewClient(baseURL string, httpClient *http.Client, tokenProvider TokenProvider) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HttpClient: httpClient, TokenProvider: tokenProvider}
+}
+
+// Error is an error returned by the Microsoft Graph API
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (m *Error) Error() string {
+	return fmt.Sprintf("Microsoft Graph API returned HTTP %d (%s): %s", m.StatusCode, m.Code, m.Message)
+}
+
+// IsNotFound returns true if an error indicates that a Microsoft Graph object does not exist
+func IsNotFound(err error) bool {
+	var graphErr *Error
+	return errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusNotFound
+}
+
+// IsAlreadyExists returns true if an error indicates that a reference being added to a Microsoft Graph object, e.g.
+// a member of a group, already exists
+func IsAlreadyExists(err error) bool {
+	var graphErr *Error
+	return errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(graphErr.Message, "already exist")
+}
+
+// ListResponse is the response of the Microsoft Graph API when listing objects
+type ListResponse[T any] struct {
+	Value []T `json:"value"`
+}
+
+func (m *Client) Get(ctx context.Context, path string, result interface{}) error {
+	return m.do(ctx, http.MethodGet, path, nil, result)
+}
+
+func (m *Client) Post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	return m.do(ctx, http.MethodPost, path, body, result)
+}
+
+func (m *Client) Patch(ctx context.Context, path string, body interface{}) error {
+	return m.do(ctx, http.MethodPatch, path, body, nil)
+}
+
+func (m *Client) Delete(ctx context.Context, path string) error {
+	return m.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (m *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		rawBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(rawBody)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, m.BaseURL+path, requestBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if m.TokenProvider != nil {
+		token, err := m.TokenProvider(ctx)
+		if err != nil {
+			return errors.New("unable to retrieve a Microsoft Graph access token: " + err.Error())
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := m.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 {
+		return parseError(response)
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func parseError(response *http.Response) error {
+	var errorResponse struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(response.Body).Decode(&errorResponse)
+	return &Error{StatusCode: response.StatusCode, Code: errorResponse.Error.Code, Message: errorResponse.Error.Message}
+}
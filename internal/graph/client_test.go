@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSendsAccessToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"value": [{"id": "org-id", "displayName": "Contoso"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil, func(ctx context.Context) (string, error) { return "my-token", nil })
+	var organizations ListResponse[Organization]
+	err := client.Get(context.Background(), "/organization", &organizations)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer my-token", authorization)
+	assert.Equal(t, []Organization{{Id: "org-id", DisplayName: "Contoso"}}, organizations.Value)
+}
+
+func TestClientParsesErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": {"code": "Request_ResourceNotFound", "message": "not found"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil, nil)
+	err := client.Delete(context.Background(), "/applications/foo")
+
+	assert.NotNil(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "Request_ResourceNotFound")
+}
+
+func TestIsAlreadyExists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"code": "Request_BadRequest", "message": "One or more added object references already exist for the following modified properties: 'members'."}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil, nil)
+	err := client.Post(context.Background(), "/groups/foo/members/$ref", map[string]string{"@odata.id": "bar"}, nil)
+
+	assert.True(t, IsAlreadyExists(err))
+	assert.False(t, IsNotFound(err))
+	assert.False(t, IsAlreadyExists(&Error{StatusCode: http.StatusBadRequest, Message: "Invalid object identifier"}))
+	assert.False(t, IsAlreadyExists(nil))
+}
+
+func TestClientFailsWithoutToken(t *testing.T) {
+	client := NewClient("http://localhost", nil, func(ctx context.Context) (string, error) { return "", errors.New("no credentials") })
+	err := client.Get(context.Background(), "/organization", nil)
+
+	assert.NotNil(t, err)
+	assert.False(t, IsNotFound(err))
+}
+
+func TestFilter(t *testing.T) {
+	assert.Equal(t, "?$filter=appId+eq+%27foo%27", Filter("appId", "foo"))
+	assert.Equal(t, "?$filter=principalId+eq+%27a%27+and+roleDefinitionId+eq+%27b%27", Filter("principalId", "a", "roleDefinitionId", "b"))
+}
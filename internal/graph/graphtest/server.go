@@ -0,0 +1,318 @@
+// Package graphtest provides an in-memory stand-in for the Microsoft Graph API, to test code using the Graph client
+package graphtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/google/uuid"
+)
+
+// Collections of objects supported by the server. Objects are created with POST, listed with GET (optionally with
+// a simple $filter), and retrieved, updated or deleted using their ID
+var collections = []string{
+	"applications",
+	"servicePrincipals",
+	"servicePrincipals/*/appRoleAssignedTo",
+	"users",
+	"groups",
+	"groups/*/members",
+	"invitations",
+	"organization",
+	"roleManagement/directory/roleAssignments",
+}
+
+type object = map[string]interface{}
+
+// Server is an in-memory Microsoft Graph API
+type Server struct {
+	*httptest.Server
+
+	lock sync.Mutex
+
+	// Collection path => ordered list of objects
+	objects map[string][]object
+}
+
+// NewServer starts a new Microsoft Graph API stand-in. Call Close when done
+func NewServer() *Server {
+	server := &Server{objects: map[string][]object{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// Client returns a Graph client targeting the server
+func (m *Server) Client() *graph.Client {
+	return graph.NewClient(m.URL, m.Server.Client(), nil)
+}
+
+// Use makes an Entra ID provider send its Microsoft Graph API calls to the server until the end of a test, after which
+// the provider builds its own client again
+func (m *Server) Use(t testing.TB, provider *providers.EntraIDProvider) {
+	provider.SetGraphClient(m.Client())
+	t.Cleanup(func() { provider.SetGraphClient(nil) })
+}
+
+// Add stores an object in a collection, and returns its ID
+func (m *Server) Add(collection string, value interface{}) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.add(collection, toObject(value))
+}
+
+// List returns the objects of a collection, decoded into result (a pointer to a slice)
+func (m *Server) List(collection string, result interface{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	raw, _ := json.Marshal(m.objects[collection])
+	_ = json.Unmarshal(raw, result)
+}
+
+// Get returns an object, decoded into result, and false if it doesn't exist
+func (m *Server) Get(collection string, id string, result interface{}) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	obj, _ := m.find(collection, id)
+	if obj == nil {
+		return false
+	}
+	raw, _ := json.Marshal(obj)
+	_ = json.Unmarshal(raw, result)
+	return true
+}
+
+func (m *Server) handle(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") && r.Header.Get("Authorization") != "" {
+		writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "malformed authorization header")
+		return
+	}
+
+	requestPath := strings.Trim(r.URL.Path, "/")
+	var body object
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	// Actions on applications
+	if r.Method == http.MethodPost && path.Dir(path.Dir(requestPath)) == "applications" {
+		m.handleApplicationAction(w, path.Base(path.Dir(requestPath)), path.Base(requestPath), body)
+		return
+	}
+
+	// References between objects, e.g. group memberships
+	if path.Base(requestPath) == "$ref" {
+		m.handleReference(w, r.Method, path.Dir(requestPath), body)
+		return
+	}
+
+	if isCollection(requestPath) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, object{"value": m.filter(requestPath, r.URL.Query().Get("$filter"))})
+		case http.MethodPost:
+			if requestPath == "invitations" {
+				m.handleInvitation(w, body)
+				return
+			}
+			id := m.add(requestPath, body)
+			obj, _ := m.find(requestPath, id)
+			writeJSON(w, http.StatusCreated, obj)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "BadRequest", "unsupported method")
+		}
+		return
+	}
+
+	collection, id := path.Dir(requestPath), path.Base(requestPath)
+	if !isCollection(collection) {
+		writeError(w, http.StatusNotFound, "BadRequest", "unsupported resource "+requestPath)
+		return
+	}
+	obj, index := m.find(collection, id)
+	if obj == nil {
+		writeError(w, http.StatusNotFound, "Request_ResourceNotFound", "resource "+id+" does not exist")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPatch:
+		for key, value := range body {
+			obj[key] = value
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		m.objects[collection] = append(m.objects[collection][:index], m.objects[collection][index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "BadRequest", "unsupported method")
+	}
+}
+
+func (m *Server) handleApplicationAction(w http.ResponseWriter, id string, action string, body object) {
+	application, _ := m.find("applications", id)
+	if application == nil {
+		writeError(w, http.StatusNotFound, "Request_ResourceNotFound", "application "+id+" does not exist")
+		return
+	}
+	credentials, _ := application["passwordCredentials"].([]interface{})
+
+	switch action {
+	case "addPassword":
+		requested, _ := body["passwordCredential"].(map[string]interface{})
+		credential := object{"keyId": uuid.NewString(), "displayName": requested["displayName"]}
+		application["passwordCredentials"] = append(credentials, credential)
+		response := object{"secretText": "secret-" + uuid.NewString()}
+		for key, value := range credential {
+			response[key] = value
+		}
+		writeJSON(w, http.StatusOK, response)
+	case "removePassword":
+		var remaining []interface{}
+		for _, credential := range credentials {
+			if credential.(map[string]interface{})["keyId"] != body["keyId"] {
+				remaining = append(remaining, credential)
+			}
+		}
+		if len(remaining) == len(credentials) {
+			writeError(w, http.StatusNotFound, "Request_ResourceNotFound", "no password with this key ID")
+			return
+		}
+		application["passwordCredentials"] = remaining
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "BadRequest", "unsupported action "+action)
+	}
+}
+
+// handleReference adds an object to a collection of references with POST {collection}/$ref, or removes it with
+// DELETE {collection}/{id}/$ref
+func (m *Server) handleReference(w http.ResponseWriter, method string, referencePath string, body object) {
+	switch {
+	case method == http.MethodPost && isCollection(referencePath):
+		reference, _ := body["@odata.id"].(string)
+		if existing, _ := m.find(referencePath, path.Base(reference)); existing != nil {
+			writeError(w, http.StatusBadRequest, "Request_BadRequest", "One or more added object references already exist for the following modified properties: 'members'.")
+			return
+		}
+		m.add(referencePath, object{"id": path.Base(reference)})
+		w.WriteHeader(http.StatusNoContent)
+	case method == http.MethodDelete && isCollection(path.Dir(referencePath)):
+		collection, id := path.Dir(referencePath), path.Base(referencePath)
+		if _, index := m.find(collection, id); index >= 0 {
+			m.objects[collection] = append(m.objects[collection][:index], m.objects[collection][index+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, http.StatusNotFound, "Request_ResourceNotFound", "reference "+id+" does not exist")
+	default:
+		writeError(w, http.StatusBadRequest, "BadRequest", "unsupported reference "+referencePath)
+	}
+}
+
+// handleInvitation creates a guest user, as Microsoft Graph does when inviting an external user. Inviting the same
+// external user again returns the existing guest user
+func (m *Server) handleInvitation(w http.ResponseWriter, body object) {
+	email, _ := body["invitedUserEmailAddress"].(string)
+	var user object
+	if existing := m.filter("users", "mail eq '"+strings.ReplaceAll(email, "'", "''")+"' and userType eq 'Guest'"); len(existing) > 0 {
+		user = existing[0]
+	} else {
+		userId := m.add("users", object{"mail": email, "userType": "Guest", "userPrincipalName": strings.ReplaceAll(email, "@", "_") + "#EXT#@example.onmicrosoft.com"})
+		user, _ = m.find("users", userId)
+	}
+	body["invitedUser"] = user
+	id := m.add("invitations", body)
+	invitation, _ := m.find("invitations", id)
+	writeJSON(w, http.StatusCreated, invitation)
+}
+
+func (m *Server) add(collection string, obj object) string {
+	if obj == nil {
+		obj = object{}
+	}
+	id, _ := obj["id"].(string)
+	if id == "" {
+		id = uuid.NewString()
+		obj["id"] = id
+	}
+	if collection == "applications" && obj["appId"] == nil {
+		obj["appId"] = uuid.NewString()
+	}
+	m.objects[collection] = append(m.objects[collection], obj)
+	return id
+}
+
+func (m *Server) find(collection string, id string) (object, int) {
+	for i, obj := range m.objects[collection] {
+		if obj["id"] == id {
+			return obj, i
+		}
+	}
+	return nil, -1
+}
+
+// filter returns the objects of a collection matching a filter made of equality conditions, e.g.
+// "appId eq 'xxx' and displayName eq 'yyy'"
+func (m *Server) filter(collection string, filter string) []object {
+	result := []object{}
+	for _, obj := range m.objects[collection] {
+		if matches(obj, filter) {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func matches(obj object, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, condition := range strings.Split(filter, " and ") {
+		parts := strings.SplitN(condition, " eq ", 2)
+		if len(parts) != 2 {
+			return false
+		}
+		expected := strings.ReplaceAll(strings.Trim(parts[1], "'"), "''", "'")
+		if value, _ := obj[strings.TrimSpace(parts[0])].(string); value != expected {
+			return false
+		}
+	}
+	return true
+}
+
+func isCollection(requestPath string) bool {
+	for _, pattern := range collections {
+		if matched, _ := path.Match(pattern, requestPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func toObject(value interface{}) object {
+	raw, _ := json.Marshal(value)
+	var result object
+	_ = json.Unmarshal(raw, &result)
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, code string, message string) {
+	writeJSON(w, statusCode, object{"error": object{"code": code, "message": message}})
+}
@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Well-known Microsoft Graph identifiers
+const (
+	// Application ID of Microsoft Graph itself, in all tenants
+	MicrosoftGraphAppId = "00000003-0000-0000-c000-000000000000"
+
+	// Template ID of the Global Administrator directory role
+	GlobalAdministratorRoleId = "62e90394-69f5-4237-9190-012177145e10"
+)
+
+type Organization struct {
+	Id          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type Application struct {
+	Id                     string                   `json:"id,omitempty"`
+	AppId                  string                   `json:"appId,omitempty"`
+	DisplayName            string                   `json:"displayName,omitempty"`
+	PasswordCredentials    []PasswordCredential     `json:"passwordCredentials,omitempty"`
+	RequiredResourceAccess []RequiredResourceAccess `json:"requiredResourceAccess,omitempty"`
+}
+
+type PasswordCredential struct {
+	KeyId       string `json:"keyId,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	SecretText  string `json:"secretText,omitempty"`
+}
+
+type RequiredResourceAccess struct {
+	ResourceAppId  string           `json:"resourceAppId"`
+	ResourceAccess []ResourceAccess `json:"resourceAccess"`
+}
+
+type ResourceAccess struct {
+	Id   string `json:"id"`
+	Type string `json:"type"` // "Role" for application permissions, "Scope" for delegated permissions
+}
+
+type ServicePrincipal struct {
+	Id          string    `json:"id,omitempty"`
+	AppId       string    `json:"appId,omitempty"`
+	DisplayName string    `json:"displayName,omitempty"`
+	AppRoles    []AppRole `json:"appRoles,omitempty"`
+}
+
+type AppRole struct {
+	Id    string `json:"id"`
+	Value string `json:"value"`
+}
+
+type AppRoleAssignment struct {
+	Id          string `json:"id,omitempty"`
+	PrincipalId string `json:"principalId"`
+	ResourceId  string `json:"resourceId"`
+	AppRoleId   string `json:"appRoleId"`
+}
+
+type UnifiedRoleAssignment struct {
+	Id               string `json:"id,omitempty"`
+	PrincipalId      string `json:"principalId"`
+	RoleDefinitionId string `json:"roleDefinitionId"`
+	DirectoryScopeId string `json:"directoryScopeId"`
+}
+
+type Invitation struct {
+	Id                      string `json:"id,omitempty"`
+	InvitedUserEmailAddress string `json:"invitedUserEmailAddress"`
+	InviteRedirectUrl       string `json:"inviteRedirectUrl"`
+	SendInvitationMessage   bool   `json:"sendInvitationMessage"`
+	InvitedUser             *User  `json:"invitedUser,omitempty"`
+}
+
+type User struct {
+	Id                string `json:"id,omitempty"`
+	Mail              string `json:"mail,omitempty"`
+	UserPrincipalName string `json:"userPrincipalName,omitempty"`
+	UserType          string `json:"userType,omitempty"`
+}
+
+// Filter builds the query string to list objects matching equality conditions, e.g. Filter("appId", "xxx")
+func Filter(keyValues ...string) string {
+	var conditions []string
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		conditions = append(conditions, keyValues[i]+" eq '"+strings.ReplaceAll(keyValues[i+1], "'", "''")+"'")
+	}
+	return "?$filter=" + url.QueryEscape(strings.Join(conditions, " and "))
+}
@@ -0,0 +1,202 @@
+// Package history records the operations run on attack techniques, e.g. to report on a purple-team session
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// FileName is the name of the history file, in the Stratus Red Team state directory
+const FileName = "history.jsonl"
+
+type Operation string
+
+const (
+	OperationWarmUp   Operation = "warmup"
+	OperationDetonate Operation = "detonate"
+	OperationRevert   Operation = "revert"
+	OperationCleanUp  Operation = "cleanup"
+)
+
+// Entry is an operation run on an attack technique
+type Entry struct {
+	ExecutionID string    `json:"execution_id"`
+	TechniqueID string    `json:"technique_id"`
+	Platform    string    `json:"platform"`
+	Operation   Operation `json:"operation"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+
+	// Environment targeted by the operation (e.g. an AWS account), and identity used, when known
+	Target   string `json:"target,omitempty"`
+	Identity string `json:"identity,omitempty"`
+
+	// Error of the operation, if it failed
+	Error string `json:"error,omitempty"`
+
+	// Result of the verification of a detonation, for techniques able to probe their detonation
+	Verification *Verification `json:"verification,omitempty"`
+}
+
+// Succeeded returns true if the operation did not fail
+func (m Entry) Succeeded() bool {
+	return m.Error == ""
+}
+
+// Verification is the result of probing an attack technique right after its detonation
+type Verification struct {
+	// Indicates if the side effects of the detonation were observed
+	Detonated bool `json:"detonated"`
+
+	// Error of the probe, if it failed
+	Error string `json:"error,omitempty"`
+
+	// Duration of the probe
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Recorder records operations run on attack techniques
+type Recorder interface {
+	Record(entry Entry) error
+}
+
+// Recorders records operations in several recorders, e.g. both in the history file and in a test report
+type Recorders []Recorder
+
+func (m Recorders) Record(entry Entry) error {
+	var errs []string
+	for _, recorder := range m {
+		if err := recorder.Record(entry); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+var (
+	registeredRecorders     Recorders
+	registeredRecordersLock sync.Mutex
+)
+
+// RegisterRecorder registers a recorder of all the operations run by runners, in addition to the history file, e.g. to
+// notify external systems
+func RegisterRecorder(recorder Recorder) {
+	registeredRecordersLock.Lock()
+	defer registeredRecordersLock.Unlock()
+	registeredRecorders = append(registeredRecorders, recorder)
+}
+
+// NewRecorder returns the recorder used by runners, recording operations in the history file at a given path and in
+// registered recorders
+func NewRecorder(path string) Recorder {
+	registeredRecordersLock.Lock()
+	defer registeredRecordersLock.Unlock()
+	if len(registeredRecorders) == 0 {
+		return NewFileStore(path)
+	}
+	return append(Recorders{NewFileStore(path)}, registeredRecorders...)
+}
+
+// FileStore stores the history as JSON lines in a file, one line per operation
+type FileStore struct {
+	Path string
+	lock sync.Mutex
+}
+
+var _ Recorder = &FileStore{}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Record appends an operation to the history file, creating it if needed
+func (m *FileStore) Record(entry Entry) error {
+	rawEntry, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0744); err != nil {
+		return errors.New("unable to create history directory: " + err.Error())
+	}
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.New("unable to open history file: " + err.Error())
+	}
+	defer file.Close()
+	_, err = file.Write(append(rawEntry, '\n'))
+	return err
+}
+
+// Read returns the operations that started after a given time, in chronological order. A missing history file is
+// read as an empty history, and malformed lines are skipped
+func (m *FileStore) Read(since time.Time) ([]Entry, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	file, err := os.Open(m.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("unable to open history file: " + err.Error())
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !entry.StartTime.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("unable to read history file: " + err.Error())
+	}
+	return entries, nil
+}
+
+// Environment describes where, and as whom, operations run on a platform
+type Environment struct {
+	Target   string
+	Identity string
+}
+
+var (
+	environments     = map[stratus.Platform]Environment{}
+	environmentsLock sync.Mutex
+)
+
+// DescribeEnvironment returns the environment targeted on a platform. It is only resolved once per platform, as it
+// requires API calls. Parts that cannot be resolved are left empty
+func DescribeEnvironment(platform stratus.Platform) Environment {
+	environmentsLock.Lock()
+	defer environmentsLock.Unlock()
+	if environment, found := environments[platform]; found {
+		return environment
+	}
+
+	environment := Environment{}
+	environment.Target, _ = stratus.DescribeTarget(platform)
+	if provider, err := stratus.GetPlatformProvider(platform); err == nil {
+		environment.Identity, _ = provider.DescribeIdentity()
+	}
+	environments[platform] = environment
+	return environment
+}
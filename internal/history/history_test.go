@@ -0,0 +1,94 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordsAndReadsHistory(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "state", FileName))
+	start := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	entries := []Entry{
+		{ExecutionID: "1", TechniqueID: "aws.defense-evasion.cloudtrail-stop", Operation: OperationWarmUp, StartTime: start, EndTime: start.Add(time.Minute)},
+		{ExecutionID: "1", TechniqueID: "aws.defense-evasion.cloudtrail-stop", Operation: OperationDetonate, StartTime: start.Add(time.Hour), EndTime: start.Add(time.Hour), Verification: &Verification{Detonated: true}},
+		{ExecutionID: "2", TechniqueID: "aws.defense-evasion.cloudtrail-stop", Operation: OperationRevert, StartTime: start.Add(2 * time.Hour), Error: "access denied"},
+	}
+	for _, entry := range entries {
+		assert.Nil(t, store.Record(entry))
+	}
+
+	read, err := store.Read(time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, entries, read)
+	assert.True(t, read[1].Succeeded())
+	assert.False(t, read[2].Succeeded())
+
+	read, err = store.Read(start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, entries[1:], read)
+}
+
+func TestReadsMissingHistoryAsEmpty(t *testing.T) {
+	entries, err := NewFileStore(filepath.Join(t.TempDir(), FileName)).Read(time.Time{})
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSkipsMalformedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	content := `{"execution_id":"1","technique_id":"foo","operation":"detonate"}` + "\n" +
+		`{"execution_id":"2","techni` + "\n" +
+		`{"execution_id":"3","technique_id":"bar","operation":"revert"}` + "\n"
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+
+	entries, err := NewFileStore(path).Read(time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "foo", entries[0].TechniqueID)
+	assert.Equal(t, "bar", entries[1].TechniqueID)
+}
+
+type failingRecorder struct{}
+
+func (failingRecorder) Record(Entry) error {
+	return errors.New("disk full")
+}
+
+func TestRecordsInSeveralRecorders(t *testing.T) {
+	first := NewFileStore(filepath.Join(t.TempDir(), FileName))
+	second := NewFileStore(filepath.Join(t.TempDir(), FileName))
+	entry := Entry{ExecutionID: "1", TechniqueID: "foo", Operation: OperationDetonate}
+
+	err := Recorders{first, failingRecorder{}, second}.Record(entry)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+
+	for _, store := range []*FileStore{first, second} {
+		entries, err := store.Read(time.Time{})
+		assert.Nil(t, err)
+		assert.Equal(t, []Entry{entry}, entries)
+	}
+}
+
+func TestNewRecorderIncludesRegisteredRecorders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	assert.IsType(t, &FileStore{}, NewRecorder(path))
+
+	registered := NewFileStore(filepath.Join(t.TempDir(), FileName))
+	RegisterRecorder(registered)
+	defer func() { registeredRecorders = nil }()
+
+	entry := Entry{ExecutionID: "1", TechniqueID: "foo", Operation: OperationWarmUp}
+	assert.Nil(t, NewRecorder(path).Record(entry))
+	for _, store := range []*FileStore{NewFileStore(path), registered} {
+		entries, err := store.Read(time.Time{})
+		assert.Nil(t, err)
+		assert.Equal(t, []Entry{entry}, entries)
+	}
+}
@@ -0,0 +1,223 @@
+// Package junit writes the operations run on attack techniques as a JUnit XML report, e.g. to track detection
+// regression tests in CI
+package junit
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+)
+
+// Name of the test case reporting on the verification of a detonation
+const verifyTestCase = "verify"
+
+// TestSuites is the root element of a JUnit XML report, with one test suite per attack technique
+type TestSuites struct {
+	XMLName    xml.Name    `xml:"testsuites"`
+	Name       string      `xml:"name,attr"`
+	Tests      int         `xml:"tests,attr"`
+	Failures   int         `xml:"failures,attr"`
+	Errors     int         `xml:"errors,attr"`
+	Time       seconds     `xml:"time,attr"`
+	TestSuites []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite reports on an attack technique, with one test case per phase (warmup, detonate, verify, revert, cleanup)
+type TestSuite struct {
+	Name       string     `xml:"name,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Errors     int        `xml:"errors,attr"`
+	Time       seconds    `xml:"time,attr"`
+	Timestamp  string     `xml:"timestamp,attr,omitempty"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	TestCases  []TestCase `xml:"testcase"`
+}
+
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// TestCase reports on a phase of an attack technique
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Time      seconds  `xml:"time,attr"`
+	Failure   *Problem `xml:"failure,omitempty"`
+	Error     *Problem `xml:"error,omitempty"`
+}
+
+// Problem is the failure of a phase, or an error preventing to check its outcome
+type Problem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Details string `xml:",chardata"`
+}
+
+// seconds is a duration, formatted in seconds as expected by JUnit
+type seconds time.Duration
+
+func (m seconds) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: fmt.Sprintf("%.3f", time.Duration(m).Seconds())}, nil
+}
+
+// Collector collects the operations run on attack techniques, to write them as a JUnit report
+type Collector struct {
+	entries []history.Entry
+	lock    sync.Mutex
+}
+
+var _ history.Recorder = &Collector{}
+
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+func (m *Collector) Record(entry history.Entry) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+// Report builds a JUnit report from the operations collected so far
+func (m *Collector) Report() *TestSuites {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return FromEntries(m.entries)
+}
+
+// WriteFile writes the JUnit report of the operations collected so far to a file
+func (m *Collector) WriteFile(path string) error {
+	output, err := xml.MarshalIndent(m.Report(), "", "  ")
+	if err != nil {
+		return errors.New("unable to generate JUnit report: " + err.Error())
+	}
+	output = append([]byte(xml.Header), append(output, '\n')...)
+	if err := os.WriteFile(path, output, 0644); err != nil {
+		return errors.New("unable to write JUnit report: " + err.Error())
+	}
+	return nil
+}
+
+// Operations run as part of another one: detonating a technique warms it up first, and cleaning it up reverts it
+var parentOperations = map[history.Operation]history.Operation{
+	history.OperationWarmUp: history.OperationDetonate,
+	history.OperationRevert: history.OperationCleanUp,
+}
+
+// FromEntries builds a JUnit report from operations run on attack techniques. Test suites are sorted by technique ID,
+// and test cases by the time their phase was run. Operations run as part of another one have their own test case, but
+// their duration is only counted once in the totals
+func FromEntries(entries []history.Entry) *TestSuites {
+	suitesByTechnique := map[string]*TestSuite{}
+	var techniqueIDs []string
+	for i, entry := range entries {
+		suite, found := suitesByTechnique[entry.TechniqueID]
+		if !found {
+			suite = &TestSuite{Name: entry.TechniqueID, Timestamp: entry.StartTime.UTC().Format(time.RFC3339)}
+			suite.Properties = properties(entry)
+			suitesByTechnique[entry.TechniqueID] = suite
+			techniqueIDs = append(techniqueIDs, entry.TechniqueID)
+		}
+		suite.add(operationTestCase(entry), isNested(entries, i))
+		if entry.Verification != nil {
+			suite.add(verificationTestCase(entry), false)
+		}
+	}
+	sort.Strings(techniqueIDs)
+
+	report := &TestSuites{Name: "stratus-red-team"}
+	for _, techniqueID := range techniqueIDs {
+		suite := suitesByTechnique[techniqueID]
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Time += suite.Time
+		report.TestSuites = append(report.TestSuites, *suite)
+	}
+	return report
+}
+
+// isNested returns true if an operation was run as part of one of the operations recorded after it, in the same
+// execution. Nested operations complete first, so they are recorded before the operation that ran them
+func isNested(entries []history.Entry, index int) bool {
+	entry := entries[index]
+	parentOperation, found := parentOperations[entry.Operation]
+	if !found {
+		return false
+	}
+	for _, other := range entries[index+1:] {
+		if other.ExecutionID == entry.ExecutionID && other.TechniqueID == entry.TechniqueID && other.Operation == parentOperation &&
+			!other.StartTime.After(entry.StartTime) && !other.EndTime.Before(entry.EndTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds a test case to the suite. The duration of nested test cases is already included in the one of their parent
+func (m *TestSuite) add(testCase TestCase, nested bool) {
+	m.Tests++
+	if !nested {
+		m.Time += testCase.Time
+	}
+	if testCase.Failure != nil {
+		m.Failures++
+	}
+	if testCase.Error != nil {
+		m.Errors++
+	}
+	m.TestCases = append(m.TestCases, testCase)
+}
+
+func properties(entry history.Entry) []Property {
+	var result []Property
+	for _, property := range []Property{
+		{"execution_id", entry.ExecutionID},
+		{"platform", entry.Platform},
+		{"target", entry.Target},
+		{"identity", entry.Identity},
+	} {
+		if property.Value != "" {
+			result = append(result, property)
+		}
+	}
+	return result
+}
+
+func operationTestCase(entry history.Entry) TestCase {
+	testCase := TestCase{
+		Name:      string(entry.Operation),
+		ClassName: entry.TechniqueID,
+		Time:      seconds(entry.EndTime.Sub(entry.StartTime)),
+	}
+	if !entry.Succeeded() {
+		testCase.Failure = &Problem{Message: entry.Error, Type: string(entry.Operation), Details: entry.Error}
+	}
+	return testCase
+}
+
+func verificationTestCase(entry history.Entry) TestCase {
+	verification := entry.Verification
+	testCase := TestCase{
+		Name:      verifyTestCase,
+		ClassName: entry.TechniqueID,
+		Time:      seconds(verification.Duration),
+	}
+	if verification.Error != "" {
+		message := "unable to verify the detonation: " + verification.Error
+		testCase.Error = &Problem{Message: message, Type: verifyTestCase, Details: message}
+	} else if !verification.Detonated {
+		message := "the side effects of the detonation were not observed"
+		testCase.Failure = &Problem{Message: message, Type: verifyTestCase, Details: message}
+	}
+	return testCase
+}
@@ -0,0 +1,120 @@
+package junit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func entry(techniqueID string, operation history.Operation, duration time.Duration) history.Entry {
+	return history.Entry{
+		ExecutionID: "e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e",
+		TechniqueID: techniqueID,
+		Platform:    "AWS",
+		Operation:   operation,
+		StartTime:   start,
+		EndTime:     start.Add(duration),
+		Target:      "account 123456789012 in region us-east-1",
+	}
+}
+
+func TestBuildsReportFromEntries(t *testing.T) {
+	detonation := entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 2*time.Second)
+	detonation.Verification = &history.Verification{Detonated: false, Duration: 500 * time.Millisecond}
+	failedDetonation := entry("aws.credential-access.ssm-retrieve-securestring-parameters", history.OperationDetonate, time.Second)
+	failedDetonation.Error = "access denied"
+	unverifiedDetonation := entry("aws.discovery.ec2-enumerate-from-instance", history.OperationDetonate, time.Second)
+	unverifiedDetonation.Verification = &history.Verification{Error: "throttled"}
+
+	report := FromEntries([]history.Entry{
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationWarmUp, 10*time.Second),
+		detonation,
+		failedDetonation,
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationCleanUp, 5*time.Second),
+		unverifiedDetonation,
+	})
+
+	assert.Equal(t, 7, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, seconds(19500*time.Millisecond), report.Time)
+
+	if !assert.Len(t, report.TestSuites, 3) {
+		return
+	}
+	assert.Equal(t, "aws.credential-access.ssm-retrieve-securestring-parameters", report.TestSuites[0].Name)
+	assert.Equal(t, "access denied", report.TestSuites[0].TestCases[0].Failure.Message)
+
+	assert.Equal(t, "aws.defense-evasion.cloudtrail-stop", report.TestSuites[1].Name)
+	var names []string
+	for _, testCase := range report.TestSuites[1].TestCases {
+		names = append(names, testCase.Name)
+	}
+	assert.Equal(t, []string{"warmup", "detonate", "verify", "cleanup"}, names)
+	assert.Nil(t, report.TestSuites[1].TestCases[1].Failure)
+	assert.Equal(t, "the side effects of the detonation were not observed", report.TestSuites[1].TestCases[2].Failure.Message)
+	assert.Equal(t, 1, report.TestSuites[1].Failures)
+	assert.Contains(t, report.TestSuites[1].Properties, Property{"target", "account 123456789012 in region us-east-1"})
+
+	assert.Equal(t, "unable to verify the detonation: throttled", report.TestSuites[2].TestCases[1].Error.Message)
+	assert.Equal(t, 1, report.TestSuites[2].Errors)
+}
+
+func TestNestedOperationsAreCountedOnce(t *testing.T) {
+	// Detonating a technique first warms it up, and cleaning it up first reverts it
+	warmup := entry("aws.defense-evasion.cloudtrail-stop", history.OperationWarmUp, 10*time.Second)
+	warmup.StartTime = warmup.StartTime.Add(time.Second)
+	warmup.EndTime = warmup.EndTime.Add(time.Second)
+	detonation := entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 15*time.Second)
+	revert := entry("aws.defense-evasion.cloudtrail-stop", history.OperationRevert, 3*time.Second)
+	revert.StartTime = revert.StartTime.Add(20 * time.Second)
+	revert.EndTime = revert.EndTime.Add(20 * time.Second)
+	cleanup := entry("aws.defense-evasion.cloudtrail-stop", history.OperationCleanUp, 5*time.Second)
+	cleanup.StartTime = cleanup.StartTime.Add(20 * time.Second)
+	cleanup.EndTime = cleanup.EndTime.Add(20 * time.Second)
+	// A warmup of another execution is not nested
+	otherWarmup := warmup
+	otherWarmup.ExecutionID = "other-execution"
+
+	report := FromEntries([]history.Entry{warmup, otherWarmup, detonation, revert, cleanup})
+
+	assert.Equal(t, 5, report.Tests)
+	assert.Equal(t, seconds(30*time.Second), report.Time)
+	if assert.Len(t, report.TestSuites, 1) {
+		assert.Len(t, report.TestSuites[0].TestCases, 5)
+		assert.Equal(t, seconds(10*time.Second), report.TestSuites[0].TestCases[0].Time)
+		assert.Equal(t, seconds(30*time.Second), report.TestSuites[0].Time)
+	}
+}
+
+func TestWritesReport(t *testing.T) {
+	collector := NewCollector()
+	failedRevert := entry("aws.defense-evasion.cloudtrail-stop", history.OperationRevert, 1500*time.Millisecond)
+	failedRevert.Error = "trail <main> not found"
+	assert.Nil(t, collector.Record(entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 2*time.Second)))
+	assert.Nil(t, collector.Record(failedRevert))
+
+	path := filepath.Join(t.TempDir(), "report.xml")
+	assert.Nil(t, collector.WriteFile(path))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	assert.Contains(t, string(content), `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, string(content), `<testsuites name="stratus-red-team" tests="2" failures="1" errors="0" time="3.500">`)
+	assert.Contains(t, string(content), `<testsuite name="aws.defense-evasion.cloudtrail-stop" tests="2" failures="1" errors="0" time="3.500" timestamp="2022-03-01T10:00:00Z">`)
+	assert.Contains(t, string(content), `<property name="execution_id" value="e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e"></property>`)
+	assert.Contains(t, string(content), `<testcase name="detonate" classname="aws.defense-evasion.cloudtrail-stop" time="2.000"></testcase>`)
+	assert.Contains(t, string(content), `<failure message="trail &lt;main&gt; not found" type="revert">trail &lt;main&gt; not found</failure>`)
+}
+
+func TestEmptyReport(t *testing.T) {
+	report := NewCollector().Report()
+	assert.Equal(t, 0, report.Tests)
+	assert.Empty(t, report.TestSuites)
+}
@@ -0,0 +1,176 @@
+// Package metrics exposes Prometheus metrics on the operations run on attack techniques and on their state, either
+// through a /metrics endpoint or as a node-exporter textfile
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "stratus"
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// States reported by the technique state gauge, one time series per state
+var states = []stratus.AttackTechniqueState{
+	stratus.AttackTechniqueStatusCold,
+	stratus.AttackTechniqueStatusWarm,
+	stratus.AttackTechniqueStatusDetonated,
+}
+
+// Buckets of the duration histogram, in seconds. Operations range from a few seconds (detonating a technique without
+// prerequisites) to tens of minutes (spinning up a Kubernetes cluster)
+var durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// StateReader returns the persisted state of an attack technique
+type StateReader func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState
+
+// Metrics records the operations run on attack techniques as Prometheus metrics
+type Metrics struct {
+	// File to write the metrics to after each operation, in the node-exporter textfile format. Optional
+	Textfile string
+
+	registry    *prometheus.Registry
+	detonations *prometheus.CounterVec
+	durations   *prometheus.HistogramVec
+	lock        sync.Mutex
+}
+
+var _ history.Recorder = &Metrics{}
+
+// New returns metrics on the operations run on attack techniques, and on the state of the techniques of a registry
+func New(registry *stratus.Registry, stateOf StateReader) *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		detonations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "detonations_total",
+			Help:      "Number of detonations of attack techniques, by outcome.",
+		}, []string{"technique_id", "platform", "outcome"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the operations (warmup, detonate, revert, cleanup) run on attack techniques.",
+			Buckets:   durationBuckets,
+		}, []string{"technique_id", "platform", "operation", "outcome"}),
+	}
+	metrics.registry.MustRegister(metrics.detonations, metrics.durations)
+	if registry != nil && stateOf != nil {
+		metrics.registry.MustRegister(newStateCollector(registry, stateOf))
+	}
+	return metrics
+}
+
+// Record updates the metrics with an operation run on an attack technique, and writes the textfile if set
+func (m *Metrics) Record(entry history.Entry) error {
+	m.observe(entry)
+	if m.Textfile == "" {
+		return nil
+	}
+	return m.WriteTextfile(m.Textfile)
+}
+
+// Seed updates the metrics with operations run previously, e.g. read from the history file, so that counters keep
+// increasing across runs when the metrics are written to a textfile
+func (m *Metrics) Seed(entries []history.Entry) {
+	for _, entry := range entries {
+		m.observe(entry)
+	}
+}
+
+// observe updates the counters and histograms with an operation run on an attack technique
+func (m *Metrics) observe(entry history.Entry) {
+	outcome := OutcomeSuccess
+	if !entry.Succeeded() {
+		outcome = OutcomeFailure
+	}
+	if entry.Operation == history.OperationDetonate {
+		m.detonations.WithLabelValues(entry.TechniqueID, entry.Platform, outcome).Inc()
+	}
+	duration := entry.EndTime.Sub(entry.StartTime).Seconds()
+	m.durations.WithLabelValues(entry.TechniqueID, entry.Platform, string(entry.Operation), outcome).Observe(duration)
+}
+
+// Gatherer returns the gatherer of the metrics, e.g. to expose them along with the metrics of another application
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.registry
+}
+
+// Handler returns an HTTP handler exposing the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// WriteTextfile writes the metrics to a file in the node-exporter textfile format. The file is replaced atomically, so
+// that the node-exporter never reads a partially written file
+func (m *Metrics) WriteTextfile(path string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if err := prometheus.WriteToTextfile(path, m.registry); err != nil {
+		return errors.New("unable to write metrics textfile: " + err.Error())
+	}
+	return nil
+}
+
+// Serve exposes the metrics on the /metrics endpoint of an address, e.g. ":9090", until the server is closed. The
+// address of the returned server is the one actually listened on
+func (m *Metrics) Serve(address string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.New("unable to listen on " + address + ": " + err.Error())
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+	go server.Serve(listener)
+	return server, nil
+}
+
+// stateCollector reports the persisted state of attack techniques, read when the metrics are gathered
+type stateCollector struct {
+	registry    *stratus.Registry
+	stateOf     StateReader
+	description *prometheus.Desc
+}
+
+func newStateCollector(registry *stratus.Registry, stateOf StateReader) *stateCollector {
+	return &stateCollector{
+		registry: registry,
+		stateOf:  stateOf,
+		description: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "technique", "state"),
+			"State of attack techniques: 1 for the current state (COLD, WARM or DETONATED), 0 for the others.",
+			[]string{"technique_id", "platform", "state"}, nil,
+		),
+	}
+}
+
+func (m *stateCollector) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- m.description
+}
+
+func (m *stateCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, technique := range m.registry.ListAttackTechniques() {
+		currentState := m.stateOf(technique)
+		if currentState == "" {
+			currentState = stratus.AttackTechniqueStatusCold
+		}
+		for _, state := range states {
+			value := 0.0
+			if state == currentState {
+				value = 1
+			}
+			metrics <- prometheus.MustNewConstMetric(m.description, prometheus.GaugeValue, value, technique.ID, string(technique.Platform), string(state))
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRegistry() *stratus.Registry {
+	registry := stratus.NewRegistry()
+	registry.RegisterAttackTechnique(&stratus.AttackTechnique{ID: "aws.foo", Platform: stratus.AWS})
+	registry.RegisterAttackTechnique(&stratus.AttackTechnique{ID: "k8s.bar", Platform: stratus.Kubernetes})
+	return &registry
+}
+
+func entry(operation history.Operation, duration time.Duration, err error) history.Entry {
+	start := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	entry := history.Entry{
+		TechniqueID: "aws.foo",
+		Platform:    string(stratus.AWS),
+		Operation:   operation,
+		StartTime:   start,
+		EndTime:     start.Add(duration),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+func TestMetricsRecordsOperations(t *testing.T) {
+	metrics := New(nil, nil)
+	assert.Nil(t, metrics.Record(entry(history.OperationWarmUp, 90*time.Second, nil)))
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, 2*time.Second, nil)))
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, 3*time.Second, errors.New("access denied"))))
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, 4*time.Second, nil)))
+	assert.Nil(t, metrics.Record(entry(history.OperationCleanUp, 40*time.Second, nil)))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.detonations.WithLabelValues("aws.foo", "AWS", OutcomeSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.detonations.WithLabelValues("aws.foo", "AWS", OutcomeFailure)))
+
+	// One histogram per operation and outcome
+	assert.Equal(t, 4, testutil.CollectAndCount(metrics.durations))
+
+	output := gatherText(t, metrics)
+	assert.Contains(t, output, `stratus_operation_duration_seconds_bucket{operation="warmup",outcome="success",platform="AWS",technique_id="aws.foo",le="60"} 0`)
+	assert.Contains(t, output, `stratus_operation_duration_seconds_bucket{operation="warmup",outcome="success",platform="AWS",technique_id="aws.foo",le="120"} 1`)
+	assert.Contains(t, output, `stratus_operation_duration_seconds_sum{operation="detonate",outcome="success",platform="AWS",technique_id="aws.foo"} 6`)
+	assert.Contains(t, output, `stratus_operation_duration_seconds_count{operation="detonate",outcome="success",platform="AWS",technique_id="aws.foo"} 2`)
+	assert.Contains(t, output, `stratus_operation_duration_seconds_count{operation="cleanup",outcome="success",platform="AWS",technique_id="aws.foo"} 1`)
+}
+
+func TestMetricsReportsTechniqueStates(t *testing.T) {
+	currentStates := map[string]stratus.AttackTechniqueState{"aws.foo": stratus.AttackTechniqueStatusWarm}
+	metrics := New(testRegistry(), func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState {
+		return currentStates[technique.ID]
+	})
+
+	output := gatherText(t, metrics)
+	assert.Contains(t, output, `stratus_technique_state{platform="AWS",state="COLD",technique_id="aws.foo"} 0`)
+	assert.Contains(t, output, `stratus_technique_state{platform="AWS",state="WARM",technique_id="aws.foo"} 1`)
+	assert.Contains(t, output, `stratus_technique_state{platform="AWS",state="DETONATED",technique_id="aws.foo"} 0`)
+	// Techniques without a persisted state are cold
+	assert.Contains(t, output, `stratus_technique_state{platform="kubernetes",state="COLD",technique_id="k8s.bar"} 1`)
+
+	// The state is read again each time metrics are gathered
+	currentStates["aws.foo"] = stratus.AttackTechniqueStatusDetonated
+	output = gatherText(t, metrics)
+	assert.Contains(t, output, `stratus_technique_state{platform="AWS",state="WARM",technique_id="aws.foo"} 0`)
+	assert.Contains(t, output, `stratus_technique_state{platform="AWS",state="DETONATED",technique_id="aws.foo"} 1`)
+}
+
+func TestMetricsWritesTextfileAfterEachOperation(t *testing.T) {
+	metrics := New(nil, nil)
+	metrics.Textfile = filepath.Join(t.TempDir(), "stratus.prom")
+
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, time.Second, nil)))
+	content, err := os.ReadFile(metrics.Textfile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `stratus_detonations_total{outcome="success",platform="AWS",technique_id="aws.foo"} 1`)
+
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, time.Second, nil)))
+	content, err = os.ReadFile(metrics.Textfile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `stratus_detonations_total{outcome="success",platform="AWS",technique_id="aws.foo"} 2`)
+}
+
+func TestMetricsSeededFromPreviousRuns(t *testing.T) {
+	metrics := New(nil, nil)
+	metrics.Textfile = filepath.Join(t.TempDir(), "stratus.prom")
+	metrics.Seed([]history.Entry{
+		entry(history.OperationDetonate, time.Second, nil),
+		entry(history.OperationDetonate, time.Second, errors.New("access denied")),
+	})
+	// Seeding does not write the textfile
+	_, err := os.Stat(metrics.Textfile)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, time.Second, nil)))
+	content, err := os.ReadFile(metrics.Textfile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `stratus_detonations_total{outcome="success",platform="AWS",technique_id="aws.foo"} 2`)
+	assert.Contains(t, string(content), `stratus_detonations_total{outcome="failure",platform="AWS",technique_id="aws.foo"} 1`)
+	assert.Contains(t, string(content), `stratus_operation_duration_seconds_count{operation="detonate",outcome="success",platform="AWS",technique_id="aws.foo"} 2`)
+}
+
+func TestMetricsWriteTextfileFailsOnMissingDirectory(t *testing.T) {
+	metrics := New(nil, nil)
+	err := metrics.WriteTextfile(filepath.Join(t.TempDir(), "missing", "stratus.prom"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unable to write metrics textfile")
+}
+
+func TestMetricsServe(t *testing.T) {
+	metrics := New(testRegistry(), func(*stratus.AttackTechnique) stratus.AttackTechniqueState { return "" })
+	assert.Nil(t, metrics.Record(entry(history.OperationDetonate, time.Second, nil)))
+
+	server, err := metrics.Serve("127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close()
+
+	response, err := http.Get("http://" + server.Addr + "/metrics")
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(body), `stratus_detonations_total{outcome="success",platform="AWS",technique_id="aws.foo"} 1`)
+	assert.Contains(t, string(body), `stratus_technique_state{platform="AWS",state="COLD",technique_id="aws.foo"} 1`)
+
+	// The address is already in use
+	_, err = metrics.Serve(server.Addr)
+	assert.NotNil(t, err)
+}
+
+// gatherText returns the metrics in the text exposition format
+func gatherText(t *testing.T, metrics *Metrics) string {
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	assert.Nil(t, metrics.WriteTextfile(path))
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	return string(content)
+}
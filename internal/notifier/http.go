@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookNotifier posts events as JSON to a URL
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+func (m *WebhookNotifier) Notify(event Event) error {
+	return postJSON(m.Client, m.URL, m.Headers, event)
+}
+
+// HECNotifier sends events to an HTTP event collector, such as the one of Splunk
+type HECNotifier struct {
+	URL        string
+	Token      string
+	Index      string
+	Source     string
+	SourceType string
+	Client     *http.Client
+}
+
+type hecEvent struct {
+	Time       float64 `json:"time"`
+	Index      string  `json:"index,omitempty"`
+	Source     string  `json:"source,omitempty"`
+	SourceType string  `json:"sourcetype,omitempty"`
+	Event      Event   `json:"event"`
+}
+
+func (m *HECNotifier) Notify(event Event) error {
+	payload := hecEvent{
+		Time:       float64(event.EndTime.UnixNano()) / 1e9,
+		Index:      m.Index,
+		Source:     m.Source,
+		SourceType: m.SourceType,
+		Event:      event,
+	}
+	return postJSON(m.Client, m.URL, map[string]string{"Authorization": "Splunk " + m.Token}, payload)
+}
+
+func postJSON(client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.New("unable to send event to " + url + ": " + err.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unable to send event to %s: HTTP %d %s", url, response.StatusCode, bytes.TrimSpace(responseBody))
+	}
+	return nil
+}
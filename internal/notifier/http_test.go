@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var received Event
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		authorization = request.Header.Get("Authorization")
+		assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+		assert.Nil(t, json.NewDecoder(request.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}, Client: server.Client()}
+	assert.Nil(t, notifier.Notify(NewEvent(testEntry(), nil)))
+	assert.Equal(t, "Bearer secret", authorization)
+	assert.Equal(t, "aws.defense-evasion.cloudtrail-stop", received.TechniqueID)
+	assert.Equal(t, "e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e", received.ExecutionID)
+	assert.Equal(t, start, received.StartTime)
+}
+
+func TestWebhookNotifierFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusForbidden)
+		writer.Write([]byte("invalid token\n"))
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL, Client: server.Client()}
+	err := notifier.Notify(NewEvent(testEntry(), nil))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "HTTP 403 invalid token")
+}
+
+func TestHECNotifier(t *testing.T) {
+	var received struct {
+		Time       float64 `json:"time"`
+		Index      string  `json:"index"`
+		SourceType string  `json:"sourcetype"`
+		Event      Event   `json:"event"`
+	}
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		authorization = request.Header.Get("Authorization")
+		assert.Nil(t, json.NewDecoder(request.Body).Decode(&received))
+		writer.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	notifier := &HECNotifier{URL: server.URL, Token: "hec-token", Index: "purple-team", SourceType: "stratus", Client: server.Client()}
+	assert.Nil(t, notifier.Notify(NewEvent(testEntry(), nil)))
+	assert.Equal(t, "Splunk hec-token", authorization)
+	assert.Equal(t, "purple-team", received.Index)
+	assert.Equal(t, "stratus", received.SourceType)
+	assert.Equal(t, float64(start.Add(2*time.Second).Unix()), received.Time)
+	assert.Equal(t, "aws.defense-evasion.cloudtrail-stop", received.Event.TechniqueID)
+}
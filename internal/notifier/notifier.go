@@ -0,0 +1,163 @@
+// Package notifier sends an event to external systems, such as a SIEM, whenever an operation is run on an attack
+// technique. Analysts can use these events as ground truth to label the alerts triggered by Stratus Red Team
+package notifier
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/config"
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/redaction"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+// DefaultTimeout is the maximum time to deliver an event, when not configured
+const DefaultTimeout = 10 * time.Second
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is the payload sent to external systems when an operation has been run on an attack technique
+type Event struct {
+	Source        string                `json:"source"`
+	TechniqueID   string                `json:"technique_id"`
+	TechniqueName string                `json:"technique_name,omitempty"`
+	Platform      string                `json:"platform"`
+	Tactics       []string              `json:"tactics,omitempty"`
+	Operation     history.Operation     `json:"operation"`
+	Outcome       string                `json:"outcome"`
+	Error         string                `json:"error,omitempty"`
+	ExecutionID   string                `json:"execution_id"`
+	UserAgent     string                `json:"user_agent"`
+	Target        string                `json:"target,omitempty"`
+	Identity      string                `json:"identity,omitempty"`
+	StartTime     time.Time             `json:"start_time"`
+	EndTime       time.Time             `json:"end_time"`
+	Verification  *history.Verification `json:"verification,omitempty"`
+}
+
+// NewEvent builds the event of an operation run on an attack technique. The technique is optional. Errors are
+// redacted, since they may contain secrets
+func NewEvent(entry history.Entry, technique *stratus.AttackTechnique) Event {
+	event := Event{
+		Source:       "stratus-red-team",
+		TechniqueID:  entry.TechniqueID,
+		Platform:     entry.Platform,
+		Operation:    entry.Operation,
+		Outcome:      OutcomeSuccess,
+		Error:        redaction.Redact(entry.Error),
+		ExecutionID:  entry.ExecutionID,
+		UserAgent:    providers.GetStratusUserAgent(),
+		Target:       entry.Target,
+		Identity:     entry.Identity,
+		StartTime:    entry.StartTime,
+		EndTime:      entry.EndTime,
+		Verification: entry.Verification,
+	}
+	if entry.Verification != nil {
+		verification := *entry.Verification
+		verification.Error = redaction.Redact(verification.Error)
+		event.Verification = &verification
+	}
+	if !entry.Succeeded() {
+		event.Outcome = OutcomeFailure
+	}
+	if technique != nil {
+		event.TechniqueName = technique.FriendlyName
+		for _, tactic := range technique.MitreAttackTactics {
+			event.Tactics = append(event.Tactics, mitreattack.AttackTacticToString(tactic))
+		}
+	}
+	return event
+}
+
+// Notifier sends events to an external system
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// Dispatcher records operations run on attack techniques by sending their event to notifiers
+type Dispatcher struct {
+	Registry  *stratus.Registry
+	Notifiers []Notifier
+}
+
+var _ history.Recorder = &Dispatcher{}
+
+func NewDispatcher(registry *stratus.Registry, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{Registry: registry, Notifiers: notifiers}
+}
+
+func (m *Dispatcher) Record(entry history.Entry) error {
+	event := NewEvent(entry, m.Registry.GetAttackTechniqueByName(entry.TechniqueID))
+	var errs []string
+	for _, notifier := range m.Notifiers {
+		if err := notifier.Notify(event); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("unable to notify: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// New returns the notifier described by a configuration
+func New(notifierConfig config.NotifierConfig) (Notifier, error) {
+	timeout, err := notifierConfig.GetTimeout()
+	if err != nil {
+		return nil, err
+	}
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch notifierConfig.Type {
+	case "webhook":
+		if notifierConfig.URL == "" {
+			return nil, errors.New("webhook notifier requires a url")
+		}
+		for _, value := range notifierConfig.Headers {
+			redaction.RegisterSecret(value)
+		}
+		return &WebhookNotifier{URL: notifierConfig.URL, Headers: notifierConfig.Headers, Client: client}, nil
+	case "hec":
+		if notifierConfig.URL == "" || notifierConfig.Token == "" {
+			return nil, errors.New("hec notifier requires a url and a token")
+		}
+		redaction.RegisterSecret(notifierConfig.Token)
+		return &HECNotifier{
+			URL:        notifierConfig.URL,
+			Token:      notifierConfig.Token,
+			Index:      notifierConfig.Index,
+			Source:     notifierConfig.Source,
+			SourceType: notifierConfig.SourceType,
+			Client:     client,
+		}, nil
+	case "syslog":
+		return NewSyslogNotifier(notifierConfig.Address, timeout)
+	default:
+		return nil, errors.New("unknown notifier type '" + notifierConfig.Type + "', use webhook, syslog or hec")
+	}
+}
+
+// FromConfig returns the notifiers described by a configuration
+func FromConfig(notifierConfigs []config.NotifierConfig) ([]Notifier, error) {
+	var notifiers []Notifier
+	for _, notifierConfig := range notifierConfigs {
+		notifier, err := New(notifierConfig)
+		if err != nil {
+			return nil, errors.New("invalid notifier configuration: " + err.Error())
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
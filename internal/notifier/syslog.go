@@ -0,0 +1,117 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Facility of the syslog messages, "user-level messages"
+	syslogFacility = 1
+
+	syslogSeverityWarning = 4
+	syslogSeverityNotice  = 5
+
+	// Private enterprise number reserved for documentation (RFC 5612), used as the ID of structured data
+	syslogEnterpriseID = "32473"
+)
+
+// SyslogNotifier sends events to a syslog server, as RFC 5424 messages. Over TCP, messages are framed using octet
+// counting (RFC 6587)
+type SyslogNotifier struct {
+	Network  string
+	Address  string
+	Hostname string
+	Timeout  time.Duration
+}
+
+// NewSyslogNotifier returns a notifier sending events to a syslog server, e.g. at udp://localhost:514
+func NewSyslogNotifier(address string, timeout time.Duration) (*SyslogNotifier, error) {
+	parsed, err := url.Parse(address)
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("syslog notifier requires an address such as udp://localhost:514")
+	}
+	if parsed.Scheme != "udp" && parsed.Scheme != "tcp" {
+		return nil, errors.New("unsupported syslog protocol " + parsed.Scheme + ", use udp or tcp")
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	return &SyslogNotifier{Network: parsed.Scheme, Address: parsed.Host, Hostname: hostname, Timeout: timeout}, nil
+}
+
+func (m *SyslogNotifier) Notify(event Event) error {
+	message, err := m.Format(event)
+	if err != nil {
+		return err
+	}
+	if m.Network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	connection, err := net.DialTimeout(m.Network, m.Address, m.Timeout)
+	if err != nil {
+		return errors.New("unable to connect to syslog server " + m.Address + ": " + err.Error())
+	}
+	defer connection.Close()
+	connection.SetDeadline(time.Now().Add(m.Timeout))
+	if _, err := connection.Write([]byte(message)); err != nil {
+		return errors.New("unable to send event to syslog server " + m.Address + ": " + err.Error())
+	}
+	return nil
+}
+
+// Format formats an event as an RFC 5424 message, with the event as JSON in the message body
+func (m *SyslogNotifier) Format(event Event) (string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	severity := syslogSeverityNotice
+	if event.Outcome != OutcomeSuccess {
+		severity = syslogSeverityWarning
+	}
+	hostname := m.Hostname
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	return fmt.Sprintf("<%d>1 %s %s stratus-red-team %d %s %s %s",
+		syslogFacility*8+severity,
+		event.EndTime.UTC().Format(time.RFC3339Nano),
+		hostname,
+		os.Getpid(),
+		event.Operation,
+		structuredData(event),
+		body,
+	), nil
+}
+
+func structuredData(event Event) string {
+	params := [][2]string{
+		{"technique_id", event.TechniqueID},
+		{"execution_id", event.ExecutionID},
+		{"operation", string(event.Operation)},
+		{"outcome", event.Outcome},
+	}
+	var data strings.Builder
+	data.WriteString("[stratus@" + syslogEnterpriseID)
+	for _, param := range params {
+		data.WriteString(" " + param[0] + `="` + escapeParamValue(param[1]) + `"`)
+	}
+	data.WriteString("]")
+	return data.String()
+}
+
+// escapeParamValue escapes the characters that cannot appear as is in structured data parameter values
+func escapeParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
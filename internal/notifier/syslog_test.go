@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatsRFC5424Messages(t *testing.T) {
+	notifier := &SyslogNotifier{Hostname: "red-team-runner"}
+	event := NewEvent(testEntry(), nil)
+	event.TechniqueID = `technique"with]special\characters`
+
+	message, err := notifier.Format(event)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(message, "<13>1 2022-03-01T10:00:02Z red-team-runner stratus-red-team "), message)
+	assert.Contains(t, message, ` detonate [stratus@32473 technique_id="technique\"with\]special\\characters" execution_id="e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e" operation="detonate" outcome="success"] {"source":"stratus-red-team"`)
+
+	event.Outcome = OutcomeFailure
+	message, err = notifier.Format(event)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(message, "<12>1 "), message)
+}
+
+func TestSyslogNotifierOverUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	notifier, err := NewSyslogNotifier("udp://"+listener.LocalAddr().String(), time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, notifier.Notify(NewEvent(testEntry(), nil)))
+
+	buffer := make([]byte, 64*1024)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := listener.ReadFrom(buffer)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(buffer[:size]), "<13>1 "))
+	assert.Contains(t, string(buffer[:size]), `"technique_id":"aws.defense-evasion.cloudtrail-stop"`)
+}
+
+func TestSyslogNotifierOverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer connection.Close()
+		reader := bufio.NewReader(connection)
+		length, _ := reader.ReadString(' ')
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		message := make([]byte, size)
+		_, _ = io.ReadFull(reader, message)
+		received <- string(message)
+	}()
+
+	notifier, err := NewSyslogNotifier("tcp://"+listener.Addr().String(), time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, notifier.Notify(NewEvent(testEntry(), nil)))
+
+	select {
+	case message := <-received:
+		assert.True(t, strings.HasPrefix(message, "<13>1 "), message)
+		assert.True(t, strings.HasSuffix(message, "}"), message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received by the syslog server")
+	}
+}
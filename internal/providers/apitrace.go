@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+)
+
+// Functions below record the API calls made to each platform, in the API call recorder of their context
+
+func apiCallRecordingApiOptions() config.LoadOptionsFunc {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// After the service metadata has been registered, and before retries so that a call is recorded once
+			return stack.Initialize.Add(awsApiCallRecordingMiddleware(), middleware.After)
+		},
+	})
+}
+
+// awsApiCallRecordingMiddleware records AWS API calls, with the name of the operation as found in CloudTrail events
+func awsApiCallRecordingMiddleware() middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("StratusAPICallRecording", func(
+		ctx context.Context, input middleware.InitializeInput, next middleware.InitializeHandler,
+	) (out middleware.InitializeOutput, metadata middleware.Metadata, err error) {
+		if !apitrace.IsRecording(ctx) {
+			return next.HandleInitialize(ctx, input)
+		}
+		call := apitrace.Call{
+			Time:      time.Now().UTC(),
+			Platform:  "AWS",
+			Service:   awsmiddleware.GetServiceID(ctx),
+			Operation: awsmiddleware.GetOperationName(ctx),
+			Resource:  apitrace.AWSResource(input.Parameters),
+		}
+		out, metadata, err = next.HandleInitialize(ctx, input)
+
+		if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+			call.RequestID = requestID
+		}
+		if response, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+			call.StatusCode = response.StatusCode
+		}
+		var responseError *awshttp.ResponseError
+		if errors.As(err, &responseError) {
+			call.StatusCode = responseError.HTTPStatusCode()
+			call.RequestID = responseError.ServiceRequestID()
+		}
+		if err != nil {
+			call.Error = err.Error()
+		}
+		apitrace.Record(ctx, call)
+		return out, metadata, err
+	})
+}
+
+// azureApiCallRecordingPolicy is an Azure SDK pipeline policy, run once per API call, that records Azure API calls
+type azureApiCallRecordingPolicy struct{}
+
+func (m *azureApiCallRecordingPolicy) Do(request *policy.Request) (*http.Response, error) {
+	ctx := request.Raw().Context()
+	if !apitrace.IsRecording(ctx) {
+		return request.Next()
+	}
+	start := time.Now().UTC()
+	response, err := request.Next()
+	apitrace.Record(ctx, apitrace.NewHTTPCall("Azure", apitrace.DescribeAzureRequest, start, request.Raw(), response, err))
+	return response, err
+}
+
+// newKubernetesApiCallRecordingTransport returns an HTTP transport recording the calls made to the Kubernetes API
+func newKubernetesApiCallRecordingTransport(next http.RoundTripper) http.RoundTripper {
+	return apitrace.NewTransport("Kubernetes", apitrace.DescribeKubernetesRequest, next)
+}
@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+// awsTestServer responds to sts:GetCallerIdentity with the identity of the caller, unless its access key is denied,
+// and to ssm:SendCommand with a command
+type awsTestServer struct {
+	*httptest.Server
+	authorizations []string
+}
+
+func newAWSTestServer(t *testing.T) *awsTestServer {
+	server := &awsTestServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		server.authorizations = append(server.authorizations, authorization)
+		if r.Header.Get("X-Amz-Target") == "AmazonSSM.SendCommand" {
+			w.Header().Set("X-Amzn-RequestId", "req-ssm")
+			w.Write([]byte(`{"Command": {"CommandId": "command-1"}}`))
+			return
+		}
+		if strings.Contains(authorization, "Credential=AKIADENIED/") {
+			w.Header().Set("X-Amzn-RequestId", "req-denied")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error><RequestId>req-denied</RequestId></ErrorResponse>`))
+			return
+		}
+		w.Header().Set("X-Amzn-RequestId", "req-identity")
+		w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult><Arn>arn:aws:iam::123456789012:user/foo</Arn>` +
+			`<UserId>AIDAEXAMPLE</UserId><Account>123456789012</Account></GetCallerIdentityResult>` +
+			`<ResponseMetadata><RequestId>req-identity</RequestId></ResponseMetadata></GetCallerIdentityResponse>`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// config returns an AWS configuration sending API calls to the server, and recording them
+func (m *awsTestServer) config(t *testing.T, accessKeyId string) aws.Config {
+	// Ignore the AWS configuration of the environment, e.g. a custom CA bundle
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		apiCallRecordingApiOptions(),
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKeyId, "secret", "")),
+		config.WithHTTPClient(m.Client()),
+		config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: m.URL, HostnameImmutable: true, SigningRegion: region}, nil
+		})),
+	)
+	assert.Nil(t, err)
+	return cfg
+}
+
+func TestAwsApiCallRecordingMiddleware(t *testing.T) {
+	server := newAWSTestServer(t)
+	recorder := apitrace.NewRecorder()
+	ctx := apitrace.WithRecorder(context.Background(), recorder)
+
+	_, err := sts.NewFromConfig(server.config(t, "AKIAEXAMPLE")).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	assert.Nil(t, err)
+	_, err = sts.NewFromConfig(server.config(t, "AKIADENIED")).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	assert.NotNil(t, err)
+	_, err = ssm.NewFromConfig(server.config(t, "AKIAEXAMPLE")).SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  []string{"i-1"},
+	})
+	assert.Nil(t, err)
+	// Calls made without a recorder are not recorded
+	_, err = sts.NewFromConfig(server.config(t, "AKIAEXAMPLE")).GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	assert.Nil(t, err)
+
+	calls := recorder.Calls()
+	if assert.Len(t, calls, 3) {
+		assert.Equal(t, "AWS", calls[0].Platform)
+		assert.Equal(t, "STS", calls[0].Service)
+		assert.Equal(t, "GetCallerIdentity", calls[0].Operation)
+		assert.Equal(t, 200, calls[0].StatusCode)
+		assert.Equal(t, "req-identity", calls[0].RequestID)
+		assert.True(t, calls[0].Succeeded())
+		assert.False(t, calls[0].Time.IsZero())
+
+		assert.Equal(t, "GetCallerIdentity", calls[1].Operation)
+		assert.Equal(t, 403, calls[1].StatusCode)
+		assert.Equal(t, "req-denied", calls[1].RequestID)
+		assert.Contains(t, calls[1].Error, "AccessDenied")
+		assert.False(t, calls[1].Succeeded())
+
+		assert.Equal(t, "SSM", calls[2].Service)
+		assert.Equal(t, "SendCommand", calls[2].Operation)
+		assert.Equal(t, "i-1", calls[2].Resource)
+		assert.Equal(t, "req-ssm", calls[2].RequestID)
+	}
+}
+
+func TestAWSProviderGetConnectionWithCredentials(t *testing.T) {
+	server := newAWSTestServer(t)
+	provider := NewAWSProviderFromConfig(server.config(t, "AKIAEXAMPLE"))
+	recorder := apitrace.NewRecorder()
+	ctx := apitrace.WithRecorder(context.Background(), recorder)
+
+	stolenConnection := provider.GetConnectionWithCredentials("AKIASTOLEN", "stolen-secret", "stolen-token")
+	identity, err := sts.NewFromConfig(stolenConnection).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, "arn:aws:iam::123456789012:user/foo", *identity.Arn)
+	}
+	assert.Contains(t, server.authorizations[0], "Credential=AKIASTOLEN/")
+	if assert.Len(t, recorder.Calls(), 1) {
+		assert.Equal(t, "req-identity", recorder.Calls()[0].RequestID)
+	}
+
+	// The connection of the provider keeps its own credentials
+	_, err = sts.NewFromConfig(provider.GetConnection()).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	assert.Nil(t, err)
+	assert.Contains(t, server.authorizations[1], "Credential=AKIAEXAMPLE/")
+}
+
+func TestAWSProviderGetHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state": "SUCCESS"}`))
+	}))
+	defer server.Close()
+	recorder := apitrace.NewRecorder()
+	ctx := apitrace.WithRecorder(context.Background(), recorder)
+
+	client := NewAWSProviderFromConfig(aws.Config{}).GetHTTPClient(func(request *http.Request, response *http.Response) apitrace.Call {
+		return apitrace.Call{Service: "Signin", Operation: "ConsoleLogin", Resource: "my-user"}
+	})
+	request, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/authenticate", nil)
+	response, err := client.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	assert.Equal(t, []apitrace.Call{{
+		Time:       recorder.Calls()[0].Time,
+		Platform:   "AWS",
+		Service:    "Signin",
+		Operation:  "ConsoleLogin",
+		Resource:   "my-user",
+		StatusCode: 200,
+	}}, recorder.Calls())
+}
+
+func TestK8sProviderGetRestConfigForToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Audit-Id", "audit-1")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	provider := NewK8sProvider(K8sOptions{})
+	provider.SetClient(fake.NewSimpleClientset())
+	provider.RestConfig = &rest.Config{Host: server.URL, BearerToken: "own-token", UserAgent: "stratus-red-team"}
+	recorder := apitrace.NewRecorder()
+	ctx := apitrace.WithRecorder(context.Background(), recorder)
+
+	config, err := provider.GetRestConfigForToken("service-account-token")
+	assert.Nil(t, err)
+	httpClient, err := rest.HTTPClientFor(config)
+	assert.Nil(t, err)
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/nodes/node-1/proxy/runningpods/", nil)
+	response, err := httpClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	assert.Equal(t, "Bearer service-account-token", authorization)
+	if assert.Len(t, recorder.Calls(), 1) {
+		assert.Equal(t, "get nodes/proxy/runningpods", recorder.Calls()[0].Operation)
+		assert.Equal(t, "node-1", recorder.Calls()[0].Resource)
+		assert.Equal(t, "audit-1", recorder.Calls()[0].RequestID)
+	}
+}
+
+func TestK8sProviderGetRestConfigForTokenWithoutRestConfig(t *testing.T) {
+	provider := NewK8sProvider(K8sOptions{})
+	provider.SetClient(fake.NewSimpleClientset())
+
+	_, err := provider.GetRestConfigForToken("service-account-token")
+	assert.NotNil(t, err)
+}
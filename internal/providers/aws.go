@@ -2,14 +2,21 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go/middleware"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
 	"github.com/google/uuid"
 	"log"
+	"net/http"
+	"sync"
 )
 
 var awsProvider = AWSProvider{
@@ -20,23 +27,143 @@ func AWS() *AWSProvider {
 	return &awsProvider
 }
 
+// NewAWSProvider returns an AWS provider independent of the default one, e.g. to target another AWS account
+func NewAWSProvider(options AWSOptions) *AWSProvider {
+	provider := &AWSProvider{UniqueCorrelationId: UniqueExecutionId}
+	provider.SetOptions(options)
+	return provider
+}
+
+// NewAWSProviderFromConfig returns an AWS provider using an existing AWS configuration, e.g. targeting a local emulator
+func NewAWSProviderFromConfig(cfg aws.Config) *AWSProvider {
+	return &AWSProvider{awsConfig: &cfg, UniqueCorrelationId: UniqueExecutionId}
+}
+
 type AWSProvider struct {
 	awsConfig           *aws.Config
+	options             AWSOptions
+	callerIdentityArn   string // cached, since the identity is described before each operation
+	callerIdentityLock  sync.Mutex
 	UniqueCorrelationId uuid.UUID // unique value injected in the user-agent, to differentiate Stratus Red Team executions
+
+	// Additional options to load the AWS configuration with, e.g. to send API calls to a fake server in tests
+	configOptions []func(*config.LoadOptions) error
+}
+
+// AWSOptions allows to override the AWS profile, region and identity resolved from the environment
+type AWSOptions struct {
+	// Name of the AWS profile to use, from the shared configuration files
+	Profile string
+
+	// AWS region to use
+	Region string
+
+	// ARN of an IAM role to assume before making any API call
+	AssumeRoleArn string
+
+	// External ID to use when assuming the role, if required by its trust policy
+	AssumeRoleExternalId string
+
+	// Session name to use when assuming the role
+	AssumeRoleSessionName string
+}
+
+const DefaultAssumeRoleSessionName = "stratus-red-team"
+
+// SetOptions configures how the AWS provider authenticates. Must be called before any connection is made
+func (m *AWSProvider) SetOptions(options AWSOptions) {
+	if options.AssumeRoleSessionName == "" {
+		options.AssumeRoleSessionName = DefaultAssumeRoleSessionName
+	}
+	m.options = options
+	m.awsConfig = nil
+	m.callerIdentityArn = ""
+}
+
+func (m *AWSProvider) GetOptions() AWSOptions {
+	return m.options
 }
 
 func (m *AWSProvider) GetConnection() aws.Config {
 	if m.awsConfig == nil {
-		cfg, err := config.LoadDefaultConfig(context.Background(), customUserAgentApiOptions(m.UniqueCorrelationId))
+		loadOptions := []func(*config.LoadOptions) error{
+			customUserAgentApiOptions(m.UniqueCorrelationId),
+			apiCallRecordingApiOptions(),
+			tracingApiOptions(),
+			retryApiOptions(GetRetryPolicy()),
+			config.WithRetryer(retryerProvider(GetRetryPolicy())),
+		}
+		if m.options.Profile != "" {
+			loadOptions = append(loadOptions, config.WithSharedConfigProfile(m.options.Profile))
+		}
+		if m.options.Region != "" {
+			loadOptions = append(loadOptions, config.WithRegion(m.options.Region))
+		}
+		loadOptions = append(loadOptions, m.configOptions...)
+		cfg, err := config.LoadDefaultConfig(context.Background(), loadOptions...)
 		if err != nil {
 			log.Fatalf("unable to load AWS configuration, %v", err)
 		}
+
+		if m.options.AssumeRoleArn != "" {
+			assumeRoleProvider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), m.options.AssumeRoleArn, func(options *stscreds.AssumeRoleOptions) {
+				options.RoleSessionName = m.options.AssumeRoleSessionName
+				if m.options.AssumeRoleExternalId != "" {
+					options.ExternalID = aws.String(m.options.AssumeRoleExternalId)
+				}
+			})
+			cfg.Credentials = aws.NewCredentialsCache(assumeRoleProvider)
+		}
 		m.awsConfig = &cfg
 	}
 
 	return *m.awsConfig
 }
 
+// GetConnectionWithCredentials returns an AWS configuration authenticated with static credentials, e.g. stolen ones,
+// instead of the credentials of the provider. API calls made with it are traced and recorded as any other
+func (m *AWSProvider) GetConnectionWithCredentials(accessKeyId string, secretAccessKey string, sessionToken string) aws.Config {
+	cfg := m.GetConnection()
+	cfg.Credentials = aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(accessKeyId, secretAccessKey, sessionToken))
+	return cfg
+}
+
+// GetHTTPClient returns an HTTP client for AWS endpoints that are not part of the AWS SDK, such as the console
+// sign-in endpoint. Requests made with it are traced, and recorded as the API calls returned by a describe function
+func (m *AWSProvider) GetHTTPClient(describe apitrace.DescribeFunc) *http.Client {
+	return &http.Client{Transport: newTracingTransport("AWS", apitrace.NewTransport("AWS", describe, http.DefaultTransport))}
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform so that the AWS Terraform provider
+// uses the same profile, region and identity as the AWS SDK. A variable with an empty value is removed from the
+// environment
+func (m *AWSProvider) TerraformEnvironment() (map[string]string, error) {
+	env := map[string]string{}
+	if m.options.Profile != "" {
+		// The profile takes precedence over static credentials from the environment, as it does for the AWS SDK
+		env["AWS_PROFILE"] = m.options.Profile
+		env["AWS_ACCESS_KEY_ID"] = ""
+		env["AWS_SECRET_ACCESS_KEY"] = ""
+		env["AWS_SESSION_TOKEN"] = ""
+	}
+	if m.options.Region != "" {
+		env["AWS_REGION"] = m.options.Region
+		env["AWS_DEFAULT_REGION"] = m.options.Region
+	}
+	if m.options.AssumeRoleArn != "" {
+		// Pass the temporary credentials of the assumed role, rather than configuring the role in the Terraform code
+		credentials, err := m.GetConnection().Credentials.Retrieve(context.Background())
+		if err != nil {
+			return nil, errors.New("unable to assume role " + m.options.AssumeRoleArn + ": " + err.Error())
+		}
+		env["AWS_ACCESS_KEY_ID"] = credentials.AccessKeyID
+		env["AWS_SECRET_ACCESS_KEY"] = credentials.SecretAccessKey
+		env["AWS_SESSION_TOKEN"] = credentials.SessionToken
+		env["AWS_PROFILE"] = ""
+	}
+	return env, nil
+}
+
 func (m *AWSProvider) IsAuthenticatedAgainstAWS() bool {
 	m.GetConnection()
 
@@ -48,6 +175,45 @@ func (m *AWSProvider) IsAuthenticatedAgainstAWS() bool {
 	return err == nil
 }
 
+// GetCallerIdentityArn returns the ARN of the AWS identity Stratus Red Team is authenticated as. It is only retrieved
+// once, until the options of the provider change
+func (m *AWSProvider) GetCallerIdentityArn() (string, error) {
+	m.callerIdentityLock.Lock()
+	defer m.callerIdentityLock.Unlock()
+	if m.callerIdentityArn != "" {
+		return m.callerIdentityArn, nil
+	}
+	stsClient := sts.NewFromConfig(m.GetConnection())
+	identity, err := stsClient.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.New("unable to retrieve the current AWS identity: " + err.Error())
+	}
+	m.callerIdentityArn = *identity.Arn
+	return m.callerIdentityArn, nil
+}
+
+// retryerProvider returns a function returning the same retryer for all AWS clients, so that retry quotas and
+// adaptive rate limits are shared across API calls
+func retryerProvider(policy RetryPolicy) func() aws.Retryer {
+	retryer := policy.newAwsRetryer()
+	return func() aws.Retryer {
+		return retryer
+	}
+}
+
+// retryApiOptions adds a client-side rate limit to all AWS API calls, if configured
+func retryApiOptions(policy RetryPolicy) config.LoadOptionsFunc {
+	limiter := policy.newRateLimiter()
+	return config.WithAPIOptions(func() (v []func(stack *middleware.Stack) error) {
+		if limiter != nil {
+			v = append(v, func(stack *middleware.Stack) error {
+				return stack.Finalize.Add(awsRateLimitMiddleware(limiter), middleware.After)
+			})
+		}
+		return v
+	}())
+}
+
 // Functions below are related to customization of the user-agent header
 // Code mostly taken from https://github.com/aws/aws-sdk-go-v2/issues/1432
 
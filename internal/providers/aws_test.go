@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSProviderCachesCallerIdentity(t *testing.T) {
+	server := newAWSTestServer(t)
+	provider := NewAWSProviderFromConfig(server.config(t, "AKIAEXAMPLE"))
+
+	for i := 0; i < 2; i++ {
+		arn, err := provider.GetCallerIdentityArn()
+		assert.Nil(t, err)
+		assert.Equal(t, "arn:aws:iam::123456789012:user/foo", arn)
+	}
+	assert.Len(t, server.authorizations, 1)
+
+	// Failures are not cached
+	provider = NewAWSProviderFromConfig(server.config(t, "AKIADENIED"))
+	_, err := provider.GetCallerIdentityArn()
+	assert.NotNil(t, err)
+	_, err = provider.GetCallerIdentityArn()
+	assert.NotNil(t, err)
+	assert.Len(t, server.authorizations, 3)
+}
+
+// newSTSTestServer returns a stand-in for STS, returning temporary credentials for the roles it is asked to assume
+func newSTSTestServer(t *testing.T) (*httptest.Server, *[]url.Values) {
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request, _ := url.ParseQuery(string(body))
+		requests = append(requests, request)
+		if request.Get("Action") != "AssumeRole" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>` +
+			`<AccessKeyId>ASIATEMPORARY</AccessKeyId><SecretAccessKey>temporary-secret</SecretAccessKey>` +
+			`<SessionToken>temporary-token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration>` +
+			`</Credentials><AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/my-role/stratus-red-team</Arn>` +
+			`<AssumedRoleId>AROAEXAMPLE:stratus-red-team</AssumedRoleId></AssumedRoleUser></AssumeRoleResult></AssumeRoleResponse>`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// newAWSProviderForServer returns an AWS provider sending API calls to a server, authenticated with static credentials
+// from the environment and ignoring the shared configuration files
+func newAWSProviderForServer(t *testing.T, options AWSOptions, server *httptest.Server) *AWSProvider {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIASTATIC")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "static-secret")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	provider := NewAWSProvider(options)
+	provider.configOptions = []func(*config.LoadOptions) error{
+		config.WithHTTPClient(server.Client()),
+		config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+		config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: server.URL, HostnameImmutable: true, SigningRegion: region}, nil
+		})),
+	}
+	return provider
+}
+
+func TestAWSProviderTerraformEnvironment(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		options  AWSOptions
+		expected map[string]string
+	}{
+		{
+			name:     "no options",
+			expected: map[string]string{},
+		},
+		{
+			name:    "profile only",
+			options: AWSOptions{Profile: "my-profile"},
+			expected: map[string]string{
+				"AWS_PROFILE":           "my-profile",
+				"AWS_ACCESS_KEY_ID":     "",
+				"AWS_SECRET_ACCESS_KEY": "",
+				"AWS_SESSION_TOKEN":     "",
+			},
+		},
+		{
+			name:     "region only",
+			options:  AWSOptions{Region: "eu-west-3"},
+			expected: map[string]string{"AWS_REGION": "eu-west-3", "AWS_DEFAULT_REGION": "eu-west-3"},
+		},
+		{
+			name:    "assumed role",
+			options: AWSOptions{AssumeRoleArn: "arn:aws:iam::123456789012:role/my-role", AssumeRoleExternalId: "my-external-id"},
+			expected: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "ASIATEMPORARY",
+				"AWS_SECRET_ACCESS_KEY": "temporary-secret",
+				"AWS_SESSION_TOKEN":     "temporary-token",
+				"AWS_PROFILE":           "",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server, requests := newSTSTestServer(t)
+			provider := newAWSProviderForServer(t, scenario.options, server)
+
+			env, err := provider.TerraformEnvironment()
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.expected, env)
+			if scenario.options.AssumeRoleArn == "" {
+				assert.Empty(t, *requests)
+				return
+			}
+			// The role is assumed with the static credentials of the environment
+			if assert.Len(t, *requests, 1) {
+				assert.Equal(t, scenario.options.AssumeRoleArn, (*requests)[0].Get("RoleArn"))
+				assert.Equal(t, "my-external-id", (*requests)[0].Get("ExternalId"))
+				assert.Equal(t, DefaultAssumeRoleSessionName, (*requests)[0].Get("RoleSessionName"))
+			}
+		})
+	}
+}
+
+func TestAWSProviderTerraformEnvironmentAssumeRoleFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+	provider := newAWSProviderForServer(t, AWSOptions{AssumeRoleArn: "arn:aws:iam::123456789012:role/my-role"}, server)
+
+	_, err := provider.TerraformEnvironment()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unable to assume role arn:aws:iam::123456789012:role/my-role")
+}
@@ -1,24 +1,84 @@
 package providers
 
 import (
-	"log"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	armruntime "github.com/Azure/azure-sdk-for-go/sdk/azcore/arm/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/google/uuid"
+	"golang.org/x/time/rate"
 )
 
-const azureSubscriptionIdEnvVarKey = "AZURE_SUBSCRIPTION_ID"
+const (
+	azureSubscriptionIdEnvVarKey = "AZURE_SUBSCRIPTION_ID"
+	azureTenantIdEnvVarKey       = "AZURE_TENANT_ID"
+	azureClientIdEnvVarKey       = "AZURE_CLIENT_ID"
+	azureClientSecretEnvVarKey   = "AZURE_CLIENT_SECRET"
+)
+
+// Azure clouds, named after the Azure CLI ones (az cloud list)
+const (
+	AzureCloudPublic            = "AzureCloud"
+	AzureCloudUSGovernment      = "AzureUSGovernment"
+	AzureCloudChina             = "AzureChina"
+	azureSubscriptionApiVersion = "2020-01-01"
+)
+
+// Azure credential types
+const (
+	// AzureCredentialDefault tries environment variables, managed identity and the Azure CLI, in this order
+	AzureCredentialDefault          = "default"
+	AzureCredentialCLI              = "cli"
+	AzureCredentialManagedIdentity  = "managed-identity"
+	AzureCredentialServicePrincipal = "service-principal"
+)
 
 type AzureProvider struct {
-	Credentials         *azidentity.DefaultAzureCredential
+	Credentials         azcore.TokenCredential
 	ClientOptions       *arm.ClientOptions
 	SubscriptionID      string
 	UniqueCorrelationId uuid.UUID // unique value injected in the user-agent, to differentiate Stratus Red Team executions
+	options             AzureOptions
+	rateLimiter         *rate.Limiter
+	rateLimiterOnce     sync.Once
+	identity            *AzureIdentity // cached, since the identity is described before each operation
+	identityLock        sync.Mutex
+}
+
+// AzureOptions allows to override the subscription, tenant, cloud and credentials used against Azure
+type AzureOptions struct {
+	// Subscription to use, instead of the one in the AZURE_SUBSCRIPTION_ID environment variable
+	SubscriptionID string
+
+	// Tenant to authenticate against, instead of the default one of the credential
+	TenantID string
+
+	// Azure cloud, one of AzureCloud (default), AzureUSGovernment or AzureChina
+	Cloud string
+
+	// Type of credential to use, one of default, cli, managed-identity or service-principal
+	Credential string
+}
+
+// AzureIdentity describes who Stratus Red Team is authenticated as, and against which subscription
+type AzureIdentity struct {
+	TenantID         string
+	SubscriptionID   string
+	SubscriptionName string
+	Principal        string
 }
 
 var DefaultClientOptions = arm.ClientOptions{
@@ -37,25 +97,264 @@ func Azure() *AzureProvider {
 	return &azureProvider
 }
 
-func (m *AzureProvider) GetCredentials() *azidentity.DefaultAzureCredential {
+// NewAzureProvider returns an Azure provider independent of the default one, e.g. to target another subscription
+func NewAzureProvider(options AzureOptions) (*AzureProvider, error) {
+	provider := &AzureProvider{
+		UniqueCorrelationId: UniqueExecutionId,
+		SubscriptionID:      os.Getenv(azureSubscriptionIdEnvVarKey),
+		ClientOptions:       &DefaultClientOptions,
+	}
+	if err := provider.SetOptions(options); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// SetOptions configures how the Azure provider authenticates. Must be called before any client is built
+func (m *AzureProvider) SetOptions(options AzureOptions) error {
+	if options.Cloud == "" {
+		options.Cloud = AzureCloudPublic
+	}
+	if options.Credential == "" {
+		options.Credential = AzureCredentialDefault
+	}
+
+	cloudConfiguration, err := getAzureCloudConfiguration(options.Cloud)
+	if err != nil {
+		return err
+	}
+	switch options.Credential {
+	case AzureCredentialDefault, AzureCredentialCLI, AzureCredentialManagedIdentity, AzureCredentialServicePrincipal:
+	default:
+		return errors.New("unknown Azure credential type " + options.Credential + ", must be one of " +
+			strings.Join([]string{AzureCredentialDefault, AzureCredentialCLI, AzureCredentialManagedIdentity, AzureCredentialServicePrincipal}, ", "))
+	}
+
+	clientOptions := *m.ClientOptions
+	clientOptions.Cloud = cloudConfiguration
+	m.ClientOptions = &clientOptions
+	if options.SubscriptionID != "" {
+		m.SubscriptionID = options.SubscriptionID
+	}
+	m.options = options
+	m.Credentials = nil
+	m.identity = nil
+	return nil
+}
+
+func (m *AzureProvider) GetOptions() AzureOptions {
+	return m.options
+}
 
+func getAzureCloudConfiguration(name string) (cloud.Configuration, error) {
+	switch name {
+	case AzureCloudPublic:
+		return cloud.AzurePublic, nil
+	case AzureCloudUSGovernment:
+		return cloud.AzureGovernment, nil
+	case AzureCloudChina:
+		return cloud.AzureChina, nil
+	default:
+		return cloud.Configuration{}, errors.New("unknown Azure cloud " + name + ", must be one of " +
+			strings.Join([]string{AzureCloudPublic, AzureCloudUSGovernment, AzureCloudChina}, ", "))
+	}
+}
+
+// GetCredentials returns the credentials to use against Azure, using the selected type of credential
+func (m *AzureProvider) GetCredentials() (azcore.TokenCredential, error) {
 	if len(m.SubscriptionID) == 0 {
-		log.Fatal(azureSubscriptionIdEnvVarKey + " is not set.")
+		return nil, errors.New(azureSubscriptionIdEnvVarKey + " is not set, and no subscription was selected with --azure-subscription")
 	}
+	return m.GetTokenCredential()
+}
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+// GetTokenCredential returns the credentials to use against Azure and Entra ID, without requiring a subscription
+func (m *AzureProvider) GetTokenCredential() (azcore.TokenCredential, error) {
+	if m.Credentials != nil {
+		return m.Credentials, nil
+	}
+
+	cred, err := m.newCredentials()
 	if err != nil {
-		log.Fatalf("failed to pull the result: %v", err)
+		return nil, errors.New("unable to load Azure credentials: " + err.Error())
 	}
 	m.Credentials = cred
 
-	return m.Credentials
+	return m.Credentials, nil
 }
 
-func (m *AzureProvider) IsAuthenticatedAgainstAzure() bool {
+func (m *AzureProvider) newCredentials() (azcore.TokenCredential, error) {
+	clientOptions := azcore.ClientOptions{Cloud: m.ClientOptions.Cloud}
 
-	cred := m.GetCredentials()
-	_, err := armresources.NewClient(m.SubscriptionID, cred, nil)
+	switch m.options.Credential {
+	case AzureCredentialCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: m.options.TenantID})
+	case AzureCredentialManagedIdentity:
+		options := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOptions}
+		// Use a user-assigned managed identity, if specified
+		if clientId := os.Getenv(azureClientIdEnvVarKey); clientId != "" {
+			options.ID = azidentity.ClientID(clientId)
+		}
+		return azidentity.NewManagedIdentityCredential(options)
+	case AzureCredentialServicePrincipal:
+		tenantId := m.getTenantId()
+		clientId := os.Getenv(azureClientIdEnvVarKey)
+		clientSecret := os.Getenv(azureClientSecretEnvVarKey)
+		if tenantId == "" || clientId == "" || clientSecret == "" {
+			return nil, errors.New("authenticating with a service principal requires " + azureClientIdEnvVarKey + ", " +
+				azureClientSecretEnvVarKey + " and " + azureTenantIdEnvVarKey + " (or --azure-tenant) to be set")
+		}
+		return azidentity.NewClientSecretCredential(tenantId, clientId, clientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions})
+	default:
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: clientOptions, TenantID: m.options.TenantID})
+	}
+}
+
+func (m *AzureProvider) getTenantId() string {
+	if m.options.TenantID != "" {
+		return m.options.TenantID
+	}
+	return os.Getenv(azureTenantIdEnvVarKey)
+}
+
+// GetIdentity makes an authenticated call to Azure to retrieve the selected subscription, and returns
+// who Stratus Red Team is authenticated as. The call is only made once per subscription
+func (m *AzureProvider) GetIdentity() (*AzureIdentity, error) {
+	cred, err := m.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
+	m.identityLock.Lock()
+	defer m.identityLock.Unlock()
+	if m.identity != nil && m.identity.SubscriptionID == m.SubscriptionID {
+		return m.identity, nil
+	}
+	resourceManager := m.ClientOptions.Cloud.Services[cloud.ResourceManager]
+	if m.ClientOptions.Cloud.Services == nil {
+		resourceManager = cloud.AzurePublic.Services[cloud.ResourceManager]
+	}
+
+	token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{Scopes: []string{resourceManager.Audience + "/.default"}})
+	if err != nil {
+		return nil, errors.New("unable to retrieve an Azure access token: " + err.Error())
+	}
+
+	pipeline, err := armruntime.NewPipeline("stratus-red-team", "", cred, runtime.PipelineOptions{}, m.GetClientOptions())
+	if err != nil {
+		return nil, err
+	}
+	request, err := runtime.NewRequest(context.Background(), http.MethodGet,
+		runtime.JoinPaths(resourceManager.Endpoint, "/subscriptions/", url.PathEscape(m.SubscriptionID)))
+	if err != nil {
+		return nil, err
+	}
+	query := request.Raw().URL.Query()
+	query.Set("api-version", azureSubscriptionApiVersion)
+	request.Raw().URL.RawQuery = query.Encode()
+
+	response, err := pipeline.Do(request)
+	if err != nil {
+		return nil, errors.New("unable to retrieve Azure subscription " + m.SubscriptionID + ": " + err.Error())
+	}
+	if !runtime.HasStatusCode(response, http.StatusOK) {
+		return nil, errors.New("unable to retrieve Azure subscription " + m.SubscriptionID + ": " + runtime.NewResponseError(response).Error())
+	}
+	var subscription struct {
+		SubscriptionID string `json:"subscriptionId"`
+		DisplayName    string `json:"displayName"`
+		TenantID       string `json:"tenantId"`
+	}
+	if err := runtime.UnmarshalAsJSON(response, &subscription); err != nil {
+		return nil, errors.New("unable to parse Azure subscription: " + err.Error())
+	}
+
+	m.identity = &AzureIdentity{
+		TenantID:         subscription.TenantID,
+		SubscriptionID:   subscription.SubscriptionID,
+		SubscriptionName: subscription.DisplayName,
+		Principal:        principalFromAccessToken(token.Token),
+	}
+	return m.identity, nil
+}
+
+// principalFromAccessToken returns a human-readable name of the principal an access token was issued to
+func principalFromAccessToken(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "unknown"
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "unknown"
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "unknown"
+	}
+	// Users have a UPN, service principals and managed identities only have an application ID
+	for _, claim := range []string{"upn", "unique_name", "appid", "oid"} {
+		if value, ok := claims[claim].(string); ok && value != "" {
+			return value
+		}
+	}
+	return "unknown"
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform so that the Azure Terraform provider
+// uses the same subscription, tenant, cloud and credentials as the Azure SDK
+func (m *AzureProvider) TerraformEnvironment() (map[string]string, error) {
+	env := map[string]string{}
+	if m.SubscriptionID != "" {
+		env["ARM_SUBSCRIPTION_ID"] = m.SubscriptionID
+	}
+	if tenantId := m.getTenantId(); tenantId != "" {
+		env["ARM_TENANT_ID"] = tenantId
+	}
+	switch m.options.Cloud {
+	case AzureCloudUSGovernment:
+		env["ARM_ENVIRONMENT"] = "usgovernment"
+	case AzureCloudChina:
+		env["ARM_ENVIRONMENT"] = "china"
+	}
+	switch m.options.Credential {
+	case AzureCredentialManagedIdentity:
+		env["ARM_USE_MSI"] = "true"
+		if clientId := os.Getenv(azureClientIdEnvVarKey); clientId != "" {
+			env["ARM_CLIENT_ID"] = clientId
+		}
+	case AzureCredentialServicePrincipal:
+		env["ARM_CLIENT_ID"] = os.Getenv(azureClientIdEnvVarKey)
+		env["ARM_CLIENT_SECRET"] = os.Getenv(azureClientSecretEnvVarKey)
+	case AzureCredentialCLI:
+		// Make sure the Terraform provider does not pick up a service principal from the environment
+		env["ARM_CLIENT_ID"] = ""
+		env["ARM_CLIENT_SECRET"] = ""
+		env["ARM_USE_MSI"] = ""
+	}
+	return env, nil
+}
+
+// GetClientOptions returns the options to use when instantiating Azure clients, including the retry policy
+func (m *AzureProvider) GetClientOptions() *arm.ClientOptions {
+	retryPolicy := GetRetryPolicy()
+	options := *m.ClientOptions
+	options.Retry = retryPolicy.azureRetryOptions()
+	options.PerCallPolicies = append(
+		append([]policy.Policy{}, options.PerCallPolicies...),
+		&azureTracingPolicy{},
+		&azureApiCallRecordingPolicy{},
+	)
+	options.PerRetryPolicies = append(
+		append([]policy.Policy{}, options.PerRetryPolicies...),
+		&azureThrottlingPolicy{limiter: m.getRateLimiter(retryPolicy)},
+	)
+	return &options
+}
 
-	return err == nil
+// getRateLimiter returns the rate limiter shared by all Azure clients
+func (m *AzureProvider) getRateLimiter(retryPolicy RetryPolicy) *rate.Limiter {
+	m.rateLimiterOnce.Do(func() {
+		m.rateLimiter = retryPolicy.newRateLimiter()
+	})
+	return m.rateLimiter
 }
@@ -0,0 +1,253 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// accessToken returns an unsigned JWT with a given payload
+func accessToken(payload string) string {
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestPrincipalFromAccessToken(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{name: "user", token: accessToken(`{"upn": "user@example.com", "oid": "1234"}`), expected: "user@example.com"},
+		{name: "guest user", token: accessToken(`{"unique_name": "live.com#user@example.com"}`), expected: "live.com#user@example.com"},
+		{name: "service principal", token: accessToken(`{"appid": "app-id", "oid": "1234"}`), expected: "app-id"},
+		{name: "object ID only", token: accessToken(`{"upn": "", "oid": "1234"}`), expected: "1234"},
+		{name: "no known claim", token: accessToken(`{"sub": "1234"}`), expected: "unknown"},
+		{name: "invalid JSON", token: accessToken(`not json`), expected: "unknown"},
+		{name: "invalid base64", token: "header.!!!.signature", expected: "unknown"},
+		{name: "not a JWT", token: "opaque-token", expected: "unknown"},
+	}
+	for _, scenario := range scenarios {
+		assert.Equal(t, scenario.expected, principalFromAccessToken(scenario.token), scenario.name)
+	}
+}
+
+func TestGetAzureCloudConfiguration(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		expected cloud.Configuration
+	}{
+		{name: AzureCloudPublic, expected: cloud.AzurePublic},
+		{name: AzureCloudUSGovernment, expected: cloud.AzureGovernment},
+		{name: AzureCloudChina, expected: cloud.AzureChina},
+	}
+	for _, scenario := range scenarios {
+		configuration, err := getAzureCloudConfiguration(scenario.name)
+		assert.Nil(t, err)
+		assert.Equal(t, scenario.expected, configuration, scenario.name)
+	}
+
+	_, err := getAzureCloudConfiguration("AzureGermanCloud")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown Azure cloud AzureGermanCloud")
+}
+
+func TestAzureProviderSetOptions(t *testing.T) {
+	t.Setenv(azureSubscriptionIdEnvVarKey, "env-subscription")
+
+	provider, err := NewAzureProvider(AzureOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "env-subscription", provider.SubscriptionID)
+	assert.Equal(t, AzureOptions{Cloud: AzureCloudPublic, Credential: AzureCredentialDefault}, provider.GetOptions())
+	assert.Equal(t, cloud.AzurePublic, provider.ClientOptions.Cloud)
+
+	assert.Nil(t, provider.SetOptions(AzureOptions{SubscriptionID: "other-subscription", Cloud: AzureCloudChina, Credential: AzureCredentialCLI}))
+	assert.Equal(t, "other-subscription", provider.SubscriptionID)
+	assert.Equal(t, cloud.AzureChina, provider.ClientOptions.Cloud)
+	// The default client options are left untouched
+	assert.Equal(t, cloud.Configuration{}, DefaultClientOptions.Cloud)
+
+	err = provider.SetOptions(AzureOptions{Cloud: "AzureGermanCloud"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown Azure cloud")
+
+	_, err = NewAzureProvider(AzureOptions{Credential: "password"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown Azure credential type password, must be one of default, cli, managed-identity, service-principal")
+}
+
+func TestAzureProviderSetOptionsResetsCredentials(t *testing.T) {
+	provider, err := NewAzureProvider(AzureOptions{})
+	assert.Nil(t, err)
+	provider.Credentials = &staticTokenCredential{}
+
+	assert.Nil(t, provider.SetOptions(AzureOptions{Credential: AzureCredentialCLI}))
+	assert.Nil(t, provider.Credentials)
+}
+
+func TestAzureProviderServicePrincipalRequiresEnvironment(t *testing.T) {
+	t.Setenv(azureSubscriptionIdEnvVarKey, "subscription")
+	t.Setenv(azureTenantIdEnvVarKey, "")
+	t.Setenv(azureClientIdEnvVarKey, "client-id")
+	t.Setenv(azureClientSecretEnvVarKey, "")
+	provider, err := NewAzureProvider(AzureOptions{Credential: AzureCredentialServicePrincipal})
+	assert.Nil(t, err)
+
+	_, err = provider.GetCredentials()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "authenticating with a service principal requires")
+}
+
+func TestAzureProviderTerraformEnvironment(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		env      map[string]string
+		options  AzureOptions
+		expected map[string]string
+	}{
+		{
+			name:     "default",
+			env:      map[string]string{azureSubscriptionIdEnvVarKey: "subscription"},
+			expected: map[string]string{"ARM_SUBSCRIPTION_ID": "subscription"},
+		},
+		{
+			name:    "selected subscription, tenant and cloud",
+			env:     map[string]string{azureSubscriptionIdEnvVarKey: "subscription", azureTenantIdEnvVarKey: "env-tenant"},
+			options: AzureOptions{SubscriptionID: "other-subscription", TenantID: "tenant", Cloud: AzureCloudUSGovernment},
+			expected: map[string]string{
+				"ARM_SUBSCRIPTION_ID": "other-subscription",
+				"ARM_TENANT_ID":       "tenant",
+				"ARM_ENVIRONMENT":     "usgovernment",
+			},
+		},
+		{
+			name:    "tenant from the environment",
+			env:     map[string]string{azureSubscriptionIdEnvVarKey: "subscription", azureTenantIdEnvVarKey: "env-tenant"},
+			options: AzureOptions{Cloud: AzureCloudChina},
+			expected: map[string]string{
+				"ARM_SUBSCRIPTION_ID": "subscription",
+				"ARM_TENANT_ID":       "env-tenant",
+				"ARM_ENVIRONMENT":     "china",
+			},
+		},
+		{
+			name:     "system-assigned managed identity",
+			env:      map[string]string{azureSubscriptionIdEnvVarKey: "subscription"},
+			options:  AzureOptions{Credential: AzureCredentialManagedIdentity},
+			expected: map[string]string{"ARM_SUBSCRIPTION_ID": "subscription", "ARM_USE_MSI": "true"},
+		},
+		{
+			name:    "user-assigned managed identity",
+			env:     map[string]string{azureSubscriptionIdEnvVarKey: "subscription", azureClientIdEnvVarKey: "identity-client-id"},
+			options: AzureOptions{Credential: AzureCredentialManagedIdentity},
+			expected: map[string]string{
+				"ARM_SUBSCRIPTION_ID": "subscription",
+				"ARM_USE_MSI":         "true",
+				"ARM_CLIENT_ID":       "identity-client-id",
+			},
+		},
+		{
+			name: "service principal",
+			env: map[string]string{
+				azureSubscriptionIdEnvVarKey: "subscription",
+				azureTenantIdEnvVarKey:       "tenant",
+				azureClientIdEnvVarKey:       "client-id",
+				azureClientSecretEnvVarKey:   "client-secret",
+			},
+			options: AzureOptions{Credential: AzureCredentialServicePrincipal},
+			expected: map[string]string{
+				"ARM_SUBSCRIPTION_ID": "subscription",
+				"ARM_TENANT_ID":       "tenant",
+				"ARM_CLIENT_ID":       "client-id",
+				"ARM_CLIENT_SECRET":   "client-secret",
+			},
+		},
+		{
+			name:    "Azure CLI",
+			env:     map[string]string{azureSubscriptionIdEnvVarKey: "subscription", azureClientIdEnvVarKey: "client-id", azureClientSecretEnvVarKey: "client-secret"},
+			options: AzureOptions{Credential: AzureCredentialCLI},
+			expected: map[string]string{
+				"ARM_SUBSCRIPTION_ID": "subscription",
+				"ARM_CLIENT_ID":       "",
+				"ARM_CLIENT_SECRET":   "",
+				"ARM_USE_MSI":         "",
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			for _, key := range []string{azureSubscriptionIdEnvVarKey, azureTenantIdEnvVarKey, azureClientIdEnvVarKey, azureClientSecretEnvVarKey} {
+				t.Setenv(key, scenario.env[key])
+			}
+			provider, err := NewAzureProvider(scenario.options)
+			assert.Nil(t, err)
+
+			env, err := provider.TerraformEnvironment()
+			assert.Nil(t, err)
+			assert.Equal(t, scenario.expected, env)
+		})
+	}
+}
+
+// staticTokenCredential always returns the same access token
+type staticTokenCredential struct {
+	token string
+}
+
+func (m *staticTokenCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: m.token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureProviderGetIdentity(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/subscriptions/my-subscription" || r.URL.Query().Get("api-version") != azureSubscriptionApiVersion {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"code": "SubscriptionNotFound", "message": "not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"subscriptionId": "my-subscription", "displayName": "My subscription", "tenantId": "my-tenant"}`))
+	}))
+	defer server.Close()
+	t.Setenv(azureSubscriptionIdEnvVarKey, "my-subscription")
+	provider, err := NewAzureProvider(AzureOptions{})
+	assert.Nil(t, err)
+	provider.Credentials = &staticTokenCredential{token: accessToken(`{"upn": "user@example.com"}`)}
+	provider.ClientOptions.Transport = server.Client()
+	provider.ClientOptions.Cloud = cloud.Configuration{Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+		cloud.ResourceManager: {Endpoint: server.URL, Audience: "https://management.core.windows.net/"},
+	}}
+
+	identity, err := provider.GetIdentity()
+	assert.Nil(t, err)
+	assert.Equal(t, &AzureIdentity{
+		TenantID:         "my-tenant",
+		SubscriptionID:   "my-subscription",
+		SubscriptionName: "My subscription",
+		Principal:        "user@example.com",
+	}, identity)
+
+	// The identity is only retrieved once per subscription
+	_, err = provider.GetIdentity()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+
+	provider.SubscriptionID = "other-subscription"
+	_, err = provider.GetIdentity()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unable to retrieve Azure subscription other-subscription")
+
+	provider.SubscriptionID = ""
+	_, err = provider.GetIdentity()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "AZURE_SUBSCRIPTION_ID is not set")
+}
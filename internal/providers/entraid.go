@@ -0,0 +1,124 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/google/uuid"
+)
+
+// Base URLs of the Microsoft Graph API, per Azure cloud
+var graphBaseURLs = map[string]string{
+	AzureCloudPublic:       graph.DefaultBaseURL,
+	AzureCloudUSGovernment: "https://graph.microsoft.us/v1.0",
+	AzureCloudChina:        "https://microsoftgraph.chinacloudapi.cn/v1.0",
+}
+
+// EntraIDProvider connects to Microsoft Entra ID (formerly Azure Active Directory) through Microsoft Graph.
+// It uses the same credentials, tenant and cloud as the Azure provider
+type EntraIDProvider struct {
+	graphClient         *graph.Client
+	azure               *AzureProvider
+	organization        *graph.Organization // cached, since the tenant is described before each operation
+	organizationLock    sync.Mutex
+	UniqueCorrelationId uuid.UUID // unique value injected in the user-agent, to differentiate Stratus Red Team executions
+}
+
+var entraIDProvider = EntraIDProvider{
+	UniqueCorrelationId: UniqueExecutionId,
+}
+
+func EntraID() *EntraIDProvider {
+	return &entraIDProvider
+}
+
+// NewEntraIDProvider returns an Entra ID provider independent of the default one, using the credentials, tenant and
+// cloud of an Azure provider
+func NewEntraIDProvider(azure *AzureProvider) *EntraIDProvider {
+	return &EntraIDProvider{azure: azure, UniqueCorrelationId: UniqueExecutionId}
+}
+
+// getAzure returns the Azure provider whose credentials are used, the default one unless specified
+func (m *EntraIDProvider) getAzure() *AzureProvider {
+	if m.azure != nil {
+		return m.azure
+	}
+	return Azure()
+}
+
+// GetGraphClient returns a Microsoft Graph client, authenticated with the Azure credentials
+func (m *EntraIDProvider) GetGraphClient() (*graph.Client, error) {
+	if m.graphClient != nil {
+		return m.graphClient, nil
+	}
+
+	cred, err := m.getAzure().GetTokenCredential()
+	if err != nil {
+		return nil, err
+	}
+	baseURL := graphBaseURLs[m.getAzure().GetOptions().Cloud]
+	if baseURL == "" {
+		baseURL = graph.DefaultBaseURL
+	}
+	scope := strings.TrimSuffix(baseURL, "/v1.0") + "/.default"
+
+	retryPolicy := GetRetryPolicy()
+	httpClient := &http.Client{Transport: newTracingTransport("Entra ID", &userAgentRoundTripper{
+		userAgent: GetStratusUserAgent(),
+		next:      &throttlingRoundTripper{platform: "Microsoft Graph", policy: retryPolicy, limiter: retryPolicy.newRateLimiter(), next: http.DefaultTransport},
+	})}
+	m.graphClient = graph.NewClient(baseURL, httpClient, func(ctx context.Context) (string, error) {
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+		if err != nil {
+			return "", err
+		}
+		return token.Token, nil
+	})
+	return m.graphClient, nil
+}
+
+// SetGraphClient overrides the Microsoft Graph client, e.g. to target a local stand-in in tests
+func (m *EntraIDProvider) SetGraphClient(client *graph.Client) {
+	m.graphClient = client
+	m.organization = nil
+}
+
+// GetOrganization makes an authenticated call to Microsoft Graph to retrieve the current tenant. The call is only made
+// once, until the Microsoft Graph client changes
+func (m *EntraIDProvider) GetOrganization() (*graph.Organization, error) {
+	client, err := m.GetGraphClient()
+	if err != nil {
+		return nil, err
+	}
+	m.organizationLock.Lock()
+	defer m.organizationLock.Unlock()
+	if m.organization != nil {
+		return m.organization, nil
+	}
+	var organizations graph.ListResponse[graph.Organization]
+	if err := client.Get(context.Background(), "/organization", &organizations); err != nil {
+		return nil, errors.New("unable to retrieve the Entra ID tenant: " + err.Error())
+	}
+	if len(organizations.Value) == 0 {
+		return nil, errors.New("unable to retrieve the Entra ID tenant: no organization found")
+	}
+	m.organization = &organizations.Value[0]
+	return m.organization, nil
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform so that the Azure AD Terraform provider
+// uses the same tenant, cloud and credentials as the Microsoft Graph client
+func (m *EntraIDProvider) TerraformEnvironment() (map[string]string, error) {
+	env, err := m.getAzure().TerraformEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	// The Azure AD provider does not use a subscription
+	delete(env, "ARM_SUBSCRIPTION_ID")
+	return env, nil
+}
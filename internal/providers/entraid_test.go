@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntraIDProviderCachesOrganization(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"value": [{"id": "tenant-id", "displayName": "My tenant"}]}`))
+	}))
+	defer server.Close()
+	provider := NewEntraIDProvider(nil)
+	newClient := func() *graph.Client {
+		return graph.NewClient(server.URL, server.Client(), func(context.Context) (string, error) { return "token", nil })
+	}
+	provider.SetGraphClient(newClient())
+
+	for i := 0; i < 2; i++ {
+		organization, err := provider.GetOrganization()
+		assert.Nil(t, err)
+		assert.Equal(t, "tenant-id", organization.Id)
+		assert.Equal(t, "My tenant", organization.DisplayName)
+	}
+	assert.Equal(t, 1, requests)
+
+	// The tenant is retrieved again with another client
+	provider.SetGraphClient(newClient())
+	_, err := provider.GetOrganization()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/option"
+)
+
+const gcpProjectIdEnvVarKey = "GOOGLE_PROJECT"
+
+type GCPProvider struct {
+	credentials         *google.Credentials
+	httpClient          *http.Client
+	httpClientOnce      sync.Once
+	httpClientErr       error
+	clientOptions       []option.ClientOption
+	ProjectId           string
+	UniqueCorrelationId uuid.UUID // unique value injected in the user-agent, to differentiate Stratus Red Team executions
+}
+
+var gcpProvider = GCPProvider{
+	UniqueCorrelationId: UniqueExecutionId,
+	ProjectId:           os.Getenv(gcpProjectIdEnvVarKey),
+}
+
+func GCP() *GCPProvider {
+	return &gcpProvider
+}
+
+// NewGCPProviderFromOptions returns a GCP provider for a project, instantiating GCP API clients with given options
+// instead of the application default credentials, e.g. to send API calls to a fake server in tests
+func NewGCPProviderFromOptions(projectId string, clientOptions ...option.ClientOption) *GCPProvider {
+	return &GCPProvider{UniqueCorrelationId: UniqueExecutionId, ProjectId: projectId, clientOptions: clientOptions}
+}
+
+// GetProjectId returns the GCP project to use, either from the GOOGLE_PROJECT environment variable or from
+// the application default credentials
+func (m *GCPProvider) GetProjectId() string {
+	if m.ProjectId == "" && m.clientOptions == nil {
+		if _, err := m.getHttpClient(); err == nil && m.credentials != nil {
+			m.ProjectId = m.credentials.ProjectID
+		}
+	}
+	return m.ProjectId
+}
+
+// Options returns the options to use when instantiating GCP API clients, e.g. iam.NewService(ctx, providers.GCP().Options()...)
+func (m *GCPProvider) Options() []option.ClientOption {
+	if m.clientOptions != nil {
+		return m.clientOptions
+	}
+	httpClient, err := m.getHttpClient()
+	if err != nil {
+		// The client creation will fail with an explicit error message
+		return []option.ClientOption{option.WithUserAgent(GetStratusUserAgent())}
+	}
+	return []option.ClientOption{option.WithHTTPClient(httpClient)}
+}
+
+// getHttpClient returns an authenticated HTTP client, injecting the Stratus Red Team user-agent and implementing the retry policy
+func (m *GCPProvider) getHttpClient() (*http.Client, error) {
+	m.httpClientOnce.Do(func() {
+		credentials, err := google.FindDefaultCredentials(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+		if err != nil {
+			m.httpClientErr = errors.New("unable to load GCP application default credentials: " + err.Error())
+			return
+		}
+		m.credentials = credentials
+
+		retryPolicy := GetRetryPolicy()
+		var transport http.RoundTripper = &oauth2.Transport{Source: credentials.TokenSource, Base: http.DefaultTransport}
+		transport = &throttlingRoundTripper{platform: "GCP", policy: retryPolicy, limiter: retryPolicy.newRateLimiter(), next: transport}
+		transport = &userAgentRoundTripper{userAgent: GetStratusUserAgent(), next: transport}
+		transport = newTracingTransport("GCP", transport)
+		m.httpClient = &http.Client{Transport: transport}
+	})
+	return m.httpClient, m.httpClientErr
+}
+
+func (m *GCPProvider) IsAuthenticatedAgainstGCP() bool {
+	return m.CheckAuthentication() == nil
+}
+
+// CheckAuthentication makes an authenticated API call to retrieve the selected GCP project
+func (m *GCPProvider) CheckAuthentication() error {
+	if m.clientOptions == nil {
+		if _, err := m.getHttpClient(); err != nil {
+			return err
+		}
+	}
+	projectId := m.GetProjectId()
+	if projectId == "" {
+		return errors.New(gcpProjectIdEnvVarKey + " is not set")
+	}
+
+	resourceManager, err := cloudresourcemanager.NewService(context.Background(), m.Options()...)
+	if err != nil {
+		return errors.New("unable to instantiate GCP client: " + err.Error())
+	}
+	_, err = resourceManager.Projects.Get("projects/" + projectId).Do()
+	if err != nil {
+		return errors.New("unable to retrieve GCP project " + projectId + ": " + err.Error())
+	}
+	return nil
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform so that the Google Terraform provider
+// uses the same project as the GCP API clients
+func (m *GCPProvider) TerraformEnvironment() (map[string]string, error) {
+	env := map[string]string{}
+	if projectId := m.GetProjectId(); projectId != "" {
+		env[gcpProjectIdEnvVarKey] = projectId
+	}
+	return env, nil
+}
+
+// userAgentRoundTripper sets the user-agent of HTTP requests
+type userAgentRoundTripper struct {
+	userAgent string
+	next      http.RoundTripper
+}
+
+func (m *userAgentRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("User-Agent", m.userAgent)
+	return m.next.RoundTrip(request)
+}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+)
+
+func TestGCPProviderTerraformEnvironment(t *testing.T) {
+	env, err := NewGCPProviderFromOptions("my-project").TerraformEnvironment()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"GOOGLE_PROJECT": "my-project"}, env)
+
+	env, err = NewGCPProviderFromOptions("", option.WithoutAuthentication()).TerraformEnvironment()
+	assert.Nil(t, err)
+	assert.Empty(t, env)
+}
+
+func TestGCPProviderOptions(t *testing.T) {
+	clientOptions := []option.ClientOption{option.WithEndpoint("http://localhost/")}
+	provider := NewGCPProviderFromOptions("my-project", clientOptions...)
+
+	assert.Equal(t, clientOptions, provider.Options())
+	assert.Equal(t, "my-project", provider.GetProjectId())
+}
+
+func TestGCPProviderCheckAuthentication(t *testing.T) {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		if r.URL.Path != "/v3/projects/my-project" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error": {"code": 403, "message": "permission denied"}}`))
+			return
+		}
+		w.Write([]byte(`{"name": "projects/123", "projectId": "my-project"}`))
+	}))
+	defer server.Close()
+	clientOptions := []option.ClientOption{option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL + "/")}
+
+	assert.Nil(t, NewGCPProviderFromOptions("my-project", clientOptions...).CheckAuthentication())
+	assert.Equal(t, "/v3/projects/my-project", requestedPath)
+
+	err := NewGCPProviderFromOptions("other-project", clientOptions...).CheckAuthentication()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unable to retrieve GCP project other-project")
+
+	err = NewGCPProviderFromOptions("", clientOptions...).CheckAuthentication()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "GOOGLE_PROJECT is not set")
+}
+
+func TestUserAgentRoundTripper(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &userAgentRoundTripper{userAgent: "stratus-red-team_foo", next: http.DefaultTransport}}
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Header.Set("User-Agent", "google-api-go-client/0.5")
+	_, err := client.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "stratus-red-team_foo", userAgent)
+	// The original request is not modified
+	assert.Equal(t, "google-api-go-client/0.5", request.Header.Get("User-Agent"))
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/google/uuid"
 	authv1 "k8s.io/api/authorization/v1"
@@ -9,10 +10,15 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/client-go/util/homedir"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 const (
@@ -20,9 +26,61 @@ const (
 )
 
 type K8sProvider struct {
-	k8sClient           *kubernetes.Clientset
+	k8sClient           kubernetes.Interface
 	RestConfig          *rest.Config
 	UniqueCorrelationId uuid.UUID // unique value injected in the user-agent, to differentiate Stratus Red Team executions
+	options             K8sOptions
+	rateLimiter         flowcontrol.RateLimiter
+	rateLimiterOnce     sync.Once
+}
+
+// K8sOptions allows to override the kubeconfig context, namespace and identity used against the cluster
+type K8sOptions struct {
+	// Name of the kubeconfig context to use, instead of the current one
+	Context string
+
+	// Existing namespace in which attack techniques create their objects, instead of a namespace of their own
+	Namespace string
+
+	// User and groups to impersonate
+	Impersonate       string
+	ImpersonateGroups []string
+}
+
+// Name of the kubeconfig file generated for Terraform, in the Terraform working directory
+const terraformKubeconfigFileName = ".kubeconfig"
+
+// Credentials of the service account of pods, used for in-cluster authentication
+const (
+	inClusterTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	inClusterCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// Validate checks that the options are consistent
+func (m K8sOptions) Validate() error {
+	if len(m.ImpersonateGroups) > 0 && m.Impersonate == "" {
+		return errors.New("impersonating Kubernetes groups requires impersonating a user")
+	}
+	return nil
+}
+
+// SetOptions configures how the Kubernetes provider connects to the cluster. Must be called before any client is built
+func (m *K8sProvider) SetOptions(options K8sOptions) {
+	m.options = options
+	m.k8sClient = nil
+}
+
+func (m *K8sProvider) GetOptions() K8sOptions {
+	return m.options
+}
+
+// GetNamespace returns the namespace in which attack techniques should create objects, falling back to
+// a technique-specific default when no namespace was explicitly selected
+func (m *K8sProvider) GetNamespace(defaultNamespace string) string {
+	if m.options.Namespace != "" {
+		return m.options.Namespace
+	}
+	return defaultNamespace
 }
 
 var (
@@ -35,6 +93,13 @@ func K8s() *K8sProvider {
 	return &k8sProvider
 }
 
+// NewK8sProvider returns a Kubernetes provider independent of the default one, e.g. to target another cluster
+func NewK8sProvider(options K8sOptions) *K8sProvider {
+	provider := &K8sProvider{UniqueCorrelationId: UniqueExecutionId}
+	provider.SetOptions(options)
+	return provider
+}
+
 // GetKubeConfigPath returns the path of the kubeconfig, with the following priority:
 // 1. KUBECONFIG environment variable
 // 2. $HOME/.kube/config
@@ -67,16 +132,29 @@ func getKubeConfigPath() string {
 }
 
 // GetClient is used to authenticate with Kubernetes and build the client from a kubeconfig
-func (m *K8sProvider) GetClient() *kubernetes.Clientset {
+func (m *K8sProvider) GetClient() kubernetes.Interface {
+	if m.k8sClient != nil {
+		return m.k8sClient
+	}
 	kubeconfig := GetKubeConfigPath()
 
 	// Will default to an in-cluster client config if kubeconfig path is not set
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: m.options.Context},
+	).ClientConfig()
 	if err != nil {
 		log.Fatalf("unable to build kube config: %v", err)
 	}
+	if m.options.Impersonate != "" || len(m.options.ImpersonateGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: m.options.Impersonate,
+			Groups:   m.options.ImpersonateGroups,
+		}
+	}
 	m.RestConfig = config
 	m.RestConfig.UserAgent = GetStratusUserAgent()
+	m.applyRetryPolicy(m.RestConfig, GetRetryPolicy())
 	m.k8sClient, err = kubernetes.NewForConfig(m.RestConfig)
 	if err != nil {
 		log.Fatalf("unable to create kube client: %v", err)
@@ -84,10 +162,49 @@ func (m *K8sProvider) GetClient() *kubernetes.Clientset {
 	return m.k8sClient
 }
 
+// SetClient overrides the Kubernetes client, e.g. to use a fake clientset in tests
+func (m *K8sProvider) SetClient(client kubernetes.Interface) {
+	m.k8sClient = client
+}
+
+// applyRetryPolicy configures the rate limit of a Kubernetes REST config, retries throttled API calls, traces them and
+// records them
+func (m *K8sProvider) applyRetryPolicy(config *rest.Config, policy RetryPolicy) {
+	if policy.RateLimit > 0 {
+		// The rate limiter is shared by all clients, since a new client is built every time GetClient is called
+		m.rateLimiterOnce.Do(func() {
+			m.rateLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(policy.RateLimit), policy.RateLimitBurst)
+		})
+		config.RateLimiter = m.rateLimiter
+	} else {
+		// Without a rate limit, disable the default client-side rate limit of client-go (5 requests per second)
+		config.RateLimiter = nil
+		config.QPS = -1
+	}
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return newTracingTransport("Kubernetes", newKubernetesApiCallRecordingTransport(
+			&throttlingRoundTripper{platform: "Kubernetes", policy: policy, next: rt, clientHandlesRetryAfter: true},
+		))
+	})
+}
+
 func (m *K8sProvider) GetRestConfig() *rest.Config {
 	return m.RestConfig
 }
 
+// GetRestConfigForToken returns a REST config to connect to the cluster as another identity, authenticated with a
+// bearer token such as a service account token. API calls made with it are throttled, traced and recorded as any other
+func (m *K8sProvider) GetRestConfigForToken(token string) (*rest.Config, error) {
+	m.GetClient()
+	if m.RestConfig == nil {
+		return nil, errors.New("no REST config available for the Kubernetes client")
+	}
+	config := rest.AnonymousClientConfig(m.RestConfig)
+	config.BearerToken = token
+	m.applyRetryPolicy(config, GetRetryPolicy())
+	return config, nil
+}
+
 func (m *K8sProvider) IsAuthenticated() bool {
 	m.GetClient()
 
@@ -108,3 +225,93 @@ func (m *K8sProvider) IsAuthenticated() bool {
 	)
 	return err == nil || auth.Status.Allowed
 }
+
+// TerraformEnvironment returns the environment variables to pass to Terraform so that the Kubernetes Terraform
+// provider uses the same cluster, identity and namespace as the Kubernetes client.
+// When a context or impersonation is selected, a dedicated kubeconfig is written to the Terraform working directory
+func (m *K8sProvider) TerraformEnvironment(terraformDirectory string) (map[string]string, error) {
+	if err := m.options.Validate(); err != nil {
+		return nil, err
+	}
+	env := map[string]string{}
+	if m.options.Namespace != "" {
+		env["TF_VAR_namespace"] = m.options.Namespace
+	}
+
+	kubeconfig := GetKubeConfigPath()
+	if kubeconfig == "" && m.options.Impersonate == "" {
+		// In-cluster authentication
+		return env, nil
+	}
+	if kubeconfig == "" {
+		// In-cluster authentication, impersonating the selected user and groups
+		terraformKubeconfig := filepath.Join(terraformDirectory, terraformKubeconfigFileName)
+		if err := m.writeInClusterKubeconfig(terraformKubeconfig); err != nil {
+			return nil, errors.New("unable to generate a kubeconfig for Terraform: " + err.Error())
+		}
+		env["TF_VAR_kubeconfig_path"] = terraformKubeconfig
+		return env, nil
+	}
+	if m.options.Context != "" || m.options.Impersonate != "" || len(m.options.ImpersonateGroups) > 0 {
+		terraformKubeconfig := filepath.Join(terraformDirectory, terraformKubeconfigFileName)
+		if err := m.writeKubeconfig(kubeconfig, terraformKubeconfig); err != nil {
+			return nil, errors.New("unable to generate a kubeconfig for Terraform: " + err.Error())
+		}
+		kubeconfig = terraformKubeconfig
+	}
+	env["TF_VAR_kubeconfig_path"] = kubeconfig
+	return env, nil
+}
+
+// writeInClusterKubeconfig writes a kubeconfig authenticating with the service account of the pod Stratus Red Team
+// runs in, and impersonating the selected user and groups
+func (m *K8sProvider) writeInClusterKubeconfig(destinationPath string) error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return errors.New("no kubeconfig found, and not running in a Kubernetes cluster")
+	}
+	config := clientcmdapi.NewConfig()
+	config.Clusters["in-cluster"] = &clientcmdapi.Cluster{
+		Server:               "https://" + net.JoinHostPort(host, port),
+		CertificateAuthority: inClusterCAFile,
+	}
+	config.AuthInfos["in-cluster"] = &clientcmdapi.AuthInfo{
+		TokenFile:         inClusterTokenFile,
+		Impersonate:       m.options.Impersonate,
+		ImpersonateGroups: m.options.ImpersonateGroups,
+	}
+	config.Contexts["in-cluster"] = &clientcmdapi.Context{Cluster: "in-cluster", AuthInfo: "in-cluster"}
+	config.CurrentContext = "in-cluster"
+	return clientcmd.WriteToFile(*config, destinationPath)
+}
+
+// writeKubeconfig writes a standalone kubeconfig, containing only the selected context and impersonating
+// the selected user and groups
+func (m *K8sProvider) writeKubeconfig(sourcePath string, destinationPath string) error {
+	rawConfig, err := clientcmd.LoadFromFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	if m.options.Context != "" {
+		rawConfig.CurrentContext = m.options.Context
+	}
+	kubeContext, found := rawConfig.Contexts[rawConfig.CurrentContext]
+	if !found {
+		return errors.New("context " + rawConfig.CurrentContext + " not found in " + sourcePath)
+	}
+	authInfo, found := rawConfig.AuthInfos[kubeContext.AuthInfo]
+	if !found {
+		return errors.New("user " + kubeContext.AuthInfo + " not found in " + sourcePath)
+	}
+	authInfo.Impersonate = m.options.Impersonate
+	authInfo.ImpersonateGroups = m.options.ImpersonateGroups
+
+	if err := clientcmdapi.MinifyConfig(rawConfig); err != nil {
+		return err
+	}
+	// Inline certificates and keys, since relative paths would be resolved from the new location
+	if err := clientcmdapi.FlattenConfig(rawConfig); err != nil {
+		return err
+	}
+	return clientcmd.WriteToFile(*rawConfig, destinationPath)
+}
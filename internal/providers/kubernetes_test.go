@@ -0,0 +1,210 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    token: prod-token
+`
+
+func writeTestKubeconfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config")
+	assert.Nil(t, os.WriteFile(path, []byte(testKubeconfig), 0600))
+	return path
+}
+
+// useKubeconfig makes GetKubeConfigPath return a path for the duration of a test
+func useKubeconfig(t *testing.T, path string) {
+	previousPath, previousWasResolved := kubeConfigPath, kubeConfigPathWasResolved
+	kubeConfigPath, kubeConfigPathWasResolved = path, true
+	t.Cleanup(func() {
+		kubeConfigPath, kubeConfigPathWasResolved = previousPath, previousWasResolved
+	})
+}
+
+func TestK8sProviderGetNamespace(t *testing.T) {
+	provider := NewK8sProvider(K8sOptions{})
+	assert.Equal(t, "kube-system", provider.GetNamespace("kube-system"))
+
+	provider.SetOptions(K8sOptions{Namespace: "my-namespace"})
+	assert.Equal(t, "my-namespace", provider.GetNamespace("kube-system"))
+	assert.Equal(t, K8sOptions{Namespace: "my-namespace"}, provider.GetOptions())
+}
+
+func TestK8sProviderSetOptionsResetsClient(t *testing.T) {
+	provider := NewK8sProvider(K8sOptions{})
+	provider.SetClient(fake.NewSimpleClientset())
+
+	provider.SetOptions(K8sOptions{Context: "prod"})
+	assert.Nil(t, provider.k8sClient)
+}
+
+func TestK8sProviderTerraformEnvironment(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t)
+	useKubeconfig(t, kubeconfig)
+
+	scenario := []struct {
+		Name                      string
+		Options                   K8sOptions
+		ExpectedEnvironment       map[string]string
+		ExpectTerraformKubeconfig bool
+	}{
+		{
+			Name:                "no options",
+			Options:             K8sOptions{},
+			ExpectedEnvironment: map[string]string{"TF_VAR_kubeconfig_path": kubeconfig},
+		},
+		{
+			Name:                "namespace",
+			Options:             K8sOptions{Namespace: "my-namespace"},
+			ExpectedEnvironment: map[string]string{"TF_VAR_kubeconfig_path": kubeconfig, "TF_VAR_namespace": "my-namespace"},
+		},
+		{
+			Name:                      "context",
+			Options:                   K8sOptions{Context: "prod"},
+			ExpectTerraformKubeconfig: true,
+		},
+		{
+			Name:                      "impersonation",
+			Options:                   K8sOptions{Impersonate: "jane"},
+			ExpectTerraformKubeconfig: true,
+		},
+	}
+
+	for i := range scenario {
+		t.Run(scenario[i].Name, func(t *testing.T) {
+			terraformDirectory := t.TempDir()
+			env, err := NewK8sProvider(scenario[i].Options).TerraformEnvironment(terraformDirectory)
+			assert.Nil(t, err)
+
+			if scenario[i].ExpectTerraformKubeconfig {
+				terraformKubeconfig := filepath.Join(terraformDirectory, terraformKubeconfigFileName)
+				assert.Equal(t, map[string]string{"TF_VAR_kubeconfig_path": terraformKubeconfig}, env)
+				assert.FileExists(t, terraformKubeconfig)
+			} else {
+				assert.Equal(t, scenario[i].ExpectedEnvironment, env)
+				assert.NoFileExists(t, filepath.Join(terraformDirectory, terraformKubeconfigFileName))
+			}
+		})
+	}
+}
+
+func TestK8sProviderTerraformEnvironmentInCluster(t *testing.T) {
+	useKubeconfig(t, "")
+
+	env, err := NewK8sProvider(K8sOptions{Context: "prod", Namespace: "my-namespace"}).TerraformEnvironment(t.TempDir())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TF_VAR_namespace": "my-namespace"}, env)
+}
+
+func TestK8sProviderTerraformEnvironmentInClusterWithImpersonation(t *testing.T) {
+	useKubeconfig(t, "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	terraformDirectory := t.TempDir()
+
+	provider := NewK8sProvider(K8sOptions{Impersonate: "jane", ImpersonateGroups: []string{"admins"}})
+	env, err := provider.TerraformEnvironment(terraformDirectory)
+	assert.Nil(t, err)
+	terraformKubeconfig := filepath.Join(terraformDirectory, terraformKubeconfigFileName)
+	assert.Equal(t, map[string]string{"TF_VAR_kubeconfig_path": terraformKubeconfig}, env)
+
+	written, err := clientcmd.LoadFromFile(terraformKubeconfig)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://10.0.0.1:443", written.Clusters["in-cluster"].Server)
+	assert.Equal(t, inClusterCAFile, written.Clusters["in-cluster"].CertificateAuthority)
+	user := written.AuthInfos["in-cluster"]
+	assert.Equal(t, inClusterTokenFile, user.TokenFile)
+	assert.Equal(t, "jane", user.Impersonate)
+	assert.Equal(t, []string{"admins"}, user.ImpersonateGroups)
+
+	// Outside of a cluster, there is no way to authenticate
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	_, err = provider.TerraformEnvironment(t.TempDir())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not running in a Kubernetes cluster")
+}
+
+func TestK8sOptionsValidate(t *testing.T) {
+	assert.Nil(t, K8sOptions{}.Validate())
+	assert.Nil(t, K8sOptions{Impersonate: "jane"}.Validate())
+	assert.Nil(t, K8sOptions{Impersonate: "jane", ImpersonateGroups: []string{"admins"}}.Validate())
+
+	err := K8sOptions{ImpersonateGroups: []string{"admins"}}.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "impersonating Kubernetes groups requires impersonating a user")
+
+	_, err = NewK8sProvider(K8sOptions{ImpersonateGroups: []string{"admins"}}).TerraformEnvironment(t.TempDir())
+	assert.NotNil(t, err)
+}
+
+func TestK8sProviderWriteKubeconfig(t *testing.T) {
+	source := writeTestKubeconfig(t)
+	destination := filepath.Join(t.TempDir(), "kubeconfig")
+	provider := NewK8sProvider(K8sOptions{Context: "prod", Impersonate: "jane", ImpersonateGroups: []string{"admins"}})
+
+	assert.Nil(t, provider.writeKubeconfig(source, destination))
+	written, err := clientcmd.LoadFromFile(destination)
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", written.CurrentContext)
+	assert.Len(t, written.Contexts, 1)
+	assert.Len(t, written.Clusters, 1)
+	assert.Equal(t, "https://prod.example.com", written.Clusters["prod-cluster"].Server)
+	assert.Len(t, written.AuthInfos, 1)
+	user := written.AuthInfos["prod-user"]
+	assert.Equal(t, "prod-token", user.Token)
+	assert.Equal(t, "jane", user.Impersonate)
+	assert.Equal(t, []string{"admins"}, user.ImpersonateGroups)
+}
+
+func TestK8sProviderWriteKubeconfigUsesCurrentContext(t *testing.T) {
+	source := writeTestKubeconfig(t)
+	destination := filepath.Join(t.TempDir(), "kubeconfig")
+
+	assert.Nil(t, NewK8sProvider(K8sOptions{Impersonate: "jane"}).writeKubeconfig(source, destination))
+	written, err := clientcmd.LoadFromFile(destination)
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", written.CurrentContext)
+	assert.Equal(t, "jane", written.AuthInfos["dev-user"].Impersonate)
+}
+
+func TestK8sProviderWriteKubeconfigUnknownContext(t *testing.T) {
+	source := writeTestKubeconfig(t)
+	destination := filepath.Join(t.TempDir(), "kubeconfig")
+
+	err := NewK8sProvider(K8sOptions{Context: "staging"}).writeKubeconfig(source, destination)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "context staging not found")
+	assert.NoFileExists(t, destination)
+}
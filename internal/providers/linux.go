@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Directory, relative to the home directory, where the original content of host resources modified by attack
+// techniques is saved until they are restored
+const linuxBackupsDirectory = ".stratus-red-team/.host-backups"
+
+// LinuxProvider executes attack techniques on the local Linux host, i.e. the machine or container running Stratus Red Team
+type LinuxProvider struct{}
+
+var linuxProvider = LinuxProvider{}
+
+func Linux() *LinuxProvider {
+	return &linuxProvider
+}
+
+// HostBackup is the original state of a host resource modified by an attack technique, e.g. the content of a file
+type HostBackup struct {
+	Exists  bool
+	Content []byte
+	Mode    os.FileMode
+}
+
+// CheckPlatform ensures that Stratus Red Team is running on Linux
+func (m *LinuxProvider) CheckPlatform() error {
+	if runtime.GOOS != "linux" {
+		return errors.New("host attack techniques can only run on Linux, current platform is " + runtime.GOOS)
+	}
+	return nil
+}
+
+// ExpandPath returns the absolute version of a path, where a leading ~/ refers to the home directory
+func (m *LinuxProvider) ExpandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDirectory, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("unable to find home directory: " + err.Error())
+		}
+		path = filepath.Join(homeDirectory, strings.TrimPrefix(path, "~"))
+	}
+	return filepath.Abs(path)
+}
+
+// SaveBackup saves the original state of a host resource before modifying it, so that it can be restored later.
+// Does nothing if a backup already exists, so that the original state is never overwritten by a modified one
+func (m *LinuxProvider) SaveBackup(key string, backup HostBackup) error {
+	backupFile, err := m.backupFile(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(backupFile); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(backupFile), 0700); err != nil {
+		return errors.New("unable to create backup directory: " + err.Error())
+	}
+	rawBackup, err := json.Marshal(backup)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backupFile, rawBackup, 0600)
+}
+
+// GetBackup returns the saved original state of a host resource, or nil if there is none
+func (m *LinuxProvider) GetBackup(key string) (*HostBackup, error) {
+	backupFile, err := m.backupFile(key)
+	if err != nil {
+		return nil, err
+	}
+	rawBackup, err := os.ReadFile(backupFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.New("unable to read backup of " + key + ": " + err.Error())
+	}
+	var backup HostBackup
+	if err := json.Unmarshal(rawBackup, &backup); err != nil {
+		return nil, errors.New("unable to read backup of " + key + ": " + err.Error())
+	}
+	return &backup, nil
+}
+
+// DeleteBackup removes the saved original state of a host resource, once it has been restored
+func (m *LinuxProvider) DeleteBackup(key string) error {
+	backupFile, err := m.backupFile(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(backupFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("unable to remove backup of " + key + ": " + err.Error())
+	}
+	return nil
+}
+
+// BackupFile saves the original content of a file (or the fact that it does not exist) before modifying it
+func (m *LinuxProvider) BackupFile(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m.SaveBackup(path, HostBackup{Exists: false})
+	} else if err != nil {
+		return errors.New("unable to back up " + path + ": " + err.Error())
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New("unable to back up " + path + ": " + err.Error())
+	}
+	return m.SaveBackup(path, HostBackup{Exists: true, Content: content, Mode: info.Mode().Perm()})
+}
+
+// RestoreFile restores the original content of a file saved with BackupFile, or removes the file if it did not
+// exist. Does nothing if the file was not backed up
+func (m *LinuxProvider) RestoreFile(path string) error {
+	backup, err := m.GetBackup(path)
+	if err != nil || backup == nil {
+		return err
+	}
+	if backup.Exists {
+		if err := os.WriteFile(path, backup.Content, backup.Mode); err != nil {
+			return errors.New("unable to restore " + path + ": " + err.Error())
+		}
+		if err := os.Chmod(path, backup.Mode); err != nil {
+			return errors.New("unable to restore permissions of " + path + ": " + err.Error())
+		}
+	} else if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.New("unable to remove " + path + ": " + err.Error())
+	}
+	return m.DeleteBackup(path)
+}
+
+// StartProcess starts a long-running process in the background, detached from Stratus Red Team, and returns its PID
+func (m *LinuxProvider) StartProcess(command []string, environment map[string]string) (int, error) {
+	if len(command) == 0 {
+		return 0, errors.New("no command to run")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for key, value := range environment {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, errors.New("unable to start " + strings.Join(command, " ") + ": " + err.Error())
+	}
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// StopProcess stops a process started with StartProcess. Does nothing if the process is no longer running, or if
+// its PID was reused by a different command
+func (m *LinuxProvider) StopProcess(pid int, command []string) error {
+	if !m.IsProcessRunning(pid, command) {
+		return nil
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return errors.New("unable to stop process " + strconv.Itoa(pid) + ": " + err.Error())
+	}
+	return nil
+}
+
+// IsProcessRunning returns true if a process is running with the given PID and command line
+func (m *LinuxProvider) IsProcessRunning(pid int, command []string) bool {
+	rawCommandLine, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return false
+	}
+	return strings.TrimSuffix(string(rawCommandLine), "\x00") == strings.Join(command, "\x00")
+}
+
+func (m *LinuxProvider) backupFile(key string) (string, error) {
+	homeDirectory, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("unable to find home directory: " + err.Error())
+	}
+	return filepath.Join(homeDirectory, linuxBackupsDirectory, url.PathEscape(key)), nil
+}
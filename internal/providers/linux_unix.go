@@ -0,0 +1,13 @@
+//go:build !windows
+
+package providers
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess starts the process in a new session, so that it keeps running after Stratus Red Team exits
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package providers
+
+import "os/exec"
+
+// detachProcess is a no-op on Windows, where host attack techniques are not supported
+func detachProcess(*exec.Cmd) {}
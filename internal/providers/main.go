@@ -12,3 +12,15 @@ var UniqueExecutionId = uuid.New()
 func GetStratusUserAgent() string {
 	return fmt.Sprintf("%s_%s", StratusUserAgent, UniqueExecutionId)
 }
+
+// SetUniqueExecutionId replaces the unique execution ID, e.g. with the one of the Stratus Red Team execution running
+// a plugin, in the user-agent of API calls and in the default providers. Must be called before any client is built
+func SetUniqueExecutionId(executionId uuid.UUID) {
+	UniqueExecutionId = executionId
+	DefaultClientOptions.Telemetry.ApplicationID = executionId.String()
+	awsProvider.UniqueCorrelationId = executionId
+	azureProvider.UniqueCorrelationId = executionId
+	entraIDProvider.UniqueCorrelationId = executionId
+	gcpProvider.UniqueCorrelationId = executionId
+	k8sProvider.UniqueCorrelationId = executionId
+}
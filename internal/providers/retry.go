@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/time/rate"
+)
+
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// RetryPolicy configures how API calls are retried and rate-limited, for all providers
+type RetryPolicy struct {
+	// Maximum number of attempts for each API call, including the initial one
+	MaxAttempts int
+
+	// Retry mode, either "standard" or "adaptive". The adaptive mode additionally slows down requests when
+	// throttling errors are received. Only supported for AWS, other providers use the standard mode
+	Mode string
+
+	// Maximum delay between two attempts
+	MaxBackoff time.Duration
+
+	// Maximum number of API calls per second, per provider. 0 means unlimited
+	RateLimit float64
+
+	// Maximum number of API calls that can be made at once before the rate limit kicks in
+	RateLimitBurst int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    retry.DefaultMaxAttempts,
+		Mode:           RetryModeStandard,
+		MaxBackoff:     retry.DefaultMaxBackoff,
+		RateLimit:      0,
+		RateLimitBurst: 10,
+	}
+}
+
+var retryPolicy = DefaultRetryPolicy()
+
+// SetRetryPolicy configures the retry policy and rate limits applied to all providers.
+// Must be called before any provider is used
+func SetRetryPolicy(policy RetryPolicy) error {
+	if policy.Mode != RetryModeStandard && policy.Mode != RetryModeAdaptive {
+		return errors.New("unknown retry mode " + policy.Mode + ", must be '" + RetryModeStandard + "' or '" + RetryModeAdaptive + "'")
+	}
+	if policy.MaxAttempts < 1 {
+		return errors.New("the maximum number of attempts must be at least 1")
+	}
+	if policy.RateLimit < 0 {
+		return errors.New("the rate limit cannot be negative")
+	}
+	if policy.RateLimitBurst < 1 {
+		policy.RateLimitBurst = 1
+	}
+	retryPolicy = policy
+	return nil
+}
+
+func GetRetryPolicy() RetryPolicy {
+	return retryPolicy
+}
+
+// newRateLimiter returns a client-side rate limiter enforcing the retry policy, or nil if there is no rate limit
+func (m RetryPolicy) newRateLimiter() *rate.Limiter {
+	if m.RateLimit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(m.RateLimit), m.RateLimitBurst)
+}
+
+// backoff returns the delay to wait before a specific attempt (starting at 1), with exponential backoff
+func (m RetryPolicy) backoff(attempt int) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(attempt-1))) * time.Second
+	if delay > m.MaxBackoff {
+		return m.MaxBackoff
+	}
+	return delay
+}
+
+func logThrottling(platform string, details string, delay time.Duration) {
+	log.Printf("Throttled by %s (%s), retrying in %s", platform, details, delay.Round(time.Millisecond))
+}
+
+//
+// AWS
+//
+
+// newAwsRetryer builds an AWS SDK retryer implementing the retry policy
+func (m RetryPolicy) newAwsRetryer() aws.Retryer {
+	standardOptions := func(options *retry.StandardOptions) {
+		options.MaxAttempts = m.MaxAttempts
+		options.MaxBackoff = m.MaxBackoff
+	}
+
+	var retryer aws.RetryerV2
+	if m.Mode == RetryModeAdaptive {
+		retryer = retry.NewAdaptiveMode(func(options *retry.AdaptiveModeOptions) {
+			options.StandardOptions = append(options.StandardOptions, standardOptions)
+		})
+	} else {
+		retryer = retry.NewStandard(standardOptions)
+	}
+	return &throttlingLoggingRetryer{RetryerV2: retryer}
+}
+
+// throttlingLoggingRetryer wraps an AWS SDK retryer to log throttling errors
+type throttlingLoggingRetryer struct {
+	aws.RetryerV2
+}
+
+func (m *throttlingLoggingRetryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	delay, delayErr := m.RetryerV2.RetryDelay(attempt, err)
+	if delayErr == nil && retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		logThrottling("AWS", err.Error(), delay)
+	}
+	return delay, delayErr
+}
+
+// awsRateLimitMiddleware waits for the rate limiter before each attempt of an API call
+func awsRateLimitMiddleware(limiter *rate.Limiter) middleware.FinalizeMiddleware {
+	return middleware.FinalizeMiddlewareFunc("StratusRateLimit", func(
+		ctx context.Context, input middleware.FinalizeInput, next middleware.FinalizeHandler,
+	) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if err := limiter.Wait(ctx); err != nil {
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, fmt.Errorf("rate limit of %s:%s: %w",
+				awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx), err)
+		}
+		return next.HandleFinalize(ctx, input)
+	})
+}
+
+//
+// Kubernetes and GCP
+//
+
+// throttlingRoundTripper retries HTTP API calls that are throttled (HTTP 429), and optionally enforces a rate limit.
+// Note that for Kubernetes, the rate limit is enforced by client-go itself, through the QPS and burst of its REST config
+type throttlingRoundTripper struct {
+	platform string
+	policy   RetryPolicy
+	limiter  *rate.Limiter
+	next     http.RoundTripper
+
+	// Set when the client already retries responses with a Retry-After header, as client-go does. These responses
+	// are then returned as is, to avoid retrying them twice
+	clientHandlesRetryAfter bool
+}
+
+func (m *throttlingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if m.limiter != nil {
+			if err := m.limiter.Wait(request.Context()); err != nil {
+				return nil, err
+			}
+		}
+		response, err := m.next.RoundTrip(request)
+		if err != nil || response.StatusCode != http.StatusTooManyRequests || attempt >= m.policy.MaxAttempts {
+			return response, err
+		}
+
+		// Only retry if the request body can be sent again
+		if request.Body != nil && request.GetBody == nil {
+			return response, err
+		}
+
+		delay := m.policy.backoff(attempt)
+		if retryAfter, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil {
+			if m.clientHandlesRetryAfter {
+				logThrottling(m.platform, request.Method+" "+request.URL.Path, time.Duration(retryAfter)*time.Second)
+				return response, err
+			}
+			if retryAfter > 0 {
+				delay = time.Duration(retryAfter) * time.Second
+			}
+		}
+		response.Body.Close()
+		logThrottling(m.platform, request.Method+" "+request.URL.Path, delay)
+
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request.Body = body
+		}
+	}
+}
+
+//
+// Azure
+//
+
+// azureRetryOptions converts the retry policy to Azure SDK retry options
+func (m RetryPolicy) azureRetryOptions() policy.RetryOptions {
+	maxRetries := int32(m.MaxAttempts - 1)
+	if maxRetries == 0 {
+		// For the Azure SDK, 0 means "use the default number of retries" and a negative value means no retry
+		maxRetries = -1
+	}
+	return policy.RetryOptions{
+		MaxRetries:    maxRetries,
+		MaxRetryDelay: m.MaxBackoff,
+	}
+}
+
+// azureThrottlingPolicy is an Azure SDK pipeline policy, run for each attempt, that enforces the rate limit and
+// logs throttling responses
+type azureThrottlingPolicy struct {
+	limiter *rate.Limiter
+}
+
+func (m *azureThrottlingPolicy) Do(request *policy.Request) (*http.Response, error) {
+	if m.limiter != nil {
+		if err := m.limiter.Wait(request.Raw().Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := request.Next()
+	if err == nil && response.StatusCode == http.StatusTooManyRequests {
+		log.Printf("Throttled by Azure (%s %s), the Azure SDK will retry", request.Raw().Method, request.Raw().URL.Path)
+	}
+	return response, err
+}
@@ -0,0 +1,285 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxBackoff: 5 * time.Second}
+	scenarios := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 1, expected: 1 * time.Second},
+		{attempt: 2, expected: 2 * time.Second},
+		{attempt: 3, expected: 4 * time.Second},
+		{attempt: 4, expected: 5 * time.Second},
+		{attempt: 20, expected: 5 * time.Second},
+	}
+	for _, scenario := range scenarios {
+		assert.Equal(t, scenario.expected, policy.backoff(scenario.attempt), "attempt %d", scenario.attempt)
+	}
+}
+
+func TestRetryPolicyNewRateLimiter(t *testing.T) {
+	assert.Nil(t, RetryPolicy{RateLimit: 0}.newRateLimiter())
+
+	limiter := RetryPolicy{RateLimit: 2.5, RateLimitBurst: 4}.newRateLimiter()
+	assert.Equal(t, rate.Limit(2.5), limiter.Limit())
+	assert.Equal(t, 4, limiter.Burst())
+}
+
+// throttlingServer responds with a sequence of status codes and Retry-After headers, then with HTTP 200
+type throttlingServer struct {
+	*httptest.Server
+	requests int32
+	bodies   []string
+}
+
+type throttledResponse struct {
+	statusCode int
+	retryAfter string
+}
+
+func newThrottlingServer(t *testing.T, responses ...throttledResponse) *throttlingServer {
+	server := &throttlingServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		server.bodies = append(server.bodies, string(body))
+		request := int(atomic.AddInt32(&server.requests, 1))
+		if request > len(responses) {
+			w.Write([]byte("{}"))
+			return
+		}
+		if responses[request-1].retryAfter != "" {
+			w.Header().Set("Retry-After", responses[request-1].retryAfter)
+		}
+		w.WriteHeader(responses[request-1].statusCode)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestThrottlingRoundTripper(t *testing.T) {
+	throttled := throttledResponse{statusCode: http.StatusTooManyRequests}
+	scenarios := []struct {
+		name                    string
+		responses               []throttledResponse
+		maxAttempts             int
+		clientHandlesRetryAfter bool
+		expectedStatusCode      int
+		expectedRequests        int
+	}{
+		{
+			name:               "not throttled",
+			maxAttempts:        3,
+			expectedStatusCode: http.StatusOK,
+			expectedRequests:   1,
+		},
+		{
+			name:               "throttled once",
+			responses:          []throttledResponse{throttled},
+			maxAttempts:        3,
+			expectedStatusCode: http.StatusOK,
+			expectedRequests:   2,
+		},
+		{
+			name:               "throttled until the maximum number of attempts",
+			responses:          []throttledResponse{throttled, throttled, throttled},
+			maxAttempts:        3,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRequests:   3,
+		},
+		{
+			name:               "single attempt",
+			responses:          []throttledResponse{throttled},
+			maxAttempts:        1,
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRequests:   1,
+		},
+		{
+			name:               "other errors are not retried",
+			responses:          []throttledResponse{{statusCode: http.StatusInternalServerError}},
+			maxAttempts:        3,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedRequests:   1,
+		},
+		{
+			name:               "invalid Retry-After",
+			responses:          []throttledResponse{{statusCode: http.StatusTooManyRequests, retryAfter: "soon"}},
+			maxAttempts:        3,
+			expectedStatusCode: http.StatusOK,
+			expectedRequests:   2,
+		},
+		{
+			name:                    "Retry-After left to the client",
+			responses:               []throttledResponse{{statusCode: http.StatusTooManyRequests, retryAfter: "0"}},
+			maxAttempts:             3,
+			clientHandlesRetryAfter: true,
+			expectedStatusCode:      http.StatusTooManyRequests,
+			expectedRequests:        1,
+		},
+		{
+			name:                    "no Retry-After for the client",
+			responses:               []throttledResponse{throttled},
+			maxAttempts:             3,
+			clientHandlesRetryAfter: true,
+			expectedStatusCode:      http.StatusOK,
+			expectedRequests:        2,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			server := newThrottlingServer(t, scenario.responses...)
+			client := &http.Client{Transport: &throttlingRoundTripper{
+				platform:                "test",
+				policy:                  RetryPolicy{MaxAttempts: scenario.maxAttempts, MaxBackoff: time.Millisecond},
+				next:                    http.DefaultTransport,
+				clientHandlesRetryAfter: scenario.clientHandlesRetryAfter,
+			}}
+
+			request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+			response, err := client.Do(request)
+			assert.Nil(t, err)
+			response.Body.Close()
+			assert.Equal(t, scenario.expectedStatusCode, response.StatusCode)
+			assert.Equal(t, scenario.expectedRequests, int(server.requests))
+			// The body is sent again with each attempt
+			for _, body := range server.bodies {
+				assert.Equal(t, "payload", body)
+			}
+		})
+	}
+}
+
+func TestThrottlingRoundTripperWaitsForRetryAfter(t *testing.T) {
+	server := newThrottlingServer(t, throttledResponse{statusCode: http.StatusTooManyRequests, retryAfter: "60"})
+	client := &http.Client{Transport: &throttlingRoundTripper{
+		platform: "test",
+		policy:   RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Millisecond},
+		next:     http.DefaultTransport,
+	}}
+
+	// The request is cancelled while waiting for the delay of the Retry-After header, not the shorter backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := client.Do(request)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, int(server.requests))
+}
+
+func TestThrottlingRoundTripperRateLimit(t *testing.T) {
+	server := newThrottlingServer(t)
+	client := &http.Client{Transport: &throttlingRoundTripper{
+		platform: "test",
+		policy:   RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Millisecond},
+		limiter:  rate.NewLimiter(rate.Every(time.Hour), 1),
+		next:     http.DefaultTransport,
+	}}
+
+	response, err := client.Get(server.URL)
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	// The next request would have to wait for an hour
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err = client.Do(request)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, int(server.requests))
+}
+
+func TestRetryPolicyAzureRetryOptions(t *testing.T) {
+	scenarios := []struct {
+		maxAttempts        int
+		expectedMaxRetries int32
+	}{
+		{maxAttempts: 1, expectedMaxRetries: -1},
+		{maxAttempts: 2, expectedMaxRetries: 1},
+		{maxAttempts: 5, expectedMaxRetries: 4},
+	}
+	for _, scenario := range scenarios {
+		options := RetryPolicy{MaxAttempts: scenario.maxAttempts, MaxBackoff: 30 * time.Second}.azureRetryOptions()
+		assert.Equal(t, scenario.expectedMaxRetries, options.MaxRetries, "%d attempts", scenario.maxAttempts)
+		assert.Equal(t, 30*time.Second, options.MaxRetryDelay)
+	}
+}
+
+func TestRetryPolicyNewAwsRetryer(t *testing.T) {
+	throttlingError := errors.New("throttled")
+	scenarios := []struct {
+		mode     string
+		expected interface{}
+	}{
+		{mode: RetryModeStandard, expected: &retry.Standard{}},
+		{mode: RetryModeAdaptive, expected: &retry.AdaptiveMode{}},
+	}
+	for _, scenario := range scenarios {
+		retryer := RetryPolicy{Mode: scenario.mode, MaxAttempts: 7, MaxBackoff: 2 * time.Second}.newAwsRetryer()
+		assert.IsType(t, &throttlingLoggingRetryer{}, retryer)
+		assert.IsType(t, scenario.expected, retryer.(*throttlingLoggingRetryer).RetryerV2)
+		assert.Equal(t, 7, retryer.MaxAttempts())
+		for attempt := 1; attempt < 10; attempt++ {
+			delay, err := retryer.RetryDelay(attempt, throttlingError)
+			assert.Nil(t, err)
+			assert.LessOrEqual(t, delay, 2*time.Second)
+		}
+	}
+	var _ aws.Retryer = RetryPolicy{Mode: RetryModeStandard}.newAwsRetryer()
+}
+
+func TestK8sProviderApplyRetryPolicy(t *testing.T) {
+	provider := NewK8sProvider(K8sOptions{})
+
+	config := &rest.Config{}
+	provider.applyRetryPolicy(config, RetryPolicy{MaxAttempts: 3, RateLimit: 0})
+	// client-go does not rate limit requests when the QPS is negative
+	assert.Nil(t, config.RateLimiter)
+	assert.Equal(t, float32(-1), config.QPS)
+
+	config = &rest.Config{}
+	provider.applyRetryPolicy(config, RetryPolicy{MaxAttempts: 3, RateLimit: 2, RateLimitBurst: 4})
+	assert.NotNil(t, config.RateLimiter)
+	assert.Equal(t, float32(2), config.RateLimiter.QPS())
+
+	// The rate limiter is shared by all configs
+	otherConfig := &rest.Config{}
+	provider.applyRetryPolicy(otherConfig, RetryPolicy{MaxAttempts: 3, RateLimit: 2, RateLimitBurst: 4})
+	assert.Same(t, config.RateLimiter, otherConfig.RateLimiter)
+}
+
+func TestK8sProviderRetriesThrottledCallsOnce(t *testing.T) {
+	var responses []throttledResponse
+	for i := 0; i < 50; i++ {
+		responses = append(responses, throttledResponse{statusCode: http.StatusTooManyRequests, retryAfter: "0"})
+	}
+	server := newThrottlingServer(t, responses...)
+	config := &rest.Config{Host: server.URL}
+	NewK8sProvider(K8sOptions{}).applyRetryPolicy(config, RetryPolicy{MaxAttempts: 3, MaxBackoff: time.Millisecond})
+	client, err := kubernetes.NewForConfig(config)
+	assert.Nil(t, err)
+
+	// client-go retries responses with a Retry-After header 10 times, and each of its attempts is not retried again
+	_, err = client.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+	assert.NotNil(t, err)
+	assert.Equal(t, 11, int(server.requests))
+}
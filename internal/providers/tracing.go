@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/datadog/stratus-red-team/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// Functions below trace the calls made to the API of each platform, as children of the span of their context
+
+// executionAttributes returns the attributes identifying the execution an API call is made for. Within a runner, the
+// context carries the technique and execution IDs, which spans inherit. Otherwise, the unique execution ID is used
+func executionAttributes(ctx context.Context) []attribute.KeyValue {
+	for _, contextAttribute := range tracing.Attributes(ctx) {
+		if contextAttribute.Key == tracing.AttributeExecutionID {
+			return nil
+		}
+	}
+	return []attribute.KeyValue{tracing.AttributeExecutionID.String(UniqueExecutionId.String())}
+}
+
+func tracingApiOptions() config.LoadOptionsFunc {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{
+		func(stack *middleware.Stack) error {
+			// After the service metadata has been registered, but before retries
+			return stack.Initialize.Add(awsTracingMiddleware(), middleware.After)
+		},
+	})
+}
+
+// awsTracingMiddleware traces AWS API calls, e.g. "AWS CloudTrail.StopLogging"
+func awsTracingMiddleware() middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("StratusTracing", func(
+		ctx context.Context, input middleware.InitializeInput, next middleware.InitializeHandler,
+	) (out middleware.InitializeOutput, metadata middleware.Metadata, err error) {
+		service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+		attributes := append(executionAttributes(ctx),
+			tracing.AttributePlatform.String("AWS"),
+			semconv.RPCSystemKey.String("aws-api"),
+			semconv.RPCServiceKey.String(service),
+			semconv.RPCMethodKey.String(operation),
+		)
+		ctx, span := tracing.Start(ctx, "AWS "+service+"."+operation, attributes...)
+		out, metadata, err = next.HandleInitialize(ctx, input)
+		tracing.End(span, err)
+		return out, metadata, err
+	})
+}
+
+// azureTracingPolicy is an Azure SDK pipeline policy, run once per API call, that traces Azure API calls
+type azureTracingPolicy struct{}
+
+func (m *azureTracingPolicy) Do(request *policy.Request) (*http.Response, error) {
+	ctx, span := tracing.StartHTTP(request.Raw(), "Azure", executionAttributes(request.Raw().Context())...)
+	response, err := request.Clone(ctx).Next()
+	tracing.EndHTTP(span, response, err)
+	return response, err
+}
+
+// tracingTransport is an HTTP transport tracing the API calls made to a platform
+type tracingTransport struct {
+	platform string
+	next     http.RoundTripper
+}
+
+func newTracingTransport(platform string, next http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{platform: platform, next: next}
+}
+
+func (m *tracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, span := tracing.StartHTTP(request, m.platform, executionAttributes(request.Context())...)
+	response, err := m.next.RoundTrip(request.WithContext(ctx))
+	tracing.EndHTTP(span, response, err)
+	return response, err
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/datadog/stratus-red-team/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans records the spans ended during a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// runnerContext returns a context carrying the attributes a runner sets
+func runnerContext() context.Context {
+	return tracing.WithAttributes(context.Background(),
+		tracing.AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"),
+		tracing.AttributeExecutionID.String("runner-execution-id"),
+	)
+}
+
+// executionIDs returns the values of the execution ID attributes of a span
+func executionIDs(span sdktrace.ReadOnlySpan) []string {
+	var ids []string
+	for _, spanAttribute := range span.Attributes() {
+		if spanAttribute.Key == tracing.AttributeExecutionID {
+			ids = append(ids, spanAttribute.Value.AsString())
+		}
+	}
+	return ids
+}
+
+func TestAWSTracingMiddlewareUsesAttributesOfRunner(t *testing.T) {
+	recorder := recordSpans(t)
+	server := newAWSTestServer(t)
+	cfg := server.config(t, "AKIAEXAMPLE")
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(awsTracingMiddleware(), middleware.After)
+	})
+
+	for _, ctx := range []context.Context{runnerContext(), context.Background()} {
+		_, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		assert.Nil(t, err)
+	}
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "AWS STS.GetCallerIdentity", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), tracing.AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"))
+	assert.Equal(t, []string{"runner-execution-id"}, executionIDs(spans[0]))
+	// Outside of a runner, the unique execution ID is used
+	assert.Equal(t, []string{UniqueExecutionId.String()}, executionIDs(spans[1]))
+}
+
+func TestTracingTransportUsesAttributesOfRunner(t *testing.T) {
+	recorder := recordSpans(t)
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: newTracingTransport("GCP", http.DefaultTransport)}
+
+	for _, ctx := range []context.Context{runnerContext(), context.Background()} {
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		response, err := client.Do(request)
+		assert.Nil(t, err)
+		response.Body.Close()
+	}
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Contains(t, spans[0].Attributes(), tracing.AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("stratus.platform", "GCP"))
+	assert.Equal(t, []string{"runner-execution-id"}, executionIDs(spans[0]))
+	assert.Equal(t, []string{UniqueExecutionId.String()}, executionIDs(spans[1]))
+}
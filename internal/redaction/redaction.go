@@ -0,0 +1,148 @@
+package redaction
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Placeholder is the value that sensitive data is replaced with
+const Placeholder = "[REDACTED]"
+
+// Names of Terraform outputs (or parts of names) that are considered sensitive
+var sensitiveKeyMarkers = []string{
+	"password",
+	"secret",
+	"token",
+	"private_key",
+	"access_key",
+	"credential",
+	"sas_url",
+}
+
+var (
+	// AWS access key IDs, for long-lived (AKIA) and temporary (ASIA) credentials
+	awsAccessKeyIdPattern = regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`)
+
+	// AWS secret access keys are 40 characters long and base64-like. To avoid false positives with
+	// e.g. SHA-1 hashes, candidates are only redacted if they contain mixed case letters and digits.
+	awsSecretKeyPattern = regexp.MustCompile(`(^|[^A-Za-z0-9/+=])([A-Za-z0-9/+]{40})([^A-Za-z0-9/+=]|$)`)
+
+	// Signature of Azure Shared Access Signature (SAS) URLs
+	sasSignaturePattern = regexp.MustCompile(`([?&]sig=)[^&\s"']+`)
+
+	// JSON Web Tokens, e.g. Kubernetes service account tokens
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+var (
+	lock         sync.RWMutex
+	enabled      = true
+	knownSecrets = map[string]bool{}
+)
+
+// SetEnabled enables or disables redaction globally, e.g. for debugging purposes
+func SetEnabled(value bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	enabled = value
+}
+
+// IsEnabled returns whether redaction is currently enabled
+func IsEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return enabled
+}
+
+// RegisterSecret registers a literal value that should never be displayed
+func RegisterSecret(secret string) {
+	if strings.TrimSpace(secret) == "" {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	knownSecrets[secret] = true
+}
+
+// IsSensitiveKey returns true if the name of a Terraform output or parameter indicates it holds sensitive data
+func IsSensitiveKey(key string) bool {
+	lowerKey := strings.ToLower(key)
+	for _, marker := range sensitiveKeyMarkers {
+		if strings.Contains(lowerKey, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterSensitiveOutputs registers the values of the Terraform outputs that have a sensitive name
+func RegisterSensitiveOutputs(outputs map[string]string) {
+	for key, value := range outputs {
+		if IsSensitiveKey(key) {
+			RegisterSecret(value)
+		}
+	}
+}
+
+// Redact removes sensitive data from a string, if redaction is enabled
+func Redact(input string) string {
+	lock.RLock()
+	defer lock.RUnlock()
+	if !enabled {
+		return input
+	}
+
+	result := input
+	for secret := range knownSecrets {
+		result = strings.ReplaceAll(result, secret, Placeholder)
+	}
+	result = jwtPattern.ReplaceAllString(result, Placeholder)
+	result = sasSignaturePattern.ReplaceAllString(result, "${1}"+Placeholder)
+	result = awsAccessKeyIdPattern.ReplaceAllString(result, Placeholder)
+	result = awsSecretKeyPattern.ReplaceAllStringFunc(result, redactAwsSecretKeyCandidate)
+	return result
+}
+
+func redactAwsSecretKeyCandidate(match string) string {
+	groups := awsSecretKeyPattern.FindStringSubmatch(match)
+	candidate := groups[2]
+	if !looksLikeSecretKey(candidate) {
+		return match
+	}
+	return groups[1] + Placeholder + groups[3]
+}
+
+func looksLikeSecretKey(candidate string) bool {
+	var hasUpper, hasLower, hasDigit bool
+	for _, c := range candidate {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			hasUpper = true
+		case c >= 'a' && c <= 'z':
+			hasLower = true
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		}
+	}
+	return hasUpper && hasLower && hasDigit
+}
+
+// Writer is an io.Writer that redacts sensitive data before writing it to an underlying writer
+type Writer struct {
+	out io.Writer
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+func (m *Writer) Write(p []byte) (int, error) {
+	_, err := m.out.Write([]byte(Redact(string(p))))
+	if err != nil {
+		return 0, err
+	}
+	// Report the length of the original input, as the redacted output may differ in size
+	return len(p), nil
+}
@@ -0,0 +1,118 @@
+// Package report summarizes the attack techniques detonated during a session, e.g. to hand over to a blue team
+package report
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// Report lists the detonations since a given time, and the techniques left in a non-COLD state
+type Report struct {
+	Since       time.Time
+	GeneratedAt time.Time
+	Detonations []Detonation
+	Leftovers   []Leftover
+}
+
+// Detonation is a detonation of an attack technique recorded in the history
+type Detonation struct {
+	history.Entry
+	FriendlyName string
+	Tactics      []string
+	Detection    string
+
+	// Time at which the detonation was reverted, or nil if it was not
+	RevertedAt *time.Time
+}
+
+// Leftover is an attack technique whose prerequisites or detonation have not been cleaned up
+type Leftover struct {
+	TechniqueID  string
+	FriendlyName string
+	State        stratus.AttackTechniqueState
+}
+
+// StateReader returns the persisted state of an attack technique
+type StateReader func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState
+
+// Build builds a report from the history entries since a given time. Techniques of the registry whose state is not
+// COLD are reported as leftovers
+func Build(entries []history.Entry, registry *stratus.Registry, since time.Time, stateOf StateReader) *Report {
+	report := &Report{Since: since, GeneratedAt: time.Now().UTC()}
+
+	for i, entry := range entries {
+		if entry.Operation != history.OperationDetonate || entry.StartTime.Before(since) {
+			continue
+		}
+		detonation := Detonation{Entry: entry, FriendlyName: entry.TechniqueID}
+		if technique := registry.GetAttackTechniqueByName(entry.TechniqueID); technique != nil {
+			detonation.FriendlyName = technique.FriendlyName
+			detonation.Detection = technique.Detection
+			for _, tactic := range technique.MitreAttackTactics {
+				detonation.Tactics = append(detonation.Tactics, mitreattack.AttackTacticToString(tactic))
+			}
+		}
+		detonation.RevertedAt = revertTime(entries[i+1:], entry.TechniqueID)
+		report.Detonations = append(report.Detonations, detonation)
+	}
+
+	for _, technique := range registry.ListAttackTechniques() {
+		state := stateOf(technique)
+		if state == "" || state == stratus.AttackTechniqueStatusCold {
+			continue
+		}
+		report.Leftovers = append(report.Leftovers, Leftover{
+			TechniqueID:  technique.ID,
+			FriendlyName: technique.FriendlyName,
+			State:        state,
+		})
+	}
+	sort.Slice(report.Leftovers, func(i, j int) bool {
+		return report.Leftovers[i].TechniqueID < report.Leftovers[j].TechniqueID
+	})
+
+	return report
+}
+
+// revertTime returns the end time of the first successful revert or cleanup of a technique in subsequent entries,
+// unless the technique is detonated again before
+func revertTime(entries []history.Entry, techniqueID string) *time.Time {
+	for _, entry := range entries {
+		if entry.TechniqueID != techniqueID || !entry.Succeeded() {
+			continue
+		}
+		switch entry.Operation {
+		case history.OperationDetonate:
+			return nil
+		case history.OperationRevert, history.OperationCleanUp:
+			endTime := entry.EndTime
+			return &endTime
+		}
+	}
+	return nil
+}
+
+// Render writes the report in a given format
+func (m *Report) Render(writer io.Writer, format Format) error {
+	switch format {
+	case FormatMarkdown:
+		return markdownTemplate.Execute(writer, m)
+	case FormatHTML:
+		return htmlTemplate.Execute(writer, m)
+	default:
+		return errors.New("unsupported report format " + string(format))
+	}
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func testRegistry() *stratus.Registry {
+	registry := stratus.NewRegistry()
+	registry.RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:                 "aws.defense-evasion.cloudtrail-stop",
+		FriendlyName:       "Stop CloudTrail Trail",
+		Detection:          "Identify when a CloudTrail trail is stopped, through <StopLogging> events",
+		Platform:           stratus.AWS,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.DefenseEvasion},
+	})
+	registry.RegisterAttackTechnique(&stratus.AttackTechnique{
+		ID:                 "aws.persistence.iam-create-admin-user",
+		FriendlyName:       "Create an administrative IAM User",
+		Platform:           stratus.AWS,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Persistence, mitreattack.PrivilegeEscalation},
+	})
+	return &registry
+}
+
+func entry(techniqueID string, operation history.Operation, offset time.Duration) history.Entry {
+	return history.Entry{
+		ExecutionID: "e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e",
+		TechniqueID: techniqueID,
+		Platform:    string(stratus.AWS),
+		Operation:   operation,
+		StartTime:   start.Add(offset),
+		EndTime:     start.Add(offset + time.Minute),
+		Target:      "account 123456789012 in region us-east-1",
+		Identity:    "arn:aws:iam::123456789012:user/red-team",
+	}
+}
+
+func TestBuildsReport(t *testing.T) {
+	failed := entry("aws.persistence.iam-create-admin-user", history.OperationDetonate, 3*time.Hour)
+	failed.Error = "access denied"
+	detonated := entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 2*time.Hour)
+	detonated.Verification = &history.Verification{Detonated: true}
+	entries := []history.Entry{
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 0),
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationWarmUp, 2*time.Hour),
+		detonated,
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationRevert, 4*time.Hour),
+		failed,
+		entry("aws.unknown.removed-technique", history.OperationDetonate, 5*time.Hour),
+	}
+	states := map[string]stratus.AttackTechniqueState{
+		"aws.defense-evasion.cloudtrail-stop":   stratus.AttackTechniqueStatusWarm,
+		"aws.persistence.iam-create-admin-user": stratus.AttackTechniqueStatusCold,
+	}
+
+	report := Build(entries, testRegistry(), start.Add(time.Hour), func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState {
+		return states[technique.ID]
+	})
+
+	assert.Equal(t, start.Add(time.Hour), report.Since)
+	assert.Len(t, report.Detonations, 3)
+
+	assert.Equal(t, "aws.defense-evasion.cloudtrail-stop", report.Detonations[0].TechniqueID)
+	assert.Equal(t, "Stop CloudTrail Trail", report.Detonations[0].FriendlyName)
+	assert.Equal(t, []string{"Defense Evasion"}, report.Detonations[0].Tactics)
+	assert.NotNil(t, report.Detonations[0].Verification)
+	if assert.NotNil(t, report.Detonations[0].RevertedAt) {
+		assert.Equal(t, start.Add(4*time.Hour+time.Minute), *report.Detonations[0].RevertedAt)
+	}
+
+	assert.Equal(t, []string{"Persistence", "Privilege Escalation"}, report.Detonations[1].Tactics)
+	assert.False(t, report.Detonations[1].Succeeded())
+	assert.Nil(t, report.Detonations[1].RevertedAt)
+
+	// Techniques no longer registered are still reported
+	assert.Equal(t, "aws.unknown.removed-technique", report.Detonations[2].FriendlyName)
+	assert.Empty(t, report.Detonations[2].Tactics)
+
+	assert.Equal(t, []Leftover{
+		{"aws.defense-evasion.cloudtrail-stop", "Stop CloudTrail Trail", stratus.AttackTechniqueStatusWarm},
+	}, report.Leftovers)
+}
+
+func TestRevertTimeStopsAtNextDetonation(t *testing.T) {
+	entries := []history.Entry{
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 0),
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, time.Hour),
+		entry("aws.defense-evasion.cloudtrail-stop", history.OperationCleanUp, 2*time.Hour),
+	}
+	report := Build(entries, testRegistry(), start, func(*stratus.AttackTechnique) stratus.AttackTechniqueState { return "" })
+
+	assert.Len(t, report.Detonations, 2)
+	assert.Nil(t, report.Detonations[0].RevertedAt)
+	assert.NotNil(t, report.Detonations[1].RevertedAt)
+	assert.Empty(t, report.Leftovers)
+}
+
+func TestRendersReport(t *testing.T) {
+	failed := entry("aws.persistence.iam-create-admin-user", history.OperationDetonate, time.Hour)
+	failed.Error = "access | denied"
+	detonated := entry("aws.defense-evasion.cloudtrail-stop", history.OperationDetonate, 0)
+	detonated.Verification = &history.Verification{Detonated: true}
+	report := Build([]history.Entry{detonated, failed}, testRegistry(), start, func(technique *stratus.AttackTechnique) stratus.AttackTechniqueState {
+		return stratus.AttackTechniqueStatusDetonated
+	})
+
+	var markdown bytes.Buffer
+	assert.Nil(t, report.Render(&markdown, FormatMarkdown))
+	assert.Contains(t, markdown.String(), "### Stop CloudTrail Trail (`aws.defense-evasion.cloudtrail-stop`)")
+	assert.Contains(t, markdown.String(), "| Time window | 2022-03-01 10:00:00 UTC - 2022-03-01 10:01:00 UTC |")
+	assert.Contains(t, markdown.String(), "| Target | account 123456789012 in region us-east-1 |")
+	assert.Contains(t, markdown.String(), "| Execution ID | `e5d4a7ea-2c2a-4d4a-8e8f-7f4b0b2c1d3e` |")
+	assert.Contains(t, markdown.String(), "| MITRE ATT&CK tactics | Defense Evasion |")
+	assert.Contains(t, markdown.String(), "| Result | Succeeded, side effects verified |")
+	assert.Contains(t, markdown.String(), "| Result | Failed: access \\| denied |")
+	assert.Contains(t, markdown.String(), "Identify when a CloudTrail trail is stopped, through <StopLogging> events")
+	assert.Contains(t, markdown.String(), "| aws.persistence.iam-create-admin-user | Create an administrative IAM User | DETONATED |")
+
+	var html bytes.Buffer
+	assert.Nil(t, report.Render(&html, FormatHTML))
+	assert.Contains(t, html.String(), "<td>Succeeded, side effects verified</td>")
+	assert.Contains(t, html.String(), "through &lt;StopLogging&gt; events")
+	assert.Contains(t, html.String(), `<td class="failed">Failed: access | denied</td>`)
+
+	assert.NotNil(t, report.Render(&html, "pdf"))
+}
+
+func TestRendersEmptyReport(t *testing.T) {
+	report := Build(nil, testRegistry(), start, func(*stratus.AttackTechnique) stratus.AttackTechniqueState { return "" })
+
+	var markdown bytes.Buffer
+	assert.Nil(t, report.Render(&markdown, FormatMarkdown))
+	assert.Contains(t, markdown.String(), "No attack technique was detonated.")
+	assert.Contains(t, markdown.String(), "All attack techniques have been cleaned up.")
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/history"
+)
+
+const timeFormat = "2006-01-02 15:04:05 MST"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// formatResult describes the outcome of a detonation and of its verification
+func formatResult(detonation Detonation) string {
+	if !detonation.Succeeded() {
+		return "Failed: " + detonation.Error
+	}
+	return "Succeeded" + formatVerification(detonation.Verification)
+}
+
+func formatVerification(verification *history.Verification) string {
+	switch {
+	case verification == nil:
+		return ""
+	case verification.Error != "":
+		return ", unable to verify the detonation: " + verification.Error
+	case verification.Detonated:
+		return ", side effects verified"
+	default:
+		return ", side effects not observed"
+	}
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// markdownCell escapes a value to be displayed in a Markdown table cell
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.ReplaceAll(strings.TrimSpace(value), "\n", "<br>")
+}
+
+var functions = map[string]interface{}{
+	"formatTime":   formatTime,
+	"formatResult": formatResult,
+	"orUnknown":    orUnknown,
+	"join":         strings.Join,
+	"cell":         markdownCell,
+}
+
+var markdownTemplate = texttemplate.Must(texttemplate.New("markdown").Funcs(functions).Parse(`# Stratus Red Team report
+
+Attack techniques detonated between {{ formatTime .Since }} and {{ formatTime .GeneratedAt }}.
+
+## Detonations
+{{ if not .Detonations }}
+No attack technique was detonated.
+{{ end }}{{ range .Detonations }}
+### {{ .FriendlyName }} (` + "`{{ .TechniqueID }}`" + `)
+
+| | |
+|---|---|
+| Time window | {{ formatTime .StartTime }} - {{ formatTime .EndTime }} |
+| Target | {{ cell (orUnknown .Target) }} |
+| Identity | {{ cell (orUnknown .Identity) }} |
+| Execution ID | ` + "`{{ .ExecutionID }}`" + ` |
+| MITRE ATT&CK tactics | {{ cell (orUnknown (join .Tactics ", ")) }} |
+| Result | {{ cell (formatResult .) }} |
+| Reverted | {{ if .RevertedAt }}{{ formatTime .RevertedAt }}{{ else }}No{{ end }} |
+{{ if .Detection }}
+**Detection**
+
+{{ .Detection }}
+{{ end }}{{ end }}
+## Leftover techniques
+{{ if .Leftovers }}
+The following attack techniques have not been cleaned up, use ` + "`stratus cleanup`" + ` to remove their resources.
+
+| ID | Name | Status |
+|---|---|---|
+{{ range .Leftovers }}| {{ .TechniqueID }} | {{ cell .FriendlyName }} | {{ .State }} |
+{{ end }}{{ else }}
+All attack techniques have been cleaned up.
+{{ end }}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(functions).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Stratus Red Team report</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+.detection { white-space: pre-wrap; }
+.failed { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Stratus Red Team report</h1>
+<p>Attack techniques detonated between {{ formatTime .Since }} and {{ formatTime .GeneratedAt }}.</p>
+
+<h2>Detonations</h2>
+{{ if not .Detonations }}<p>No attack technique was detonated.</p>
+{{ end }}{{ range .Detonations }}<h3>{{ .FriendlyName }} (<code>{{ .TechniqueID }}</code>)</h3>
+<table>
+<tr><th>Time window</th><td>{{ formatTime .StartTime }} - {{ formatTime .EndTime }}</td></tr>
+<tr><th>Target</th><td>{{ orUnknown .Target }}</td></tr>
+<tr><th>Identity</th><td>{{ orUnknown .Identity }}</td></tr>
+<tr><th>Execution ID</th><td><code>{{ .ExecutionID }}</code></td></tr>
+<tr><th>MITRE ATT&amp;CK tactics</th><td>{{ orUnknown (join .Tactics ", ") }}</td></tr>
+<tr><th>Result</th><td{{ if not .Succeeded }} class="failed"{{ end }}>{{ formatResult . }}</td></tr>
+<tr><th>Reverted</th><td>{{ if .RevertedAt }}{{ formatTime .RevertedAt }}{{ else }}No{{ end }}</td></tr>
+</table>
+{{ if .Detection }}<h4>Detection</h4>
+<div class="detection">{{ .Detection }}</div>
+{{ end }}{{ end }}
+<h2>Leftover techniques</h2>
+{{ if .Leftovers }}<p>The following attack techniques have not been cleaned up, use <code>stratus cleanup</code> to remove their resources.</p>
+<table>
+<tr><th>ID</th><th>Name</th><th>Status</th></tr>
+{{ range .Leftovers }}<tr><td>{{ .TechniqueID }}</td><td>{{ .FriendlyName }}</td><td>{{ .State }}</td></tr>
+{{ end }}</table>
+{{ else }}<p>All attack techniques have been cleaned up.</p>
+{{ end }}</body>
+</html>
+`))
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	apitrace "github.com/datadog/stratus-red-team/internal/apitrace"
 	mock "github.com/stretchr/testify/mock"
 
 	stratus "github.com/datadog/stratus-red-team/pkg/stratus"
@@ -41,6 +42,52 @@ func (_m *StateManager) ExtractTechnique() error {
 	return r0
 }
 
+// GetAPICallTraces provides a mock function with given fields:
+func (_m *StateManager) GetAPICallTraces() ([]apitrace.Trace, error) {
+	ret := _m.Called()
+
+	var r0 []apitrace.Trace
+	if rf, ok := ret.Get(0).(func() []apitrace.Trace); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]apitrace.Trace)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPrerequisitesVersion provides a mock function with given fields:
+func (_m *StateManager) GetPrerequisitesVersion() (*stratus.PrerequisitesVersion, error) {
+	ret := _m.Called()
+
+	var r0 *stratus.PrerequisitesVersion
+	if rf, ok := ret.Get(0).(func() *stratus.PrerequisitesVersion); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*stratus.PrerequisitesVersion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRootDirectory provides a mock function with given fields:
 func (_m *StateManager) GetRootDirectory() string {
 	ret := _m.Called()
@@ -70,15 +117,15 @@ func (_m *StateManager) GetTechniqueState() stratus.AttackTechniqueState {
 }
 
 // GetTerraformOutputs provides a mock function with given fields:
-func (_m *StateManager) GetTerraformOutputs() (map[string]string, error) {
+func (_m *StateManager) GetTerraformOutputs() (stratus.Outputs, error) {
 	ret := _m.Called()
 
-	var r0 map[string]string
-	if rf, ok := ret.Get(0).(func() map[string]string); ok {
+	var r0 stratus.Outputs
+	if rf, ok := ret.Get(0).(func() stratus.Outputs); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(map[string]string)
+			r0 = ret.Get(0).(stratus.Outputs)
 		}
 	}
 
@@ -111,12 +158,40 @@ func (_m *StateManager) SetTechniqueState(_a0 stratus.AttackTechniqueState) erro
 	return r0
 }
 
+// WriteAPICallTraces provides a mock function with given fields: traces
+func (_m *StateManager) WriteAPICallTraces(traces []apitrace.Trace) error {
+	ret := _m.Called(traces)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]apitrace.Trace) error); ok {
+		r0 = rf(traces)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WritePrerequisitesVersion provides a mock function with given fields: version
+func (_m *StateManager) WritePrerequisitesVersion(version stratus.PrerequisitesVersion) error {
+	ret := _m.Called(version)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(stratus.PrerequisitesVersion) error); ok {
+		r0 = rf(version)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // WriteTerraformOutputs provides a mock function with given fields: outputs
-func (_m *StateManager) WriteTerraformOutputs(outputs map[string]string) error {
+func (_m *StateManager) WriteTerraformOutputs(outputs stratus.Outputs) error {
 	ret := _m.Called(outputs)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(map[string]string) error); ok {
+	if rf, ok := ret.Get(0).(func(stratus.Outputs) error); ok {
 		r0 = rf(outputs)
 	} else {
 		r0 = ret.Error(0)
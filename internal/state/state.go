@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/json"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
 	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
 	"log"
@@ -13,6 +14,11 @@ const StratusStateDirectoryName = ".stratus-red-team"
 const StratusStateTerraformOutputsFileName = ".terraform-outputs"
 const StratusStateTechniqueStateFileName = ".state"
 const StratusStateTerraformFileName = "main.tf"
+const StratusStatePrerequisitesVersionFileName = ".prerequisites-version"
+
+// StratusStateAPICallTracesDirectoryName is the directory in which the API calls made by techniques are persisted.
+// Unlike the state of techniques, API call traces are kept when techniques are cleaned up
+const StratusStateAPICallTracesDirectoryName = "api-calls"
 
 type FileSystemStateManager struct {
 	RootDirectory string
@@ -55,10 +61,14 @@ type StateManager interface {
 	GetRootDirectory() string
 	ExtractTechnique() error
 	CleanupTechnique() error
-	GetTerraformOutputs() (map[string]string, error)
-	WriteTerraformOutputs(outputs map[string]string) error
+	GetTerraformOutputs() (stratus.Outputs, error)
+	WriteTerraformOutputs(outputs stratus.Outputs) error
 	GetTechniqueState() stratus.AttackTechniqueState
 	SetTechniqueState(state stratus.AttackTechniqueState) error
+	GetPrerequisitesVersion() (*stratus.PrerequisitesVersion, error)
+	WritePrerequisitesVersion(version stratus.PrerequisitesVersion) error
+	GetAPICallTraces() ([]apitrace.Trace, error)
+	WriteAPICallTraces(traces []apitrace.Trace) error
 }
 
 func NewFileSystemStateManager(technique *stratus.AttackTechnique) *FileSystemStateManager {
@@ -100,9 +110,11 @@ func (m *FileSystemStateManager) CleanupTechnique() error {
 	return m.FileSystem.RemoveDirectory(m.getTechniqueStateDirectory())
 }
 
-func (m *FileSystemStateManager) GetTerraformOutputs() (map[string]string, error) {
+// GetTerraformOutputs returns the persisted outputs of the technique prerequisites. Outputs persisted as plain strings
+// by previous versions are read as string outputs
+func (m *FileSystemStateManager) GetTerraformOutputs() (stratus.Outputs, error) {
 	outputPath := m.getOutputsStateFile()
-	outputs := make(map[string]string)
+	outputs := make(stratus.Outputs)
 
 	// If we have persisted Terraform outputs on disk, read them
 	if m.FileSystem.FileExists(outputPath) {
@@ -119,7 +131,7 @@ func (m *FileSystemStateManager) GetTerraformOutputs() (map[string]string, error
 	return outputs, nil
 }
 
-func (m *FileSystemStateManager) WriteTerraformOutputs(outputs map[string]string) error {
+func (m *FileSystemStateManager) WriteTerraformOutputs(outputs stratus.Outputs) error {
 	outputString, err := json.Marshal(outputs)
 	if err != nil {
 		return err
@@ -136,6 +148,63 @@ func (m *FileSystemStateManager) SetTechniqueState(state stratus.AttackTechnique
 	return m.FileSystem.WriteFile(m.getTechniqueStateFile(), []byte(state), 0744)
 }
 
+// GetPrerequisitesVersion returns the version of the prerequisites persisted when warming up the technique, or nil if
+// it was warmed up by a version of Stratus Red Team not persisting it
+func (m *FileSystemStateManager) GetPrerequisitesVersion() (*stratus.PrerequisitesVersion, error) {
+	versionPath := m.getPrerequisitesVersionFile()
+	if !m.FileSystem.FileExists(versionPath) {
+		return nil, nil
+	}
+	rawVersion, err := m.FileSystem.ReadFile(versionPath)
+	if err != nil {
+		return nil, err
+	}
+	var version stratus.PrerequisitesVersion
+	if err := json.Unmarshal(rawVersion, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func (m *FileSystemStateManager) WritePrerequisitesVersion(version stratus.PrerequisitesVersion) error {
+	rawVersion, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	return m.FileSystem.WriteFile(m.getPrerequisitesVersionFile(), rawVersion, 0744)
+}
+
+// GetAPICallTraces returns the API calls made by the last detonation of the technique and its reversions, if any
+func (m *FileSystemStateManager) GetAPICallTraces() ([]apitrace.Trace, error) {
+	tracesPath := m.getAPICallTracesFile()
+	if !m.FileSystem.FileExists(tracesPath) {
+		return nil, nil
+	}
+	rawTraces, err := m.FileSystem.ReadFile(tracesPath)
+	if err != nil {
+		return nil, err
+	}
+	var traces []apitrace.Trace
+	if err := json.Unmarshal(rawTraces, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+func (m *FileSystemStateManager) WriteAPICallTraces(traces []apitrace.Trace) error {
+	rawTraces, err := json.MarshalIndent(traces, "", "  ")
+	if err != nil {
+		return err
+	}
+	tracesDirectory := filepath.Join(m.RootDirectory, StratusStateAPICallTracesDirectoryName)
+	if !m.FileSystem.FileExists(tracesDirectory) {
+		if err := m.FileSystem.CreateDirectory(tracesDirectory, 0744); err != nil {
+			return err
+		}
+	}
+	return m.FileSystem.WriteFile(m.getAPICallTracesFile(), rawTraces, 0644)
+}
+
 func (m *FileSystemStateManager) getTechniqueStateDirectory() string {
 	return filepath.Join(m.RootDirectory, m.Technique.ID)
 }
@@ -148,6 +217,14 @@ func (m *FileSystemStateManager) getOutputsStateFile() string {
 	return filepath.Join(m.RootDirectory, m.Technique.ID, StratusStateTerraformOutputsFileName)
 }
 
+func (m *FileSystemStateManager) getPrerequisitesVersionFile() string {
+	return filepath.Join(m.RootDirectory, m.Technique.ID, StratusStatePrerequisitesVersionFileName)
+}
+
+func (m *FileSystemStateManager) getAPICallTracesFile() string {
+	return filepath.Join(m.RootDirectory, StratusStateAPICallTracesDirectoryName, m.Technique.ID+".json")
+}
+
 func (m *FileSystemStateManager) GetRootDirectory() string {
 	return m.RootDirectory
 }
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"encoding/json"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
 	"github.com/datadog/stratus-red-team/internal/state/mocks"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
 	"github.com/stretchr/testify/assert"
@@ -8,7 +10,7 @@ import (
 	"testing"
 )
 
-func noop(map[string]string) error {
+func noop(*stratus.ExecutionContext) error {
 	return nil
 }
 
@@ -99,7 +101,30 @@ func TestStateManagerRetrievesTechniqueOutputs(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Len(t, outputs, 1)
-	assert.Equal(t, "bar", outputs["foo"])
+	assert.Equal(t, "bar", outputs["foo"].String())
+}
+
+func TestStateManagerRetrievesTypedTechniqueOutputs(t *testing.T) {
+	fsMock := new(mocks.FileSystemMock)
+	fsMock.On("FileExists", mock.Anything).Return(true)
+	fsMock.On("ReadFile", "/root/.stratus-red-team/my-technique/.terraform-outputs").Return([]byte(
+		`{"foo": {"type": "string", "value": "bar"}, "ports": {"type": ["list", "number"], "value": [22, 3389]}}`,
+	), nil)
+
+	statemanager := FileSystemStateManager{
+		RootDirectory: "/root/.stratus-red-team",
+		Technique:     &stratus.AttackTechnique{ID: "my-technique", Detonate: noop},
+		FileSystem:    fsMock,
+	}
+	outputs, err := statemanager.GetTerraformOutputs()
+
+	assert.Nil(t, err)
+	assert.Len(t, outputs, 2)
+	assert.Equal(t, "bar", outputs["foo"].String())
+	assert.Equal(t, json.RawMessage(`["list", "number"]`), outputs["ports"].Type)
+	var ports []int
+	assert.Nil(t, outputs["ports"].Decode(&ports))
+	assert.Equal(t, []int{22, 3389}, ports)
 }
 
 func TestStateManagerWritesTechniqueOutputs(t *testing.T) {
@@ -115,10 +140,14 @@ func TestStateManagerWritesTechniqueOutputs(t *testing.T) {
 		FileSystem:    fsMock,
 	}
 	statemanager.Initialize()
-	err := statemanager.WriteTerraformOutputs(map[string]string{"bar": "foo"})
+	err := statemanager.WriteTerraformOutputs(stratus.Outputs{
+		"bar":   stratus.StringOutput("foo"),
+		"ports": {Type: json.RawMessage(`["list","number"]`), Value: json.RawMessage(`[22,3389]`)},
+	})
 
 	assert.Nil(t, err)
-	fsMock.AssertCalled(t, "WriteFile", outputFile, []byte("{\"bar\":\"foo\"}"), mock.Anything)
+	expectedOutputs := `{"bar":{"type":"string","value":"foo"},"ports":{"type":["list","number"],"value":[22,3389]}}`
+	fsMock.AssertCalled(t, "WriteFile", outputFile, []byte(expectedOutputs), mock.Anything)
 }
 
 func TestStateManagerReadsTechniqueState(t *testing.T) {
@@ -214,3 +243,67 @@ func TestStateManagerCleanupTechnique(t *testing.T) {
 // root dir exists?
 // technique dir exists?
 // output file?
+
+func TestStateManagerPersistsPrerequisitesVersion(t *testing.T) {
+	fsMock := new(mocks.FileSystemMock)
+	versionFile := "/root/.stratus-red-team/my-technique/.prerequisites-version"
+	fsMock.On("FileExists", versionFile).Return(true)
+	fsMock.On("ReadFile", versionFile).Return([]byte(`{"version":2,"hash":"abc"}`), nil)
+	fsMock.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	statemanager := FileSystemStateManager{
+		RootDirectory: "/root/.stratus-red-team",
+		Technique:     &stratus.AttackTechnique{ID: "my-technique", Detonate: noop},
+		FileSystem:    fsMock,
+	}
+
+	err := statemanager.WritePrerequisitesVersion(stratus.PrerequisitesVersion{Version: 2, Hash: "abc"})
+	assert.Nil(t, err)
+	fsMock.AssertCalled(t, "WriteFile", versionFile, []byte(`{"version":2,"hash":"abc"}`), mock.Anything)
+
+	version, err := statemanager.GetPrerequisitesVersion()
+	assert.Nil(t, err)
+	assert.Equal(t, &stratus.PrerequisitesVersion{Version: 2, Hash: "abc"}, version)
+}
+
+func TestStateManagerReadsMissingPrerequisitesVersion(t *testing.T) {
+	fsMock := new(mocks.FileSystemMock)
+	fsMock.On("FileExists", mock.Anything).Return(false)
+
+	statemanager := FileSystemStateManager{
+		RootDirectory: "/root/.stratus-red-team",
+		Technique:     &stratus.AttackTechnique{ID: "my-technique", Detonate: noop},
+		FileSystem:    fsMock,
+	}
+
+	version, err := statemanager.GetPrerequisitesVersion()
+	assert.Nil(t, err)
+	assert.Nil(t, version)
+}
+
+func TestStateManagerPersistsAPICallTracesOutsideOfTechniqueDirectory(t *testing.T) {
+	fsMock := new(mocks.FileSystemMock)
+	tracesFile := "/root/.stratus-red-team/api-calls/my-technique.json"
+	traces := []apitrace.Trace{{ExecutionID: "e5d4a7ea", Operation: "detonate", Calls: []apitrace.Call{{Platform: "AWS", Operation: "StopLogging"}}}}
+	rawTraces, _ := json.MarshalIndent(traces, "", "  ")
+	fsMock.On("FileExists", "/root/.stratus-red-team/api-calls").Return(false)
+	fsMock.On("FileExists", tracesFile).Return(true)
+	fsMock.On("CreateDirectory", mock.Anything, mock.Anything).Return(nil)
+	fsMock.On("WriteFile", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	fsMock.On("ReadFile", tracesFile).Return(rawTraces, nil)
+
+	statemanager := FileSystemStateManager{
+		RootDirectory: "/root/.stratus-red-team",
+		Technique:     &stratus.AttackTechnique{ID: "my-technique", Detonate: noop},
+		FileSystem:    fsMock,
+	}
+
+	err := statemanager.WriteAPICallTraces(traces)
+	assert.Nil(t, err)
+	fsMock.AssertCalled(t, "CreateDirectory", "/root/.stratus-red-team/api-calls", mock.Anything)
+	fsMock.AssertCalled(t, "WriteFile", tracesFile, rawTraces, mock.Anything)
+
+	persistedTraces, err := statemanager.GetAPICallTraces()
+	assert.Nil(t, err)
+	assert.Equal(t, traces, persistedTraces)
+}
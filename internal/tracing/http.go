@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport traces the HTTP requests made to the API of a platform, as children of the span of their context
+type Transport struct {
+	Platform   string
+	Attributes []attribute.KeyValue
+	Next       http.RoundTripper
+}
+
+// NewTransport returns an HTTP transport tracing the requests made to the API of a platform
+func NewTransport(platform string, next http.RoundTripper, attributes ...attribute.KeyValue) *Transport {
+	return &Transport{Platform: platform, Attributes: attributes, Next: next}
+}
+
+func (m *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, span := StartHTTP(request, m.Platform, m.Attributes...)
+	response, err := m.Next.RoundTrip(request.WithContext(ctx))
+	EndHTTP(span, response, err)
+	return response, err
+}
+
+// StartHTTP starts the span of an HTTP request made to the API of a platform, e.g. "GCP POST iam.googleapis.com"
+func StartHTTP(request *http.Request, platform string, attributes ...attribute.KeyValue) (ctx context.Context, span trace.Span) {
+	attributes = append([]attribute.KeyValue{
+		AttributePlatform.String(platform),
+		semconv.HTTPMethodKey.String(request.Method),
+		semconv.HTTPURLKey.String(request.URL.Redacted()),
+	}, attributes...)
+	return Start(request.Context(), platform+" "+request.Method+" "+request.URL.Host, attributes...)
+}
+
+// EndHTTP ends the span of an HTTP request, recording its status code
+func EndHTTP(span trace.Span, response *http.Response, err error) {
+	if err == nil && response != nil {
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(response.StatusCode))
+		if response.StatusCode >= 400 {
+			err = errors.New(response.Status)
+		}
+	}
+	End(span, err)
+}
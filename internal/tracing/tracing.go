@@ -0,0 +1,156 @@
+// Package tracing traces the operations run by Stratus Red Team with OpenTelemetry: phases of the runner, Terraform
+// commands and cloud API calls. Spans are exported via OTLP/HTTP or written to a local file, and are not recorded at
+// all unless tracing is set up
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const TracerName = "github.com/datadog/stratus-red-team"
+
+// Attributes set on spans
+const (
+	AttributeTechniqueID = attribute.Key("stratus.technique.id")
+	AttributeExecutionID = attribute.Key("stratus.execution.id")
+	AttributePlatform    = attribute.Key("stratus.platform")
+)
+
+type Options struct {
+	// OTLP/HTTP endpoint to export spans to, e.g. "localhost:4318" or "https://otel-collector.internal:4318". Uses
+	// HTTPS unless the endpoint starts with http://
+	Endpoint string
+
+	// File to write spans to, as JSON
+	File string
+}
+
+var (
+	provider     *sdktrace.TracerProvider
+	providerLock sync.Mutex
+)
+
+// Setup sets up the export of spans. Spans are exported synchronously when they end, so that they are not lost when
+// the process exits on an error
+func Setup(options Options) error {
+	if options.Endpoint == "" && options.File == "" {
+		return nil
+	}
+
+	var processors []sdktrace.SpanProcessor
+	if options.Endpoint != "" {
+		exporter, err := otlptracehttp.New(context.Background(), otlpOptions(options.Endpoint)...)
+		if err != nil {
+			return errors.New("unable to create OTLP trace exporter: " + err.Error())
+		}
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(exporter))
+	}
+	if options.File != "" {
+		file, err := os.OpenFile(options.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.New("unable to open trace file: " + err.Error())
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			return errors.New("unable to create file trace exporter: " + err.Error())
+		}
+		processors = append(processors, sdktrace.NewSimpleSpanProcessor(exporter))
+	}
+
+	providerOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String("stratus-red-team"))),
+	}
+	for _, processor := range processors {
+		providerOptions = append(providerOptions, sdktrace.WithSpanProcessor(processor))
+	}
+
+	providerLock.Lock()
+	defer providerLock.Unlock()
+	provider = sdktrace.NewTracerProvider(providerOptions...)
+	otel.SetTracerProvider(provider)
+	return nil
+}
+
+// otlpOptions returns the options of the OTLP exporter for an endpoint, with or without scheme and path
+func otlpOptions(endpoint string) []otlptracehttp.Option {
+	if !strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	}
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(parsed.Host)}
+	if parsed.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if parsed.Path != "" && parsed.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(parsed.Path))
+	}
+	return options
+}
+
+// Shutdown flushes pending spans and stops exporting them
+func Shutdown(ctx context.Context) error {
+	providerLock.Lock()
+	defer providerLock.Unlock()
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	provider = nil
+	return err
+}
+
+type attributesKey struct{}
+
+// WithAttributes returns a context whose attributes are set on all the spans started from it, including the spans of
+// nested operations
+func WithAttributes(ctx context.Context, attributes ...attribute.KeyValue) context.Context {
+	return context.WithValue(ctx, attributesKey{}, withAttributes(ctx, attributes))
+}
+
+// Attributes returns the attributes set on a context with WithAttributes
+func Attributes(ctx context.Context) []attribute.KeyValue {
+	attributes, _ := ctx.Value(attributesKey{}).([]attribute.KeyValue)
+	return attributes
+}
+
+// Start starts a span, with the attributes of the context
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(withAttributes(ctx, attributes)...))
+}
+
+// withAttributes returns the attributes of a context, followed by additional attributes
+func withAttributes(ctx context.Context, attributes []attribute.KeyValue) []attribute.KeyValue {
+	inherited := Attributes(ctx)
+	result := make([]attribute.KeyValue, 0, len(inherited)+len(attributes))
+	return append(append(result, inherited...), attributes...)
+}
+
+// End ends a span, recording the error of the operation if it failed
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans records the spans ended during a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestSpansInheritAttributesOfTheirContext(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx := WithAttributes(context.Background(), AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"))
+	ctx = WithAttributes(ctx, AttributeExecutionID.String("e5d4a7ea"))
+	ctx, parent := Start(ctx, "stratus.detonate")
+	_, child := Start(ctx, "terraform apply", attribute.String("foo", "bar"))
+	End(child, errors.New("apply failed"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	assert.Equal(t, "terraform apply", spans[0].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, []attribute.KeyValue{
+		AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"),
+		AttributeExecutionID.String("e5d4a7ea"),
+		attribute.String("foo", "bar"),
+	}, spans[0].Attributes())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "apply failed", spans[0].Status().Description)
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestTransportTracesRequests(t *testing.T) {
+	recorder := recordSpans(t)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/missing" {
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport("GCP", http.DefaultTransport, AttributeExecutionID.String("e5d4a7ea"))}
+	ctx, parent := Start(context.Background(), "stratus.detonate")
+	for _, path := range []string{"/", "/missing"} {
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		response, err := client.Do(request)
+		assert.Nil(t, err)
+		response.Body.Close()
+	}
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	assert.Equal(t, "GCP GET "+server.Listener.Addr().String(), spans[0].Name())
+	assert.Equal(t, spans[2].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Contains(t, spans[0].Attributes(), AttributeExecutionID.String("e5d4a7ea"))
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.status_code", 200))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestWritesSpansToFile(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	assert.Nil(t, Setup(Options{File: path}))
+	_, span := Start(context.Background(), "stratus.warmup", AttributeTechniqueID.String("aws.defense-evasion.cloudtrail-stop"))
+	End(span, nil)
+	assert.Nil(t, Shutdown(context.Background()))
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `"Name":"stratus.warmup"`)
+	assert.Contains(t, string(content), `"aws.defense-evasion.cloudtrail-stop"`)
+}
+
+func TestSetupWithoutExporterIsNoop(t *testing.T) {
+	assert.Nil(t, Setup(Options{}))
+	assert.Nil(t, Shutdown(context.Background()))
+}
+
+func TestOtlpOptions(t *testing.T) {
+	assert.Len(t, otlpOptions("localhost:4318"), 1)
+	assert.Len(t, otlpOptions("https://otel-collector:4318"), 1)
+	assert.Len(t, otlpOptions("http://otel-collector:4318"), 2)
+	assert.Len(t, otlpOptions("http://otel-collector:4318/custom/v1/traces"), 3)
+}
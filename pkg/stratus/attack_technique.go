@@ -1,6 +1,11 @@
 package stratus
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
 	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
 )
 
@@ -8,6 +13,10 @@ type AttackTechnique struct {
 	// Short identifier, e.g. aws.persistence.create-iam-user
 	ID string
 
+	// Version of the technique, to increment when its prerequisites change in a way that requires re-applying them
+	// on techniques already warm. Defaults to 1
+	Version int
+
 	// Friendly-looking short name
 	FriendlyName string
 
@@ -17,6 +26,10 @@ type AttackTechnique struct {
 	// Pointer and leads for detection opportunities (multi-line)
 	Detection string
 
+	// Sigma rules detecting the technique, as a YAML stream with one document per rule
+	// see https://github.com/SigmaHQ/sigma-specification
+	SigmaRules []byte
+
 	// Indicates if the technique is expected to be slow to warm-up or detonate
 	IsSlow bool
 
@@ -30,15 +43,74 @@ type AttackTechnique struct {
 	// Terraform code to apply to create the necessary prerequisites for the technique to be detonated
 	PrerequisitesTerraformCode []byte
 
+	// Files and processes to create on the local host for the technique to be detonated, for techniques
+	// executed on the machine running Stratus Red Team. Used instead of PrerequisitesTerraformCode
+	PrerequisitesHost *HostPrerequisites
+
+	// Names of the outputs of the prerequisites (Terraform outputs, or names of host files and processes) read by the
+	// technique from the parameters of the execution context. Checked against the prerequisites by "stratus validate"
+	RequiredOutputs []string
+
 	// Detonation function
-	// Parameters are the Terraform outputs
-	Detonate func(params map[string]string) error
+	// The parameters of the execution context are the Terraform outputs
+	Detonate func(execution *ExecutionContext) error
 
 	// Indicates if the detonation function is idempotent, i.e. if it can be run multiple times without reverting it
 	IsIdempotent bool
 
 	// Reversion function, to revert the side effects of a detonation
-	Revert func(params map[string]string) error
+	Revert func(execution *ExecutionContext) error
+
+	// Optional simulation function, generating the log records a detonation would produce (e.g. CloudTrail events)
+	// without calling any API. Used by "stratus simulate" to test detection rules offline
+	Simulate func(simulation *SimulationContext) []LogRecord
+
+	// Optional probe, reporting whether the side effects of the detonation are currently present
+	// (e.g. the CloudTrail trail is not logging). Used to detect drift between the persisted state and reality.
+	// The parameters of the execution context are the Terraform outputs
+	IsDetonated func(execution *ExecutionContext) (bool, error)
+}
+
+// HasPrerequisites returns true if the technique has prerequisites to create during warm-up
+func (m AttackTechnique) HasPrerequisites() bool {
+	return m.PrerequisitesTerraformCode != nil || m.PrerequisitesHost != nil
+}
+
+// GetVersion returns the version of the technique, 1 if not set
+func (m AttackTechnique) GetVersion() int {
+	if m.Version <= 0 {
+		return 1
+	}
+	return m.Version
+}
+
+// GetPrerequisitesVersion returns the version of the technique and a hash of its prerequisites, persisted when warming
+// it up to detect prerequisites spun up by a previous version of the technique
+func (m AttackTechnique) GetPrerequisitesVersion() PrerequisitesVersion {
+	hash := sha256.New()
+	if m.PrerequisitesTerraformCode != nil {
+		hash.Write(m.PrerequisitesTerraformCode)
+	} else if m.PrerequisitesHost != nil {
+		rawPrerequisites, _ := json.Marshal(m.PrerequisitesHost)
+		hash.Write(rawPrerequisites)
+	}
+	return PrerequisitesVersion{Version: m.GetVersion(), Hash: hex.EncodeToString(hash.Sum(nil))}
+}
+
+// PrerequisitesVersion identifies the version of the prerequisites of an attack technique
+type PrerequisitesVersion struct {
+	Version int `json:"version"`
+
+	// SHA-256 hash of the Terraform code (or of the host prerequisites)
+	Hash string `json:"hash"`
+}
+
+func (m PrerequisitesVersion) String() string {
+	hash := m.Hash
+	if len(hash) > 8 {
+		hash = hash[:8]
+	}
+	return "v" + strconv.Itoa(m.Version) + " (" + hash + ")"
 }
 
 func (m AttackTechnique) String() string {
@@ -0,0 +1,30 @@
+package stratus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttackTechniqueVersionDefaultsToOne(t *testing.T) {
+	assert.Equal(t, 1, AttackTechnique{}.GetVersion())
+	assert.Equal(t, 3, AttackTechnique{Version: 3}.GetVersion())
+}
+
+func TestAttackTechniquePrerequisitesVersion(t *testing.T) {
+	technique := AttackTechnique{PrerequisitesTerraformCode: []byte("resource \"aws_s3_bucket\" \"bucket\" {}")}
+	version := technique.GetPrerequisitesVersion()
+	assert.Equal(t, 1, version.Version)
+	assert.Len(t, version.Hash, 64)
+	assert.Equal(t, version, technique.GetPrerequisitesVersion())
+
+	// Any change to the Terraform code changes the hash, even without bumping the version
+	changed := AttackTechnique{PrerequisitesTerraformCode: []byte("resource \"aws_s3_bucket\" \"other\" {}")}
+	assert.NotEqual(t, version.Hash, changed.GetPrerequisitesVersion().Hash)
+
+	// Host prerequisites are hashed as well
+	host := AttackTechnique{PrerequisitesHost: &HostPrerequisites{Files: []HostFile{{Name: "file", Path: "/tmp/foo"}}}}
+	assert.NotEqual(t, AttackTechnique{}.GetPrerequisitesVersion().Hash, host.GetPrerequisitesVersion().Hash)
+
+	assert.Equal(t, "v2 (abcdef01)", PrerequisitesVersion{Version: 2, Hash: "abcdef0123456789"}.String())
+}
@@ -0,0 +1,182 @@
+package stratus
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/google/uuid"
+)
+
+// ExecutionContext is passed to the detonation, revert and probe functions of attack techniques. It carries
+// everything they need to run, so that techniques do not rely on global state and can be tested in isolation
+type ExecutionContext struct {
+	// Unique identifier of the execution, injected in the user-agent of API calls
+	ExecutionID uuid.UUID
+
+	// Context of the operation, carrying its trace. Pass it to API calls so that they are traced as part of the
+	// operation
+	Context context.Context
+
+	// Parameters of the technique, i.e. the outputs of its Terraform or host prerequisites. Outputs that are not
+	// strings, such as lists or numbers, are JSON-encoded
+	Parameters map[string]string
+
+	// Typed outputs of the Terraform or host prerequisites, read with the Output accessors
+	Outputs Outputs
+
+	// Logger to use to report progress
+	Logger *log.Logger
+
+	// Providers of each platform, holding the SDK configurations and clients
+	AWS     *providers.AWSProvider
+	Azure   *providers.AzureProvider
+	EntraID *providers.EntraIDProvider
+	GCP     *providers.GCPProvider
+	K8s     *providers.K8sProvider
+	Linux   *providers.LinuxProvider
+}
+
+// NewExecutionContext returns an execution context using the default providers, configured from the environment
+// and the command-line flags
+func NewExecutionContext(parameters map[string]string) *ExecutionContext {
+	return &ExecutionContext{
+		ExecutionID: providers.UniqueExecutionId,
+		Context:     context.Background(),
+		Parameters:  parameters,
+		Outputs:     StringOutputs(parameters),
+		Logger:      log.Default(),
+		AWS:         providers.AWS(),
+		Azure:       providers.Azure(),
+		EntraID:     providers.EntraID(),
+		GCP:         providers.GCP(),
+		K8s:         providers.K8s(),
+		Linux:       providers.Linux(),
+	}
+}
+
+// WithParameters returns a copy of the execution context, with different parameters
+func (m *ExecutionContext) WithParameters(parameters map[string]string) *ExecutionContext {
+	execution := *m
+	execution.Parameters = parameters
+	execution.Outputs = StringOutputs(parameters)
+	return &execution
+}
+
+// WithContext returns a copy of the execution context, with a different Go context
+func (m *ExecutionContext) WithContext(ctx context.Context) *ExecutionContext {
+	execution := *m
+	execution.Context = ctx
+	return &execution
+}
+
+// WithOutputs returns a copy of the execution context, with different outputs of the prerequisites
+func (m *ExecutionContext) WithOutputs(outputs Outputs) *ExecutionContext {
+	execution := *m
+	execution.Parameters = outputs.Strings()
+	execution.Outputs = outputs
+	return &execution
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform when spinning up the prerequisites of
+// an attack technique, so that Terraform targets the same environment as the providers of the execution context.
+// Platforms without a provider in the execution context use their registered platform provider
+func (m *ExecutionContext) TerraformEnvironment(platform Platform, terraformDirectory string) (map[string]string, error) {
+	switch {
+	case platform == AWS && m.AWS != nil:
+		return m.AWS.TerraformEnvironment()
+	case platform == Azure && m.Azure != nil:
+		return m.Azure.TerraformEnvironment()
+	case platform == EntraID && m.EntraID != nil:
+		return m.EntraID.TerraformEnvironment()
+	case platform == GCP && m.GCP != nil:
+		return m.GCP.TerraformEnvironment()
+	case platform == Kubernetes && m.K8s != nil:
+		return m.K8s.TerraformEnvironment(terraformDirectory)
+	}
+	return TerraformEnvironment(platform, terraformDirectory)
+}
+
+// ProviderEnvironment returns the environment variables to pass to a process running attack techniques, such as a
+// plugin, so that SDKs configured from the environment target the same environment as the providers of the execution
+// context. It extends the Terraform environment with the variables read by the SDKs, and a kubeconfig may be written
+// to the directory
+func (m *ExecutionContext) ProviderEnvironment(platform Platform, directory string) (map[string]string, error) {
+	env, err := m.TerraformEnvironment(platform, directory)
+	if err != nil {
+		return nil, err
+	}
+	// Variables read by the SDKs, and the Terraform variables they correspond to
+	aliases := map[string]string{
+		"KUBECONFIG":            "TF_VAR_kubeconfig_path",
+		"AZURE_SUBSCRIPTION_ID": "ARM_SUBSCRIPTION_ID",
+		"AZURE_TENANT_ID":       "ARM_TENANT_ID",
+		"AZURE_CLIENT_ID":       "ARM_CLIENT_ID",
+		"AZURE_CLIENT_SECRET":   "ARM_CLIENT_SECRET",
+	}
+	for key, terraformKey := range aliases {
+		if value, found := env[terraformKey]; found {
+			env[key] = value
+		}
+	}
+	return env, nil
+}
+
+// Output decodes the value of an output of the prerequisites into a Go value, e.g. a *[]string for a list of strings
+func (m *ExecutionContext) Output(name string, value interface{}) error {
+	output, found := m.Outputs[name]
+	if !found {
+		parameter, found := m.Parameters[name]
+		if !found {
+			return errors.New("output " + name + " does not exist")
+		}
+		output = StringOutput(parameter)
+	}
+	if err := output.Decode(value); err != nil {
+		return errors.New("unable to decode output " + name + ": " + err.Error())
+	}
+	return nil
+}
+
+// SetOutput records an output of the detonation, e.g. the namespace in which objects were created. Outputs of the
+// detonation are persisted along with the outputs of the prerequisites, so that the reversion and probe functions read
+// them even when run by another process, with other options
+func (m *ExecutionContext) SetOutput(name string, value string) {
+	if m.Outputs == nil {
+		m.Outputs = Outputs{}
+	}
+	m.Outputs[name] = StringOutput(value)
+	if m.Parameters == nil {
+		m.Parameters = map[string]string{}
+	}
+	m.Parameters[name] = value
+}
+
+// StringListOutput returns the value of an output holding a list of strings
+func (m *ExecutionContext) StringListOutput(name string) ([]string, error) {
+	var value []string
+	err := m.Output(name, &value)
+	return value, err
+}
+
+// StringMapOutput returns the value of an output holding a map of strings
+func (m *ExecutionContext) StringMapOutput(name string) (map[string]string, error) {
+	var value map[string]string
+	err := m.Output(name, &value)
+	return value, err
+}
+
+// NumberOutput returns the value of an output holding a number
+func (m *ExecutionContext) NumberOutput(name string) (float64, error) {
+	var value float64
+	err := m.Output(name, &value)
+	return value, err
+}
+
+// BoolOutput returns the value of an output holding a boolean
+func (m *ExecutionContext) BoolOutput(name string) (bool, error) {
+	var value bool
+	err := m.Output(name, &value)
+	return value, err
+}
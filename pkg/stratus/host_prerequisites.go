@@ -0,0 +1,41 @@
+package stratus
+
+import "os"
+
+// HostPrerequisites describes the prerequisites of an attack technique executed on the local host, created during
+// warm-up and removed during cleanup instead of being managed by Terraform
+type HostPrerequisites struct {
+	// Files to create. Existing files are overwritten, and restored to their original content during cleanup
+	Files []HostFile
+
+	// Long-running processes to start
+	Processes []HostProcess
+}
+
+// HostFile is a file created as a prerequisite of an attack technique
+type HostFile struct {
+	// Name of the output holding the absolute path of the file, passed to the detonation function
+	Name string
+
+	// Path of the file. A leading ~/ refers to the home directory of the current user
+	Path string
+
+	Content []byte
+
+	Mode os.FileMode
+
+	// Indicates if an existing file should be kept as-is rather than overwritten
+	KeepExisting bool
+}
+
+// HostProcess is a long-running process started as a prerequisite of an attack technique
+type HostProcess struct {
+	// Name of the output holding the PID of the process, passed to the detonation function
+	Name string
+
+	// Command to run, e.g. []string{"sleep", "86400"}
+	Command []string
+
+	// Additional environment variables of the process
+	Environment map[string]string
+}
@@ -0,0 +1,226 @@
+// Package logs builds the log records attack techniques generate when simulated with "stratus simulate": AWS
+// CloudTrail events, Kubernetes audit events and Azure Activity logs. Records are built from the simulation context,
+// so that they carry the account, principal, source IP and user-agent of the simulated execution
+package logs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/google/uuid"
+)
+
+// Object converts a value, e.g. a Kubernetes object or an API request, to the generic form it has in log records
+func Object(value interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return result
+	}
+	_ = json.Unmarshal(raw, &result)
+	return result
+}
+
+// CloudTrailEvent returns the CloudTrail management event of an API call made by the principal of the simulation
+// See https://docs.aws.amazon.com/awscloudtrail/latest/userguide/cloudtrail-event-reference-record-contents.html
+func CloudTrailEvent(simulation *stratus.SimulationContext, eventSource string, eventName string, requestParameters map[string]interface{}) stratus.LogRecord {
+	readOnly := false
+	for _, prefix := range []string{"Get", "Describe", "List"} {
+		readOnly = readOnly || strings.HasPrefix(eventName, prefix)
+	}
+	return stratus.LogRecord{
+		"eventVersion":       "1.08",
+		"userIdentity":       AWSIdentity(simulation.AccountID, simulation.Principal),
+		"eventTime":          simulation.NextTime().Format(time.RFC3339),
+		"eventSource":        eventSource,
+		"eventName":          eventName,
+		"awsRegion":          simulation.Region,
+		"sourceIPAddress":    simulation.SourceIP,
+		"userAgent":          simulation.UserAgent,
+		"requestParameters":  requestParameters,
+		"responseElements":   nil,
+		"requestID":          uuid.NewString(),
+		"eventID":            uuid.NewString(),
+		"readOnly":           readOnly,
+		"eventType":          "AwsApiCall",
+		"managementEvent":    true,
+		"recipientAccountId": simulation.AccountID,
+		"eventCategory":      "Management",
+	}
+}
+
+// CloudTrailError marks a CloudTrail event as failed, e.g. with Client.UnauthorizedOperation or AccessDenied
+func CloudTrailError(event stratus.LogRecord, errorCode string, errorMessage string) stratus.LogRecord {
+	event["errorCode"] = errorCode
+	event["errorMessage"] = errorMessage
+	return event
+}
+
+// AWSIdentity returns the userIdentity element of CloudTrail events for an IAM principal, from its ARN: IAM user,
+// assumed role (including EC2 instance roles) or root user. Identifiers are derived from the ARN, so that all the
+// events of a principal share the same access key ID
+func AWSIdentity(accountID string, arn string) map[string]interface{} {
+	identity := map[string]interface{}{
+		"arn":         arn,
+		"accountId":   accountID,
+		"accessKeyId": "AKIA" + awsIdentifier(arn, "access-key", 16),
+	}
+	resource := arn[strings.LastIndex(arn, ":")+1:]
+	switch {
+	case strings.HasPrefix(resource, "user/"):
+		identity["type"] = "IAMUser"
+		identity["principalId"] = "AIDA" + awsIdentifier(arn, "user", 17)
+		identity["userName"] = resource[strings.LastIndex(resource, "/")+1:]
+	case strings.HasPrefix(resource, "assumed-role/"):
+		parts := strings.Split(resource, "/")
+		roleName, sessionName := parts[1], parts[len(parts)-1]
+		roleArn := "arn:aws:iam::" + accountID + ":role/" + roleName
+		roleId := "AROA" + awsIdentifier(roleArn, "role", 17)
+		identity["type"] = "AssumedRole"
+		identity["principalId"] = roleId + ":" + sessionName
+		identity["accessKeyId"] = "ASIA" + awsIdentifier(arn, "access-key", 16)
+		identity["sessionContext"] = map[string]interface{}{
+			"sessionIssuer": map[string]interface{}{
+				"type":        "Role",
+				"principalId": roleId,
+				"arn":         roleArn,
+				"accountId":   accountID,
+				"userName":    roleName,
+			},
+			"attributes": map[string]interface{}{"mfaAuthenticated": "false"},
+		}
+	case resource == "root":
+		identity["type"] = "Root"
+		identity["principalId"] = accountID
+	}
+	return identity
+}
+
+// awsIdentifier returns a stable, uppercase identifier of an IAM principal, e.g. the suffix of its access key ID
+func awsIdentifier(arn string, kind string, length int) string {
+	hash := sha256.Sum256([]byte(kind + ":" + arn))
+	return strings.ToUpper(hex.EncodeToString(hash[:]))[:length]
+}
+
+// AWSAssumedRoleArn returns the ARN of a session of an IAM role, e.g. the role of an EC2 instance
+func AWSAssumedRoleArn(accountID string, roleName string, sessionName string) string {
+	return "arn:aws:sts::" + accountID + ":assumed-role/" + roleName + "/" + sessionName
+}
+
+// KubernetesObjectReference identifies the Kubernetes object an API request is about
+type KubernetesObjectReference struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+// requestURI returns the path of the API request about an object
+func (m KubernetesObjectReference) requestURI(verb string) string {
+	path := "/api/" + m.APIVersion
+	if m.APIGroup != "" {
+		path = "/apis/" + m.APIGroup + "/" + m.APIVersion
+	}
+	if m.Namespace != "" {
+		path += "/namespaces/" + m.Namespace
+	}
+	path += "/" + m.Resource
+	if m.Name != "" && verb != "create" || m.Subresource != "" {
+		path += "/" + m.Name
+	}
+	if m.Subresource != "" {
+		path += "/" + m.Subresource
+	}
+	return path
+}
+
+// KubernetesAuditEvent returns the audit event of a successful request to the Kubernetes API server, made by the
+// principal of the simulation. The request object is optional
+// See https://kubernetes.io/docs/reference/config-api/apiserver-audit.v1/#audit-k8s-io-v1-Event
+func KubernetesAuditEvent(simulation *stratus.SimulationContext, verb string, objectRef KubernetesObjectReference, requestObject interface{}) stratus.LogRecord {
+	if objectRef.APIVersion == "" {
+		objectRef.APIVersion = "v1"
+	}
+	timestamp := simulation.NextTime().Format(time.RFC3339Nano)
+	responseCode := 200
+	if verb == "create" {
+		responseCode = 201
+	}
+	event := stratus.LogRecord{
+		"kind":       "Event",
+		"apiVersion": "audit.k8s.io/v1",
+		"level":      "RequestResponse",
+		"auditID":    uuid.NewString(),
+		"stage":      "ResponseComplete",
+		"requestURI": objectRef.requestURI(verb),
+		"verb":       verb,
+		"user": map[string]interface{}{
+			"username": simulation.Principal,
+			"groups":   []interface{}{"system:masters", "system:authenticated"},
+		},
+		"sourceIPs":                []interface{}{simulation.SourceIP},
+		"userAgent":                simulation.UserAgent,
+		"objectRef":                Object(objectRef),
+		"responseStatus":           map[string]interface{}{"metadata": map[string]interface{}{}, "code": responseCode},
+		"requestReceivedTimestamp": timestamp,
+		"stageTimestamp":           timestamp,
+		"annotations": map[string]interface{}{
+			"authorization.k8s.io/decision": "allow",
+			"authorization.k8s.io/reason":   "",
+		},
+	}
+	if requestObject != nil {
+		event["requestObject"] = Object(requestObject)
+	}
+	return event
+}
+
+// AzureResourceID returns the ID of an Azure resource of the subscription of the simulation, as found in Activity logs
+func AzureResourceID(simulation *stratus.SimulationContext, resourceGroup string, resourcePath string) string {
+	return strings.ToUpper("/subscriptions/" + simulation.AccountID + "/resourceGroups/" + resourceGroup + "/providers/" + resourcePath)
+}
+
+// AzureActivityLog returns the Activity log of a successful operation on an Azure resource, performed by the principal
+// of the simulation
+// See https://learn.microsoft.com/en-us/azure/azure-monitor/essentials/activity-log-schema
+func AzureActivityLog(simulation *stratus.SimulationContext, operationName string, resourceID string) stratus.LogRecord {
+	return stratus.LogRecord{
+		"time":            simulation.NextTime().Format(time.RFC3339Nano),
+		"resourceId":      resourceID,
+		"operationName":   strings.ToUpper(operationName),
+		"category":        "Administrative",
+		"resultType":      "Success",
+		"resultSignature": "Succeeded.",
+		"durationMs":      0,
+		"callerIpAddress": simulation.SourceIP,
+		"correlationId":   uuid.NewString(),
+		"identity": map[string]interface{}{
+			"authorization": map[string]interface{}{
+				"scope":  resourceID,
+				"action": strings.TrimSuffix(strings.ToLower(operationName), "/"),
+			},
+			"claims": map[string]interface{}{
+				"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/upn": simulation.Principal,
+				"name": simulation.Principal,
+			},
+		},
+		"level":    "Information",
+		"location": "global",
+		"properties": map[string]interface{}{
+			"eventCategory":    "Administrative",
+			"entity":           resourceID,
+			"message":          strings.ToLower(operationName),
+			"hierarchy":        simulation.AccountID,
+			"subscriptionId":   simulation.AccountID,
+			"activityStatus":   "Succeeded",
+			"clientUserAgent":  simulation.UserAgent,
+			"serviceRequestId": uuid.NewString(),
+		},
+	}
+}
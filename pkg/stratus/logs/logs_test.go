@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSimulation(platform stratus.Platform) *stratus.SimulationContext {
+	simulation := stratus.NewSimulationContext(platform, "")
+	simulation.StartTime = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	return simulation
+}
+
+func TestCloudTrailEvent(t *testing.T) {
+	simulation := testSimulation(stratus.AWS)
+	event := CloudTrailEvent(simulation, "cloudtrail.amazonaws.com", "StopLogging", map[string]interface{}{"name": "my-trail"})
+	assert.Equal(t, "StopLogging", event["eventName"])
+	assert.Equal(t, "2022-03-01T10:00:00Z", event["eventTime"])
+	assert.Equal(t, simulation.UserAgent, event["userAgent"])
+	assert.Equal(t, stratus.DefaultSimulatedSourceIP, event["sourceIPAddress"])
+	assert.Equal(t, "123456789012", event["recipientAccountId"])
+	assert.Equal(t, false, event["readOnly"])
+	assert.NotContains(t, event, "errorCode")
+
+	event = CloudTrailError(CloudTrailEvent(simulation, "ec2.amazonaws.com", "DescribeInstances", nil), "Client.UnauthorizedOperation", "denied")
+	assert.Equal(t, "2022-03-01T10:00:01Z", event["eventTime"])
+	assert.Equal(t, true, event["readOnly"])
+	assert.Equal(t, "Client.UnauthorizedOperation", event["errorCode"])
+}
+
+func TestAWSIdentity(t *testing.T) {
+	user := AWSIdentity("123456789012", "arn:aws:iam::123456789012:user/path/alice")
+	assert.Equal(t, "IAMUser", user["type"])
+	assert.Equal(t, "alice", user["userName"])
+	assert.Regexp(t, "^AKIA[A-Z0-9]{16}$", user["accessKeyId"])
+	assert.Equal(t, user, AWSIdentity("123456789012", "arn:aws:iam::123456789012:user/path/alice"))
+	assert.NotEqual(t, user["accessKeyId"], AWSIdentity("123456789012", "arn:aws:iam::123456789012:user/bob")["accessKeyId"])
+
+	role := AWSIdentity("123456789012", AWSAssumedRoleArn("123456789012", "my-role", "i-0123456789abcdef0"))
+	assert.Equal(t, "AssumedRole", role["type"])
+	assert.Regexp(t, "^AROA[A-Z0-9]{17}:i-0123456789abcdef0$", role["principalId"])
+	assert.Regexp(t, "^ASIA[A-Z0-9]{16}$", role["accessKeyId"])
+	issuer := role["sessionContext"].(map[string]interface{})["sessionIssuer"].(map[string]interface{})
+	assert.Equal(t, "arn:aws:iam::123456789012:role/my-role", issuer["arn"])
+
+	root := AWSIdentity("123456789012", "arn:aws:iam::123456789012:root")
+	assert.Equal(t, "Root", root["type"])
+}
+
+func TestKubernetesAuditEvent(t *testing.T) {
+	simulation := testSimulation(stratus.Kubernetes)
+	pod := map[string]interface{}{"metadata": map[string]interface{}{"name": "my-pod"}}
+	event := KubernetesAuditEvent(simulation, "create", KubernetesObjectReference{Resource: "pods", Namespace: "default", Name: "my-pod"}, pod)
+	assert.Equal(t, "/api/v1/namespaces/default/pods", event["requestURI"])
+	assert.Equal(t, map[string]interface{}{"resource": "pods", "namespace": "default", "name": "my-pod", "apiVersion": "v1"}, event["objectRef"])
+	assert.Equal(t, pod, event["requestObject"])
+	assert.Equal(t, []interface{}{stratus.DefaultSimulatedSourceIP}, event["sourceIPs"])
+	assert.Equal(t, "kubernetes-admin", event["user"].(map[string]interface{})["username"])
+
+	event = KubernetesAuditEvent(simulation, "create", KubernetesObjectReference{
+		Resource: "clusterrolebindings", APIGroup: "rbac.authorization.k8s.io", APIVersion: "v1",
+	}, nil)
+	assert.Equal(t, "/apis/rbac.authorization.k8s.io/v1/clusterrolebindings", event["requestURI"])
+	assert.NotContains(t, event, "requestObject")
+
+	event = KubernetesAuditEvent(simulation, "get", KubernetesObjectReference{Resource: "nodes", Name: "node-1", Subresource: "proxy"}, nil)
+	assert.Equal(t, "/api/v1/nodes/node-1/proxy", event["requestURI"])
+}
+
+func TestAzureActivityLog(t *testing.T) {
+	simulation := testSimulation(stratus.Azure)
+	resourceId := AzureResourceID(simulation, "my-group", "Microsoft.Compute/disks/my-disk")
+	assert.Equal(t, "/SUBSCRIPTIONS/00000000-0000-4000-8000-000000000000/RESOURCEGROUPS/MY-GROUP/PROVIDERS/MICROSOFT.COMPUTE/DISKS/MY-DISK", resourceId)
+
+	event := AzureActivityLog(simulation, "Microsoft.Compute/disks/beginGetAccess/action", resourceId)
+	assert.Equal(t, "MICROSOFT.COMPUTE/DISKS/BEGINGETACCESS/ACTION", event["operationName"])
+	assert.Equal(t, stratus.DefaultSimulatedSourceIP, event["callerIpAddress"])
+	assert.Equal(t, "2022-03-01T10:00:00Z", event["time"])
+}
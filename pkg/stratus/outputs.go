@@ -0,0 +1,94 @@
+package stratus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// Output is an output of the prerequisites of an attack technique, e.g. a Terraform output
+type Output struct {
+	// Terraform type of the output, e.g. "string", ["list","string"] or ["map","number"]. Empty if unknown
+	Type json.RawMessage `json:"type,omitempty"`
+
+	// JSON-encoded value of the output
+	Value json.RawMessage `json:"value"`
+
+	// Indicates if the output is marked as sensitive in Terraform
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// Outputs are the outputs of the prerequisites of an attack technique, by name
+type Outputs map[string]Output
+
+// StringOutput returns an output holding a string
+func StringOutput(value string) Output {
+	rawValue, _ := json.Marshal(value)
+	return Output{Type: json.RawMessage(`"string"`), Value: rawValue}
+}
+
+// StringOutputs returns outputs holding strings, e.g. the outputs of host prerequisites
+func StringOutputs(values map[string]string) Outputs {
+	outputs := make(Outputs, len(values))
+	for name, value := range values {
+		outputs[name] = StringOutput(value)
+	}
+	return outputs
+}
+
+// String returns the value of a string output, and the JSON-encoded value of other outputs
+func (m Output) String() string {
+	var value string
+	if err := json.Unmarshal(m.Value, &value); err == nil {
+		return value
+	}
+	return string(m.Value)
+}
+
+// Decode decodes the value of the output into a Go value, e.g. a *[]string for a list of strings. Non-string values
+// encoded as JSON in a string output, such as parameters passed to plugins, are decoded as well
+func (m Output) Decode(value interface{}) error {
+	err := json.Unmarshal(m.Value, value)
+	if err == nil {
+		return nil
+	}
+	var encodedValue string
+	if json.Unmarshal(m.Value, &encodedValue) == nil && json.Unmarshal([]byte(encodedValue), value) == nil {
+		return nil
+	}
+	return err
+}
+
+// UnmarshalJSON decodes an output, either typed or persisted as a plain string by previous versions
+func (m *Output) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		var value string
+		if err := json.Unmarshal(trimmed, &value); err != nil {
+			return err
+		}
+		*m = StringOutput(value)
+		return nil
+	}
+
+	// Use a different type to avoid calling UnmarshalJSON recursively
+	type typedOutput Output
+	var output typedOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return err
+	}
+	if output.Value == nil {
+		return errors.New("output has no value")
+	}
+	*m = Output(output)
+	return nil
+}
+
+// Strings returns the string representation of outputs, as passed to attack techniques in the parameters of their
+// execution context
+func (m Outputs) Strings() map[string]string {
+	values := make(map[string]string, len(m))
+	for name, output := range m {
+		values[name] = output.String()
+	}
+	return values
+}
@@ -0,0 +1,74 @@
+package stratus
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputString(t *testing.T) {
+	assert.Equal(t, "foo", StringOutput("foo").String())
+	assert.Equal(t, `["a","b"]`, Output{Value: json.RawMessage(`["a","b"]`)}.String())
+	assert.Equal(t, "3", Output{Value: json.RawMessage(`3`)}.String())
+	assert.Equal(t, "true", Output{Value: json.RawMessage(`true`)}.String())
+}
+
+func TestOutputsAreReadFromLegacyFormat(t *testing.T) {
+	var outputs Outputs
+	err := json.Unmarshal([]byte(`{"legacy": "foo", "typed": {"type": ["list", "string"], "value": ["a", "b"]}}`), &outputs)
+	assert.Nil(t, err)
+	assert.Equal(t, StringOutput("foo"), outputs["legacy"])
+	assert.Equal(t, map[string]string{"legacy": "foo", "typed": `["a", "b"]`}, outputs.Strings())
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"invalid": {"type": "string"}}`), &outputs))
+}
+
+func TestExecutionContextTypedOutputs(t *testing.T) {
+	execution := NewExecutionContext(nil).WithOutputs(Outputs{
+		"name":    StringOutput("foo"),
+		"subnets": {Value: json.RawMessage(`["subnet-1","subnet-2"]`)},
+		"tags":    {Value: json.RawMessage(`{"owner":"stratus"}`)},
+		"port":    {Value: json.RawMessage(`22`)},
+		"enabled": {Value: json.RawMessage(`true`)},
+	})
+
+	// String-only techniques keep reading their parameters
+	assert.Equal(t, "foo", execution.Parameters["name"])
+	assert.Equal(t, `["subnet-1","subnet-2"]`, execution.Parameters["subnets"])
+
+	subnets, err := execution.StringListOutput("subnets")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"subnet-1", "subnet-2"}, subnets)
+	tags, err := execution.StringMapOutput("tags")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"owner": "stratus"}, tags)
+	port, err := execution.NumberOutput("port")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(22), port)
+	enabled, err := execution.BoolOutput("enabled")
+	assert.Nil(t, err)
+	assert.True(t, enabled)
+
+	_, err = execution.NumberOutput("name")
+	assert.NotNil(t, err)
+	_, err = execution.BoolOutput("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestExecutionContextDecodesJSONParameters(t *testing.T) {
+	// e.g. parameters passed to plugins, where outputs that are not strings are JSON-encoded
+	execution := NewExecutionContext(map[string]string{"subnets": `["subnet-1"]`, "port": "22"})
+
+	subnets, err := execution.StringListOutput("subnets")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"subnet-1"}, subnets)
+	port, err := execution.NumberOutput("port")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(22), port)
+
+	execution.Parameters = map[string]string{"enabled": "true"}
+	enabled, err := execution.BoolOutput("enabled")
+	assert.Nil(t, err)
+	assert.True(t, enabled)
+}
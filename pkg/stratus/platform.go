@@ -2,6 +2,7 @@ package stratus
 
 import (
 	"errors"
+	"sort"
 	"strings"
 )
 
@@ -11,17 +12,88 @@ const (
 	AWS        = "AWS"
 	Kubernetes = "kubernetes"
 	Azure      = "azure"
+	GCP        = "GCP"
+	EntraID    = "entra-id"
+	Linux      = "linux"
 )
 
+// PlatformProvider implements the platform-specific behavior of Stratus Red Team, e.g. checking authentication.
+// Built-in platforms are registered by default, and library users can register their own with RegisterPlatform
+type PlatformProvider interface {
+	// Name of the platform, as used in attack techniques and on the command line, e.g. "AWS"
+	Name() Platform
+
+	// DisplayName is the human-readable name of the platform, e.g. "Entra ID"
+	DisplayName() string
+
+	// Authenticate ensures that the current user is properly authenticated against the platform
+	Authenticate() error
+
+	// DescribeIdentity returns a human-readable description of who Stratus Red Team is authenticated as
+	DescribeIdentity() (string, error)
+
+	// TerraformEnvironment returns the environment variables configuring the Terraform provider of the platform,
+	// so that the prerequisites of attack techniques target the same environment as Stratus Red Team
+	TerraformEnvironment(terraformDirectory string) (map[string]string, error)
+}
+
+// TargetDescriber is optionally implemented by platform providers to describe the environment targeted by attack
+// techniques, e.g. an AWS account or a Kubernetes cluster
+type TargetDescriber interface {
+	// DescribeTarget returns a human-readable description of the targeted environment
+	DescribeTarget() (string, error)
+}
+
+var platformProviders = map[Platform]PlatformProvider{}
+
+// RegisterPlatform makes a platform available to attack techniques. Registering a platform with the name of an
+// existing one replaces it
+func RegisterPlatform(provider PlatformProvider) {
+	platformProviders[provider.Name()] = provider
+}
+
+// GetPlatformProvider returns the provider of a registered platform
+func GetPlatformProvider(platform Platform) (PlatformProvider, error) {
+	provider, found := platformProviders[platform]
+	if !found {
+		return nil, errors.New("unhandled platform " + string(platform))
+	}
+	return provider, nil
+}
+
+// GetPlatforms returns the names of all registered platforms, sorted alphabetically
+func GetPlatforms() []Platform {
+	var platforms []Platform
+	for platform := range platformProviders {
+		platforms = append(platforms, platform)
+	}
+	sort.Slice(platforms, func(i, j int) bool {
+		return strings.ToLower(string(platforms[i])) < strings.ToLower(string(platforms[j]))
+	})
+	return platforms
+}
+
+// DescribeTarget returns a description of the environment targeted on a platform, or an empty string if its provider
+// does not implement TargetDescriber
+func DescribeTarget(platform Platform) (string, error) {
+	provider, err := GetPlatformProvider(platform)
+	if err != nil {
+		return "", err
+	}
+	if describer, ok := provider.(TargetDescriber); ok {
+		return describer.DescribeTarget()
+	}
+	return "", nil
+}
+
+// PlatformFromString returns the registered platform with a given name, case-insensitive
 func PlatformFromString(name string) (Platform, error) {
-	switch strings.ToLower(name) {
-	case strings.ToLower(AWS):
-		return AWS, nil
-	case strings.ToLower(Kubernetes):
-		return Kubernetes, nil
-	case strings.ToLower(Azure):
-		return Azure, nil
-	default:
-		return "", errors.New("unknown platform: " + name)
+	var names []string
+	for _, platform := range GetPlatforms() {
+		if strings.EqualFold(string(platform), name) {
+			return platform, nil
+		}
+		names = append(names, string(platform))
 	}
+	return "", errors.New("unknown platform: " + name + " (supported platforms: " + strings.Join(names, ", ") + ")")
 }
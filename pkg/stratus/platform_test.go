@@ -0,0 +1,68 @@
+package stratus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePlatform struct {
+	authenticationError error
+}
+
+func (fakePlatform) Name() Platform                    { return "my-platform" }
+func (fakePlatform) DisplayName() string               { return "My Platform" }
+func (m fakePlatform) Authenticate() error             { return m.authenticationError }
+func (fakePlatform) DescribeIdentity() (string, error) { return "test-user", nil }
+func (fakePlatform) TerraformEnvironment(directory string) (map[string]string, error) {
+	return map[string]string{"MY_PLATFORM_DIRECTORY": directory}, nil
+}
+
+func registerFakePlatform(t *testing.T, platform fakePlatform) {
+	RegisterPlatform(platform)
+	t.Cleanup(func() { delete(platformProviders, platform.Name()) })
+}
+
+func TestBuiltInPlatformsAreRegistered(t *testing.T) {
+	for _, platform := range []Platform{AWS, Azure, EntraID, GCP, Kubernetes, Linux} {
+		provider, err := GetPlatformProvider(platform)
+		assert.Nil(t, err)
+		assert.Equal(t, platform, provider.Name())
+	}
+}
+
+func TestPlatformFromString(t *testing.T) {
+	registerFakePlatform(t, fakePlatform{})
+
+	platform, err := PlatformFromString("aws")
+	assert.Nil(t, err)
+	assert.Equal(t, Platform(AWS), platform)
+
+	platform, err = PlatformFromString("MY-PLATFORM")
+	assert.Nil(t, err)
+	assert.Equal(t, Platform("my-platform"), platform)
+
+	_, err = PlatformFromString("nope")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown platform: nope")
+	assert.Contains(t, err.Error(), "my-platform")
+}
+
+func TestCustomPlatform(t *testing.T) {
+	registerFakePlatform(t, fakePlatform{})
+
+	assert.Nil(t, EnsureAuthenticated("my-platform"))
+	env, err := TerraformEnvironment("my-platform", "/tmp/terraform")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"MY_PLATFORM_DIRECTORY": "/tmp/terraform"}, env)
+
+	registerFakePlatform(t, fakePlatform{authenticationError: errors.New("not authenticated")})
+	assert.EqualError(t, EnsureAuthenticated("my-platform"), "not authenticated")
+}
+
+func TestUnknownPlatform(t *testing.T) {
+	assert.EqualError(t, EnsureAuthenticated("nope"), "unhandled platform nope")
+	_, err := TerraformEnvironment("nope", "")
+	assert.NotNil(t, err)
+}
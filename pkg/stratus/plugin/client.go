@@ -0,0 +1,294 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+)
+
+// MetadataTimeout is the maximum time a plugin can take to describe its techniques
+const MetadataTimeout = 10 * time.Second
+
+// DefaultTimeout is the default maximum time a plugin can take to detonate or revert a technique
+const DefaultTimeout = 10 * time.Minute
+
+// Plugin is an executable implementing attack techniques
+type Plugin struct {
+	// Path of the executable
+	Path string
+
+	// Arguments passed to the executable
+	Args []string
+
+	// Maximum time the plugin can take to detonate or revert a technique
+	Timeout time.Duration
+}
+
+// Name returns the name of the plugin, used as a prefix of its logs
+func (m *Plugin) Name() string {
+	return strings.TrimSuffix(filepath.Base(m.Path), ".exe")
+}
+
+// Discover returns the plugins of a directory, i.e. its executable files
+func Discover(directory string, timeout time.Duration) ([]*Plugin, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, errors.New("unable to list plugins in " + directory + ": " + err.Error())
+	}
+	var plugins []*Plugin
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !isExecutable(info) {
+			continue
+		}
+		plugins = append(plugins, &Plugin{Path: filepath.Join(directory, entry.Name()), Timeout: timeout})
+	}
+	return plugins, nil
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// LoadDirectory registers the techniques of all the plugins of a directory. Plugins failing to describe their
+// techniques, or implementing techniques that already exist, are skipped with a warning
+func LoadDirectory(directory string, timeout time.Duration, registry *stratus.Registry) ([]*stratus.AttackTechnique, error) {
+	plugins, err := Discover(directory, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var techniques []*stratus.AttackTechnique
+	for _, plugin := range plugins {
+		pluginTechniques, err := plugin.Techniques()
+		if err == nil {
+			err = checkNotRegistered(plugin, pluginTechniques, registry)
+		}
+		if err != nil {
+			log.Println("Warning: skipping " + plugin.Path + ": " + err.Error())
+			continue
+		}
+		for _, technique := range pluginTechniques {
+			registry.RegisterAttackTechnique(technique)
+		}
+		techniques = append(techniques, pluginTechniques...)
+	}
+	return techniques, nil
+}
+
+// checkNotRegistered ensures that none of the techniques of a plugin exists in a registry, or twice in the plugin
+func checkNotRegistered(plugin *Plugin, techniques []*stratus.AttackTechnique, registry *stratus.Registry) error {
+	ids := map[string]bool{}
+	for _, technique := range techniques {
+		if registry.GetAttackTechniqueByName(technique.ID) != nil || ids[technique.ID] {
+			return errors.New("plugin " + plugin.Name() + ": technique " + technique.ID + " already exists")
+		}
+		ids[technique.ID] = true
+	}
+	return nil
+}
+
+// Techniques performs the metadata handshake with the plugin, and returns the techniques it implements
+func (m *Plugin) Techniques() ([]*stratus.AttackTechnique, error) {
+	response, err := m.call(Request{Command: CommandMetadata}, nil, MetadataTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var techniques []*stratus.AttackTechnique
+	for _, metadata := range response.Techniques {
+		technique, err := m.toAttackTechnique(metadata)
+		if err != nil {
+			return nil, errors.New("plugin " + m.Name() + ": " + err.Error())
+		}
+		techniques = append(techniques, technique)
+	}
+	return techniques, nil
+}
+
+func (m *Plugin) toAttackTechnique(metadata TechniqueMetadata) (*stratus.AttackTechnique, error) {
+	if metadata.ID == "" || metadata.Name == "" {
+		return nil, errors.New("techniques must have an ID and a name")
+	}
+	platform, err := stratus.PlatformFromString(metadata.Platform)
+	if err != nil {
+		return nil, errors.New(metadata.ID + ": " + err.Error())
+	}
+	if len(metadata.Tactics) == 0 {
+		return nil, errors.New(metadata.ID + ": at least one MITRE ATT&CK tactic is required")
+	}
+	var tactics []mitreattack.Tactic
+	for _, name := range metadata.Tactics {
+		tactic, err := mitreattack.AttackTacticFromString(name)
+		if err != nil {
+			return nil, errors.New(metadata.ID + ": " + err.Error())
+		}
+		tactics = append(tactics, tactic)
+	}
+
+	id := metadata.ID
+	technique := &stratus.AttackTechnique{
+		ID:                 id,
+		FriendlyName:       metadata.Name,
+		Description:        metadata.Description,
+		Detection:          metadata.Detection,
+		Platform:           platform,
+		MitreAttackTactics: tactics,
+		IsIdempotent:       metadata.Idempotent,
+		IsSlow:             metadata.Slow,
+		Version:            metadata.Version,
+		RequiredOutputs:    metadata.RequiredOutputs,
+		Detonate: func(execution *stratus.ExecutionContext) error {
+			_, err := m.callTechnique(execution, platform, Request{Command: CommandDetonate, TechniqueID: id})
+			return err
+		},
+	}
+	if metadata.Terraform != "" {
+		technique.PrerequisitesTerraformCode = []byte(metadata.Terraform)
+	}
+	if metadata.Revertible {
+		technique.Revert = func(execution *stratus.ExecutionContext) error {
+			_, err := m.callTechnique(execution, platform, Request{Command: CommandRevert, TechniqueID: id})
+			return err
+		}
+	}
+	if metadata.Probe {
+		technique.IsDetonated = func(execution *stratus.ExecutionContext) (bool, error) {
+			response, err := m.callTechnique(execution, platform, Request{Command: CommandIsDetonated, TechniqueID: id})
+			if err != nil {
+				return false, err
+			}
+			return response.Detonated, nil
+		}
+	}
+	return technique, nil
+}
+
+// callTechnique runs the plugin with a request about a technique, passing the parameters and execution ID of an
+// execution context, and an environment targeting the same environment as its providers
+func (m *Plugin) callTechnique(execution *stratus.ExecutionContext, platform stratus.Platform, request Request) (*Response, error) {
+	// Directory in which a kubeconfig may be written for the plugin
+	directory, err := os.MkdirTemp("", "stratus-plugin-")
+	if err != nil {
+		return nil, errors.New("unable to create a working directory for plugin " + m.Name() + ": " + err.Error())
+	}
+	defer os.RemoveAll(directory)
+	env, err := execution.ProviderEnvironment(platform, directory)
+	if err != nil {
+		return nil, errors.New("unable to configure the environment of plugin " + m.Name() + ": " + err.Error())
+	}
+
+	request.ExecutionID = execution.ExecutionID.String()
+	request.Parameters = execution.Parameters
+	return m.call(request, env, m.Timeout)
+}
+
+// call runs the plugin with a request, and returns its response. The environment variables are added to (or, when
+// empty, removed from) the environment of the plugin. The standard error of the plugin is logged
+func (m *Plugin) call(request Request, env map[string]string, timeout time.Duration) (*Response, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	request.ProtocolVersion = ProtocolVersion
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	stderr := &logWriter{prefix: "[" + m.Name() + "] "}
+	cmd := exec.CommandContext(ctx, m.Path, m.Args...)
+	cmd.Env = mergeEnvironment(os.Environ(), env)
+	cmd.Stdin = bytes.NewReader(rawRequest)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+	stderr.Flush()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s timed out after %s", m.Name(), timeout)
+	}
+	var response Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		if runErr != nil {
+			return nil, errors.New("plugin " + m.Name() + " failed: " + runErr.Error())
+		}
+		return nil, errors.New("plugin " + m.Name() + " returned an invalid response: " + err.Error())
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	if runErr != nil {
+		return nil, errors.New("plugin " + m.Name() + " failed: " + runErr.Error())
+	}
+	if response.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("plugin %s uses protocol version %d, expected %d", m.Name(), response.ProtocolVersion, ProtocolVersion)
+	}
+	return &response, nil
+}
+
+// mergeEnvironment overrides a list of KEY=value environment variables. Overrides with an empty value are removed
+func mergeEnvironment(environ []string, overrides map[string]string) []string {
+	var env []string
+	for _, variable := range environ {
+		key, _, _ := strings.Cut(variable, "=")
+		if _, overridden := overrides[key]; !overridden {
+			env = append(env, variable)
+		}
+	}
+	for key, value := range overrides {
+		if value != "" {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// logWriter logs each line written to it
+type logWriter struct {
+	prefix string
+	buffer bytes.Buffer
+}
+
+func (m *logWriter) Write(data []byte) (int, error) {
+	m.buffer.Write(data)
+	for {
+		line, err := m.buffer.ReadString('\n')
+		if err != nil {
+			// Incomplete line, wait for the rest of it
+			m.buffer.Reset()
+			m.buffer.WriteString(line)
+			return len(data), nil
+		}
+		log.Println(m.prefix + strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush logs the last line, if it does not end with a newline
+func (m *logWriter) Flush() {
+	if m.buffer.Len() > 0 {
+		log.Println(m.prefix + m.buffer.String())
+		m.buffer.Reset()
+	}
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+)
+
+// The test binary acts as a plugin when this environment variable is set
+const helperPluginEnvVar = "STRATUS_TEST_HELPER_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperPluginEnvVar) != "" {
+		Serve(helperTechniques()...)
+	}
+	os.Exit(m.Run())
+}
+
+func helperTechniques() []*stratus.AttackTechnique {
+	return []*stratus.AttackTechnique{
+		{
+			ID:                         "plugin.test.detonate",
+			FriendlyName:               "Detonate",
+			Platform:                   stratus.AWS,
+			MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Execution},
+			PrerequisitesTerraformCode: []byte(`output "bucket_name" { value = "my-bucket" }`),
+			Detonate: func(execution *stratus.ExecutionContext) error {
+				execution.Logger.Println("Detonating against " + execution.Parameters["bucket_name"])
+				return nil
+			},
+			Revert: func(execution *stratus.ExecutionContext) error {
+				return errors.New("unable to revert: bucket " + execution.Parameters["bucket_name"] + " not found")
+			},
+			IsDetonated: func(*stratus.ExecutionContext) (bool, error) {
+				return true, nil
+			},
+		},
+		{
+			ID:                 "plugin.test.slow",
+			FriendlyName:       "Slow",
+			Platform:           stratus.AWS,
+			MitreAttackTactics: []mitreattack.Tactic{mitreattack.Exfiltration},
+			Detonate: func(*stratus.ExecutionContext) error {
+				time.Sleep(time.Minute)
+				return nil
+			},
+		},
+	}
+}
+
+func helperPlugin(t *testing.T) *Plugin {
+	t.Setenv(helperPluginEnvVar, "1")
+	return &Plugin{Path: os.Args[0], Args: []string{"-test.run=^$"}, Timeout: 10 * time.Second}
+}
+
+func TestPluginTechniques(t *testing.T) {
+	techniques, err := helperPlugin(t).Techniques()
+	assert.Nil(t, err)
+	assert.Len(t, techniques, 2)
+
+	technique := techniques[0]
+	assert.Equal(t, "plugin.test.detonate", technique.ID)
+	assert.Equal(t, stratus.Platform(stratus.AWS), technique.Platform)
+	assert.Equal(t, []mitreattack.Tactic{mitreattack.Execution}, technique.MitreAttackTactics)
+	assert.Contains(t, string(technique.PrerequisitesTerraformCode), "bucket_name")
+	assert.NotNil(t, technique.Revert)
+	assert.NotNil(t, technique.IsDetonated)
+
+	assert.Nil(t, techniques[1].Revert)
+	assert.Nil(t, techniques[1].IsDetonated)
+}
+
+func TestPluginDetonateAndRevert(t *testing.T) {
+	techniques, err := helperPlugin(t).Techniques()
+	assert.Nil(t, err)
+	technique := techniques[0]
+
+	assert.Nil(t, technique.Detonate(stratus.NewExecutionContext(map[string]string{"bucket_name": "my-bucket"})))
+
+	detonated, err := technique.IsDetonated(stratus.NewExecutionContext(map[string]string{}))
+	assert.Nil(t, err)
+	assert.True(t, detonated)
+
+	err = technique.Revert(stratus.NewExecutionContext(map[string]string{"bucket_name": "my-bucket"}))
+	assert.EqualError(t, err, "unable to revert: bucket my-bucket not found")
+}
+
+func TestPluginEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_PROFILE", "default")
+	directory := t.TempDir()
+	path := filepath.Join(directory, "env-plugin")
+	writeScript(t, path, `cat > "`+path+`.request"; env > "`+path+`.env"; echo '{"protocol_version": 1}'`)
+	plugin := &Plugin{Path: path}
+	technique, err := plugin.toAttackTechnique(TechniqueMetadata{ID: "plugin.test.env", Name: "Env", Platform: "AWS", Tactics: []string{"execution"}})
+	assert.Nil(t, err)
+
+	execution := stratus.NewExecutionContext(map[string]string{"bucket_name": "my-bucket"})
+	execution.AWS = providers.NewAWSProvider(providers.AWSOptions{Profile: "my-profile", Region: "eu-west-3"})
+	assert.Nil(t, technique.Detonate(execution))
+
+	// The plugin targets the same environment as the providers of the execution context
+	rawEnv, err := os.ReadFile(path + ".env")
+	assert.Nil(t, err)
+	env := strings.Split(string(rawEnv), "\n")
+	assert.Contains(t, env, "AWS_PROFILE=my-profile")
+	assert.Contains(t, env, "AWS_REGION=eu-west-3")
+	assert.Contains(t, env, "AWS_DEFAULT_REGION=eu-west-3")
+	assert.NotContains(t, string(rawEnv), "AWS_ACCESS_KEY_ID")
+
+	var request Request
+	rawRequest, err := os.ReadFile(path + ".request")
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(rawRequest, &request))
+	assert.Equal(t, execution.ExecutionID.String(), request.ExecutionID)
+	assert.Equal(t, map[string]string{"bucket_name": "my-bucket"}, request.Parameters)
+}
+
+func TestPluginTimeout(t *testing.T) {
+	plugin := helperPlugin(t)
+	techniques, err := plugin.Techniques()
+	assert.Nil(t, err)
+
+	plugin.Timeout = 500 * time.Millisecond
+	err = techniques[1].Detonate(stratus.NewExecutionContext(map[string]string{}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timed out after 500ms")
+}
+
+func TestPluginInvalidResponse(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+	directory := t.TempDir()
+	writeScript(t, filepath.Join(directory, "broken"), "echo not json")
+	writeScript(t, filepath.Join(directory, "old"), `echo '{"protocol_version": 0}'`)
+
+	_, err := (&Plugin{Path: filepath.Join(directory, "broken")}).Techniques()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "plugin broken returned an invalid response")
+
+	_, err = (&Plugin{Path: filepath.Join(directory, "old")}).Techniques()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "plugin old uses protocol version 0, expected 1")
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+	directory := t.TempDir()
+	writeScript(t, filepath.Join(directory, "my-plugin"), "true")
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "README.md"), []byte("not a plugin"), 0644))
+	assert.Nil(t, os.Mkdir(filepath.Join(directory, "subdirectory"), 0755))
+
+	plugins, err := Discover(directory, time.Minute)
+	assert.Nil(t, err)
+	assert.Len(t, plugins, 1)
+	assert.Equal(t, "my-plugin", plugins[0].Name())
+	assert.Equal(t, time.Minute, plugins[0].Timeout)
+
+	_, err = Discover(filepath.Join(directory, "nonexistent"), time.Minute)
+	assert.NotNil(t, err)
+}
+
+func TestLoadDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+	t.Setenv(helperPluginEnvVar, "1")
+	directory := t.TempDir()
+	writeScript(t, filepath.Join(directory, "helper"), `exec "`+os.Args[0]+`" -test.run='^$'`)
+
+	registry := stratus.NewRegistry()
+	techniques, err := LoadDirectory(directory, time.Minute, &registry)
+	assert.Nil(t, err)
+	assert.Len(t, techniques, 2)
+	assert.NotNil(t, registry.GetAttackTechniqueByName("plugin.test.detonate"))
+
+	// Plugins implementing existing techniques, or failing to describe them, are skipped
+	writeScript(t, filepath.Join(directory, "broken"), "echo not json")
+	otherRegistry := stratus.NewRegistry()
+	techniques, err = LoadDirectory(directory, time.Minute, &otherRegistry)
+	assert.Nil(t, err)
+	assert.Len(t, techniques, 2)
+	assert.Len(t, otherRegistry.ListAttackTechniques(), 2)
+
+	techniques, err = LoadDirectory(directory, time.Minute, &registry)
+	assert.Nil(t, err)
+	assert.Empty(t, techniques)
+	assert.Len(t, registry.ListAttackTechniques(), 2)
+
+	_, err = LoadDirectory(filepath.Join(directory, "nonexistent"), time.Minute, &registry)
+	assert.NotNil(t, err)
+}
+
+func writeScript(t *testing.T, path string, content string) {
+	assert.Nil(t, os.WriteFile(path, []byte("#!/bin/sh\n"+content+"\n"), 0755))
+}
@@ -0,0 +1,84 @@
+// Package plugin implements external attack technique plugins: executables, possibly written in other languages,
+// that Stratus Red Team discovers in a plugins directory and invokes with a JSON protocol over stdin and stdout.
+//
+// For each call, Stratus Red Team starts the plugin, writes a Request to its standard input and reads a Response from
+// its standard output. Anything the plugin writes to its standard error is displayed as logs.
+//
+// Plugins run with the environment of Stratus Red Team, in which the variables configuring the SDK of the platform of
+// the technique (e.g. AWS_PROFILE, AWS_REGION, KUBECONFIG or AZURE_SUBSCRIPTION_ID) reflect its command-line options.
+package plugin
+
+// ProtocolVersion is the version of the plugin protocol. Plugins must include it in their responses
+const ProtocolVersion = 1
+
+// Commands sent to plugins
+const (
+	// CommandMetadata asks the plugin for the techniques it implements
+	CommandMetadata = "metadata"
+
+	// CommandDetonate detonates a technique
+	CommandDetonate = "detonate"
+
+	// CommandRevert reverts the detonation of a technique
+	CommandRevert = "revert"
+
+	// CommandIsDetonated asks the plugin whether the side effects of a detonation are currently present
+	CommandIsDetonated = "is_detonated"
+)
+
+// Request is sent by Stratus Red Team to a plugin on its standard input
+type Request struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	Command         string `json:"command"`
+
+	// ID of the technique, for all commands but metadata
+	TechniqueID string `json:"technique_id,omitempty"`
+
+	// Unique identifier of the execution, for all commands but metadata. Inject it in the user-agent of API calls,
+	// as Stratus Red Team does
+	ExecutionID string `json:"execution_id,omitempty"`
+
+	// Terraform outputs of the technique prerequisites
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// Response is written by a plugin to its standard output
+type Response struct {
+	ProtocolVersion int `json:"protocol_version"`
+
+	// Error message, if the command failed
+	Error string `json:"error,omitempty"`
+
+	// Techniques implemented by the plugin, in response to the metadata command
+	Techniques []TechniqueMetadata `json:"techniques,omitempty"`
+
+	// Whether the technique is detonated, in response to the is_detonated command
+	Detonated bool `json:"detonated,omitempty"`
+}
+
+// TechniqueMetadata describes an attack technique implemented by a plugin
+type TechniqueMetadata struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Detection   string   `json:"detection"`
+	Platform    string   `json:"platform"`
+	Tactics     []string `json:"tactics"`
+	Idempotent  bool     `json:"idempotent"`
+	Slow        bool     `json:"slow"`
+
+	// Version of the technique, see AttackTechnique.Version
+	Version int `json:"version,omitempty"`
+
+	// Terraform code of the prerequisites, if any
+	Terraform string `json:"terraform,omitempty"`
+
+	// Names of the Terraform outputs read by the technique, checked by "stratus validate"
+	RequiredOutputs []string `json:"required_outputs,omitempty"`
+
+	// Whether the plugin implements the revert command for the technique
+	Revertible bool `json:"revertible"`
+
+	// Whether the plugin implements the is_detonated command for the technique
+	Probe bool `json:"probe"`
+}
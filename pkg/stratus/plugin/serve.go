@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/google/uuid"
+)
+
+// Serve implements the plugin protocol for attack techniques written in Go, so that they can be distributed as a
+// plugin executable instead of being built into Stratus Red Team. Call it from the main function of the plugin:
+//
+//	func main() {
+//		plugin.Serve(&myTechnique)
+//	}
+//
+// Logs of the techniques must be written to the standard error, which is the default for the log package
+func Serve(techniques ...*stratus.AttackTechnique) {
+	// Stratus Red Team timestamps the logs of plugins
+	log.SetFlags(0)
+	os.Exit(serve(os.Stdin, os.Stdout, techniques))
+}
+
+func serve(stdin io.Reader, stdout io.Writer, techniques []*stratus.AttackTechnique) int {
+	response := handle(stdin, techniques)
+	response.ProtocolVersion = ProtocolVersion
+	if err := json.NewEncoder(stdout).Encode(response); err != nil {
+		return 1
+	}
+	if response.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+func handle(stdin io.Reader, techniques []*stratus.AttackTechnique) *Response {
+	var request Request
+	if err := json.NewDecoder(stdin).Decode(&request); err != nil {
+		return &Response{Error: "invalid request: " + err.Error()}
+	}
+	if request.ProtocolVersion != ProtocolVersion {
+		return &Response{Error: "unsupported protocol version"}
+	}
+
+	if request.Command == CommandMetadata {
+		response := &Response{}
+		for _, technique := range techniques {
+			response.Techniques = append(response.Techniques, toMetadata(technique))
+		}
+		return response
+	}
+
+	var technique *stratus.AttackTechnique
+	for i := range techniques {
+		if techniques[i].ID == request.TechniqueID {
+			technique = techniques[i]
+		}
+	}
+	if technique == nil {
+		return &Response{Error: "unknown technique " + request.TechniqueID}
+	}
+
+	if request.ExecutionID != "" {
+		executionID, err := uuid.Parse(request.ExecutionID)
+		if err != nil {
+			return &Response{Error: "invalid execution ID " + request.ExecutionID}
+		}
+		// API calls of the plugin are attributed to the execution of Stratus Red Team running it
+		providers.SetUniqueExecutionId(executionID)
+	}
+
+	var err error
+	response := &Response{}
+	execution := stratus.NewExecutionContext(request.Parameters)
+	switch {
+	case request.Command == CommandDetonate:
+		err = technique.Detonate(execution)
+	case request.Command == CommandRevert && technique.Revert != nil:
+		err = technique.Revert(execution)
+	case request.Command == CommandIsDetonated && technique.IsDetonated != nil:
+		response.Detonated, err = technique.IsDetonated(execution)
+	default:
+		return &Response{Error: "unsupported command " + request.Command + " for technique " + technique.ID}
+	}
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+	return response
+}
+
+func toMetadata(technique *stratus.AttackTechnique) TechniqueMetadata {
+	metadata := TechniqueMetadata{
+		ID:              technique.ID,
+		Name:            technique.FriendlyName,
+		Description:     technique.Description,
+		Detection:       technique.Detection,
+		Platform:        string(technique.Platform),
+		Idempotent:      technique.IsIdempotent,
+		Slow:            technique.IsSlow,
+		Version:         technique.Version,
+		Terraform:       string(technique.PrerequisitesTerraformCode),
+		Revertible:      technique.Revert != nil,
+		Probe:           technique.IsDetonated != nil,
+		RequiredOutputs: technique.RequiredOutputs,
+	}
+	for _, tactic := range technique.MitreAttackTactics {
+		metadata.Tactics = append(metadata.Tactics, mitreattack.AttackTacticToString(tactic))
+	}
+	return metadata
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	scenarios := []struct {
+		Name             string
+		Request          string
+		ExpectedExitCode int
+		ExpectedResponse Response
+	}{
+		{
+			Name:             "invalid request",
+			Request:          "not json",
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "invalid request: invalid character 'o' in literal null (expecting 'u')"},
+		},
+		{
+			Name:             "unsupported protocol version",
+			Request:          `{"protocol_version": 2, "command": "metadata"}`,
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "unsupported protocol version"},
+		},
+		{
+			Name:             "unknown technique",
+			Request:          `{"protocol_version": 1, "command": "detonate", "technique_id": "unknown"}`,
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "unknown technique unknown"},
+		},
+		{
+			Name:             "unsupported command",
+			Request:          `{"protocol_version": 1, "command": "revert", "technique_id": "plugin.test.slow"}`,
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "unsupported command revert for technique plugin.test.slow"},
+		},
+		{
+			Name:             "failed revert",
+			Request:          `{"protocol_version": 1, "command": "revert", "technique_id": "plugin.test.detonate", "parameters": {"bucket_name": "foo"}}`,
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "unable to revert: bucket foo not found"},
+		},
+		{
+			Name:             "invalid execution ID",
+			Request:          `{"protocol_version": 1, "command": "is_detonated", "technique_id": "plugin.test.detonate", "execution_id": "foo"}`,
+			ExpectedExitCode: 1,
+			ExpectedResponse: Response{Error: "invalid execution ID foo"},
+		},
+		{
+			Name:             "is detonated",
+			Request:          `{"protocol_version": 1, "command": "is_detonated", "technique_id": "plugin.test.detonate"}`,
+			ExpectedResponse: Response{Detonated: true},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			exitCode := serve(strings.NewReader(scenario.Request), &stdout, helperTechniques())
+			assert.Equal(t, scenario.ExpectedExitCode, exitCode)
+
+			var response Response
+			assert.Nil(t, json.Unmarshal(stdout.Bytes(), &response))
+			scenario.ExpectedResponse.ProtocolVersion = ProtocolVersion
+			assert.Equal(t, scenario.ExpectedResponse, response)
+		})
+	}
+}
+
+func TestServeUsesExecutionID(t *testing.T) {
+	previousExecutionID := providers.UniqueExecutionId
+	t.Cleanup(func() { providers.SetUniqueExecutionId(previousExecutionID) })
+	executionID := uuid.New()
+
+	request := `{"protocol_version": 1, "command": "is_detonated", "technique_id": "plugin.test.detonate", "execution_id": "` + executionID.String() + `"}`
+	assert.Equal(t, 0, serve(strings.NewReader(request), &bytes.Buffer{}, helperTechniques()))
+	assert.Equal(t, executionID, providers.UniqueExecutionId)
+	assert.Equal(t, executionID, providers.AWS().UniqueCorrelationId)
+	assert.Contains(t, providers.GetStratusUserAgent(), executionID.String())
+}
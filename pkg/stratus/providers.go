@@ -2,6 +2,11 @@ package stratus
 
 import (
 	"errors"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+
 	"github.com/datadog/stratus-red-team/internal/providers"
 )
 
@@ -13,29 +18,280 @@ func K8sProvider() *providers.K8sProvider {
 	return providers.K8s()
 }
 
+func EntraIDProvider() *providers.EntraIDProvider {
+	return providers.EntraID()
+}
+
+func GCPProvider() *providers.GCPProvider {
+	return providers.GCP()
+}
+
+func LinuxProvider() *providers.LinuxProvider {
+	return providers.Linux()
+}
+
+// Options of the providers, to build providers targeting another environment than the default one, e.g. to detonate
+// attack techniques in two AWS accounts from the same process
+type (
+	AWSOptions   = providers.AWSOptions
+	AzureOptions = providers.AzureOptions
+	K8sOptions   = providers.K8sOptions
+)
+
+// NewAWSProvider returns an AWS provider independent of the default one, to set on an ExecutionContext
+func NewAWSProvider(options AWSOptions) *providers.AWSProvider {
+	return providers.NewAWSProvider(options)
+}
+
+// NewAzureProvider returns an Azure provider independent of the default one, to set on an ExecutionContext
+func NewAzureProvider(options AzureOptions) (*providers.AzureProvider, error) {
+	return providers.NewAzureProvider(options)
+}
+
+// NewEntraIDProvider returns an Entra ID provider using the credentials of an Azure provider, to set on an
+// ExecutionContext
+func NewEntraIDProvider(azure *providers.AzureProvider) *providers.EntraIDProvider {
+	return providers.NewEntraIDProvider(azure)
+}
+
+// NewK8sProvider returns a Kubernetes provider independent of the default one, to set on an ExecutionContext
+func NewK8sProvider(options K8sOptions) *providers.K8sProvider {
+	return providers.NewK8sProvider(options)
+}
+
+func init() {
+	RegisterPlatform(awsPlatform{})
+	RegisterPlatform(azurePlatform{})
+	RegisterPlatform(entraIDPlatform{})
+	RegisterPlatform(gcpPlatform{})
+	RegisterPlatform(kubernetesPlatform{})
+	RegisterPlatform(linuxPlatform{})
+}
+
 // EnsureAuthenticated ensures that the current user is properly authenticated against a specific platform
 func EnsureAuthenticated(platform Platform) error {
-	switch platform {
-	case AWS:
-		if !providers.AWS().IsAuthenticatedAgainstAWS() {
-			return errors.New("you are not authenticated against AWS, or you have not set your region. " +
-				"Make sure you are authenticated against AWS, and you have a default region set in your AWS config " +
-				"or environment (export AWS_DEFAULT_REGION=us-east-1)")
-		}
-	case Azure:
-		if !providers.Azure().IsAuthenticatedAgainstAzure() {
-			return errors.New("you are not authenticated against Azure, or you have not set your subscription. " +
-				"Make sure you are authenticated against Azure and you have your Azure subscription ID set in your environment" +
-				" (export AZURE_SUBSCRIPTION_ID=xxx)")
-		}
-	case Kubernetes:
-		if !providers.K8s().IsAuthenticated() {
-			return errors.New("You do not have a kubeconfig set up, or you do not have proper permissions for " +
-				"this cluster. Make sure you have proper credentials set in " + providers.GetKubeConfigPath())
-		}
-	default:
-		return errors.New("unhandled platform " + string(platform))
+	provider, err := GetPlatformProvider(platform)
+	if err != nil {
+		return err
+	}
+	if err := provider.Authenticate(); err != nil {
+		return err
+	}
+	if identity, err := provider.DescribeIdentity(); err == nil && identity != "" {
+		log.Println("Authenticated against " + provider.DisplayName() + " as " + identity)
+	}
+	return nil
+}
+
+// TerraformEnvironment returns the environment variables to pass to Terraform when spinning up the prerequisites
+// of an attack technique, so that Terraform targets the same environment as Stratus Red Team
+func TerraformEnvironment(platform Platform, terraformDirectory string) (map[string]string, error) {
+	provider, err := GetPlatformProvider(platform)
+	if err != nil {
+		return nil, err
+	}
+	return provider.TerraformEnvironment(terraformDirectory)
+}
+
+type awsPlatform struct{}
+
+func (awsPlatform) Name() Platform      { return AWS }
+func (awsPlatform) DisplayName() string { return "AWS" }
+
+func (awsPlatform) Authenticate() error {
+	if !providers.AWS().IsAuthenticatedAgainstAWS() {
+		return errors.New("you are not authenticated against AWS, or you have not set your region. " +
+			"Make sure you are authenticated against AWS, and you have a default region set in your AWS config " +
+			"or environment (export AWS_DEFAULT_REGION=us-east-1)")
+	}
+	return nil
+}
+
+func (awsPlatform) DescribeIdentity() (string, error) {
+	arn, err := providers.AWS().GetCallerIdentityArn()
+	if err != nil {
+		return "", err
+	}
+	return arn + " in region " + providers.AWS().GetConnection().Region, nil
+}
+
+func (awsPlatform) DescribeTarget() (string, error) {
+	arn, err := providers.AWS().GetCallerIdentityArn()
+	if err != nil {
+		return "", err
+	}
+	// e.g. arn:aws:sts::123456789012:assumed-role/my-role/my-session
+	arnParts := strings.Split(arn, ":")
+	if len(arnParts) < 5 {
+		return "", errors.New("unexpected caller identity ARN " + arn)
+	}
+	return "account " + arnParts[4] + " in region " + providers.AWS().GetConnection().Region, nil
+}
+
+func (awsPlatform) TerraformEnvironment(string) (map[string]string, error) {
+	return providers.AWS().TerraformEnvironment()
+}
+
+type azurePlatform struct{}
+
+func (azurePlatform) Name() Platform      { return Azure }
+func (azurePlatform) DisplayName() string { return "Azure" }
+
+func (azurePlatform) Authenticate() error {
+	if _, err := providers.Azure().GetIdentity(); err != nil {
+		return errors.New("you are not authenticated against Azure, or you have not set your subscription. " +
+			"Make sure you are authenticated against Azure and you have your Azure subscription ID set in your environment" +
+			" (export AZURE_SUBSCRIPTION_ID=xxx) or using --azure-subscription: " + err.Error())
+	}
+	return nil
+}
+
+func (azurePlatform) DescribeIdentity() (string, error) {
+	identity, err := providers.Azure().GetIdentity()
+	if err != nil {
+		return "", err
+	}
+	return identity.Principal + " in tenant " + identity.TenantID + ", using subscription " +
+		identity.SubscriptionName + " (" + identity.SubscriptionID + ")", nil
+}
+
+func (azurePlatform) DescribeTarget() (string, error) {
+	identity, err := providers.Azure().GetIdentity()
+	if err != nil {
+		return "", err
+	}
+	return "subscription " + identity.SubscriptionName + " (" + identity.SubscriptionID + ")", nil
+}
+
+func (azurePlatform) TerraformEnvironment(string) (map[string]string, error) {
+	return providers.Azure().TerraformEnvironment()
+}
+
+type entraIDPlatform struct{}
+
+func (entraIDPlatform) Name() Platform      { return EntraID }
+func (entraIDPlatform) DisplayName() string { return "Entra ID" }
+
+func (entraIDPlatform) Authenticate() error {
+	if _, err := providers.EntraID().GetOrganization(); err != nil {
+		return errors.New("you are not authenticated against Entra ID. " +
+			"Make sure you are authenticated against Azure, for instance using the Azure CLI (az login): " + err.Error())
+	}
+	return nil
+}
+
+func (entraIDPlatform) DescribeIdentity() (string, error) {
+	organization, err := providers.EntraID().GetOrganization()
+	if err != nil {
+		return "", err
 	}
+	return "a principal of tenant " + organization.DisplayName + " (" + organization.Id + ")", nil
+}
+
+func (entraIDPlatform) DescribeTarget() (string, error) {
+	organization, err := providers.EntraID().GetOrganization()
+	if err != nil {
+		return "", err
+	}
+	return "tenant " + organization.DisplayName + " (" + organization.Id + ")", nil
+}
 
+func (entraIDPlatform) TerraformEnvironment(string) (map[string]string, error) {
+	return providers.EntraID().TerraformEnvironment()
+}
+
+type gcpPlatform struct{}
+
+func (gcpPlatform) Name() Platform      { return GCP }
+func (gcpPlatform) DisplayName() string { return "GCP" }
+
+func (gcpPlatform) Authenticate() error {
+	if err := providers.GCP().CheckAuthentication(); err != nil {
+		return errors.New("you are not authenticated against GCP, or you have not set your project. " +
+			"Make sure you are authenticated against GCP (gcloud auth application-default login) and you have " +
+			"your GCP project ID set in your environment (export GOOGLE_PROJECT=xxx): " + err.Error())
+	}
 	return nil
 }
+
+func (gcpPlatform) DescribeIdentity() (string, error) {
+	return "application default credentials, using project " + providers.GCP().GetProjectId(), nil
+}
+
+func (gcpPlatform) DescribeTarget() (string, error) {
+	return "project " + providers.GCP().GetProjectId(), nil
+}
+
+func (gcpPlatform) TerraformEnvironment(string) (map[string]string, error) {
+	return providers.GCP().TerraformEnvironment()
+}
+
+type kubernetesPlatform struct{}
+
+func (kubernetesPlatform) Name() Platform      { return Kubernetes }
+func (kubernetesPlatform) DisplayName() string { return "Kubernetes" }
+
+func (kubernetesPlatform) Authenticate() error {
+	if !providers.K8s().IsAuthenticated() {
+		return errors.New("You do not have a kubeconfig set up, or you do not have proper permissions for " +
+			"this cluster. Make sure you have proper credentials set in " + providers.GetKubeConfigPath())
+	}
+	return nil
+}
+
+func (kubernetesPlatform) DescribeIdentity() (string, error) {
+	config := providers.K8s().GetRestConfig()
+	if config == nil {
+		return "", errors.New("no Kubernetes configuration loaded")
+	}
+	identity := "the current kubeconfig user of cluster " + config.Host
+	if config.Impersonate.UserName != "" {
+		identity = config.Impersonate.UserName + " (impersonated) on cluster " + config.Host
+	}
+	return identity, nil
+}
+
+func (kubernetesPlatform) DescribeTarget() (string, error) {
+	config := providers.K8s().GetRestConfig()
+	if config == nil {
+		return "", errors.New("no Kubernetes configuration loaded")
+	}
+	return "cluster " + config.Host, nil
+}
+
+func (kubernetesPlatform) TerraformEnvironment(terraformDirectory string) (map[string]string, error) {
+	return providers.K8s().TerraformEnvironment(terraformDirectory)
+}
+
+type linuxPlatform struct{}
+
+func (linuxPlatform) Name() Platform      { return Linux }
+func (linuxPlatform) DisplayName() string { return "Linux" }
+
+func (linuxPlatform) Authenticate() error {
+	return providers.Linux().CheckPlatform()
+}
+
+func (linuxPlatform) DescribeIdentity() (string, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	return currentUser.Username + " on host " + hostname, nil
+}
+
+func (linuxPlatform) DescribeTarget() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	return "host " + hostname, nil
+}
+
+func (linuxPlatform) TerraformEnvironment(string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"errors"
+	"log"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// StateDrift describes how the persisted state of an attack technique compares to reality
+type StateDrift struct {
+	// State persisted by Stratus Red Team
+	PersistedState stratus.AttackTechniqueState
+
+	// State observed by probing the side effects of the detonation. Equal to PersistedState when the technique
+	// has no probe
+	ObservedState stratus.AttackTechniqueState
+
+	// Indicates if the detonation was actually probed
+	WasProbed bool
+
+	// Indicates if the prerequisites were checked using a Terraform plan
+	PrerequisitesChecked bool
+
+	// Indicates if the prerequisites no longer match the Terraform code, e.g. because they were manually deleted
+	PrerequisitesDrifted bool
+}
+
+// HasDrifted returns true if the persisted state does not match reality
+func (m *StateDrift) HasDrifted() bool {
+	return m.PersistedState != m.ObservedState || m.PrerequisitesDrifted
+}
+
+// CanDetectDrift returns true if there is anything to compare the persisted state of the technique with
+func (m *Runner) CanDetectDrift() bool {
+	switch m.GetState() {
+	case stratus.AttackTechniqueStatusWarm:
+		return m.Technique.PrerequisitesTerraformCode != nil || m.Technique.IsDetonated != nil
+	case stratus.AttackTechniqueStatusDetonated:
+		return m.Technique.IsDetonated != nil
+	default:
+		// A COLD technique with prerequisites has no Terraform outputs we could use to probe its detonation
+		return !m.Technique.HasPrerequisites() && m.Technique.IsDetonated != nil
+	}
+}
+
+// DetectDrift compares the persisted state of the technique with reality, using the technique probe and a
+// Terraform plan of its prerequisites.
+// Prerequisites are only checked when the technique is observed as WARM, since the side effects of a detonation
+// frequently modify resources managed by Terraform (e.g. stopping a CloudTrail trail), or when the probe fails
+func (m *Runner) DetectDrift() (*StateDrift, error) {
+	drift := &StateDrift{PersistedState: m.GetState(), ObservedState: m.GetState()}
+	if !m.CanDetectDrift() {
+		return drift, nil
+	}
+
+	var probeErr error
+	if m.Technique.IsDetonated != nil {
+		observedState, err := m.probeState()
+		if err == nil {
+			drift.WasProbed = true
+			drift.ObservedState = observedState
+		}
+		// The probe may fail because a prerequisite was manually deleted, which the Terraform plan reports
+		probeErr = err
+	}
+
+	if m.Technique.PrerequisitesTerraformCode != nil && (drift.ObservedState == stratus.AttackTechniqueStatusWarm || probeErr != nil) {
+		err := m.StateManager.ExtractTechnique()
+		if err != nil {
+			return nil, errors.New("unable to extract Terraform file: " + err.Error())
+		}
+		hasChanges, err := m.TerraformManager.TerraformPlan(m.terraformContext(), m.TerraformDir)
+		if err != nil {
+			return nil, errors.New("unable to check prerequisites of " + m.Technique.ID + ": " + errorMessageFromTerraformError(err))
+		}
+		drift.PrerequisitesChecked = true
+		drift.PrerequisitesDrifted = hasChanges
+	}
+
+	if probeErr != nil {
+		if !drift.PrerequisitesDrifted {
+			return nil, probeErr
+		}
+		// Re-applied prerequisites are in a fresh, non-detonated state
+		drift.ObservedState = stratus.AttackTechniqueStatusWarm
+	}
+
+	return drift, nil
+}
+
+// FixDrift updates the persisted state to match the observed one, and re-applies the prerequisites if they drifted
+func (m *Runner) FixDrift(drift *StateDrift) error {
+	if drift.PrerequisitesDrifted {
+		log.Println("Re-applying drifted prerequisites of " + m.Technique.ID)
+		outputs, err := m.TerraformManager.TerraformInitAndApply(m.terraformContext(), m.TerraformDir)
+		if err != nil {
+			return errors.New("unable to re-apply prerequisites of " + m.Technique.ID + ": " + errorMessageFromTerraformError(err))
+		}
+		registerSensitiveOutputs(outputs)
+		err = m.StateManager.WriteTerraformOutputs(outputs)
+		if err != nil {
+			return errors.New("unable to persist Terraform outputs of " + m.Technique.ID + ": " + err.Error())
+		}
+		m.writePrerequisitesVersion()
+
+		drift.PrerequisitesDrifted = false
+	}
+
+	if drift.ObservedState != m.GetState() {
+		log.Println("Updating state of " + m.Technique.ID + " from " + string(m.GetState()) + " to " + string(drift.ObservedState))
+		m.setState(drift.ObservedState)
+	}
+
+	return nil
+}
+
+// probeState determines the actual state of the technique using its probe
+func (m *Runner) probeState() (stratus.AttackTechniqueState, error) {
+	outputs, err := m.getTerraformOutputs()
+	if err != nil {
+		return "", errors.New("unable to retrieve outputs of " + m.Technique.ID + ": " + err.Error())
+	}
+
+	isDetonated, err := m.Technique.IsDetonated(m.executionContext(outputs))
+	if err != nil {
+		return "", errors.New("unable to probe detonation of " + m.Technique.ID + ": " + err.Error())
+	}
+
+	switch {
+	case isDetonated:
+		return stratus.AttackTechniqueStatusDetonated, nil
+	case m.GetState() == stratus.AttackTechniqueStatusDetonated:
+		// Consistent with what happens when reverting a technique
+		return stratus.AttackTechniqueStatusWarm, nil
+	default:
+		return m.GetState(), nil
+	}
+}
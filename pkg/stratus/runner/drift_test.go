@@ -0,0 +1,210 @@
+package runner
+
+import (
+	"errors"
+	"github.com/datadog/stratus-red-team/internal/redaction"
+	statemocks "github.com/datadog/stratus-red-team/internal/state/mocks"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/runner/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestRunnerDetectDrift(t *testing.T) {
+	probe := func(result bool) func(*stratus.ExecutionContext) (bool, error) {
+		return func(*stratus.ExecutionContext) (bool, error) { return result, nil }
+	}
+
+	type DriftTestScenario struct {
+		Name                  string
+		Technique             *stratus.AttackTechnique
+		InitialTechniqueState stratus.AttackTechniqueState
+		TerraformPlanChanges  bool
+		// results
+		CheckExpectations func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift)
+	}
+
+	var scenario = []DriftTestScenario{
+		{
+			Name:                  "COLD technique with prerequisites cannot be checked",
+			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo"), IsDetonated: probe(true)},
+			InitialTechniqueState: stratus.AttackTechniqueStatusCold,
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift) {
+				terraform.AssertNotCalled(t, "TerraformPlan", mock.Anything, mock.Anything)
+				assert.False(t, drift.WasProbed)
+				assert.False(t, drift.HasDrifted())
+			},
+		},
+		{
+			Name:                  "COLD technique without prerequisites detected as detonated",
+			Technique:             &stratus.AttackTechnique{ID: "foo", IsDetonated: probe(true)},
+			InitialTechniqueState: stratus.AttackTechniqueStatusCold,
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift) {
+				assert.True(t, drift.WasProbed)
+				assert.True(t, drift.HasDrifted())
+				assert.Equal(t, stratus.AttackTechniqueState(stratus.AttackTechniqueStatusDetonated), drift.ObservedState)
+			},
+		},
+		{
+			Name:                  "DETONATED technique whose side effects were manually reverted",
+			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo"), IsDetonated: probe(false)},
+			InitialTechniqueState: stratus.AttackTechniqueStatusDetonated,
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift) {
+				assert.True(t, drift.HasDrifted())
+				assert.Equal(t, stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), drift.ObservedState)
+				terraform.AssertCalled(t, "TerraformPlan", mock.Anything, "/root/foo")
+				assert.True(t, drift.PrerequisitesChecked)
+			},
+		},
+		{
+			Name:                  "DETONATED technique still detonated does not check prerequisites",
+			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo"), IsDetonated: probe(true)},
+			InitialTechniqueState: stratus.AttackTechniqueStatusDetonated,
+			TerraformPlanChanges:  true,
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift) {
+				assert.False(t, drift.HasDrifted())
+				terraform.AssertNotCalled(t, "TerraformPlan", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			Name:                  "WARM technique with deleted prerequisites",
+			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo")},
+			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
+			TerraformPlanChanges:  true,
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, drift *StateDrift) {
+				assert.False(t, drift.WasProbed)
+				assert.True(t, drift.PrerequisitesDrifted)
+				assert.True(t, drift.HasDrifted())
+			},
+		},
+	}
+
+	for i := range scenario {
+		state := new(statemocks.StateManager)
+		terraform := new(mocks.TerraformManager)
+
+		state.On("GetRootDirectory").Return("/root")
+		state.On("ExtractTechnique").Return(nil)
+		state.On("GetTechniqueState", mock.Anything).Return(scenario[i].InitialTechniqueState, nil)
+		state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{}), nil)
+		terraform.On("TerraformPlan", mock.Anything, mock.Anything).Return(scenario[i].TerraformPlanChanges, nil)
+
+		runner := Runner{
+			Technique:        scenario[i].Technique,
+			TerraformManager: terraform,
+			StateManager:     state,
+		}
+		runner.initialize()
+		drift, err := runner.DetectDrift()
+		assert.Nil(t, err)
+		t.Run(scenario[i].Name, func(t *testing.T) { scenario[i].CheckExpectations(t, terraform, drift) })
+	}
+}
+
+func TestRunnerFixDrift(t *testing.T) {
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusDetonated), nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.StringOutputs(map[string]string{"foo": "bar"}), nil)
+
+	runner := Runner{
+		Technique:        &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo")},
+		TerraformManager: terraform,
+		StateManager:     state,
+	}
+	runner.initialize()
+	err := runner.FixDrift(&StateDrift{
+		PersistedState:       stratus.AttackTechniqueStatusDetonated,
+		ObservedState:        stratus.AttackTechniqueStatusWarm,
+		PrerequisitesDrifted: true,
+	})
+
+	assert.Nil(t, err)
+	terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, "/root/foo")
+	state.AssertCalled(t, "WriteTerraformOutputs", stratus.StringOutputs(map[string]string{"foo": "bar"}))
+	state.AssertCalled(t, "SetTechniqueState", stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm))
+	assert.Equal(t, stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), runner.GetState())
+}
+
+func TestRunnerFixDriftRegistersSensitiveOutputs(t *testing.T) {
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	password := stratus.StringOutput("drifted-hunter2")
+	password.Sensitive = true
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.Outputs{
+		"password":   password,
+		"user_token": stratus.StringOutput("drifted-token"),
+	}, nil)
+
+	runner := Runner{
+		Technique:        &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo")},
+		TerraformManager: terraform,
+		StateManager:     state,
+	}
+	runner.initialize()
+	err := runner.FixDrift(&StateDrift{
+		PersistedState:       stratus.AttackTechniqueStatusWarm,
+		ObservedState:        stratus.AttackTechniqueStatusWarm,
+		PrerequisitesDrifted: true,
+	})
+
+	assert.Nil(t, err)
+	redacted := redaction.Redact("password drifted-hunter2, token drifted-token")
+	assert.NotContains(t, redacted, "drifted-hunter2")
+	assert.NotContains(t, redacted, "drifted-token")
+}
+
+func TestRunnerDetectDriftWhenProbeFails(t *testing.T) {
+	failingProbe := func(*stratus.ExecutionContext) (bool, error) {
+		return false, errors.New("trail not found")
+	}
+	scenarios := []struct {
+		name                 string
+		terraformPlanChanges bool
+		expectedError        bool
+	}{
+		{name: "deleted prerequisites are reported as drift", terraformPlanChanges: true},
+		{name: "probe error is returned when prerequisites did not drift", terraformPlanChanges: false, expectedError: true},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			state := new(statemocks.StateManager)
+			terraform := new(mocks.TerraformManager)
+			state.On("GetRootDirectory").Return("/root")
+			state.On("ExtractTechnique").Return(nil)
+			state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusDetonated), nil)
+			state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{}), nil)
+			terraform.On("TerraformPlan", mock.Anything, mock.Anything).Return(scenario.terraformPlanChanges, nil)
+
+			runner := Runner{
+				Technique:        &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo"), IsDetonated: failingProbe},
+				TerraformManager: terraform,
+				StateManager:     state,
+			}
+			runner.initialize()
+			drift, err := runner.DetectDrift()
+
+			terraform.AssertCalled(t, "TerraformPlan", mock.Anything, "/root/foo")
+			if scenario.expectedError {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), "unable to probe detonation of foo: trail not found")
+				return
+			}
+			assert.Nil(t, err)
+			assert.False(t, drift.WasProbed)
+			assert.True(t, drift.PrerequisitesDrifted)
+			assert.True(t, drift.HasDrifted())
+			assert.Equal(t, stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), drift.ObservedState)
+		})
+	}
+}
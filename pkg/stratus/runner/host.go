@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// HostPrerequisitesManager creates and removes the prerequisites of attack techniques executed on the local host
+type HostPrerequisitesManager interface {
+	CreatePrerequisites(prerequisites *stratus.HostPrerequisites) (map[string]string, error)
+	DestroyPrerequisites(prerequisites *stratus.HostPrerequisites, outputs map[string]string) error
+}
+
+type HostPrerequisitesManagerImpl struct{}
+
+func NewHostPrerequisitesManager() HostPrerequisitesManager {
+	return &HostPrerequisitesManagerImpl{}
+}
+
+// CreatePrerequisites creates the files and starts the processes of a technique. It returns outputs mapping the
+// name of each file to its absolute path, and the name of each process to its PID
+func (m *HostPrerequisitesManagerImpl) CreatePrerequisites(prerequisites *stratus.HostPrerequisites) (map[string]string, error) {
+	linux := providers.Linux()
+	outputs := map[string]string{}
+
+	for _, file := range prerequisites.Files {
+		path, err := linux.ExpandPath(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		outputs[file.Name] = path
+		if _, err := os.Stat(path); err == nil && file.KeepExisting {
+			log.Println("Keeping existing file " + path)
+			continue
+		}
+
+		log.Println("Creating file " + path)
+		if err := linux.BackupFile(path); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, errors.New("unable to create directory of " + path + ": " + err.Error())
+		}
+		if err := os.WriteFile(path, file.Content, file.Mode); err != nil {
+			return nil, errors.New("unable to create " + path + ": " + err.Error())
+		}
+		if err := os.Chmod(path, file.Mode); err != nil {
+			return nil, errors.New("unable to set permissions of " + path + ": " + err.Error())
+		}
+	}
+
+	for _, process := range prerequisites.Processes {
+		pid, err := linux.StartProcess(process.Command, process.Environment)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Started process " + strconv.Itoa(pid))
+		outputs[process.Name] = strconv.Itoa(pid)
+	}
+
+	return outputs, nil
+}
+
+// DestroyPrerequisites stops the processes of a technique, and restores its files to their original content
+func (m *HostPrerequisitesManagerImpl) DestroyPrerequisites(prerequisites *stratus.HostPrerequisites, outputs map[string]string) error {
+	linux := providers.Linux()
+
+	for _, process := range prerequisites.Processes {
+		pid, err := strconv.Atoi(outputs[process.Name])
+		if err != nil {
+			// The process was never started
+			continue
+		}
+		log.Println("Stopping process " + strconv.Itoa(pid))
+		if err := linux.StopProcess(pid, process.Command); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range prerequisites.Files {
+		path, err := linux.ExpandPath(file.Path)
+		if err != nil {
+			return err
+		}
+		log.Println("Restoring file " + path)
+		if err := linux.RestoreFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
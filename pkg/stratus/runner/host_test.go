@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostPrerequisitesFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	existingFile := filepath.Join(home, "existing")
+	keptFile := filepath.Join(home, "kept")
+	assert.Nil(t, os.WriteFile(existingFile, []byte("original"), 0640))
+	assert.Nil(t, os.WriteFile(keptFile, []byte("kept"), 0600))
+
+	prerequisites := &stratus.HostPrerequisites{Files: []stratus.HostFile{
+		{Name: "existing", Path: "~/existing", Content: []byte("overwritten"), Mode: 0600},
+		{Name: "new", Path: "~/sub/directory/new", Content: []byte("new"), Mode: 0700},
+		{Name: "kept", Path: "~/kept", Content: []byte("decoy"), Mode: 0600, KeepExisting: true},
+	}}
+	manager := NewHostPrerequisitesManager()
+
+	outputs, err := manager.CreatePrerequisites(prerequisites)
+	assert.Nil(t, err)
+	newFile := filepath.Join(home, "sub", "directory", "new")
+	assert.Equal(t, map[string]string{"existing": existingFile, "new": newFile, "kept": keptFile}, outputs)
+	assertFile(t, existingFile, "overwritten", 0600)
+	assertFile(t, newFile, "new", 0700)
+	assertFile(t, keptFile, "kept", 0600)
+
+	// Warming up again must not lose the original content
+	_, err = manager.CreatePrerequisites(prerequisites)
+	assert.Nil(t, err)
+
+	assert.Nil(t, manager.DestroyPrerequisites(prerequisites, outputs))
+	assertFile(t, existingFile, "original", 0640)
+	assertFile(t, keptFile, "kept", 0600)
+	assert.NoFileExists(t, newFile)
+}
+
+func TestHostPrerequisitesProcesses(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("processes are only supported on Linux")
+	}
+	t.Setenv("HOME", t.TempDir())
+	command := []string{"sleep", "60"}
+	prerequisites := &stratus.HostPrerequisites{Processes: []stratus.HostProcess{
+		{Name: "pid", Command: command, Environment: map[string]string{"STRATUS_TEST": "value"}},
+	}}
+	manager := NewHostPrerequisitesManager()
+
+	outputs, err := manager.CreatePrerequisites(prerequisites)
+	assert.Nil(t, err)
+	pid, err := strconv.Atoi(outputs["pid"])
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool { return providers.Linux().IsProcessRunning(pid, command) }, 5*time.Second, 10*time.Millisecond)
+	environment, err := os.ReadFile(filepath.Join("/proc", outputs["pid"], "environ"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(environment), "STRATUS_TEST=value")
+
+	assert.Nil(t, manager.DestroyPrerequisites(prerequisites, outputs))
+	assert.Eventually(t, func() bool { return !providers.Linux().IsProcessRunning(pid, command) }, 5*time.Second, 10*time.Millisecond)
+
+	// Stopping a process which is not running anymore is a no-op
+	assert.Nil(t, manager.DestroyPrerequisites(prerequisites, outputs))
+}
+
+func assertFile(t *testing.T, path string, expectedContent string, expectedMode os.FileMode) {
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedContent, string(content))
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedMode, info.Mode().Perm())
+}
@@ -2,7 +2,12 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	stratus "github.com/datadog/stratus-red-team/pkg/stratus"
+	mock "github.com/stretchr/testify/mock"
+)
 
 // TerraformManager is an autogenerated mock type for the TerraformManager type
 type TerraformManager struct {
@@ -14,13 +19,13 @@ func (_m *TerraformManager) Initialize() {
 	_m.Called()
 }
 
-// TerraformDestroy provides a mock function with given fields: directory
-func (_m *TerraformManager) TerraformDestroy(directory string) error {
-	ret := _m.Called(directory)
+// TerraformDestroy provides a mock function with given fields: ctx, directory
+func (_m *TerraformManager) TerraformDestroy(ctx context.Context, directory string) error {
+	ret := _m.Called(ctx, directory)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(directory)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, directory)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -28,22 +33,43 @@ func (_m *TerraformManager) TerraformDestroy(directory string) error {
 	return r0
 }
 
-// TerraformInitAndApply provides a mock function with given fields: directory
-func (_m *TerraformManager) TerraformInitAndApply(directory string) (map[string]string, error) {
-	ret := _m.Called(directory)
+// TerraformInitAndApply provides a mock function with given fields: ctx, directory
+func (_m *TerraformManager) TerraformInitAndApply(ctx context.Context, directory string) (stratus.Outputs, error) {
+	ret := _m.Called(ctx, directory)
 
-	var r0 map[string]string
-	if rf, ok := ret.Get(0).(func(string) map[string]string); ok {
-		r0 = rf(directory)
+	var r0 stratus.Outputs
+	if rf, ok := ret.Get(0).(func(context.Context, string) stratus.Outputs); ok {
+		r0 = rf(ctx, directory)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(map[string]string)
+			r0 = ret.Get(0).(stratus.Outputs)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(directory)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, directory)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TerraformPlan provides a mock function with given fields: ctx, directory
+func (_m *TerraformManager) TerraformPlan(ctx context.Context, directory string) (bool, error) {
+	ret := _m.Called(ctx, directory)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, directory)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, directory)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,11 +1,19 @@
 package runner
 
 import (
+	"context"
 	"errors"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+	"github.com/datadog/stratus-red-team/internal/history"
 	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/redaction"
+	"github.com/datadog/stratus-red-team/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/datadog/stratus-red-team/internal/state"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
@@ -20,16 +28,38 @@ type Runner struct {
 	TerraformDir     string
 	ShouldForce      bool
 	TerraformManager TerraformManager
+	HostManager      HostPrerequisitesManager
 	StateManager     state.StateManager
+
+	// Execution context passed to the technique, with the outputs of its prerequisites
+	ExecutionContext *stratus.ExecutionContext
+
+	// Optional history in which operations run on the technique are recorded
+	History history.Recorder
+
+	// Indicates if prerequisites spun up by a previous version of the technique should be re-applied when warming it up
+	ShouldReapplyOutdated bool
+
+	// Context of the running operation, carrying its trace
+	ctx context.Context
 }
 
 func NewRunner(technique *stratus.AttackTechnique, force bool) Runner {
 	stateManager := state.NewFileSystemStateManager(technique)
+	// Terraform is only downloaded when needed, e.g. not for techniques executed on the local host. Its environment is
+	// built from the providers of the execution context of the runner, see terraformContext
+	var terraformManager TerraformManager
+	if technique.PrerequisitesTerraformCode != nil {
+		terraformManager = NewTerraformManager(filepath.Join(stateManager.GetRootDirectory(), "terraform"), nil)
+	}
 	runner := Runner{
 		Technique:        technique,
 		ShouldForce:      force,
-		TerraformManager: NewTerraformManager(filepath.Join(stateManager.GetRootDirectory(), "terraform")),
+		TerraformManager: terraformManager,
+		HostManager:      NewHostPrerequisitesManager(),
 		StateManager:     stateManager,
+		ExecutionContext: stratus.NewExecutionContext(nil),
+		History:          history.NewRecorder(filepath.Join(stateManager.GetRootDirectory(), history.FileName)),
 	}
 	runner.initialize()
 
@@ -44,15 +74,56 @@ func (m *Runner) initialize() {
 	}
 }
 
-func (m *Runner) WarmUp() (map[string]string, error) {
+// executionContext returns the execution context to pass to the technique
+func (m *Runner) executionContext(outputs stratus.Outputs) *stratus.ExecutionContext {
+	if m.ExecutionContext == nil {
+		m.ExecutionContext = stratus.NewExecutionContext(nil)
+	}
+	return m.ExecutionContext.WithOutputs(outputs).WithContext(m.context())
+}
+
+// context returns the Go context of the running operation, carrying its trace
+func (m *Runner) context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	executionID := providers.UniqueExecutionId
+	if m.ExecutionContext != nil {
+		executionID = m.ExecutionContext.ExecutionID
+	}
+	return tracing.WithAttributes(context.Background(),
+		tracing.AttributeTechniqueID.String(m.Technique.ID),
+		tracing.AttributeExecutionID.String(executionID.String()),
+		tracing.AttributePlatform.String(string(m.Technique.Platform)),
+	)
+}
+
+// terraformContext returns the context to run Terraform in, so that the prerequisites are spun up and destroyed in
+// the environment targeted by the providers of the execution context
+func (m *Runner) terraformContext() context.Context {
+	return WithTerraformEnvironment(m.context(), func(directory string) (map[string]string, error) {
+		return m.executionContext(nil).TerraformEnvironment(m.Technique.Platform, directory)
+	})
+}
+
+func (m *Runner) WarmUp() (stratus.Outputs, error) {
+	operation := m.startOperation(history.OperationWarmUp)
+	outputs, err := m.warmUp()
+	m.endOperation(operation, err)
+	return outputs, err
+}
+
+func (m *Runner) warmUp() (stratus.Outputs, error) {
 	// No prerequisites to spin-up
-	if m.Technique.PrerequisitesTerraformCode == nil {
-		return map[string]string{}, nil
+	if !m.Technique.HasPrerequisites() {
+		return stratus.Outputs{}, nil
 	}
 
-	err := m.StateManager.ExtractTechnique()
-	if err != nil {
-		return nil, errors.New("unable to extract Terraform file: " + err.Error())
+	if m.Technique.PrerequisitesTerraformCode != nil {
+		err := m.StateManager.ExtractTechnique()
+		if err != nil {
+			return nil, errors.New("unable to extract Terraform file: " + err.Error())
+		}
 	}
 
 	// We don't want to warm up the technique
@@ -60,45 +131,69 @@ func (m *Runner) WarmUp() (map[string]string, error) {
 
 	// Technique is already warm
 	if m.TechniqueState == stratus.AttackTechniqueStatusWarm && !m.ShouldForce {
-		log.Println("Not warming up - " + m.Technique.ID + " is already warm. Use --force to force")
-		willWarmUp = false
+		if outdated := m.GetOutdatedPrerequisites(); outdated != nil && m.ShouldReapplyOutdated {
+			log.Println("Re-applying outdated prerequisites of " + m.Technique.ID + ", " + outdated.String())
+			if err := resetTerraformInitialization(m.TerraformDir); err != nil {
+				return nil, errors.New("unable to re-initialize Terraform for " + m.Technique.ID + ": " + err.Error())
+			}
+		} else {
+			if outdated != nil {
+				log.Println("Warning: prerequisites of " + m.Technique.ID + " are outdated (" + outdated.String() + "). Use --reapply-outdated to re-apply them")
+			}
+			log.Println("Not warming up - " + m.Technique.ID + " is already warm. Use --force to force")
+			willWarmUp = false
+		}
 	}
 
 	if m.TechniqueState == stratus.AttackTechniqueStatusDetonated {
+		if outdated := m.GetOutdatedPrerequisites(); outdated != nil {
+			log.Println("Warning: prerequisites of " + m.Technique.ID + " are outdated (" + outdated.String() + "). Revert it before re-applying them")
+		}
 		log.Println(m.Technique.ID + " has been detonated but not cleaned up, not warming up as it should be warm already.")
 		willWarmUp = false
 	}
 
 	if !willWarmUp {
-		outputs, err := m.StateManager.GetTerraformOutputs()
-		return outputs, err
+		return m.getTerraformOutputs()
 	}
 
 	log.Println("Warming up " + m.Technique.ID)
-	outputs, err := m.TerraformManager.TerraformInitAndApply(m.TerraformDir)
+	outputs, err := m.createPrerequisites()
 	if err != nil {
-		return nil, errors.New("unable to run terraform apply on prerequisite: " + errorMessageFromTerraformError(err))
+		return nil, err
 	}
 
 	// Persist outputs to disk
+	registerSensitiveOutputs(outputs)
 	err = m.StateManager.WriteTerraformOutputs(outputs)
+	m.writePrerequisitesVersion()
 	m.setState(stratus.AttackTechniqueStatusWarm)
 
 	if display, ok := outputs["display"]; ok {
-		log.Println(display)
+		log.Println(display.String())
 	}
 	return outputs, err
 }
 
 func (m *Runner) Detonate() error {
+	operation := m.startOperation(history.OperationDetonate)
+	outputs, err := m.detonate()
+	if operation.entry != nil && err == nil {
+		operation.entry.Verification = m.verifyDetonation(outputs)
+	}
+	m.endOperation(operation, err)
+	return err
+}
+
+func (m *Runner) detonate() (stratus.Outputs, error) {
 	willWarmUp := true
 	var err error
-	var outputs map[string]string
+	var outputs stratus.Outputs
 
 	// If the attack technique has already been detonated, make sure it's idempotent
 	if m.GetState() == stratus.AttackTechniqueStatusDetonated {
 		if !m.Technique.IsIdempotent && !m.ShouldForce {
-			return errors.New(m.Technique.ID + " has already been detonated and is not idempotent. " +
+			return nil, errors.New(m.Technique.ID + " has already been detonated and is not idempotent. " +
 				"Revert it with 'stratus revert' before detonating it again, or use --force")
 		}
 		willWarmUp = false
@@ -107,28 +202,60 @@ func (m *Runner) Detonate() error {
 	if willWarmUp {
 		outputs, err = m.WarmUp()
 	} else {
-		outputs, err = m.StateManager.GetTerraformOutputs()
+		outputs, err = m.getTerraformOutputs()
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Detonate
-	err = m.Technique.Detonate(outputs)
+	// Detonate, persisting the outputs set by the detonation (even if it failed midway) so that it can be reverted
+	detonationOutputs := stratus.Outputs{}
+	for name, output := range outputs {
+		detonationOutputs[name] = output
+	}
+	err = m.recordAPICalls(history.OperationDetonate, detonationOutputs, m.Technique.Detonate)
+	if len(detonationOutputs) > 0 && !reflect.DeepEqual(detonationOutputs, outputs) {
+		registerSensitiveOutputs(detonationOutputs)
+		if err := m.StateManager.WriteTerraformOutputs(detonationOutputs); err != nil {
+			log.Println("Warning: unable to persist the outputs of the detonation of " + m.Technique.ID + ": " + err.Error())
+		}
+	}
 	if err != nil {
-		return errors.New("Error while detonating attack technique " + m.Technique.ID + ": " + err.Error())
+		return nil, errors.New("Error while detonating attack technique " + m.Technique.ID + ": " + err.Error())
 	}
 	m.setState(stratus.AttackTechniqueStatusDetonated)
-	return nil
+	return detonationOutputs, nil
+}
+
+// verifyDetonation probes the side effects of a detonation, for techniques able to
+func (m *Runner) verifyDetonation(outputs stratus.Outputs) *history.Verification {
+	if m.Technique.IsDetonated == nil {
+		return nil
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(m.context(), "stratus.verify")
+	detonated, err := m.Technique.IsDetonated(m.executionContext(outputs).WithContext(ctx))
+	tracing.End(span, err)
+	if err != nil {
+		return &history.Verification{Error: redaction.Redact(err.Error()), Duration: time.Since(start)}
+	}
+	return &history.Verification{Detonated: detonated, Duration: time.Since(start)}
 }
 
 func (m *Runner) Revert() error {
+	operation := m.startOperation(history.OperationRevert)
+	err := m.revert()
+	m.endOperation(operation, err)
+	return err
+}
+
+func (m *Runner) revert() error {
 	if m.GetState() != stratus.AttackTechniqueStatusDetonated && !m.ShouldForce {
 		return errors.New(m.Technique.ID + " is not in DETONATED state and should not need to be reverted, use --force to force")
 	}
 
-	outputs, err := m.StateManager.GetTerraformOutputs()
+	outputs, err := m.getTerraformOutputs()
 	if err != nil {
 		return errors.New("unable to retrieve outputs of " + m.Technique.ID + ": " + err.Error())
 	}
@@ -136,7 +263,7 @@ func (m *Runner) Revert() error {
 	log.Println("Reverting detonation of technique " + m.Technique.ID)
 
 	if m.Technique.Revert != nil {
-		err = m.Technique.Revert(outputs)
+		err = m.recordAPICalls(history.OperationRevert, outputs, m.Technique.Revert)
 		if err != nil {
 			return errors.New("unable to revert detonation of " + m.Technique.ID + ": " + err.Error())
 		}
@@ -148,6 +275,13 @@ func (m *Runner) Revert() error {
 }
 
 func (m *Runner) CleanUp() error {
+	operation := m.startOperation(history.OperationCleanUp)
+	err := m.cleanUp()
+	m.endOperation(operation, err)
+	return err
+}
+
+func (m *Runner) cleanUp() error {
 	// Has the technique already been cleaned up?
 	if m.TechniqueState == stratus.AttackTechniqueStatusCold && !m.ShouldForce {
 		return errors.New(m.Technique.ID + " is already COLD and should already be clean, use --force to force cleanup")
@@ -166,11 +300,22 @@ func (m *Runner) CleanUp() error {
 	// Nuke prerequisites
 	if m.Technique.PrerequisitesTerraformCode != nil {
 		log.Println("Cleaning up technique prerequisites with terraform destroy")
-		err := m.TerraformManager.TerraformDestroy(m.TerraformDir)
+		err := m.TerraformManager.TerraformDestroy(m.terraformContext(), m.TerraformDir)
 		if err != nil {
 			return errors.New("unable to cleanup TTP prerequisites: " + errorMessageFromTerraformError(err))
 		}
 	}
+	if m.Technique.PrerequisitesHost != nil {
+		log.Println("Cleaning up technique prerequisites on the local host")
+		outputs, err := m.getTerraformOutputs()
+		if err != nil {
+			return errors.New("unable to retrieve outputs of " + m.Technique.ID + ": " + err.Error())
+		}
+		err = m.HostManager.DestroyPrerequisites(m.Technique.PrerequisitesHost, outputs.Strings())
+		if err != nil {
+			return errors.New("unable to cleanup TTP prerequisites: " + err.Error())
+		}
+	}
 
 	m.setState(stratus.AttackTechniqueStatusCold)
 
@@ -183,6 +328,132 @@ func (m *Runner) CleanUp() error {
 	return nil
 }
 
+// createPrerequisites spins up the prerequisites of the technique, either with Terraform or on the local host
+func (m *Runner) createPrerequisites() (stratus.Outputs, error) {
+	if m.Technique.PrerequisitesHost != nil {
+		outputs, err := m.HostManager.CreatePrerequisites(m.Technique.PrerequisitesHost)
+		if err != nil {
+			return nil, errors.New("unable to create prerequisites on the local host: " + err.Error())
+		}
+		return stratus.StringOutputs(outputs), nil
+	}
+
+	outputs, err := m.TerraformManager.TerraformInitAndApply(m.terraformContext(), m.TerraformDir)
+	if err != nil {
+		return nil, errors.New("unable to run terraform apply on prerequisite: " + errorMessageFromTerraformError(err))
+	}
+	return outputs, nil
+}
+
+// getTerraformOutputs retrieves the persisted Terraform outputs, making sure sensitive ones are never displayed
+func (m *Runner) getTerraformOutputs() (stratus.Outputs, error) {
+	outputs, err := m.StateManager.GetTerraformOutputs()
+	if err != nil {
+		return nil, err
+	}
+	registerSensitiveOutputs(outputs)
+	return outputs, nil
+}
+
+// registerSensitiveOutputs registers the values of outputs that have a sensitive name or are marked as sensitive in
+// Terraform, so that they are redacted from the output
+func registerSensitiveOutputs(outputs stratus.Outputs) {
+	redaction.RegisterSensitiveOutputs(outputs.Strings())
+	for _, output := range outputs {
+		if output.Sensitive {
+			redaction.RegisterSecret(output.String())
+		}
+	}
+}
+
+// recordAPICalls runs the detonation or reversion function of the technique, and persists the API calls it made
+func (m *Runner) recordAPICalls(operation history.Operation, outputs stratus.Outputs, run func(*stratus.ExecutionContext) error) error {
+	execution := m.executionContext(outputs)
+	recorder := apitrace.NewRecorder()
+	trace := apitrace.Trace{
+		ExecutionID: execution.ExecutionID.String(),
+		Operation:   string(operation),
+		StartTime:   time.Now().UTC(),
+	}
+	err := run(execution.WithContext(apitrace.WithRecorder(execution.Context, recorder)))
+	trace.EndTime = time.Now().UTC()
+	trace.Calls = recorder.Calls()
+	for i := range trace.Calls {
+		trace.Calls[i].Error = redaction.Redact(trace.Calls[i].Error)
+	}
+	m.saveAPICallTrace(trace)
+	return err
+}
+
+// saveAPICallTrace persists the API calls made by an operation. A detonation replaces the API calls of previous ones,
+// while a reversion is appended to the API calls of the detonation it reverts
+func (m *Runner) saveAPICallTrace(trace apitrace.Trace) {
+	var traces []apitrace.Trace
+	if trace.Operation != string(history.OperationDetonate) {
+		previousTraces, err := m.StateManager.GetAPICallTraces()
+		if err != nil {
+			log.Println("Warning: unable to read the API calls previously made by " + m.Technique.ID + ": " + err.Error())
+		}
+		traces = previousTraces
+	}
+	if err := m.StateManager.WriteAPICallTraces(append(traces, trace)); err != nil {
+		log.Println("Warning: unable to persist the API calls made by " + m.Technique.ID + ": " + err.Error())
+	}
+}
+
+// operation is an operation running on the technique, traced and recorded in the history
+type operation struct {
+	entry  *history.Entry
+	span   trace.Span
+	parent context.Context
+}
+
+// startOperation starts the span of an operation, nested in the span of the operation running it if any, and its
+// history entry
+func (m *Runner) startOperation(name history.Operation) *operation {
+	operation := &operation{entry: m.startRecording(name), parent: m.ctx}
+	m.ctx, operation.span = tracing.Start(m.context(), "stratus."+string(name))
+	return operation
+}
+
+// endOperation ends the span of an operation and records it in the history
+func (m *Runner) endOperation(operation *operation, err error) {
+	m.record(operation.entry, err)
+	tracing.End(operation.span, err)
+	m.ctx = operation.parent
+}
+
+// startRecording returns the history entry of an operation starting, or nil if the history is disabled
+func (m *Runner) startRecording(operation history.Operation) *history.Entry {
+	if m.History == nil {
+		return nil
+	}
+	environment := history.DescribeEnvironment(m.Technique.Platform)
+	return &history.Entry{
+		ExecutionID: m.executionContext(nil).ExecutionID.String(),
+		TechniqueID: m.Technique.ID,
+		Platform:    string(m.Technique.Platform),
+		Operation:   operation,
+		StartTime:   time.Now().UTC(),
+		Target:      environment.Target,
+		Identity:    environment.Identity,
+	}
+}
+
+// record records an operation in the history, once it has completed
+func (m *Runner) record(entry *history.Entry, err error) {
+	if entry == nil {
+		return
+	}
+	entry.EndTime = time.Now().UTC()
+	if err != nil {
+		entry.Error = redaction.Redact(err.Error())
+	}
+	if err := m.History.Record(*entry); err != nil {
+		log.Println("Warning: unable to record " + string(entry.Operation) + " of " + m.Technique.ID + " in the history: " + err.Error())
+	}
+}
+
 func (m *Runner) GetState() stratus.AttackTechniqueState {
 	return m.TechniqueState
 }
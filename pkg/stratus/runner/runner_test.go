@@ -1,12 +1,24 @@
 package runner
 
 import (
+	"context"
 	"errors"
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+	"github.com/datadog/stratus-red-team/internal/history"
+	"github.com/datadog/stratus-red-team/internal/providers"
 	statemocks "github.com/datadog/stratus-red-team/internal/state/mocks"
+	"github.com/datadog/stratus-red-team/internal/tracing"
+	"github.com/datadog/stratus-red-team/internal/utils"
 	"github.com/datadog/stratus-red-team/pkg/stratus"
 	"github.com/datadog/stratus-red-team/pkg/stratus/runner/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -17,10 +29,12 @@ func TestRunnerWarmUp(t *testing.T) {
 		Technique             *stratus.AttackTechnique
 		ShouldForce           bool
 		InitialTechniqueState stratus.AttackTechniqueState
-		TerraformOutputs      map[string]string
-		PersistedOutputs      map[string]string
+		TerraformOutputs      stratus.Outputs
+		PersistedOutputs      stratus.Outputs
+		PersistedVersion      *stratus.PrerequisitesVersion
+		ShouldReapplyOutdated bool
 		// results
-		CheckExpectations func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error)
+		CheckExpectations func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error)
 	}
 
 	var scenario = []RunnerWarmupTestScenario{
@@ -28,8 +42,8 @@ func TestRunnerWarmUp(t *testing.T) {
 			Name:                  "Warming up a technique without prerequisite Terraform code",
 			Technique:             &stratus.AttackTechnique{ID: "foo"},
 			InitialTechniqueState: stratus.AttackTechniqueStatusCold,
-			PersistedOutputs:      map[string]string{"myoutput": "foo"},
-			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error) {
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "foo"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
 				terraform.AssertNotCalled(t, "TerraformInitAndApply")
 				state.AssertNotCalled(t, "ExtractTechnique")
 				assert.Nil(t, err)
@@ -42,28 +56,80 @@ func TestRunnerWarmUp(t *testing.T) {
 			Name:                  "Warming up a COLD technique",
 			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("foo")},
 			InitialTechniqueState: stratus.AttackTechniqueStatusCold,
-			TerraformOutputs:      map[string]string{"myoutput": "new"},
-			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error) {
+			TerraformOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "new"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
 				state.AssertCalled(t, "ExtractTechnique")
-				terraform.AssertCalled(t, "TerraformInitAndApply", "/root/foo")
-				state.AssertCalled(t, "WriteTerraformOutputs", map[string]string{"myoutput": "new"})
+				terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, "/root/foo")
+				state.AssertCalled(t, "WriteTerraformOutputs", stratus.StringOutputs(map[string]string{"myoutput": "new"}))
+				state.AssertCalled(t, "WritePrerequisitesVersion", stratus.AttackTechnique{PrerequisitesTerraformCode: []byte("foo")}.GetPrerequisitesVersion())
 				state.AssertCalled(t, "SetTechniqueState", stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm))
 
 				assert.Nil(t, err)
 				assert.Len(t, outputs, 1)
-				assert.Equal(t, "new", outputs["myoutput"])
+				assert.Equal(t, "new", outputs["myoutput"].String())
 			},
 		},
 		{
 			Name:                  "Warming up a WARM technique without force flag",
 			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("bar")},
 			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
-			PersistedOutputs:      map[string]string{"myoutput": "new"},
-			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error) {
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "new"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
 				terraform.AssertNotCalled(t, "TerraformInitAndApply")
 				assert.Nil(t, err)
 				assert.Len(t, outputs, 1)
-				assert.Equal(t, "new", outputs["myoutput"])
+				assert.Equal(t, "new", outputs["myoutput"].String())
+			},
+		},
+		{
+			Name:                  "Warming up a WARM technique with outdated prerequisites",
+			Technique:             &stratus.AttackTechnique{ID: "foo", Version: 2, PrerequisitesTerraformCode: []byte("bar")},
+			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			PersistedVersion:      &stratus.PrerequisitesVersion{Version: 1, Hash: "abc"},
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
+				terraform.AssertNotCalled(t, "TerraformInitAndApply")
+				assert.Nil(t, err)
+				assert.Equal(t, "old", outputs["myoutput"].String())
+			},
+		},
+		{
+			Name:                  "Warming up a WARM technique with outdated prerequisites, re-applying them",
+			Technique:             &stratus.AttackTechnique{ID: "foo", Version: 2, PrerequisitesTerraformCode: []byte("bar")},
+			ShouldReapplyOutdated: true,
+			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
+			TerraformOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "new"}),
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			PersistedVersion:      &stratus.PrerequisitesVersion{Version: 1, Hash: "abc"},
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
+				terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, "/root/foo")
+				state.AssertCalled(t, "WritePrerequisitesVersion", stratus.AttackTechnique{Version: 2, PrerequisitesTerraformCode: []byte("bar")}.GetPrerequisitesVersion())
+				assert.Nil(t, err)
+				assert.Equal(t, "new", outputs["myoutput"].String())
+			},
+		},
+		{
+			Name:                  "Warming up a WARM technique with up-to-date prerequisites, re-applying outdated ones",
+			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("bar")},
+			ShouldReapplyOutdated: true,
+			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			PersistedVersion:      &stratus.PrerequisitesVersion{Version: 1, Hash: stratus.AttackTechnique{PrerequisitesTerraformCode: []byte("bar")}.GetPrerequisitesVersion().Hash},
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
+				terraform.AssertNotCalled(t, "TerraformInitAndApply")
+				assert.Nil(t, err)
+				assert.Equal(t, "old", outputs["myoutput"].String())
+			},
+		},
+		{
+			Name:                  "Warming up a WARM technique warmed up before prerequisites were versioned, re-applying outdated ones",
+			Technique:             &stratus.AttackTechnique{ID: "foo", Version: 2, PrerequisitesTerraformCode: []byte("bar")},
+			ShouldReapplyOutdated: true,
+			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
+				terraform.AssertNotCalled(t, "TerraformInitAndApply")
+				assert.Nil(t, err)
 			},
 		},
 		{
@@ -71,24 +137,24 @@ func TestRunnerWarmUp(t *testing.T) {
 			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("bar")},
 			ShouldForce:           true,
 			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
-			TerraformOutputs:      map[string]string{"myoutput": "old"},
-			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error) {
-				terraform.AssertCalled(t, "TerraformInitAndApply", "/root/foo")
+			TerraformOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
+				terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, "/root/foo")
 				assert.Nil(t, err)
 				assert.Len(t, outputs, 1)
-				assert.Equal(t, "old", outputs["myoutput"])
+				assert.Equal(t, "old", outputs["myoutput"].String())
 			},
 		},
 		{
 			Name:                  "Warming up a DETONATED technique",
 			Technique:             &stratus.AttackTechnique{ID: "foo", PrerequisitesTerraformCode: []byte("bar")},
 			InitialTechniqueState: stratus.AttackTechniqueStatusDetonated,
-			PersistedOutputs:      map[string]string{"myoutput": "old"},
-			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs map[string]string, err error) {
+			PersistedOutputs:      stratus.StringOutputs(map[string]string{"myoutput": "old"}),
+			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, outputs stratus.Outputs, err error) {
 				terraform.AssertNotCalled(t, "TerraformInitAndApply")
 				assert.Nil(t, err)
 				assert.Len(t, outputs, 1)
-				assert.Equal(t, "old", outputs["myoutput"])
+				assert.Equal(t, "old", outputs["myoutput"].String())
 			},
 		},
 	}
@@ -101,15 +167,18 @@ func TestRunnerWarmUp(t *testing.T) {
 		state.On("ExtractTechnique").Return(nil)
 		state.On("GetTechniqueState", mock.Anything).Return(scenario[i].InitialTechniqueState, nil)
 		state.On("GetTerraformOutputs").Return(scenario[i].PersistedOutputs, nil)
-		terraform.On("TerraformInitAndApply", mock.Anything).Return(scenario[i].TerraformOutputs, nil)
+		state.On("GetPrerequisitesVersion").Return(scenario[i].PersistedVersion, nil)
+		terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(scenario[i].TerraformOutputs, nil)
 		state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+		state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
 		state.On("SetTechniqueState", mock.Anything).Return(nil)
 
 		runner := Runner{
-			Technique:        scenario[i].Technique,
-			ShouldForce:      scenario[i].ShouldForce,
-			TerraformManager: terraform,
-			StateManager:     state,
+			Technique:             scenario[i].Technique,
+			ShouldForce:           scenario[i].ShouldForce,
+			ShouldReapplyOutdated: scenario[i].ShouldReapplyOutdated,
+			TerraformManager:      terraform,
+			StateManager:          state,
 		}
 		runner.initialize()
 		outputs, err := runner.WarmUp()
@@ -117,6 +186,41 @@ func TestRunnerWarmUp(t *testing.T) {
 	}
 }
 
+func TestRunnerReapplyingOutdatedPrerequisitesInitializesTerraformAgain(t *testing.T) {
+	rootDirectory := t.TempDir()
+	terraformDirectory := filepath.Join(rootDirectory, "foo")
+	assert.Nil(t, os.Mkdir(terraformDirectory, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(terraformDirectory, terraformInitializedFileName), []byte{}, 0644))
+
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return(rootDirectory)
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), nil)
+	state.On("GetPrerequisitesVersion").Return(&stratus.PrerequisitesVersion{Version: 1, Hash: "abc"}, nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	var initializedWhenApplying bool
+	terraform.On("TerraformInitAndApply", mock.Anything, terraformDirectory).Run(func(mock.Arguments) {
+		initializedWhenApplying = utils.FileExists(filepath.Join(terraformDirectory, terraformInitializedFileName))
+	}).Return(stratus.StringOutputs(map[string]string{}), nil)
+
+	runner := Runner{
+		Technique:             &stratus.AttackTechnique{ID: "foo", Version: 2, PrerequisitesTerraformCode: []byte("bar")},
+		ShouldReapplyOutdated: true,
+		TerraformManager:      terraform,
+		StateManager:          state,
+	}
+	runner.initialize()
+	_, err := runner.WarmUp()
+
+	assert.Nil(t, err)
+	terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, terraformDirectory)
+	// "terraform init" runs again, since the Terraform code of the prerequisites changed
+	assert.False(t, initializedWhenApplying)
+}
+
 func TestRunnerDetonate(t *testing.T) {
 
 	type TestDetonationScenario struct {
@@ -191,16 +295,19 @@ func TestRunnerDetonate(t *testing.T) {
 			state.On("GetRootDirectory").Return("/root")
 			state.On("ExtractTechnique").Return(nil)
 			state.On("GetTechniqueState", mock.Anything).Return(scenario[i].TechniqueState, nil)
-			terraform.On("TerraformInitAndApply", mock.Anything).Return(map[string]string{}, nil)
+			terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.StringOutputs(map[string]string{}), nil)
 			state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
-			state.On("GetTerraformOutputs").Return(map[string]string{}, nil)
+			state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+			state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{}), nil)
 			state.On("SetTechniqueState", mock.Anything).Return(nil)
+			state.On("GetAPICallTraces").Return(nil, nil)
+			state.On("WriteAPICallTraces", mock.Anything).Return(nil)
 
 			var wasDetonated = false
 			runner := Runner{
 				Technique: &stratus.AttackTechnique{
 					ID: "sample-technique",
-					Detonate: func(map[string]string) error {
+					Detonate: func(*stratus.ExecutionContext) error {
 						wasDetonated = true
 						return nil
 					},
@@ -220,9 +327,9 @@ func TestRunnerDetonate(t *testing.T) {
 			}
 
 			if scenario[i].ExpectWarmedUp {
-				terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything)
+				terraform.AssertCalled(t, "TerraformInitAndApply", mock.Anything, mock.Anything)
 			} else {
-				terraform.AssertNotCalled(t, "TerraformInitAndApply", mock.Anything)
+				terraform.AssertNotCalled(t, "TerraformInitAndApply", mock.Anything, mock.Anything)
 			}
 
 			if scenario[i].ExpectDetonated {
@@ -281,16 +388,18 @@ func TestRunnerRevert(t *testing.T) {
 			state := new(statemocks.StateManager)
 			state.On("GetRootDirectory").Return("/root")
 			state.On("ExtractTechnique").Return(nil)
-			state.On("GetTerraformOutputs").Return(map[string]string{"foo": "bar"}, nil)
+			state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{"foo": "bar"}), nil)
 			state.On("GetTechniqueState", mock.Anything).Return(scenario[i].TechniqueState)
 			state.On("SetTechniqueState", mock.Anything).Return(nil)
+			state.On("GetAPICallTraces").Return(nil, nil)
+			state.On("WriteAPICallTraces", mock.Anything).Return(nil)
 
 			var wasReverted = false
 			runner := Runner{
 				Technique: &stratus.AttackTechnique{
 					ID:       "foo",
-					Detonate: func(map[string]string) error { return nil },
-					Revert: func(params map[string]string) error {
+					Detonate: func(*stratus.ExecutionContext) error { return nil },
+					Revert: func(*stratus.ExecutionContext) error {
 						wasReverted = true
 						return nil
 					},
@@ -355,7 +464,7 @@ func TestRunnerCleanup(t *testing.T) {
 			ShouldForce:           true,
 			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, err error) {
 				assert.Nil(t, err)
-				terraform.AssertCalled(t, "TerraformDestroy", mock.Anything)
+				terraform.AssertCalled(t, "TerraformDestroy", mock.Anything, mock.Anything)
 				state.AssertCalled(t, "CleanupTechnique")
 			},
 		},
@@ -365,7 +474,7 @@ func TestRunnerCleanup(t *testing.T) {
 			InitialTechniqueState: stratus.AttackTechniqueStatusWarm,
 			CheckExpectations: func(t *testing.T, terraform *mocks.TerraformManager, state *statemocks.StateManager, err error) {
 				assert.Nil(t, err)
-				terraform.AssertCalled(t, "TerraformDestroy", mock.Anything)
+				terraform.AssertCalled(t, "TerraformDestroy", mock.Anything, mock.Anything)
 				state.AssertCalled(t, "CleanupTechnique")
 				state.AssertCalled(t, "SetTechniqueState", stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold))
 			},
@@ -404,15 +513,17 @@ func TestRunnerCleanup(t *testing.T) {
 		state.On("ExtractTechnique").Return(nil)
 		state.On("GetTechniqueState", mock.Anything).Return(scenario[i].InitialTechniqueState, nil)
 		state.On("SetTechniqueState", mock.Anything).Return(nil)
+		state.On("GetAPICallTraces").Return(nil, nil)
+		state.On("WriteAPICallTraces", mock.Anything).Return(nil)
 		state.On("CleanupTechnique").Return(nil)
-		state.On("GetTerraformOutputs").Return(map[string]string{}, nil)
+		state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{}), nil)
 		if scenario[i].TerraformDestroyFails {
-			terraform.On("TerraformDestroy", mock.Anything).Return(errors.New("nope"))
+			terraform.On("TerraformDestroy", mock.Anything, mock.Anything).Return(errors.New("nope"))
 		} else {
-			terraform.On("TerraformDestroy", mock.Anything).Return(nil)
+			terraform.On("TerraformDestroy", mock.Anything, mock.Anything).Return(nil)
 		}
 		if scenario[i].RevertFails {
-			scenario[i].Technique.Revert = func(map[string]string) error {
+			scenario[i].Technique.Revert = func(*stratus.ExecutionContext) error {
 				return errors.New("nope")
 			}
 		}
@@ -427,3 +538,340 @@ func TestRunnerCleanup(t *testing.T) {
 		t.Run(scenario[i].Name, func(t *testing.T) { scenario[i].CheckExpectations(t, terraform, state, err) })
 	}
 }
+
+func TestRunnerHostPrerequisites(t *testing.T) {
+	prerequisites := &stratus.HostPrerequisites{Files: []stratus.HostFile{{Name: "file", Path: "~/file"}}}
+	technique := &stratus.AttackTechnique{ID: "foo", PrerequisitesHost: prerequisites}
+
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	host := new(mocks.HostPrerequisitesManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold), nil)
+	state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{"file": "/home/foo/file"}), nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+	state.On("CleanupTechnique").Return(nil)
+	host.On("CreatePrerequisites", prerequisites).Return(map[string]string{"file": "/home/foo/file"}, nil)
+	host.On("DestroyPrerequisites", prerequisites, mock.Anything).Return(nil)
+
+	runner := Runner{
+		Technique:        technique,
+		TerraformManager: terraform,
+		HostManager:      host,
+		StateManager:     state,
+	}
+	runner.initialize()
+
+	outputs, err := runner.WarmUp()
+	assert.Nil(t, err)
+	assert.Equal(t, stratus.StringOutputs(map[string]string{"file": "/home/foo/file"}), outputs)
+	state.AssertNotCalled(t, "ExtractTechnique")
+	terraform.AssertNotCalled(t, "TerraformInitAndApply", mock.Anything, mock.Anything)
+	state.AssertCalled(t, "WriteTerraformOutputs", stratus.StringOutputs(map[string]string{"file": "/home/foo/file"}))
+
+	assert.Nil(t, runner.CleanUp())
+	host.AssertCalled(t, "DestroyPrerequisites", prerequisites, map[string]string{"file": "/home/foo/file"})
+	terraform.AssertNotCalled(t, "TerraformDestroy", mock.Anything, mock.Anything)
+	state.AssertCalled(t, "SetTechniqueState", stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold))
+}
+
+func TestRunnerPassesExecutionContextToTechnique(t *testing.T) {
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold), nil)
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.StringOutputs(map[string]string{"bucket_name": "my-bucket"}), nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+
+	awsProvider := providers.NewAWSProvider(providers.AWSOptions{Region: "eu-west-3"})
+	var received *stratus.ExecutionContext
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID:                         "foo",
+			PrerequisitesTerraformCode: []byte("foo"),
+			Detonate: func(execution *stratus.ExecutionContext) error {
+				received = execution
+				return nil
+			},
+		},
+		TerraformManager: terraform,
+		StateManager:     state,
+		ExecutionContext: &stratus.ExecutionContext{AWS: awsProvider},
+	}
+	runner.initialize()
+
+	assert.Nil(t, runner.Detonate())
+	assert.Equal(t, map[string]string{"bucket_name": "my-bucket"}, received.Parameters)
+	assert.Same(t, awsProvider, received.AWS)
+}
+
+type fakeRecorder struct {
+	entries []history.Entry
+}
+
+func (m *fakeRecorder) Record(entry history.Entry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func TestRunnerRecordsOperationsInHistory(t *testing.T) {
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold), nil)
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.StringOutputs(map[string]string{"bucket_name": "my-bucket"}), nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{"bucket_name": "my-bucket"}), nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+
+	recorder := &fakeRecorder{}
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID:                         "foo",
+			PrerequisitesTerraformCode: []byte("foo"),
+			Detonate: func(*stratus.ExecutionContext) error {
+				return nil
+			},
+			IsDetonated: func(execution *stratus.ExecutionContext) (bool, error) {
+				return execution.Parameters["bucket_name"] == "my-bucket", nil
+			},
+		},
+		TerraformManager: terraform,
+		StateManager:     state,
+		History:          recorder,
+	}
+	runner.initialize()
+
+	assert.Nil(t, runner.Detonate())
+	if assert.Len(t, recorder.entries, 2) {
+		assert.Equal(t, history.OperationWarmUp, recorder.entries[0].Operation)
+		detonation := recorder.entries[1]
+		assert.Equal(t, history.OperationDetonate, detonation.Operation)
+		assert.Equal(t, "foo", detonation.TechniqueID)
+		assert.Equal(t, runner.GetUniqueExecutionId(), detonation.ExecutionID)
+		assert.True(t, detonation.Succeeded())
+		assert.False(t, detonation.EndTime.Before(detonation.StartTime))
+		if assert.NotNil(t, detonation.Verification) {
+			assert.True(t, detonation.Verification.Detonated)
+			assert.Empty(t, detonation.Verification.Error)
+		}
+	}
+
+	runner.ShouldForce = true
+	runner.Technique.Detonate = func(*stratus.ExecutionContext) error {
+		return errors.New("access denied")
+	}
+	assert.NotNil(t, runner.Detonate())
+	failure := recorder.entries[len(recorder.entries)-1]
+	assert.Equal(t, history.OperationDetonate, failure.Operation)
+	assert.Contains(t, failure.Error, "access denied")
+	assert.Nil(t, failure.Verification)
+}
+
+func TestRunnerTracesOperations(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold), nil)
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.StringOutputs(map[string]string{}), nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID:                         "foo",
+			Platform:                   stratus.AWS,
+			PrerequisitesTerraformCode: []byte("foo"),
+			Detonate: func(execution *stratus.ExecutionContext) error {
+				_, span := tracing.Start(execution.Context, "AWS CloudTrail.StopLogging")
+				span.End()
+				return nil
+			},
+			IsDetonated: func(*stratus.ExecutionContext) (bool, error) {
+				return false, errors.New("throttled")
+			},
+		},
+		TerraformManager: terraform,
+		StateManager:     state,
+		History:          &fakeRecorder{},
+	}
+	runner.initialize()
+	assert.Nil(t, runner.Detonate())
+
+	// Describing the target environment for the history may call the cloud provider in its own trace
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	detonate, ok := spans["stratus.detonate"]
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.False(t, detonate.Parent().IsValid())
+	assert.Contains(t, detonate.Attributes(), tracing.AttributeTechniqueID.String("foo"))
+	assert.Contains(t, detonate.Attributes(), tracing.AttributeExecutionID.String(runner.GetUniqueExecutionId()))
+	for _, name := range []string{"stratus.warmup", "AWS CloudTrail.StopLogging", "stratus.verify"} {
+		assert.Equal(t, detonate.SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+		assert.Contains(t, spans[name].Attributes(), tracing.AttributeTechniqueID.String("foo"), name)
+	}
+	assert.Equal(t, "throttled", spans["stratus.verify"].Status().Description)
+
+	// The context passed to Terraform carries the span of the warm up
+	ctx := terraform.Calls[0].Arguments.Get(0).(context.Context)
+	assert.Equal(t, spans["stratus.warmup"].SpanContext().SpanID(), trace.SpanContextFromContext(ctx).SpanID())
+}
+
+func TestRunnerRecordsAPICalls(t *testing.T) {
+	state := new(statemocks.StateManager)
+	var persistedTraces []apitrace.Trace
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), nil)
+	state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{}), nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(func() []apitrace.Trace { return persistedTraces }, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		persistedTraces = args.Get(0).([]apitrace.Trace)
+	})
+
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID: "foo",
+			Detonate: func(execution *stratus.ExecutionContext) error {
+				apitrace.Record(execution.Context, apitrace.Call{Platform: "AWS", Operation: "StopLogging", Resource: "my-trail"})
+				return nil
+			},
+			Revert: func(execution *stratus.ExecutionContext) error {
+				apitrace.Record(execution.Context, apitrace.Call{Platform: "AWS", Operation: "StartLogging", Resource: "my-trail"})
+				return errors.New("access denied")
+			},
+		},
+		StateManager: state,
+	}
+	runner.initialize()
+
+	assert.Nil(t, runner.Detonate())
+	assert.NotNil(t, runner.Revert())
+	if assert.Len(t, persistedTraces, 2) {
+		assert.Equal(t, "detonate", persistedTraces[0].Operation)
+		assert.Equal(t, runner.GetUniqueExecutionId(), persistedTraces[0].ExecutionID)
+		assert.Equal(t, []apitrace.Call{{Platform: "AWS", Operation: "StopLogging", Resource: "my-trail"}}, persistedTraces[0].Calls)
+		assert.Equal(t, "revert", persistedTraces[1].Operation)
+		assert.Len(t, persistedTraces[1].Calls, 1)
+	}
+
+	// A new detonation replaces the API calls of the previous one
+	runner.ShouldForce = true
+	assert.Nil(t, runner.Detonate())
+	assert.Len(t, persistedTraces, 1)
+}
+
+func TestRunnerPersistsOutputsOfDetonation(t *testing.T) {
+	state := new(statemocks.StateManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), nil)
+	state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{"bucket_name": "my-bucket"}), nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetPrerequisitesVersion").Return(nil, nil)
+
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID:                         "foo",
+			PrerequisitesTerraformCode: []byte("foo"),
+			Detonate: func(execution *stratus.ExecutionContext) error {
+				execution.SetOutput("namespace", "my-namespace")
+				return nil
+			},
+		},
+		StateManager: state,
+	}
+	runner.initialize()
+
+	assert.Nil(t, runner.Detonate())
+	state.AssertCalled(t, "WriteTerraformOutputs", stratus.StringOutputs(map[string]string{
+		"bucket_name": "my-bucket",
+		"namespace":   "my-namespace",
+	}))
+}
+
+func TestRunnerDoesNotPersistOutputsOfDetonationWhenUnchanged(t *testing.T) {
+	state := new(statemocks.StateManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), nil)
+	state.On("GetTerraformOutputs").Return(stratus.StringOutputs(map[string]string{"bucket_name": "my-bucket"}), nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+	state.On("GetAPICallTraces").Return(nil, nil)
+	state.On("WriteAPICallTraces", mock.Anything).Return(nil)
+
+	runner := Runner{
+		Technique: &stratus.AttackTechnique{
+			ID:       "foo",
+			Detonate: func(execution *stratus.ExecutionContext) error { return nil },
+		},
+		StateManager: state,
+	}
+	runner.initialize()
+
+	assert.Nil(t, runner.Detonate())
+	state.AssertNotCalled(t, "WriteTerraformOutputs", mock.Anything)
+}
+
+func TestRunnerRunsTerraformAgainstProvidersOfExecutionContext(t *testing.T) {
+	state := new(statemocks.StateManager)
+	terraform := new(mocks.TerraformManager)
+	state.On("GetRootDirectory").Return("/root")
+	state.On("ExtractTechnique").Return(nil)
+	state.On("GetTechniqueState", mock.Anything).Return(stratus.AttackTechniqueState(stratus.AttackTechniqueStatusCold), nil)
+	state.On("GetPrerequisitesVersion").Return(nil, nil)
+	state.On("WriteTerraformOutputs", mock.Anything).Return(nil)
+	state.On("WritePrerequisitesVersion", mock.Anything).Return(nil)
+	state.On("SetTechniqueState", mock.Anything).Return(nil)
+
+	var environment map[string]string
+	terraform.On("TerraformInitAndApply", mock.Anything, mock.Anything).Return(stratus.Outputs{}, nil).Run(func(args mock.Arguments) {
+		getEnvironment, ok := args.Get(0).(context.Context).Value(terraformEnvironmentKey{}).(TerraformEnvironmentFunc)
+		if assert.True(t, ok) {
+			environment, _ = getEnvironment("/root/foo")
+		}
+	})
+
+	execution := stratus.NewExecutionContext(nil)
+	execution.AWS = stratus.NewAWSProvider(stratus.AWSOptions{Profile: "other-account", Region: "eu-west-3"})
+	runner := Runner{
+		Technique:        &stratus.AttackTechnique{ID: "foo", Platform: stratus.AWS, PrerequisitesTerraformCode: []byte("foo")},
+		TerraformManager: terraform,
+		StateManager:     state,
+		ExecutionContext: execution,
+	}
+	runner.initialize()
+
+	_, err := runner.WarmUp()
+	assert.Nil(t, err)
+	assert.Equal(t, "other-account", environment["AWS_PROFILE"])
+	assert.Equal(t, "eu-west-3", environment["AWS_REGION"])
+}
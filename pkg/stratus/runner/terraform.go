@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/internal/tracing"
 	"github.com/datadog/stratus-red-team/internal/utils"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hc-install/product"
 	"github.com/hashicorp/hc-install/releases"
@@ -13,25 +15,45 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 )
 
 const TerraformVersion = "1.1.2"
 
 type TerraformManager interface {
 	Initialize()
-	TerraformInitAndApply(directory string) (map[string]string, error)
-	TerraformDestroy(directory string) error
+	TerraformInitAndApply(ctx context.Context, directory string) (stratus.Outputs, error)
+	TerraformDestroy(ctx context.Context, directory string) error
+	TerraformPlan(ctx context.Context, directory string) (bool, error)
 }
 
 type TerraformManagerImpl struct {
 	terraformBinaryPath string
 	terraformVersion    string
+	environment         TerraformEnvironmentFunc
 }
 
-func NewTerraformManager(terraformBinaryPath string) TerraformManager {
+// TerraformEnvironmentFunc returns environment variables to add to (or, when empty, remove from) the environment of
+// Terraform when running in a specific directory
+type TerraformEnvironmentFunc func(directory string) (map[string]string, error)
+
+type terraformEnvironmentKey struct{}
+
+// WithTerraformEnvironment returns a copy of a context in which Terraform runs with the environment returned by a
+// function, instead of the default environment of the Terraform manager. Used by runners to run Terraform against the
+// providers of their execution context
+func WithTerraformEnvironment(ctx context.Context, environment TerraformEnvironmentFunc) context.Context {
+	return context.WithValue(ctx, terraformEnvironmentKey{}, environment)
+}
+
+// NewTerraformManager creates a Terraform manager. The environment function returns environment variables
+// to add to (or, when empty, remove from) the environment of Terraform when running in a specific directory, unless
+// overridden in the context of a command with WithTerraformEnvironment
+func NewTerraformManager(terraformBinaryPath string, environment TerraformEnvironmentFunc) TerraformManager {
 	manager := TerraformManagerImpl{
 		terraformVersion:    TerraformVersion,
 		terraformBinaryPath: terraformBinaryPath,
+		environment:         environment,
 	}
 	manager.Initialize()
 	return &manager
@@ -54,21 +76,84 @@ func (m *TerraformManagerImpl) Initialize() {
 	}
 }
 
-func (m *TerraformManagerImpl) TerraformInitAndApply(directory string) (map[string]string, error) {
+// newTerraform instantiates Terraform in a specific directory, with the Stratus Red Team user-agent and environment
+func (m *TerraformManagerImpl) newTerraform(ctx context.Context, directory string) (*tfexec.Terraform, error) {
 	terraform, err := tfexec.NewTerraform(directory, m.terraformBinaryPath)
 	if err != nil {
-		return map[string]string{}, errors.New("unable to instantiate Terraform: " + err.Error())
+		return nil, errors.New("unable to instantiate Terraform: " + err.Error())
 	}
 
 	err = terraform.SetAppendUserAgent(providers.GetStratusUserAgent())
 	if err != nil {
-		return map[string]string{}, errors.New("unable to configure Terraform: " + err.Error())
+		return nil, errors.New("unable to configure Terraform: " + err.Error())
+	}
+
+	getEnvironment := m.environment
+	if contextEnvironment, ok := ctx.Value(terraformEnvironmentKey{}).(TerraformEnvironmentFunc); ok {
+		getEnvironment = contextEnvironment
+	}
+	if getEnvironment != nil {
+		environment, err := getEnvironment(directory)
+		if err != nil {
+			return nil, errors.New("unable to configure Terraform: " + err.Error())
+		}
+		if len(environment) > 0 {
+			err = terraform.SetEnv(mergeEnvironment(os.Environ(), environment))
+			if err != nil {
+				return nil, errors.New("unable to configure Terraform: " + err.Error())
+			}
+		}
+	}
+
+	return terraform, nil
+}
+
+// mergeEnvironment overrides a list of KEY=value environment variables. Overrides with an empty value are removed.
+// Environment variables that Terraform does not allow to set manually are skipped
+func mergeEnvironment(environ []string, overrides map[string]string) map[string]string {
+	env := make(map[string]string, len(environ)+len(overrides))
+	for _, variable := range environ {
+		if key, value, found := strings.Cut(variable, "="); found {
+			env[key] = value
+		}
+	}
+	for key, value := range overrides {
+		if value == "" {
+			delete(env, key)
+		} else {
+			env[key] = value
+		}
+	}
+	return tfexec.CleanEnv(env)
+}
+
+// Name of the file marking a Terraform directory as initialized, so that "terraform init" only runs once
+const terraformInitializedFileName = ".terraform-initialized"
+
+// resetTerraformInitialization makes the next apply in a Terraform directory initialize it again, e.g. because its
+// Terraform code changed
+func resetTerraformInitialization(directory string) error {
+	err := os.Remove(path.Join(directory, terraformInitializedFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// TerraformInitAndApply applies the Terraform code of a directory, and returns its outputs with their types
+func (m *TerraformManagerImpl) TerraformInitAndApply(ctx context.Context, directory string) (stratus.Outputs, error) {
+	terraform, err := m.newTerraform(ctx, directory)
+	if err != nil {
+		return nil, err
 	}
 
-	terraformInitializedFile := path.Join(directory, ".terraform-initialized")
+	terraformInitializedFile := path.Join(directory, terraformInitializedFileName)
 	if !utils.FileExists(terraformInitializedFile) {
 		log.Println("Initializing Terraform to spin up technique prerequisites")
-		err = terraform.Init(context.Background())
+		err = traceTerraform(ctx, "init", func(ctx context.Context) error {
+			// Re-applied prerequisites may require other provider versions than the ones of the lock file
+			return terraform.Init(ctx, tfexec.Upgrade(true))
+		})
 		if err != nil {
 			return nil, errors.New("unable to Initialize Terraform: " + err.Error())
 		}
@@ -81,27 +166,64 @@ func (m *TerraformManagerImpl) TerraformInitAndApply(directory string) (map[stri
 	}
 
 	log.Println("Applying Terraform to spin up technique prerequisites")
-	err = terraform.Apply(context.Background(), tfexec.Refresh(false))
+	err = traceTerraform(ctx, "apply", func(ctx context.Context) error {
+		return terraform.Apply(ctx, tfexec.Refresh(false))
+	})
 	if err != nil {
 		return nil, errors.New("unable to apply Terraform: " + err.Error())
 	}
 
-	rawOutputs, _ := terraform.Output(context.Background())
-	outputs := make(map[string]string, len(rawOutputs))
-	for outputName, outputRawValue := range rawOutputs {
-		outputValue := string(outputRawValue.Value)
-		// Strip the first and last quote which gets added for some reason
-		outputValue = outputValue[1 : len(outputValue)-1]
-		outputs[outputName] = outputValue
+	var rawOutputs map[string]tfexec.OutputMeta
+	err = traceTerraform(ctx, "output", func(ctx context.Context) (err error) {
+		rawOutputs, err = terraform.Output(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("unable to retrieve Terraform outputs: " + err.Error())
 	}
-	return outputs, nil
+	return toOutputs(rawOutputs), nil
 }
 
-func (m *TerraformManagerImpl) TerraformDestroy(directory string) error {
-	terraform, err := tfexec.NewTerraform(directory, m.terraformBinaryPath)
+// toOutputs converts the outputs of "terraform output -json", keeping their JSON-encoded values and types
+func toOutputs(rawOutputs map[string]tfexec.OutputMeta) stratus.Outputs {
+	outputs := make(stratus.Outputs, len(rawOutputs))
+	for name, rawOutput := range rawOutputs {
+		outputs[name] = stratus.Output{Type: rawOutput.Type, Value: rawOutput.Value, Sensitive: rawOutput.Sensitive}
+	}
+	return outputs
+}
+
+func (m *TerraformManagerImpl) TerraformDestroy(ctx context.Context, directory string) error {
+	terraform, err := m.newTerraform(ctx, directory)
 	if err != nil {
 		return err
 	}
 
-	return terraform.Destroy(context.Background())
+	return traceTerraform(ctx, "destroy", func(ctx context.Context) error {
+		return terraform.Destroy(ctx)
+	})
+}
+
+// TerraformPlan refreshes the Terraform state of a directory and returns true if applying it would cause changes,
+// meaning the prerequisites have drifted from their expected configuration or do not exist anymore
+func (m *TerraformManagerImpl) TerraformPlan(ctx context.Context, directory string) (bool, error) {
+	terraform, err := m.newTerraform(ctx, directory)
+	if err != nil {
+		return false, err
+	}
+
+	var hasChanges bool
+	err = traceTerraform(ctx, "plan", func(ctx context.Context) (err error) {
+		hasChanges, err = terraform.Plan(ctx)
+		return err
+	})
+	return hasChanges, err
+}
+
+// traceTerraform runs a Terraform command in a span, e.g. "terraform apply"
+func traceTerraform(ctx context.Context, command string, run func(ctx context.Context) error) error {
+	ctx, span := tracing.Start(ctx, "terraform "+command)
+	err := run(ctx)
+	tracing.End(span, err)
+	return err
 }
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"encoding/json"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergeEnvironment(t *testing.T) {
+	env := mergeEnvironment(
+		[]string{"HOME=/root", "AWS_PROFILE=default", "AWS_ACCESS_KEY_ID=foo", "TF_LOG=debug", "EMPTY="},
+		map[string]string{"AWS_PROFILE": "sandbox", "AWS_ACCESS_KEY_ID": "", "AWS_REGION": "eu-west-1"},
+	)
+
+	assert.Equal(t, map[string]string{
+		"HOME":        "/root",
+		"AWS_PROFILE": "sandbox",
+		"AWS_REGION":  "eu-west-1",
+		"EMPTY":       "",
+	}, env)
+}
+
+func TestTerraformOutputsKeepTheirType(t *testing.T) {
+	outputs := toOutputs(map[string]tfexec.OutputMeta{
+		"bucket_name": {Type: json.RawMessage(`"string"`), Value: json.RawMessage(`"my-bucket"`)},
+		"ports":       {Type: json.RawMessage(`["list","number"]`), Value: json.RawMessage(`[22,3389]`)},
+		"password":    {Sensitive: true, Type: json.RawMessage(`"string"`), Value: json.RawMessage(`"hunter2"`)},
+	})
+
+	assert.Equal(t, "my-bucket", outputs["bucket_name"].String())
+	assert.Equal(t, "[22,3389]", outputs["ports"].String())
+	assert.Equal(t, json.RawMessage(`["list","number"]`), outputs["ports"].Type)
+	assert.True(t, outputs["password"].Sensitive)
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"log"
+
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// OutdatedPrerequisites describes prerequisites spun up by a previous version of an attack technique, e.g. before
+// upgrading Stratus Red Team
+type OutdatedPrerequisites struct {
+	// Version of the prerequisites persisted when warming up the technique
+	Persisted stratus.PrerequisitesVersion
+
+	// Version of the prerequisites of the technique being run
+	Current stratus.PrerequisitesVersion
+}
+
+func (m *OutdatedPrerequisites) String() string {
+	return "spun up with " + m.Persisted.String() + ", current version is " + m.Current.String()
+}
+
+// GetOutdatedPrerequisites returns how the prerequisites of a technique that is not COLD differ from its current
+// version, or nil if they are up-to-date. Prerequisites of techniques warmed up by a version of Stratus Red Team not
+// persisting their version are considered up-to-date, since there is nothing to compare them with
+func GetOutdatedPrerequisites(technique *stratus.AttackTechnique, stateManager state.StateManager) *OutdatedPrerequisites {
+	if !technique.HasPrerequisites() {
+		return nil
+	}
+	techniqueState := stateManager.GetTechniqueState()
+	if techniqueState == "" || techniqueState == stratus.AttackTechniqueStatusCold {
+		return nil
+	}
+	persisted, err := stateManager.GetPrerequisitesVersion()
+	if err != nil {
+		log.Println("Warning: unable to read the prerequisites version of " + technique.ID + ": " + err.Error())
+		return nil
+	}
+	current := technique.GetPrerequisitesVersion()
+	if persisted == nil || *persisted == current {
+		return nil
+	}
+	return &OutdatedPrerequisites{Persisted: *persisted, Current: current}
+}
+
+// GetOutdatedPrerequisites returns how the prerequisites of the technique differ from its current version, or nil if
+// they are up-to-date
+func (m *Runner) GetOutdatedPrerequisites() *OutdatedPrerequisites {
+	return GetOutdatedPrerequisites(m.Technique, m.StateManager)
+}
+
+// writePrerequisitesVersion persists the version of the prerequisites that were just spun up
+func (m *Runner) writePrerequisitesVersion() {
+	err := m.StateManager.WritePrerequisitesVersion(m.Technique.GetPrerequisitesVersion())
+	if err != nil {
+		log.Println("Warning: unable to persist the prerequisites version of " + m.Technique.ID + ": " + err.Error())
+	}
+}
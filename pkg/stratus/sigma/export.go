@@ -0,0 +1,36 @@
+package sigma
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// Export writes the Sigma rules of attack techniques to a directory, one file per rule, and returns the paths of the
+// written files. Files are named after the technique, e.g. aws.defense-evasion.cloudtrail-stop.yml, with a numeric
+// suffix for techniques shipping several rules. Techniques without Sigma rules are skipped
+func Export(techniques []*stratus.AttackTechnique, directory string) ([]string, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, errors.New("unable to create directory " + directory + ": " + err.Error())
+	}
+
+	var paths []string
+	for _, technique := range techniques {
+		documents := Documents(technique.SigmaRules)
+		for i, document := range documents {
+			fileName := technique.ID + ".yml"
+			if len(documents) > 1 {
+				fileName = technique.ID + "-" + strconv.Itoa(i+1) + ".yml"
+			}
+			path := filepath.Join(directory, fileName)
+			if err := os.WriteFile(path, document, 0644); err != nil {
+				return paths, errors.New("unable to write Sigma rule " + path + ": " + err.Error())
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
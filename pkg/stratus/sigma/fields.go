@@ -0,0 +1,91 @@
+package sigma
+
+import "strings"
+
+// fieldCatalog lists the fields of a log source. Names ending with ".*" match any nested field, e.g.
+// requestParameters.* matches requestParameters.bucketName
+type fieldCatalog []string
+
+func (m fieldCatalog) matches(field string) bool {
+	for _, known := range m {
+		if known == field {
+			return true
+		}
+		if prefix := strings.TrimSuffix(known, "*"); prefix != known && strings.HasPrefix(field, prefix) && len(field) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields of the log sources used by the rules of attack techniques, keyed by product/service or product/category
+var logSourceCatalogs = map[string]fieldCatalog{
+	// https://docs.aws.amazon.com/awscloudtrail/latest/userguide/cloudtrail-event-reference-record-contents.html
+	"aws/cloudtrail": {
+		"eventVersion", "eventTime", "eventSource", "eventName", "eventType", "eventCategory", "awsRegion",
+		"sourceIPAddress", "userAgent", "errorCode", "errorMessage", "readOnly", "recipientAccountId",
+		"managementEvent", "sharedEventID", "vpcEndpointId", "apiVersion",
+		"userIdentity.*", "requestParameters.*", "responseElements.*", "additionalEventData.*", "resources.*",
+		"serviceEventDetails.*", "tlsDetails.*",
+	},
+
+	// https://kubernetes.io/docs/reference/config-api/apiserver-audit.v1/#audit-k8s-io-v1-Event
+	"kubernetes/audit": {
+		"level", "auditID", "stage", "requestURI", "verb", "sourceIPs", "userAgent",
+		"user.username", "user.uid", "user.groups", "user.extra.*", "impersonatedUser.*",
+		"objectRef.resource", "objectRef.namespace", "objectRef.name", "objectRef.uid", "objectRef.apiGroup",
+		"objectRef.apiVersion", "objectRef.resourceVersion", "objectRef.subresource",
+		"responseStatus.code", "responseStatus.status", "responseStatus.reason", "responseStatus.message",
+		"requestObject.*", "responseObject.*", "annotations.*",
+	},
+
+	// https://learn.microsoft.com/en-us/azure/azure-monitor/essentials/activity-log-schema
+	"azure/activitylogs": {
+		"operationName", "category", "resultType", "resultSignature", "caller", "callerIpAddress", "correlationId",
+		"resourceId", "level", "status", "subStatus", "tenantId", "identity.*", "properties.*",
+	},
+
+	// https://learn.microsoft.com/en-us/azure/active-directory/reports-monitoring/reference-azure-monitor-audit-log-schema
+	"azure/auditlogs": {
+		"operationName", "OperationName", "Category", "category", "ActivityDisplayName", "ActivityType", "Result",
+		"ResultReason", "LoggedByService", "Status", "Target", "TargetResources.*", "InitiatedBy.*",
+		"AdditionalDetails.*", "properties.*",
+	},
+
+	// https://cloud.google.com/logging/docs/reference/audit/auditlog/rest/Shared.Types/AuditLog
+	"gcp/gcp.audit": {
+		"gcp.audit.method_name", "gcp.audit.service_name", "gcp.audit.resource_name",
+		"protoPayload.methodName", "protoPayload.serviceName", "protoPayload.resourceName",
+		"protoPayload.authenticationInfo.*", "protoPayload.authorizationInfo.*", "protoPayload.requestMetadata.*",
+		"protoPayload.request.*", "protoPayload.response.*", "protoPayload.status.*", "protoPayload.metadata.*",
+		"resource.type", "resource.labels.*", "severity", "logName",
+	},
+
+	// https://github.com/SigmaHQ/sigma-specification/blob/main/appendix/sigma-taxonomy-appendix.md
+	"linux/process_creation": {
+		"Image", "CommandLine", "CurrentDirectory", "User", "LogonId", "ProcessId", "ParentProcessId", "ParentImage",
+		"ParentCommandLine", "ParentUser", "OriginalFileName",
+	},
+	"linux/file_event": {
+		"TargetFilename", "Image", "User", "ProcessId",
+	},
+
+	// Linux audit records, see https://github.com/linux-audit/audit-documentation/wiki/SPEC-Audit-Event-Enrichment
+	"linux/auditd": {
+		"type", "key", "syscall", "success", "exit", "a0", "a1", "a2", "a3", "pid", "ppid", "uid", "auid", "euid",
+		"gid", "comm", "exe", "name", "nametype", "cwd", "proctitle", "SYSCALL", "inode", "mode", "ouid",
+	},
+}
+
+// logSourceFields returns the fields of the logs of a log source, and whether the log source is known
+func logSourceFields(logSource LogSource) (fieldCatalog, bool) {
+	var key string
+	switch {
+	case logSource.Service != "":
+		key = logSource.Product + "/" + logSource.Service
+	case logSource.Category != "":
+		key = logSource.Product + "/" + logSource.Category
+	}
+	fields, ok := logSourceCatalogs[key]
+	return fields, ok
+}
@@ -0,0 +1,383 @@
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matches returns true if a log record, e.g. a CloudTrail event decoded from JSON, matches the detection of the rule.
+// Supports the subset of Sigma used by the rules of attack techniques: field lists and maps, the contains, startswith,
+// endswith, all, re, cidr, exists and numeric comparison modifiers, wildcards and the condition operators. Time-based
+// aggregations are not supported
+func (m *Rule) Matches(record map[string]interface{}) (bool, error) {
+	matcher := &recordMatcher{rule: m, record: record, results: map[string]bool{}}
+	var selections []string
+	for name := range m.Detection {
+		if name != "condition" && name != "timeframe" {
+			selections = append(selections, name)
+		}
+	}
+	for _, name := range selections {
+		matched, err := matcher.matchSelection(m.Detection[name])
+		if err != nil {
+			return false, errors.New("selection " + name + ": " + err.Error())
+		}
+		matcher.results[name] = matched
+	}
+
+	var conditions []string
+	switch condition := m.Detection["condition"].(type) {
+	case string:
+		conditions = []string{condition}
+	case []interface{}:
+		for _, item := range condition {
+			conditions = append(conditions, fmt.Sprint(item))
+		}
+	}
+	if len(conditions) == 0 {
+		return false, errors.New("missing detection condition")
+	}
+	// A list of conditions matches if any of them matches
+	for _, condition := range conditions {
+		parser := &conditionParser{tokens: conditionTokens.FindAllString(condition, -1), selections: selections, results: matcher.results}
+		matched, err := parser.parseOr()
+		if err == nil && parser.position < len(parser.tokens) {
+			err = errors.New("unexpected " + parser.tokens[parser.position])
+		}
+		if err != nil {
+			return false, errors.New("invalid condition " + strconv.Quote(condition) + ": " + err.Error())
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type recordMatcher struct {
+	rule    *Rule
+	record  map[string]interface{}
+	results map[string]bool
+}
+
+// matchSelection matches a selection: a map of fields that must all match, a list of such maps of which one must
+// match, or a list of keywords of which one must be found in the record
+func (m *recordMatcher) matchSelection(selection interface{}) (bool, error) {
+	switch typedSelection := selection.(type) {
+	case map[string]interface{}:
+		for field, expected := range typedSelection {
+			matched, err := m.matchField(field, expected)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	case []interface{}:
+		for _, item := range typedSelection {
+			var matched bool
+			var err error
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				matched, err = m.matchSelection(itemMap)
+			} else {
+				matched = m.matchKeyword(fmt.Sprint(item))
+			}
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+	return false, errors.New("unsupported selection type")
+}
+
+func (m *recordMatcher) matchKeyword(keyword string) bool {
+	for _, value := range leafValues(m.record) {
+		if strings.Contains(strings.ToLower(value), strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchField matches a field, with its modifiers, against one or several expected values
+func (m *recordMatcher) matchField(field string, expected interface{}) (bool, error) {
+	name, modifiers := splitField(field)
+	values := resolveField(m.record, strings.Split(name, "."))
+
+	var expectedValues []interface{}
+	if list, ok := expected.([]interface{}); ok {
+		expectedValues = list
+	} else {
+		expectedValues = []interface{}{expected}
+	}
+
+	matchAll := contains(modifiers, "all")
+	for _, expectedValue := range expectedValues {
+		matched, err := matchValues(values, expectedValue, modifiers)
+		if err != nil {
+			return false, err
+		}
+		if matched && !matchAll {
+			return true, nil
+		}
+		if !matched && matchAll {
+			return false, nil
+		}
+	}
+	return matchAll && len(expectedValues) > 0, nil
+}
+
+// matchValues returns true if any of the values of a field matches an expected value
+func matchValues(values []interface{}, expected interface{}, modifiers []string) (bool, error) {
+	if contains(modifiers, "exists") {
+		shouldExist, _ := strconv.ParseBool(fmt.Sprint(expected))
+		return (len(values) > 0) == shouldExist, nil
+	}
+	if expected == nil {
+		return len(values) == 0, nil
+	}
+
+	expectedString := stringValue(expected)
+	var compare func(value string) (bool, error)
+	switch {
+	case contains(modifiers, "re"):
+		flags := ""
+		for _, flag := range []string{"i", "m", "s"} {
+			if contains(modifiers, flag) {
+				flags += flag
+			}
+		}
+		if flags != "" {
+			expectedString = "(?" + flags + ")" + expectedString
+		}
+		pattern, err := regexp.Compile(expectedString)
+		if err != nil {
+			return false, errors.New("invalid regular expression: " + err.Error())
+		}
+		compare = func(value string) (bool, error) { return pattern.MatchString(value), nil }
+	case contains(modifiers, "cidr"):
+		_, network, err := net.ParseCIDR(expectedString)
+		if err != nil {
+			return false, errors.New("invalid CIDR: " + err.Error())
+		}
+		compare = func(value string) (bool, error) {
+			ip := net.ParseIP(value)
+			return ip != nil && network.Contains(ip), nil
+		}
+	case contains(modifiers, "lt"), contains(modifiers, "lte"), contains(modifiers, "gt"), contains(modifiers, "gte"):
+		threshold, err := strconv.ParseFloat(expectedString, 64)
+		if err != nil {
+			return false, errors.New("invalid number " + expectedString)
+		}
+		compare = func(value string) (bool, error) {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, nil
+			}
+			return contains(modifiers, "lt") && number < threshold || contains(modifiers, "lte") && number <= threshold ||
+				contains(modifiers, "gt") && number > threshold || contains(modifiers, "gte") && number >= threshold, nil
+		}
+	default:
+		pattern := wildcardPattern(expectedString)
+		switch {
+		case contains(modifiers, "contains"):
+			pattern = ".*" + pattern + ".*"
+		case contains(modifiers, "startswith"):
+			pattern = pattern + ".*"
+		case contains(modifiers, "endswith"):
+			pattern = ".*" + pattern
+		}
+		for _, modifier := range modifiers {
+			if !contains([]string{"contains", "startswith", "endswith", "all"}, modifier) {
+				return false, errors.New("unsupported modifier " + modifier)
+			}
+		}
+		compiled := regexp.MustCompile("(?is)^" + pattern + "$")
+		compare = func(value string) (bool, error) { return compiled.MatchString(value), nil }
+	}
+
+	for _, value := range values {
+		matched, err := compare(stringValue(value))
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// wildcardPattern converts a Sigma value to a regular expression: * matches any string and ? any character, unless
+// escaped with a backslash
+func wildcardPattern(value string) string {
+	var pattern strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch character := value[i]; {
+		case character == '\\' && i+1 < len(value) && strings.ContainsRune(`*?\`, rune(value[i+1])):
+			pattern.WriteString(regexp.QuoteMeta(string(value[i+1])))
+			i++
+		case character == '*':
+			pattern.WriteString(".*")
+		case character == '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+	return pattern.String()
+}
+
+// resolveField returns the values of a dotted field of a record. Lists are traversed, so that a field matches if any of
+// their items matches, and keys containing dots, e.g. Kubernetes annotations, are supported
+func resolveField(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if value == nil {
+			return nil
+		}
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		var values []interface{}
+		for i := len(path); i > 0; i-- {
+			if child, ok := typedValue[strings.Join(path[:i], ".")]; ok {
+				values = append(values, resolveField(child, path[i:])...)
+			}
+		}
+		return values
+	case []interface{}:
+		var values []interface{}
+		for _, item := range typedValue {
+			values = append(values, resolveField(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// leafValues returns all the scalar values of a record
+func leafValues(value interface{}) []string {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		var values []string
+		for _, child := range typedValue {
+			values = append(values, leafValues(child)...)
+		}
+		return values
+	case []interface{}:
+		var values []string
+		for _, child := range typedValue {
+			values = append(values, leafValues(child)...)
+		}
+		return values
+	case nil:
+		return nil
+	}
+	return []string{stringValue(value)}
+}
+
+// stringValue formats a scalar value the way it appears in logs, e.g. 22 rather than 22.000000 for numbers decoded
+// from JSON
+func stringValue(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// conditionParser evaluates a condition, with the precedence not > and > or
+type conditionParser struct {
+	tokens     []string
+	position   int
+	selections []string
+	results    map[string]bool
+}
+
+func (m *conditionParser) peek() string {
+	if m.position < len(m.tokens) {
+		return strings.ToLower(m.tokens[m.position])
+	}
+	return ""
+}
+
+func (m *conditionParser) parseOr() (bool, error) {
+	result, err := m.parseAnd()
+	for err == nil && m.peek() == "or" {
+		m.position++
+		var right bool
+		right, err = m.parseAnd()
+		result = result || right
+	}
+	return result, err
+}
+
+func (m *conditionParser) parseAnd() (bool, error) {
+	result, err := m.parseNot()
+	for err == nil && m.peek() == "and" {
+		m.position++
+		var right bool
+		right, err = m.parseNot()
+		result = result && right
+	}
+	return result, err
+}
+
+func (m *conditionParser) parseNot() (bool, error) {
+	switch token := m.peek(); token {
+	case "not":
+		m.position++
+		result, err := m.parseNot()
+		return !result, err
+	case "(":
+		m.position++
+		result, err := m.parseOr()
+		if err != nil {
+			return false, err
+		}
+		if m.peek() != ")" {
+			return false, errors.New("missing closing parenthesis")
+		}
+		m.position++
+		return result, nil
+	case "1", "all":
+		m.position++
+		if m.peek() != "of" || m.position+1 >= len(m.tokens) {
+			return false, errors.New("expected '" + token + " of <selections>'")
+		}
+		pattern := m.tokens[m.position+1]
+		m.position += 2
+		return m.quantify(token == "all", pattern)
+	case "":
+		return false, errors.New("unexpected end of condition")
+	default:
+		m.position++
+		result, ok := m.results[m.tokens[m.position-1]]
+		if !ok {
+			return false, errors.New("undefined selection " + m.tokens[m.position-1])
+		}
+		return result, nil
+	}
+}
+
+// quantify evaluates "1 of" or "all of" the selections matching a pattern, e.g. selection_* or them
+func (m *conditionParser) quantify(all bool, pattern string) (bool, error) {
+	matchedSelections := 0
+	for _, selection := range m.selections {
+		if pattern != "them" && !matchesSelection(pattern, []string{selection}, true) {
+			continue
+		}
+		matchedSelections++
+		if m.results[selection] != all {
+			return !all, nil
+		}
+	}
+	if matchedSelections == 0 {
+		return false, errors.New("no selection matches " + pattern)
+	}
+	return all, nil
+}
@@ -0,0 +1,90 @@
+package sigma
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cloudTrailEvent = `{
+	"eventSource": "ec2.amazonaws.com",
+	"eventName": "AuthorizeSecurityGroupIngress",
+	"sourceIPAddress": "203.0.113.10",
+	"userIdentity": {"type": "AssumedRole", "arn": "arn:aws:sts::123456789012:assumed-role/my-role/i-0123456789abcdef0"},
+	"requestParameters": {
+		"groupId": "sg-0123",
+		"ipPermissions": {"items": [
+			{"fromPort": 443, "ipRanges": {"items": [{"cidrIp": "10.0.0.0/8"}]}},
+			{"fromPort": 22, "ipRanges": {"items": [{"cidrIp": "0.0.0.0/0"}]}}
+		]}
+	},
+	"responseElements": null,
+	"annotations": {"authorization.k8s.io/decision": "allow"}
+}`
+
+func parseRecord(t *testing.T, record string) map[string]interface{} {
+	var result map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(record), &result))
+	return result
+}
+
+func ruleWithDetection(t *testing.T, detection string) *Rule {
+	rules, err := Parse([]byte("title: test\ndetection:\n" + detection))
+	assert.Nil(t, err)
+	return rules[0]
+}
+
+func TestRuleMatches(t *testing.T) {
+	record := parseRecord(t, cloudTrailEvent)
+	testCases := []struct {
+		Name      string
+		Detection string
+		Matches   bool
+	}{
+		{"equality is case-insensitive", "  selection:\n    eventName: authorizesecuritygroupingress\n  condition: selection", true},
+		{"all fields must match", "  selection:\n    eventName: AuthorizeSecurityGroupIngress\n    eventSource: s3.amazonaws.com\n  condition: selection", false},
+		{"one value of a list must match", "  selection:\n    eventSource:\n      - s3.amazonaws.com\n      - ec2.amazonaws.com\n  condition: selection", true},
+		{"all modifier", "  selection:\n    eventName|contains|all:\n      - Authorize\n      - Revoke\n  condition: selection", false},
+		{"wildcards", "  selection:\n    eventName: Authorize*Ingress\n  condition: selection", true},
+		{"escaped wildcards", "  selection:\n    eventName: 'Authorize\\*'\n  condition: selection", false},
+		{"lists are traversed", "  selection:\n    requestParameters.ipPermissions.items.ipRanges.items.cidrIp: 0.0.0.0/0\n  condition: selection", true},
+		{"numbers", "  selection:\n    requestParameters.ipPermissions.items.fromPort: 22\n  condition: selection", true},
+		{"numeric comparison", "  selection:\n    requestParameters.ipPermissions.items.fromPort|gt: 1000\n  condition: selection", false},
+		{"regular expression", "  selection:\n    userIdentity.arn|re: ':assumed-role/.+/i-[0-9a-f]+$'\n  condition: selection", true},
+		{"cidr", "  selection:\n    sourceIPAddress|cidr: 203.0.113.0/24\n  condition: selection", true},
+		{"exists", "  selection:\n    errorCode|exists: false\n    requestParameters.groupId|exists: true\n  condition: selection", true},
+		{"null", "  selection:\n    responseElements: null\n  condition: selection", true},
+		{"keys containing dots", "  selection:\n    annotations.authorization.k8s.io/decision: allow\n  condition: selection", true},
+		{"keywords", "  keywords:\n    - sg-0123\n  condition: keywords", true},
+		{"list of maps", "  selection:\n    - eventName: RunInstances\n    - userIdentity.type|startswith: Assumed\n  condition: selection", true},
+		{"not", "  selection:\n    eventName: AuthorizeSecurityGroupIngress\n  filter:\n    userIdentity.type: AssumedRole\n  condition: selection and not filter", false},
+		{"precedence", "  a:\n    eventName: AuthorizeSecurityGroupIngress\n  b:\n    eventName: RunInstances\n  condition: b and a or a", true},
+		{"parentheses", "  a:\n    eventName: AuthorizeSecurityGroupIngress\n  b:\n    eventName: RunInstances\n  condition: b and (a or a)", false},
+		{"1 of", "  selection_ec2:\n    eventSource: ec2.amazonaws.com\n  selection_s3:\n    eventSource: s3.amazonaws.com\n  condition: 1 of selection_*", true},
+		{"all of", "  selection_ec2:\n    eventSource: ec2.amazonaws.com\n  selection_s3:\n    eventSource: s3.amazonaws.com\n  condition: all of selection_*", false},
+		{"all of them", "  selection_ec2:\n    eventSource: ec2.amazonaws.com\n  selection_ip:\n    sourceIPAddress|startswith: '203.'\n  condition: all of them", true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			matched, err := ruleWithDetection(t, testCase.Detection).Matches(record)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.Matches, matched)
+		})
+	}
+}
+
+func TestRuleMatchesReportsInvalidDetections(t *testing.T) {
+	record := parseRecord(t, cloudTrailEvent)
+	for _, detection := range []string{
+		"  selection:\n    eventName: RunInstances\n  condition: selection and",
+		"  selection:\n    eventName: RunInstances\n  condition: (selection",
+		"  selection:\n    eventName: RunInstances\n  condition: other",
+		"  selection:\n    eventName|base64: RunInstances\n  condition: selection",
+		"  selection:\n    eventName|re: '('\n  condition: selection",
+		"  selection:\n    eventName: RunInstances",
+	} {
+		_, err := ruleWithDetection(t, detection).Matches(record)
+		assert.NotNil(t, err, detection)
+	}
+}
@@ -0,0 +1,315 @@
+// Package sigma parses and validates the Sigma rules shipped with attack techniques, and exports them as rule files
+// that can be converted to the query language of a SIEM.
+// See https://github.com/SigmaHQ/sigma-specification
+package sigma
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"sigs.k8s.io/yaml"
+)
+
+// Rule is a Sigma rule. Only the attributes needed to validate rules are decoded
+type Rule struct {
+	Title       string                 `json:"title"`
+	ID          string                 `json:"id"`
+	Status      string                 `json:"status"`
+	Description string                 `json:"description"`
+	References  []string               `json:"references"`
+	Tags        []string               `json:"tags"`
+	LogSource   LogSource              `json:"logsource"`
+	Detection   map[string]interface{} `json:"detection"`
+	Level       string                 `json:"level"`
+
+	// Raw YAML document of the rule, as shipped with the technique
+	Raw []byte `json:"-"`
+}
+
+// LogSource describes the logs a rule applies to, e.g. AWS CloudTrail
+type LogSource struct {
+	Product  string `json:"product"`
+	Service  string `json:"service"`
+	Category string `json:"category"`
+}
+
+func (m LogSource) String() string {
+	var parts []string
+	for _, part := range []string{m.Product, m.Service, m.Category} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+var (
+	uuidFormat      = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	validStatuses   = []string{"stable", "test", "experimental", "deprecated", "unsupported"}
+	validLevels     = []string{"informational", "low", "medium", "high", "critical"}
+	validModifiers  = []string{"contains", "startswith", "endswith", "all", "re", "i", "m", "s", "cidr", "base64", "base64offset", "wide", "utf16le", "utf16be", "windash", "exists", "lt", "lte", "gt", "gte", "expand", "fieldref"}
+	conditionTokens = regexp.MustCompile(`\(|\)|[^\s()]+`)
+)
+
+// Sigma products of the logs of each platform
+var platformProducts = map[stratus.Platform]string{
+	stratus.AWS:        "aws",
+	stratus.Azure:      "azure",
+	stratus.EntraID:    "azure",
+	stratus.GCP:        "gcp",
+	stratus.Kubernetes: "kubernetes",
+	stratus.Linux:      "linux",
+}
+
+// Product returns the Sigma product of the logs of a platform, e.g. "aws", or an empty string if unknown
+func Product(platform stratus.Platform) string {
+	return platformProducts[platform]
+}
+
+// TacticTag returns the Sigma tag of a MITRE ATT&CK tactic, e.g. attack.defense_evasion
+func TacticTag(tactic mitreattack.Tactic) string {
+	return "attack." + strings.ReplaceAll(strings.ToLower(mitreattack.AttackTacticToString(tactic)), " ", "_")
+}
+
+// Documents splits a YAML stream into its documents, one per rule
+func Documents(rules []byte) [][]byte {
+	var documents [][]byte
+	var current bytes.Buffer
+	flush := func() {
+		if len(bytes.TrimSpace(current.Bytes())) > 0 {
+			documents = append(documents, append([]byte(nil), current.Bytes()...))
+		}
+		current.Reset()
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(rules))
+	for scanner.Scan() {
+		if strings.TrimRight(scanner.Text(), " ") == "---" {
+			flush()
+			continue
+		}
+		current.Write(scanner.Bytes())
+		current.WriteByte('\n')
+	}
+	flush()
+	return documents
+}
+
+// Parse parses a YAML stream of Sigma rules, one document per rule
+func Parse(rules []byte) ([]*Rule, error) {
+	var result []*Rule
+	for i, document := range Documents(rules) {
+		rule := &Rule{Raw: document}
+		if err := yaml.Unmarshal(document, rule); err != nil {
+			return nil, errors.New("unable to parse Sigma rule " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		result = append(result, rule)
+	}
+	return result, nil
+}
+
+// Validate returns the problems found in a rule: missing attributes, invalid condition, or fields that do not exist
+// in the logs of its log source
+func (m *Rule) Validate() []string {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if strings.TrimSpace(m.Title) == "" {
+		problemf("missing title")
+	}
+	if !uuidFormat.MatchString(m.ID) {
+		problemf("id %q is not a lowercase UUID", m.ID)
+	}
+	if m.Status != "" && !contains(validStatuses, m.Status) {
+		problemf("invalid status %q", m.Status)
+	}
+	if !contains(validLevels, m.Level) {
+		problemf("invalid level %q, should be one of %s", m.Level, strings.Join(validLevels, ", "))
+	}
+	for _, tag := range m.Tags {
+		if tag != strings.ToLower(tag) || strings.Contains(tag, " ") {
+			problemf("tag %q should be lowercase, without spaces", tag)
+		}
+	}
+
+	fields, knownLogSource := logSourceFields(m.LogSource)
+	if !knownLogSource {
+		problemf("unsupported log source %q", m.LogSource.String())
+	}
+
+	if len(m.Detection) == 0 {
+		problemf("missing detection")
+		return problems
+	}
+	var selections []string
+	for name := range m.Detection {
+		if name != "condition" && name != "timeframe" {
+			selections = append(selections, name)
+		}
+	}
+	sort.Strings(selections)
+	for _, selection := range selections {
+		for _, field := range selectionFields(m.Detection[selection]) {
+			name, modifiers := splitField(field)
+			for _, modifier := range modifiers {
+				if !contains(validModifiers, modifier) {
+					problemf("unknown modifier %q of field %s", modifier, name)
+				}
+			}
+			if knownLogSource && !fields.matches(name) {
+				problemf("field %s does not exist in %s logs", name, m.LogSource.String())
+			}
+		}
+		for _, field := range wildcardFields(m.Detection[selection]) {
+			name, _ := splitField(field)
+			problemf(`field %s matches any value with an unescaped '*', use '\*' to match a literal '*'`, name)
+		}
+	}
+	for _, problem := range validateCondition(m.Detection["condition"], selections) {
+		problemf("%s", problem)
+	}
+	return problems
+}
+
+// HasTag returns true if the rule has a given tag, e.g. attack.persistence
+func (m *Rule) HasTag(tag string) bool {
+	return contains(m.Tags, tag)
+}
+
+// selectionFields returns the fields (with their modifiers) of a selection, either a map of fields or a list of maps.
+// Keyword selections, lists of plain values, have no field
+func selectionFields(selection interface{}) []string {
+	var fields []string
+	switch typedSelection := selection.(type) {
+	case map[string]interface{}:
+		for field := range typedSelection {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+	case []interface{}:
+		for _, item := range typedSelection {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				fields = append(fields, selectionFields(itemMap)...)
+			}
+		}
+	}
+	return fields
+}
+
+// wildcardFields returns the fields of a selection having a lone unescaped wildcard as value, which matches any value
+func wildcardFields(selection interface{}) []string {
+	var fields []string
+	switch typedSelection := selection.(type) {
+	case map[string]interface{}:
+		for field, value := range typedSelection {
+			if isLoneWildcard(value) {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+	case []interface{}:
+		for _, item := range typedSelection {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				fields = append(fields, wildcardFields(itemMap)...)
+			}
+		}
+	}
+	return fields
+}
+
+// isLoneWildcard returns true if a value, or one of the values of a list, is a lone '*'
+func isLoneWildcard(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue == "*"
+	case []interface{}:
+		for _, item := range typedValue {
+			if isLoneWildcard(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitField splits a field of a selection into its name and its modifiers, e.g. eventName|startswith
+func splitField(field string) (string, []string) {
+	parts := strings.Split(field, "|")
+	return parts[0], parts[1:]
+}
+
+// validateCondition checks that a condition, or list of conditions, only references existing selections
+func validateCondition(rawCondition interface{}, selections []string) []string {
+	var conditions []string
+	switch condition := rawCondition.(type) {
+	case string:
+		conditions = []string{condition}
+	case []interface{}:
+		for _, item := range condition {
+			if itemString, ok := item.(string); ok {
+				conditions = append(conditions, itemString)
+			}
+		}
+	}
+	if len(conditions) == 0 {
+		return []string{"missing detection condition"}
+	}
+
+	var problems []string
+	for _, condition := range conditions {
+		depth, balanced := 0, true
+		tokens := conditionTokens.FindAllString(condition, -1)
+		for i, token := range tokens {
+			switch strings.ToLower(token) {
+			case "(":
+				depth++
+			case ")":
+				depth--
+				balanced = balanced && depth >= 0
+			case "and", "or", "not", "of", "1", "all", "them":
+			default:
+				quantified := i > 1 && strings.ToLower(tokens[i-1]) == "of"
+				if !matchesSelection(token, selections, quantified) {
+					problems = append(problems, "condition references undefined selection "+token)
+				}
+			}
+		}
+		if !balanced || depth != 0 {
+			problems = append(problems, "unbalanced parentheses in condition "+strconv.Quote(condition))
+		}
+	}
+	return problems
+}
+
+// matchesSelection returns true if a condition identifier refers to at least one selection. Only identifiers
+// quantified with "1 of" or "all of" can use wildcards
+func matchesSelection(identifier string, selections []string, quantified bool) bool {
+	if quantified && strings.HasSuffix(identifier, "*") {
+		prefix := strings.TrimSuffix(identifier, "*")
+		for _, selection := range selections {
+			if strings.HasPrefix(selection, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(selections, identifier)
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
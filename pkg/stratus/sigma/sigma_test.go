@@ -0,0 +1,169 @@
+package sigma
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+)
+
+const stopLoggingRule = `title: CloudTrail Logging Stopped
+id: 4f6ea1b5-2d4c-4e7a-9b8e-0f1a2b3c4d5e
+status: experimental
+description: Detects when a CloudTrail trail stops logging.
+tags:
+    - attack.defense_evasion
+logsource:
+    product: aws
+    service: cloudtrail
+detection:
+    selection:
+        eventSource: cloudtrail.amazonaws.com
+        eventName: StopLogging
+    filter_automation:
+        userIdentity.arn|endswith: ':role/automation'
+    condition: selection and not filter_automation
+level: high
+`
+
+const deleteTrailRule = `title: CloudTrail Trail Deleted
+id: 0b4c3f39-8f8e-4b1e-9d54-2f7c6a1e7d21
+logsource:
+    product: aws
+    service: cloudtrail
+detection:
+    selection:
+        - eventName: DeleteTrail
+        - eventName: StopLogging
+    condition: 1 of selection*
+level: high
+`
+
+func TestParseRules(t *testing.T) {
+	rules, err := Parse([]byte(stopLoggingRule + "---\n" + deleteTrailRule))
+	assert.Nil(t, err)
+	assert.Len(t, rules, 2)
+
+	assert.Equal(t, "CloudTrail Logging Stopped", rules[0].Title)
+	assert.Equal(t, LogSource{Product: "aws", Service: "cloudtrail"}, rules[0].LogSource)
+	assert.Equal(t, "aws/cloudtrail", rules[0].LogSource.String())
+	assert.True(t, rules[0].HasTag("attack.defense_evasion"))
+	assert.Equal(t, stopLoggingRule, string(rules[0].Raw))
+	assert.Equal(t, deleteTrailRule, string(rules[1].Raw))
+
+	for _, rule := range rules {
+		assert.Empty(t, rule.Validate())
+	}
+
+	_, err = Parse([]byte("title: [unbalanced"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unable to parse Sigma rule 1")
+}
+
+func TestDocuments(t *testing.T) {
+	assert.Empty(t, Documents(nil))
+	assert.Len(t, Documents([]byte("---\ntitle: a\n---\n\n---\ntitle: b\n")), 2)
+}
+
+func TestValidateRule(t *testing.T) {
+	rules, err := Parse([]byte(`title: ""
+id: 1234
+status: draft
+tags:
+    - attack.Persistence
+logsource:
+    product: aws
+    service: cloudtrail
+detection:
+    selection:
+        eventName|beginswith: Create
+        requestParameter.userName: admin
+    selection_wildcard:
+        - eventSource: '*'
+        - eventName:
+            - '\*'
+            - '*'
+    condition: selection and (not filter
+level: severe
+`))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		"missing title",
+		`id "1234" is not a lowercase UUID`,
+		`invalid status "draft"`,
+		`invalid level "severe", should be one of informational, low, medium, high, critical`,
+		`tag "attack.Persistence" should be lowercase, without spaces`,
+		`unknown modifier "beginswith" of field eventName`,
+		"field requestParameter.userName does not exist in aws/cloudtrail logs",
+		`field eventSource matches any value with an unescaped '*', use '\*' to match a literal '*'`,
+		`field eventName matches any value with an unescaped '*', use '\*' to match a literal '*'`,
+		"condition references undefined selection filter",
+		`unbalanced parentheses in condition "selection and (not filter"`,
+	}, rules[0].Validate())
+}
+
+func TestValidateRuleLogSource(t *testing.T) {
+	rule := &Rule{
+		Title:     "Privileged Pod",
+		ID:        "d6d1b8a4-5f3e-4a0a-8c1e-6b7a9c2d3e4f",
+		Level:     "high",
+		LogSource: LogSource{Product: "kubernetes", Service: "audit"},
+		Detection: map[string]interface{}{
+			"selection": map[string]interface{}{
+				"objectRef.resource": "pods",
+				"requestObject.spec.containers.securityContext.privileged": true,
+			},
+			"condition": "selection",
+		},
+	}
+	assert.Empty(t, rule.Validate())
+
+	rule.Detection["selection"].(map[string]interface{})["eventName"] = "CreatePod"
+	assert.Equal(t, []string{"field eventName does not exist in kubernetes/audit logs"}, rule.Validate())
+
+	rule.LogSource = LogSource{Product: "aws", Category: "process_creation"}
+	assert.Equal(t, []string{`unsupported log source "aws/process_creation"`}, rule.Validate())
+
+	rule.Detection = nil
+	assert.Contains(t, rule.Validate(), "missing detection")
+}
+
+func TestValidateCondition(t *testing.T) {
+	selections := []string{"selection_user", "selection_role", "filter"}
+	assert.Empty(t, validateCondition("all of selection_* and not filter", selections))
+	assert.Empty(t, validateCondition("1 of them", selections))
+	assert.Empty(t, validateCondition([]interface{}{"selection_user", "(selection_role or selection_user) and not filter"}, selections))
+
+	assert.Equal(t, []string{"condition references undefined selection selection_*"}, validateCondition("selection_*", selections))
+	assert.Equal(t, []string{"condition references undefined selection other*"}, validateCondition("1 of other*", selections))
+	assert.Equal(t, []string{"missing detection condition"}, validateCondition(nil, selections))
+}
+
+func TestTacticTag(t *testing.T) {
+	assert.Equal(t, "attack.defense_evasion", TacticTag(mitreattack.DefenseEvasion))
+	assert.Equal(t, "attack.credential_access", TacticTag(mitreattack.CredentialAccess))
+}
+
+func TestExport(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "rules")
+	techniques := []*stratus.AttackTechnique{
+		{ID: "aws.defense-evasion.cloudtrail-stop", SigmaRules: []byte(stopLoggingRule)},
+		{ID: "aws.defense-evasion.cloudtrail-delete", SigmaRules: []byte(stopLoggingRule + "---\n" + deleteTrailRule)},
+		{ID: "aws.discovery.no-rules"},
+	}
+
+	paths, err := Export(techniques, directory)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(directory, "aws.defense-evasion.cloudtrail-stop.yml"),
+		filepath.Join(directory, "aws.defense-evasion.cloudtrail-delete-1.yml"),
+		filepath.Join(directory, "aws.defense-evasion.cloudtrail-delete-2.yml"),
+	}, paths)
+
+	content, err := os.ReadFile(paths[2])
+	assert.Nil(t, err)
+	assert.Equal(t, deleteTrailRule, string(content))
+}
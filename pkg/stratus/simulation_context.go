@@ -0,0 +1,91 @@
+package stratus
+
+import (
+	"time"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/google/uuid"
+)
+
+// Default values of the simulation context, using documentation ranges so that simulated logs are easy to tell
+// apart from actual ones
+const (
+	DefaultSimulatedAWSAccountID       = "123456789012"
+	DefaultSimulatedAzureSubscription  = "00000000-0000-4000-8000-000000000000"
+	DefaultSimulatedKubernetesCluster  = "stratus-red-team-cluster"
+	DefaultSimulatedSourceIP           = "203.0.113.10"
+	DefaultSimulatedAzureUserPrincipal = "stratus-red-team@contoso.onmicrosoft.com"
+	DefaultSimulatedKubernetesUsername = "kubernetes-admin"
+)
+
+// LogRecord is a log record generated by the simulation of an attack technique, e.g. a CloudTrail event
+type LogRecord map[string]interface{}
+
+// SimulationContext is passed to the simulation functions of attack techniques. It describes the environment the
+// technique is simulated in, so that the generated log records look like the ones of an actual detonation
+type SimulationContext struct {
+	// Unique identifier of the simulated execution, injected in the user-agent of the log records
+	ExecutionID uuid.UUID
+
+	// User-agent of the simulated API calls
+	UserAgent string
+
+	// Account the technique is simulated in: AWS account ID, Azure subscription ID or Kubernetes cluster name
+	AccountID string
+
+	// Identity detonating the technique: ARN of an AWS IAM principal, Azure user principal name or Kubernetes username
+	Principal string
+
+	// IP address the simulated API calls are made from
+	SourceIP string
+
+	// Cloud region the technique is simulated in, if applicable
+	Region string
+
+	// Time of the first log record, and interval between two consecutive log records
+	StartTime time.Time
+	Interval  time.Duration
+
+	records int
+}
+
+// NewSimulationContext returns a simulation context with realistic default values for a platform, in an account
+// (defaults to a placeholder account if empty)
+func NewSimulationContext(platform Platform, accountID string) *SimulationContext {
+	executionID := uuid.New()
+	simulation := &SimulationContext{
+		ExecutionID: executionID,
+		UserAgent:   providers.StratusUserAgent + "_" + executionID.String(),
+		AccountID:   accountID,
+		SourceIP:    DefaultSimulatedSourceIP,
+		StartTime:   time.Now().UTC(),
+		Interval:    time.Second,
+	}
+	switch platform {
+	case AWS:
+		if simulation.AccountID == "" {
+			simulation.AccountID = DefaultSimulatedAWSAccountID
+		}
+		simulation.Principal = "arn:aws:iam::" + simulation.AccountID + ":user/stratus-red-team"
+		simulation.Region = "us-east-1"
+	case Azure:
+		if simulation.AccountID == "" {
+			simulation.AccountID = DefaultSimulatedAzureSubscription
+		}
+		simulation.Principal = DefaultSimulatedAzureUserPrincipal
+		simulation.Region = "westus"
+	case Kubernetes:
+		if simulation.AccountID == "" {
+			simulation.AccountID = DefaultSimulatedKubernetesCluster
+		}
+		simulation.Principal = DefaultSimulatedKubernetesUsername
+	}
+	return simulation
+}
+
+// NextTime returns the time of the next log record. Each call advances the clock of the simulation by its interval
+func (m *SimulationContext) NextTime() time.Time {
+	next := m.StartTime.Add(time.Duration(m.records) * m.Interval)
+	m.records++
+	return next
+}
@@ -0,0 +1,32 @@
+package stratus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSimulationContext(t *testing.T) {
+	simulation := NewSimulationContext(AWS, "")
+	assert.Equal(t, DefaultSimulatedAWSAccountID, simulation.AccountID)
+	assert.Equal(t, "arn:aws:iam::123456789012:user/stratus-red-team", simulation.Principal)
+	assert.Equal(t, "stratus-red-team_"+simulation.ExecutionID.String(), simulation.UserAgent)
+
+	simulation = NewSimulationContext(AWS, "210987654321")
+	assert.Equal(t, "arn:aws:iam::210987654321:user/stratus-red-team", simulation.Principal)
+
+	simulation = NewSimulationContext(Kubernetes, "")
+	assert.Equal(t, DefaultSimulatedKubernetesCluster, simulation.AccountID)
+	assert.Equal(t, DefaultSimulatedKubernetesUsername, simulation.Principal)
+}
+
+func TestSimulationContextNextTime(t *testing.T) {
+	simulation := NewSimulationContext(Azure, "")
+	simulation.StartTime = time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	simulation.Interval = 2 * time.Second
+
+	assert.Equal(t, simulation.StartTime, simulation.NextTime())
+	assert.Equal(t, simulation.StartTime.Add(2*time.Second), simulation.NextTime())
+	assert.Equal(t, simulation.StartTime.Add(4*time.Second), simulation.NextTime())
+}
@@ -0,0 +1,293 @@
+// Package stratustest runs the lifecycle of attack techniques against fakes, to test them without a real cloud
+// environment:
+//
+//   - AWS API calls are sent to an AWSServer recording them, or to a configurable endpoint such as a local emulator
+//   - Kubernetes API calls are sent to a client-go fake clientset
+//   - Terraform is not run, and the prerequisites outputs are configured by the test
+//   - The state of the technique is kept in memory
+//
+// For instance:
+//
+//	func TestMyTechnique(t *testing.T) {
+//		harness := stratustest.New(t, &myTechnique, stratustest.WithOutputs(map[string]string{"user_name": "foo"}))
+//		assert.Nil(t, harness.Detonate())
+//		harness.AssertAWSCalled("iam", "CreateAccessKey")
+//		harness.AssertRevertUndoesDetonation()
+//	}
+package stratustest
+
+import (
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/internal/providers"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/runner"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// Harness runs the lifecycle of an attack technique against fakes
+type Harness struct {
+	T         testing.TB
+	Technique *stratus.AttackTechnique
+
+	// Runner of the technique, using the fakes below
+	Runner *runner.Runner
+
+	// Execution context passed to the technique
+	Execution *stratus.ExecutionContext
+
+	State     *StateManager
+	Terraform *TerraformManager
+
+	// Stand-in receiving AWS API calls, unless WithAWSEndpoint is used
+	AWS *AWSServer
+
+	// Fake clientset receiving Kubernetes API calls
+	Kubernetes *fake.Clientset
+}
+
+// Option customizes a harness
+type Option func(*harnessOptions)
+
+type harnessOptions struct {
+	outputs           stratus.Outputs
+	awsEndpoint       string
+	kubernetesObjects []runtime.Object
+}
+
+// WithOutputs sets the Terraform outputs of the technique prerequisites, as if they had been created
+func WithOutputs(outputs map[string]string) Option {
+	return func(options *harnessOptions) {
+		options.outputs = stratus.StringOutputs(outputs)
+	}
+}
+
+// WithTypedOutputs sets Terraform outputs that are not strings, such as lists or numbers, e.g.
+// stratustest.WithTypedOutputs(stratus.Outputs{"ports": {Value: json.RawMessage(`[22, 3389]`)}})
+func WithTypedOutputs(outputs stratus.Outputs) Option {
+	return func(options *harnessOptions) {
+		if options.outputs == nil {
+			options.outputs = stratus.Outputs{}
+		}
+		for name, output := range outputs {
+			options.outputs[name] = output
+		}
+	}
+}
+
+// WithAWSEndpoint sends AWS API calls to an endpoint, such as a local AWS emulator, instead of a recording AWSServer
+func WithAWSEndpoint(endpoint string) Option {
+	return func(options *harnessOptions) {
+		options.awsEndpoint = endpoint
+	}
+}
+
+// WithKubernetesObjects adds existing objects to the fake Kubernetes cluster
+func WithKubernetesObjects(objects ...runtime.Object) Option {
+	return func(options *harnessOptions) {
+		options.kubernetesObjects = append(options.kubernetesObjects, objects...)
+	}
+}
+
+// New returns a harness for an attack technique. Its fakes are released at the end of the test
+func New(t testing.TB, technique *stratus.AttackTechnique, options ...Option) *Harness {
+	harnessOptions := &harnessOptions{}
+	for _, option := range options {
+		option(harnessOptions)
+	}
+
+	harness := &Harness{
+		T:          t,
+		Technique:  technique,
+		State:      NewStateManager(),
+		Terraform:  NewTerraformManager(harnessOptions.outputs),
+		Kubernetes: fake.NewSimpleClientset(harnessOptions.kubernetesObjects...),
+	}
+
+	execution := stratus.NewExecutionContext(nil)
+	execution.Logger = log.New(testWriter{t}, "", 0)
+	if harnessOptions.awsEndpoint != "" {
+		execution.AWS = providers.NewAWSProviderFromConfig(AWSConfigForEndpoint(harnessOptions.awsEndpoint))
+	} else {
+		harness.AWS = NewAWSServer()
+		t.Cleanup(harness.AWS.Close)
+		execution.AWS = providers.NewAWSProviderFromConfig(harness.AWS.Config())
+	}
+	execution.K8s = &providers.K8sProvider{}
+	execution.K8s.SetClient(harness.Kubernetes)
+	harness.Execution = execution
+
+	harness.Runner = &runner.Runner{
+		Technique:        technique,
+		TechniqueState:   stratus.AttackTechniqueStatusCold,
+		TerraformDir:     "/stratustest/" + technique.ID,
+		TerraformManager: harness.Terraform,
+		HostManager:      runner.NewHostPrerequisitesManager(),
+		StateManager:     harness.State,
+		ExecutionContext: execution,
+	}
+	return harness
+}
+
+// WarmUp creates the prerequisites of the technique
+func (m *Harness) WarmUp() (stratus.Outputs, error) {
+	return m.Runner.WarmUp()
+}
+
+// Detonate detonates the technique, warming it up first if needed
+func (m *Harness) Detonate() error {
+	return m.Runner.Detonate()
+}
+
+// Revert reverts the detonation of the technique
+func (m *Harness) Revert() error {
+	return m.Runner.Revert()
+}
+
+// CleanUp reverts the detonation of the technique if needed, and destroys its prerequisites
+func (m *Harness) CleanUp() error {
+	return m.Runner.CleanUp()
+}
+
+// AssertAWSCalled asserts that an AWS API call was made, e.g. AssertAWSCalled("iam", "CreateUser")
+func (m *Harness) AssertAWSCalled(service string, action string) bool {
+	m.T.Helper()
+	if m.AWS == nil {
+		m.T.Errorf("AWS API calls are not recorded when using WithAWSEndpoint")
+		return false
+	}
+	if !m.AWS.Called(service, action) {
+		m.T.Errorf("expected a call to %s:%s, got: %s", service, action, formatAWSCalls(m.AWS.Calls()))
+		return false
+	}
+	return true
+}
+
+// AssertAWSNotCalled asserts that an AWS API call was not made
+func (m *Harness) AssertAWSNotCalled(service string, action string) bool {
+	m.T.Helper()
+	if m.AWS != nil && m.AWS.Called(service, action) {
+		m.T.Errorf("unexpected call to %s:%s", service, action)
+		return false
+	}
+	return true
+}
+
+// AssertKubernetesCalled asserts that a Kubernetes API call was made, e.g. AssertKubernetesCalled("create", "clusterroles")
+func (m *Harness) AssertKubernetesCalled(verb string, resource string) bool {
+	m.T.Helper()
+	var calls []string
+	for _, action := range m.Kubernetes.Actions() {
+		if action.Matches(verb, resource) {
+			return true
+		}
+		calls = append(calls, action.GetVerb()+" "+action.GetResource().Resource)
+	}
+	m.T.Errorf("expected a Kubernetes call to %s %s, got: [%s]", verb, resource, strings.Join(calls, ", "))
+	return false
+}
+
+// AssertRevertUndoesDetonation detonates the technique, reverts it, and asserts that reverting undid the detonation:
+// Kubernetes objects created during the detonation were deleted, and the probe of the technique (if any) reports
+// the detonation before reverting it, and not after
+func (m *Harness) AssertRevertUndoesDetonation() bool {
+	m.T.Helper()
+	if m.Technique.Revert == nil {
+		m.T.Errorf("technique %s cannot be reverted", m.Technique.ID)
+		return false
+	}
+
+	firstAction := len(m.Kubernetes.Actions())
+	if err := m.Detonate(); err != nil {
+		m.T.Errorf("unable to detonate %s: %s", m.Technique.ID, err)
+		return false
+	}
+	createdObjects := m.kubernetesObjectsCreatedSince(firstAction)
+	if !m.assertProbe(true) {
+		return false
+	}
+
+	if err := m.Revert(); err != nil {
+		m.T.Errorf("unable to revert %s: %s", m.Technique.ID, err)
+		return false
+	}
+	if !m.assertProbe(false) {
+		return false
+	}
+
+	success := true
+	for _, object := range createdObjects {
+		_, err := m.Kubernetes.Tracker().Get(object.resource, object.namespace, object.name)
+		if !apierrors.IsNotFound(err) {
+			m.T.Errorf("%s %s was created during the detonation and still exists after reverting it", object.resource.Resource, object.name)
+			success = false
+		}
+	}
+	return success
+}
+
+func (m *Harness) assertProbe(expectDetonated bool) bool {
+	m.T.Helper()
+	if m.Technique.IsDetonated == nil {
+		return true
+	}
+	outputs, _ := m.State.GetTerraformOutputs()
+	detonated, err := m.Technique.IsDetonated(m.Execution.WithOutputs(outputs))
+	if err != nil {
+		m.T.Errorf("unable to probe detonation of %s: %s", m.Technique.ID, err)
+		return false
+	}
+	if detonated != expectDetonated {
+		m.T.Errorf("expected the probe of %s to report detonated=%t, got %t", m.Technique.ID, expectDetonated, detonated)
+		return false
+	}
+	return true
+}
+
+type kubernetesObject struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func (m *Harness) kubernetesObjectsCreatedSince(firstAction int) []kubernetesObject {
+	var objects []kubernetesObject
+	actions := m.Kubernetes.Actions()
+	for _, action := range actions[firstAction:] {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || action.GetSubresource() != "" {
+			continue
+		}
+		accessor, err := meta.Accessor(create.GetObject())
+		if err != nil {
+			continue
+		}
+		objects = append(objects, kubernetesObject{resource: action.GetResource(), namespace: action.GetNamespace(), name: accessor.GetName()})
+	}
+	return objects
+}
+
+func formatAWSCalls(calls []AWSCall) string {
+	var formatted []string
+	for _, call := range calls {
+		formatted = append(formatted, call.Service+":"+call.Action)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
+
+// testWriter writes the logs of the technique to the test logs
+type testWriter struct {
+	t testing.TB
+}
+
+func (m testWriter) Write(data []byte) (int, error) {
+	m.t.Log(strings.TrimSuffix(string(data), "\n"))
+	return len(data), nil
+}
@@ -0,0 +1,134 @@
+package stratustest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	_ "github.com/datadog/stratus-red-team/internal/attacktechniques/k8s/credential-access/dump-secrets"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stratus", Namespace: "default"}}
+
+func kubernetesTechnique(revert func(*stratus.ExecutionContext) error) *stratus.AttackTechnique {
+	return &stratus.AttackTechnique{
+		ID:                 "k8s.test.configmap",
+		Platform:           stratus.Kubernetes,
+		MitreAttackTactics: []mitreattack.Tactic{mitreattack.Persistence},
+		Detonate: func(execution *stratus.ExecutionContext) error {
+			execution.Logger.Println("Creating ConfigMap")
+			_, err := execution.K8s.GetClient().CoreV1().ConfigMaps("default").Create(context.Background(), configMap, metav1.CreateOptions{})
+			return err
+		},
+		Revert: revert,
+	}
+}
+
+func TestKubernetesTechnique(t *testing.T) {
+	technique := kubernetesTechnique(func(execution *stratus.ExecutionContext) error {
+		return execution.K8s.GetClient().CoreV1().ConfigMaps("default").Delete(context.Background(), configMap.Name, metav1.DeleteOptions{})
+	})
+	harness := New(t, technique)
+
+	assert.True(t, harness.AssertRevertUndoesDetonation())
+	harness.AssertKubernetesCalled("create", "configmaps")
+	harness.AssertKubernetesCalled("delete", "configmaps")
+	assert.Equal(t, stratus.AttackTechniqueState(stratus.AttackTechniqueStatusWarm), harness.State.GetTechniqueState())
+}
+
+func TestRevertNotUndoingDetonationIsReported(t *testing.T) {
+	technique := kubernetesTechnique(func(*stratus.ExecutionContext) error { return nil })
+	recorder := &recordingT{TB: t}
+
+	assert.False(t, New(recorder, technique).AssertRevertUndoesDetonation())
+	assert.Equal(t, []string{"configmaps stratus was created during the detonation and still exists after reverting it"}, recorder.errors)
+}
+
+func TestAWSTechnique(t *testing.T) {
+	technique := &stratus.AttackTechnique{
+		ID:                         "aws.test.iam-user",
+		Platform:                   stratus.AWS,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.Persistence},
+		PrerequisitesTerraformCode: []byte(`output "user_name" { value = "my-user" }`),
+		Detonate: func(execution *stratus.ExecutionContext) error {
+			iamClient := iam.NewFromConfig(execution.AWS.GetConnection())
+			result, err := iamClient.CreateAccessKey(context.Background(), &iam.CreateAccessKeyInput{UserName: aws.String(execution.Parameters["user_name"])})
+			if err != nil {
+				return err
+			}
+			execution.Logger.Println("Created access key " + *result.AccessKey.AccessKeyId)
+			return nil
+		},
+	}
+	harness := New(t, technique, WithOutputs(map[string]string{"user_name": "my-user"}))
+	harness.AWS.Respond("iam", "CreateAccessKey", 200, `<CreateAccessKeyResponse><CreateAccessKeyResult><AccessKey>
+		<UserName>my-user</UserName><AccessKeyId>AKIAEXAMPLE</AccessKeyId><Status>Active</Status><SecretAccessKey>secret</SecretAccessKey>
+	</AccessKey></CreateAccessKeyResult></CreateAccessKeyResponse>`)
+
+	assert.Nil(t, harness.Detonate())
+	assert.True(t, harness.Terraform.Applied())
+	harness.AssertAWSCalled("iam", "CreateAccessKey")
+	harness.AssertAWSNotCalled("iam", "DeleteAccessKey")
+	assert.Contains(t, harness.AWS.Calls()[0].Body, "UserName=my-user")
+
+	assert.Nil(t, harness.CleanUp())
+	assert.True(t, harness.Terraform.Destroyed())
+}
+
+func TestAWSErrors(t *testing.T) {
+	technique := &stratus.AttackTechnique{
+		ID:       "aws.test.error",
+		Platform: stratus.AWS,
+		Detonate: func(execution *stratus.ExecutionContext) error {
+			_, err := iam.NewFromConfig(execution.AWS.GetConnection()).DeleteUser(context.Background(), &iam.DeleteUserInput{UserName: aws.String("foo")})
+			return err
+		},
+	}
+	harness := New(t, technique)
+	harness.AWS.Respond("iam", "DeleteUser", 404, `<ErrorResponse><Error><Code>NoSuchEntity</Code><Message>not found</Message></Error></ErrorResponse>`)
+
+	err := harness.Detonate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "NoSuchEntity")
+}
+
+func TestBuiltInTechnique(t *testing.T) {
+	technique := stratus.GetRegistry().GetAttackTechniqueByName("k8s.credential-access.dump-secrets")
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "database-password", Namespace: "default"}}
+	harness := New(t, technique, WithKubernetesObjects(secret))
+
+	assert.Nil(t, harness.Detonate())
+	harness.AssertKubernetesCalled("list", "secrets")
+}
+
+func TestFailedAssertionsAreReported(t *testing.T) {
+	recorder := &recordingT{TB: t}
+	harness := New(recorder, &stratus.AttackTechnique{ID: "foo", Detonate: func(*stratus.ExecutionContext) error {
+		return errors.New("boom")
+	}})
+
+	assert.False(t, harness.AssertAWSCalled("iam", "CreateUser"))
+	assert.False(t, harness.AssertKubernetesCalled("create", "pods"))
+	assert.False(t, harness.AssertRevertUndoesDetonation())
+	assert.Len(t, recorder.errors, 3)
+}
+
+// recordingT records the errors of assertions, to test failing assertions
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (m *recordingT) Helper() {}
+
+func (m *recordingT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
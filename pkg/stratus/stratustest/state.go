@@ -0,0 +1,104 @@
+package stratustest
+
+import (
+	"sync"
+
+	"github.com/datadog/stratus-red-team/internal/apitrace"
+	"github.com/datadog/stratus-red-team/internal/state"
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+)
+
+// StateManager is an in-memory state.StateManager
+type StateManager struct {
+	lock           sync.Mutex
+	outputs        stratus.Outputs
+	techniqueState stratus.AttackTechniqueState
+	version        *stratus.PrerequisitesVersion
+	traces         []apitrace.Trace
+	extracted      bool
+}
+
+var _ state.StateManager = &StateManager{}
+
+// NewStateManager returns an in-memory state manager, for a technique in COLD state
+func NewStateManager() *StateManager {
+	return &StateManager{techniqueState: stratus.AttackTechniqueStatusCold}
+}
+
+func (m *StateManager) Initialize() {}
+
+func (m *StateManager) GetRootDirectory() string {
+	return "/stratustest"
+}
+
+func (m *StateManager) ExtractTechnique() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.extracted = true
+	return nil
+}
+
+func (m *StateManager) CleanupTechnique() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.extracted = false
+	m.outputs = nil
+	m.version = nil
+	return nil
+}
+
+func (m *StateManager) GetTerraformOutputs() (stratus.Outputs, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	outputs := stratus.Outputs{}
+	for key, value := range m.outputs {
+		outputs[key] = value
+	}
+	return outputs, nil
+}
+
+func (m *StateManager) WriteTerraformOutputs(outputs stratus.Outputs) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.outputs = outputs
+	return nil
+}
+
+func (m *StateManager) GetTechniqueState() stratus.AttackTechniqueState {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.techniqueState
+}
+
+func (m *StateManager) SetTechniqueState(techniqueState stratus.AttackTechniqueState) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.techniqueState = techniqueState
+	return nil
+}
+
+func (m *StateManager) GetPrerequisitesVersion() (*stratus.PrerequisitesVersion, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.version, nil
+}
+
+func (m *StateManager) WritePrerequisitesVersion(version stratus.PrerequisitesVersion) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.version = &version
+	return nil
+}
+
+func (m *StateManager) GetAPICallTraces() ([]apitrace.Trace, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]apitrace.Trace{}, m.traces...), nil
+}
+
+func (m *StateManager) WriteAPICallTraces(traces []apitrace.Trace) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.traces = traces
+	return nil
+}
@@ -0,0 +1,68 @@
+package stratustest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/runner"
+)
+
+// TerraformManager is a runner.TerraformManager that does not run Terraform. Applying returns the configured
+// outputs, as if the prerequisites had been created
+type TerraformManager struct {
+	// Outputs returned when applying the prerequisites
+	Outputs stratus.Outputs
+
+	lock      sync.Mutex
+	applied   bool
+	destroyed bool
+}
+
+var _ runner.TerraformManager = &TerraformManager{}
+
+// NewTerraformManager returns a Terraform manager returning outputs when applying the prerequisites
+func NewTerraformManager(outputs stratus.Outputs) *TerraformManager {
+	return &TerraformManager{Outputs: outputs}
+}
+
+func (m *TerraformManager) Initialize() {}
+
+func (m *TerraformManager) TerraformInitAndApply(context.Context, string) (stratus.Outputs, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.applied, m.destroyed = true, false
+	outputs := stratus.Outputs{}
+	for key, value := range m.Outputs {
+		outputs[key] = value
+	}
+	return outputs, nil
+}
+
+func (m *TerraformManager) TerraformDestroy(context.Context, string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.applied, m.destroyed = false, true
+	return nil
+}
+
+// TerraformPlan reports drift once the prerequisites have been destroyed, or were never applied
+func (m *TerraformManager) TerraformPlan(context.Context, string) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return !m.applied, nil
+}
+
+// Applied returns true if the prerequisites are currently applied
+func (m *TerraformManager) Applied() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.applied
+}
+
+// Destroyed returns true if the prerequisites have been destroyed
+func (m *TerraformManager) Destroyed() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.destroyed
+}
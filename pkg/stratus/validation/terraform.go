@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"errors"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// Schema of the Terraform blocks relevant to validation, other blocks are ignored
+var terraformSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "output", LabelNames: []string{"name"}}},
+}
+
+// TerraformOutputs parses Terraform code and returns the names of the outputs it declares
+func TerraformOutputs(code []byte) ([]string, error) {
+	file, diagnostics := hclparse.NewParser().ParseHCL(code, "main.tf")
+	if diagnostics.HasErrors() {
+		return nil, errors.New(diagnostics.Error())
+	}
+	content, _, diagnostics := file.Body.PartialContent(terraformSchema)
+	if diagnostics.HasErrors() {
+		return nil, errors.New(diagnostics.Error())
+	}
+
+	var outputs []string
+	for _, block := range content.Blocks {
+		outputs = append(outputs, block.Labels[0])
+	}
+	return outputs, nil
+}
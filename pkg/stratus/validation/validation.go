@@ -0,0 +1,225 @@
+// Package validation statically checks that attack techniques are well-formed, without detonating them
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/datadog/stratus-red-team/pkg/stratus/sigma"
+)
+
+type Severity string
+
+const (
+	// SeverityError is used for problems preventing the technique from working as expected
+	SeverityError Severity = "error"
+
+	// SeverityWarning is used for incomplete metadata, or techniques that are inconvenient to use
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in an attack technique
+type Issue struct {
+	TechniqueID string
+	Severity    Severity
+	Message     string
+}
+
+func (m Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", m.TechniqueID, m.Severity, m.Message)
+}
+
+// Technique IDs have the format <platform>.<tactic>.<name>, e.g. aws.defense-evasion.cloudtrail-stop
+var techniqueIdFormat = regexp.MustCompile(`^([a-z0-9]+(?:-[a-z0-9]+)*)\.([a-z]+(?:-[a-z]+)*)\.([a-z0-9]+(?:-[a-z0-9]+)*)$`)
+
+// Platforms using a shorter prefix in technique IDs
+var platformIdPrefixes = map[stratus.Platform]string{
+	stratus.Kubernetes: "k8s",
+}
+
+// ValidateRegistry validates all the attack techniques of a registry, and returns the issues found in registration order
+func ValidateRegistry(registry *stratus.Registry) []Issue {
+	var issues []Issue
+	seen := map[string]bool{}
+	for _, technique := range registry.ListAttackTechniques() {
+		if seen[technique.ID] {
+			issues = append(issues, Issue{technique.ID, SeverityError, "technique ID is registered multiple times"})
+		}
+		seen[technique.ID] = true
+		issues = append(issues, ValidateTechnique(technique)...)
+	}
+	return issues
+}
+
+// ValidateTechnique validates the metadata of an attack technique, checks the outputs it requires against its
+// prerequisites, and validates its Sigma rules
+func ValidateTechnique(technique *stratus.AttackTechnique) []Issue {
+	v := &validator{technique: technique}
+	v.validateMetadata()
+	v.validateFunctions()
+	v.validatePrerequisites()
+	v.validateSigmaRules()
+	return v.issues
+}
+
+type validator struct {
+	technique *stratus.AttackTechnique
+	issues    []Issue
+}
+
+func (m *validator) errorf(format string, args ...interface{}) {
+	m.issues = append(m.issues, Issue{m.technique.ID, SeverityError, fmt.Sprintf(format, args...)})
+}
+
+func (m *validator) warnf(format string, args ...interface{}) {
+	m.issues = append(m.issues, Issue{m.technique.ID, SeverityWarning, fmt.Sprintf(format, args...)})
+}
+
+func (m *validator) validateMetadata() {
+	technique := m.technique
+
+	if _, err := stratus.GetPlatformProvider(technique.Platform); err != nil {
+		m.errorf("%s", err)
+	}
+	if len(technique.MitreAttackTactics) == 0 {
+		m.errorf("no MITRE ATT&CK tactic")
+	}
+	m.validateId()
+
+	if strings.TrimSpace(technique.FriendlyName) == "" {
+		m.warnf("missing friendly name")
+	}
+	if strings.TrimSpace(technique.Description) == "" {
+		m.errorf("missing description")
+	}
+	if strings.TrimSpace(technique.Detection) == "" {
+		m.warnf("missing detection guidance")
+	}
+}
+
+// validateId checks that the technique ID has the format <platform>.<tactic>.<name>, where the platform and the
+// tactic are the ones of the technique
+func (m *validator) validateId() {
+	technique := m.technique
+	match := techniqueIdFormat.FindStringSubmatch(technique.ID)
+	if match == nil {
+		m.errorf("technique ID does not match the format <platform>.<tactic>.<name>, e.g. aws.defense-evasion.cloudtrail-stop")
+		return
+	}
+
+	expectedPrefix := strings.ToLower(string(technique.Platform))
+	if prefix, ok := platformIdPrefixes[technique.Platform]; ok {
+		expectedPrefix = prefix
+	}
+	if match[1] != expectedPrefix {
+		m.errorf("technique ID should start with %s, the platform of the technique", expectedPrefix)
+	}
+
+	var tacticNames []string
+	for _, tactic := range technique.MitreAttackTactics {
+		tacticName := tacticIdName(tactic)
+		if tacticName == match[2] {
+			return
+		}
+		tacticNames = append(tacticNames, tacticName)
+	}
+	if len(tacticNames) > 0 {
+		m.errorf("tactic %s of the technique ID is not one of the tactics of the technique (%s)", match[2], strings.Join(tacticNames, ", "))
+	}
+}
+
+func (m *validator) validateFunctions() {
+	technique := m.technique
+	if technique.Detonate == nil {
+		m.errorf("missing detonation function")
+	}
+	// Without a revert function, a technique that is not idempotent can only be detonated again after its
+	// prerequisites are cleaned up and warmed up again
+	if !technique.IsIdempotent && technique.Revert == nil {
+		if technique.HasPrerequisites() {
+			m.warnf("technique is not idempotent and has no revert function, it can only be detonated again after a cleanup")
+		} else {
+			m.errorf("technique is not idempotent and has no revert function or prerequisites, it cannot be detonated again")
+		}
+	}
+}
+
+// validatePrerequisites checks that all the outputs required by the technique are declared by its prerequisites
+func (m *validator) validatePrerequisites() {
+	technique := m.technique
+	if technique.PrerequisitesTerraformCode != nil && technique.PrerequisitesHost != nil {
+		m.errorf("technique cannot have both Terraform and host prerequisites")
+		return
+	}
+
+	declared := map[string]bool{}
+	switch {
+	case technique.PrerequisitesTerraformCode != nil:
+		outputs, err := TerraformOutputs(technique.PrerequisitesTerraformCode)
+		if err != nil {
+			m.errorf("invalid Terraform code: %s", err)
+			return
+		}
+		for _, output := range outputs {
+			declared[output] = true
+		}
+	case technique.PrerequisitesHost != nil:
+		for _, file := range technique.PrerequisitesHost.Files {
+			declared[file.Name] = true
+		}
+		for _, process := range technique.PrerequisitesHost.Processes {
+			declared[process.Name] = true
+		}
+	}
+
+	for _, output := range technique.RequiredOutputs {
+		if !technique.HasPrerequisites() {
+			m.errorf("output %s is required, but the technique has no prerequisites", output)
+		} else if !declared[output] {
+			m.errorf("output %s is required, but not declared by the prerequisites", output)
+		}
+	}
+}
+
+// validateSigmaRules checks that the Sigma rules of the technique parse, only reference fields of the logs of its
+// platform, and are tagged with its tactics
+func (m *validator) validateSigmaRules() {
+	technique := m.technique
+	if len(technique.SigmaRules) == 0 {
+		return
+	}
+	rules, err := sigma.Parse(technique.SigmaRules)
+	if err != nil {
+		m.errorf("%s", err)
+		return
+	}
+	if len(rules) == 0 {
+		m.errorf("Sigma rules are set, but contain no rule")
+	}
+
+	product := sigma.Product(technique.Platform)
+	for i, rule := range rules {
+		name := fmt.Sprintf("Sigma rule %d (%s)", i+1, rule.Title)
+		for _, problem := range rule.Validate() {
+			m.errorf("%s: %s", name, problem)
+		}
+		if product != "" && rule.LogSource.Product != product {
+			m.errorf("%s: log source product %q should be %q, the product of %s logs", name, rule.LogSource.Product, product, technique.Platform)
+		}
+		tagged := len(technique.MitreAttackTactics) == 0
+		for _, tactic := range technique.MitreAttackTactics {
+			tagged = tagged || rule.HasTag(sigma.TacticTag(tactic))
+		}
+		if !tagged {
+			m.warnf("%s: not tagged with any tactic of the technique, e.g. %s", name, sigma.TacticTag(technique.MitreAttackTactics[0]))
+		}
+	}
+}
+
+// tacticIdName returns the name of a tactic as used in technique IDs, e.g. defense-evasion
+func tacticIdName(tactic mitreattack.Tactic) string {
+	return strings.ReplaceAll(strings.ToLower(mitreattack.AttackTacticToString(tactic)), " ", "-")
+}
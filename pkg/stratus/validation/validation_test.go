@@ -0,0 +1,209 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/datadog/stratus-red-team/pkg/stratus"
+	"github.com/datadog/stratus-red-team/pkg/stratus/mitreattack"
+	"github.com/stretchr/testify/assert"
+)
+
+const terraformCode = `
+resource "aws_cloudtrail" "trail" {
+  name = "my-trail"
+}
+
+output "cloudtrail_trail_name" {
+  value = aws_cloudtrail.trail.name
+}
+
+output "display" {
+  value = format("CloudTrail trail %s ready", aws_cloudtrail.trail.arn)
+}
+`
+
+func noop(*stratus.ExecutionContext) error { return nil }
+
+func validTechnique() *stratus.AttackTechnique {
+	return &stratus.AttackTechnique{
+		ID:                         "aws.defense-evasion.cloudtrail-stop",
+		FriendlyName:               "Stop CloudTrail Trail",
+		Description:                "Stops a CloudTrail trail",
+		Detection:                  "Through CloudTrail's StopLogging event",
+		Platform:                   stratus.AWS,
+		MitreAttackTactics:         []mitreattack.Tactic{mitreattack.DefenseEvasion},
+		PrerequisitesTerraformCode: []byte(terraformCode),
+		RequiredOutputs:            []string{"cloudtrail_trail_name"},
+		Detonate:                   noop,
+		Revert:                     noop,
+	}
+}
+
+func messages(issues []Issue) []string {
+	var result []string
+	for _, issue := range issues {
+		result = append(result, string(issue.Severity)+": "+issue.Message)
+	}
+	return result
+}
+
+func TestValidTechniqueHasNoIssue(t *testing.T) {
+	assert.Empty(t, ValidateTechnique(validTechnique()))
+
+	technique := validTechnique()
+	technique.ID = "k8s.persistence.create-admin-clusterrole"
+	technique.Platform = stratus.Kubernetes
+	technique.MitreAttackTactics = []mitreattack.Tactic{mitreattack.PrivilegeEscalation, mitreattack.Persistence}
+	technique.PrerequisitesTerraformCode = nil
+	technique.RequiredOutputs = nil
+	assert.Empty(t, ValidateTechnique(technique))
+}
+
+func TestValidatesTechniqueId(t *testing.T) {
+	scenarios := []struct {
+		ID    string
+		Issue string
+	}{
+		{"cloudtrail-stop", "error: technique ID does not match the format <platform>.<tactic>.<name>, e.g. aws.defense-evasion.cloudtrail-stop"},
+		{"aws.defense-evasion.CloudTrail_Stop", "error: technique ID does not match the format <platform>.<tactic>.<name>, e.g. aws.defense-evasion.cloudtrail-stop"},
+		{"gcp.defense-evasion.cloudtrail-stop", "error: technique ID should start with aws, the platform of the technique"},
+		{"aws.persistence.cloudtrail-stop", "error: tactic persistence of the technique ID is not one of the tactics of the technique (defense-evasion)"},
+	}
+	for _, scenario := range scenarios {
+		technique := validTechnique()
+		technique.ID = scenario.ID
+		assert.Equal(t, []string{scenario.Issue}, messages(ValidateTechnique(technique)), scenario.ID)
+	}
+}
+
+func TestValidatesMetadata(t *testing.T) {
+	technique := validTechnique()
+	technique.FriendlyName = ""
+	technique.Description = " "
+	technique.Detection = ""
+	technique.Platform = "unknown"
+	technique.ID = "unknown.defense-evasion.cloudtrail-stop"
+
+	assert.Equal(t, []string{
+		"error: unhandled platform unknown",
+		"warning: missing friendly name",
+		"error: missing description",
+		"warning: missing detection guidance",
+	}, messages(ValidateTechnique(technique)))
+}
+
+func TestValidatesRevertOfNonIdempotentTechniques(t *testing.T) {
+	technique := validTechnique()
+	technique.Revert = nil
+	assert.Equal(t, []string{
+		"warning: technique is not idempotent and has no revert function, it can only be detonated again after a cleanup",
+	}, messages(ValidateTechnique(technique)))
+
+	technique.PrerequisitesTerraformCode = nil
+	technique.RequiredOutputs = nil
+	assert.Equal(t, []string{
+		"error: technique is not idempotent and has no revert function or prerequisites, it cannot be detonated again",
+	}, messages(ValidateTechnique(technique)))
+
+	technique.IsIdempotent = true
+	assert.Empty(t, ValidateTechnique(technique))
+}
+
+func TestValidatesRequiredOutputs(t *testing.T) {
+	technique := validTechnique()
+	technique.RequiredOutputs = []string{"cloudtrail_trail_name", "trail_arn"}
+	assert.Equal(t, []string{
+		"error: output trail_arn is required, but not declared by the prerequisites",
+	}, messages(ValidateTechnique(technique)))
+
+	technique.PrerequisitesTerraformCode = nil
+	technique.IsIdempotent = true
+	assert.Equal(t, []string{
+		"error: output cloudtrail_trail_name is required, but the technique has no prerequisites",
+		"error: output trail_arn is required, but the technique has no prerequisites",
+	}, messages(ValidateTechnique(technique)))
+
+	technique.PrerequisitesHost = &stratus.HostPrerequisites{
+		Files:     []stratus.HostFile{{Name: "cloudtrail_trail_name"}},
+		Processes: []stratus.HostProcess{{Name: "trail_arn"}},
+	}
+	assert.Empty(t, ValidateTechnique(technique))
+}
+
+func TestValidatesTerraformCode(t *testing.T) {
+	technique := validTechnique()
+	technique.PrerequisitesTerraformCode = []byte(`output "cloudtrail_trail_name" {`)
+
+	issues := ValidateTechnique(technique)
+	assert.Len(t, issues, 1)
+	assert.Equal(t, SeverityError, issues[0].Severity)
+	assert.Contains(t, issues[0].Message, "invalid Terraform code")
+}
+
+const sigmaRule = `title: CloudTrail Logging Stopped
+id: 4f6ea1b5-2d4c-4e7a-9b8e-0f1a2b3c4d5e
+tags:
+    - attack.defense_evasion
+logsource:
+    product: aws
+    service: cloudtrail
+detection:
+    selection:
+        eventName: StopLogging
+    condition: selection
+level: high
+`
+
+func TestValidatesSigmaRules(t *testing.T) {
+	technique := validTechnique()
+	technique.SigmaRules = []byte(sigmaRule)
+	assert.Empty(t, ValidateTechnique(technique))
+
+	technique.SigmaRules = []byte(sigmaRule + "---\n" + strings.NewReplacer(
+		"attack.defense_evasion", "attack.persistence",
+		"product: aws", "product: azure",
+		"service: cloudtrail", "service: activitylogs",
+		"condition: selection", "condition: selection and not filter",
+	).Replace(sigmaRule))
+	assert.Equal(t, []string{
+		"error: Sigma rule 2 (CloudTrail Logging Stopped): field eventName does not exist in azure/activitylogs logs",
+		"error: Sigma rule 2 (CloudTrail Logging Stopped): condition references undefined selection filter",
+		`error: Sigma rule 2 (CloudTrail Logging Stopped): log source product "azure" should be "aws", the product of AWS logs`,
+		"warning: Sigma rule 2 (CloudTrail Logging Stopped): not tagged with any tactic of the technique, e.g. attack.defense_evasion",
+	}, messages(ValidateTechnique(technique)))
+
+	technique.SigmaRules = []byte("detection: [")
+	issues := ValidateTechnique(technique)
+	assert.Len(t, issues, 1)
+	assert.Contains(t, issues[0].Message, "unable to parse Sigma rule 1")
+}
+
+func TestValidatesRegistry(t *testing.T) {
+	registry := stratus.NewRegistry()
+	registry.RegisterAttackTechnique(validTechnique())
+	registry.RegisterAttackTechnique(validTechnique())
+	invalidTechnique := validTechnique()
+	invalidTechnique.ID = "aws.defense-evasion.other"
+	invalidTechnique.Detection = ""
+	registry.RegisterAttackTechnique(invalidTechnique)
+
+	issues := ValidateRegistry(&registry)
+	assert.Equal(t, []Issue{
+		{"aws.defense-evasion.cloudtrail-stop", SeverityError, "technique ID is registered multiple times"},
+		{"aws.defense-evasion.other", SeverityWarning, "missing detection guidance"},
+	}, issues)
+}
+
+func TestTerraformOutputs(t *testing.T) {
+	outputs, err := TerraformOutputs([]byte(terraformCode))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cloudtrail_trail_name", "display"}, outputs)
+
+	outputs, err = TerraformOutputs([]byte(`resource "aws_s3_bucket" "bucket" {}`))
+	assert.Nil(t, err)
+	assert.Empty(t, outputs)
+
+	_, err = TerraformOutputs([]byte(`output {`))
+	assert.NotNil(t, err)
+}
@@ -21,9 +21,21 @@ Platform: {{FormatPlatformName .Platform}}
 
 ```bash title="Detonate with Stratus Red Team"
 stratus detonate {{.ID}}
-```{{ if .Detection }}
+```{{ if .Simulate }}
+
+```bash title="Simulate the logs of the technique, without calling any API"
+stratus simulate {{.ID}}
+```{{ end }}{{ if .Detection }}
 ## Detection
 
 {{ .Detection }}
 
+{{ end }}{{ if .SigmaRules }}
+## Sigma Rules
+
+Sigma rules detecting this technique are available with <code>stratus show {{.ID}} --sigma</code>, or exported with <code>stratus export sigma</code>.
+
+```yaml
+{{ printf "%s" .SigmaRules }}```
+
 {{ end }}
\ No newline at end of file
@@ -110,14 +110,9 @@ func formatTechniqueDescription(technique *stratus.AttackTechnique) {
 }
 
 func FormatPlatformName(platform stratus.Platform) string {
-	switch platform {
-	case stratus.AWS:
-		return "AWS"
-	case stratus.Azure:
-		return "Azure"
-	case stratus.Kubernetes:
-		return "Kubernetes"
+	provider, err := stratus.GetPlatformProvider(platform)
+	if err != nil {
+		log.Fatal(err)
 	}
-	log.Fatal("unknown platform " + platform)
-	return ""
+	return provider.DisplayName()
 }